@@ -3,7 +3,10 @@ package dbus
 import (
 	"context"
 	"errors"
+	"fmt"
 	"maps"
+	"slices"
+	"sync"
 
 	"github.com/creachadair/mds/mapset"
 	"github.com/danderson/dbus/fragments"
@@ -11,6 +14,7 @@ import (
 
 const (
 	ifaceBus        = "org.freedesktop.DBus"
+	ifaceBusStats   = "org.freedesktop.DBus.Debug.Stats"
 	ifacePeer       = "org.freedesktop.DBus.Peer"
 	ifaceIntrospect = "org.freedesktop.DBus.Introspectable"
 	ifaceObjects    = "org.freedesktop.DBus.ObjectManager"
@@ -58,22 +62,377 @@ func (c *Conn) BusID(ctx context.Context) (string, error) {
 
 // Features returns a list of strings describing the optional features
 // that the bus supports.
+//
+// The bus's features cannot change during the lifetime of a
+// connection, so the Conn fetches them from the bus once, and
+// answers subsequent calls from a cache. Errors are not cached.
 func (c *Conn) Features(ctx context.Context) ([]string, error) {
-	var features []string
-	if err := c.bus.Interface(ifaceBus).GetProperty(ctx, "Features", &features); err != nil {
+	features, err := c.cachedFeatures(ctx)
+	if err != nil {
 		return nil, err
 	}
+	return slices.Clone(features), nil
+}
+
+// HasFeature reports whether the bus supports the named optional
+// feature, as listed by [Conn.Features].
+func (c *Conn) HasFeature(ctx context.Context, name string) (bool, error) {
+	features, err := c.cachedFeatures(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(features, name), nil
+}
+
+// cachedFeatures returns the bus's features, fetching them if they
+// aren't cached yet. The returned slice must not be modified.
+func (c *Conn) cachedFeatures(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	features := c.features
+	c.mu.Unlock()
+	if features == nil {
+		if err := c.bus.Interface(ifaceBus).GetProperty(ctx, "Features", &features); err != nil {
+			return nil, err
+		}
+		if features == nil {
+			features = []string{}
+		}
+		c.mu.Lock()
+		c.features = features
+		c.mu.Unlock()
+	}
 	return features, nil
 }
 
+// UpdateActivationEnvironment adds env to the environment of
+// services that the bus activates in the future. Existing values of
+// the same variables are replaced.
+//
+// UpdateActivationEnvironment is only available on session buses,
+// and only to connections belonging to the user that owns the
+// bus. On a system bus, it returns an error matching
+// [ErrAccessDenied].
+func (c *Conn) UpdateActivationEnvironment(ctx context.Context, env map[string]string) error {
+	err := c.bus.Interface(ifaceBus).Call(ctx, "UpdateActivationEnvironment", env, nil)
+	if errors.Is(err, ErrAccessDenied) {
+		return fmt.Errorf("activation environment can only be updated on the session bus, by its owner: %w", err)
+	}
+	return err
+}
+
+// DebugStats returns the bus's internal statistics, such as the
+// number of connections, match rules and messages it is tracking.
+//
+// The set of statistics is specific to the bus implementation, and
+// is only available if the bus was built with statistics support. If
+// it wasn't, DebugStats returns an error matching [ErrUnknownInterface]
+// or [ErrUnknownMethod].
+func (c *Conn) DebugStats(ctx context.Context) (map[string]any, error) {
+	var ret map[string]any
+	if err := c.bus.Interface(ifaceBusStats).Call(ctx, "GetStats", nil, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DebugConnectionStats returns the bus's internal statistics about
+// peer's connection, such as its pending replies and the number of
+// match rules it has added.
+//
+// As with [Conn.DebugStats], the statistics are specific to the bus
+// implementation and only available if the bus was built with
+// statistics support.
+func (c *Conn) DebugConnectionStats(ctx context.Context, peer Peer) (map[string]any, error) {
+	var ret map[string]any
+	if err := c.bus.Interface(ifaceBusStats).Call(ctx, "GetConnectionStats", peer.Name(), &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// RequestNameFlags are flags that modify the behavior of
+// [Conn.RequestName].
+type RequestNameFlags uint32
+
+const (
+	// RequestNameAllowReplacement allows another client to take over
+	// ownership of the name, if it requests ownership with
+	// RequestNameReplaceExisting.
+	RequestNameAllowReplacement RequestNameFlags = 1 << iota
+	// RequestNameReplaceExisting attempts to replace the current
+	// owner of the name, if the current owner allowed replacement.
+	RequestNameReplaceExisting
+	// RequestNameDoNotQueue prevents the request from joining the
+	// queue of backup owners, if ownership cannot be obtained
+	// immediately.
+	RequestNameDoNotQueue
+)
+
+// RequestNameReply is the outcome of a [Conn.RequestName] call.
+type RequestNameReply uint32
+
+const (
+	// RequestNamePrimaryOwner indicates that the caller is now the
+	// owner of the name.
+	RequestNamePrimaryOwner RequestNameReply = 1
+	// RequestNameInQueue indicates that the name already has an
+	// owner, and the caller has joined the queue of backup owners.
+	RequestNameInQueue RequestNameReply = 2
+	// RequestNameExists indicates that the name already has an
+	// owner, and the caller did not join the queue of backup owners.
+	RequestNameExists RequestNameReply = 3
+	// RequestNameAlreadyOwner indicates that the caller was already
+	// the owner of the name.
+	RequestNameAlreadyOwner RequestNameReply = 4
+)
+
+func (r RequestNameReply) String() string {
+	switch r {
+	case RequestNamePrimaryOwner:
+		return "PrimaryOwner"
+	case RequestNameInQueue:
+		return "InQueue"
+	case RequestNameExists:
+		return "Exists"
+	case RequestNameAlreadyOwner:
+		return "AlreadyOwner"
+	default:
+		return fmt.Sprintf("RequestNameReply(%d)", uint32(r))
+	}
+}
+
+// ReleaseNameReply is the outcome of a [Conn.ReleaseName] call.
+type ReleaseNameReply uint32
+
+const (
+	// ReleaseNameReleased indicates that the caller released its
+	// ownership of, or place in the queue for, the name.
+	ReleaseNameReleased ReleaseNameReply = 1
+	// ReleaseNameNonExistent indicates that the name has no owner.
+	ReleaseNameNonExistent ReleaseNameReply = 2
+	// ReleaseNameNotOwner indicates that the caller was neither the
+	// owner of the name nor queued for ownership.
+	ReleaseNameNotOwner ReleaseNameReply = 3
+)
+
+func (r ReleaseNameReply) String() string {
+	switch r {
+	case ReleaseNameReleased:
+		return "Released"
+	case ReleaseNameNonExistent:
+		return "NonExistent"
+	case ReleaseNameNotOwner:
+		return "NotOwner"
+	default:
+		return fmt.Sprintf("ReleaseNameReply(%d)", uint32(r))
+	}
+}
+
+// RequestName asks the bus to assign ownership of name to the Conn.
+//
+// RequestName is a low-level wrapper around the bus's
+// [org.freedesktop.DBus.RequestName] method. Most programs should
+// use [Conn.Claim] instead, which tracks changes in ownership over
+// time.
+//
+// [org.freedesktop.DBus.RequestName]: https://dbus.freedesktop.org/doc/dbus-specification.html#bus-messages-request-name
+func (c *Conn) RequestName(ctx context.Context, name string, flags RequestNameFlags) (RequestNameReply, error) {
+	var req struct {
+		Name  string
+		Flags RequestNameFlags
+	}
+	req.Name, req.Flags = name, flags
+	var resp uint32
+	if err := c.bus.Interface(ifaceBus).Call(ctx, "RequestName", req, &resp); err != nil {
+		return 0, err
+	}
+	return RequestNameReply(resp), nil
+}
+
+// ReleaseName asks the bus to release the Conn's ownership of name,
+// or remove the Conn from the name's queue of backup owners.
+//
+// ReleaseName is a low-level wrapper around the bus's
+// [org.freedesktop.DBus.ReleaseName] method. Most programs should
+// use [Conn.Claim] and [Claim.Close] instead.
+//
+// [org.freedesktop.DBus.ReleaseName]: https://dbus.freedesktop.org/doc/dbus-specification.html#bus-messages-release-name
+func (c *Conn) ReleaseName(ctx context.Context, name string) (ReleaseNameReply, error) {
+	var resp uint32
+	if err := c.bus.Interface(ifaceBus).Call(ctx, "ReleaseName", name, &resp); err != nil {
+		return 0, err
+	}
+	return ReleaseNameReply(resp), nil
+}
+
+// matchState is the state of a match rule used by one or more
+// Watchers.
+type matchState struct {
+	refs int
+	// added is closed once the bus has replied to the rule's
+	// AddMatch call, after which err is the call's result.
+	added chan struct{}
+	err   error
+}
+
+// addMatch adds m's match rule to the bus, if it's not already
+// present. Rules are reference counted, such that the rule is only
+// removed from the bus once every addMatch has been paired with a
+// removeMatch.
+//
+// The reference count is updated under matchMu, but the bus RPC is
+// made after releasing it. Waiting for the bus's reply with matchMu
+// held would wedge every other Watcher operation if the reply never
+// arrives. Concurrent addMatch calls for a rule whose AddMatch is
+// still in flight wait for its result, which is bounded by the ctx
+// of the call that sent it.
 func (c *Conn) addMatch(ctx context.Context, m *Match) error {
 	rule := m.filterString()
-	return c.bus.Interface(ifaceBus).Call(ctx, "AddMatch", rule, nil)
+
+	st, first := func() (*matchState, bool) {
+		c.matchMu.Lock()
+		defer c.matchMu.Unlock()
+		if c.matchRefs == nil {
+			c.matchRefs = map[string]*matchState{}
+		}
+		st := c.matchRefs[rule]
+		first := st == nil
+		if first {
+			st = &matchState{added: make(chan struct{})}
+			c.matchRefs[rule] = st
+		}
+		st.refs++
+		return st, first
+	}()
+	if !first {
+		<-st.added
+		return st.err
+	}
+
+	var err error
+	// Peer-to-peer Conns receive everything the peer sends, there
+	// is no bus to filter signals.
+	if !c.peerToPeer {
+		err = c.bus.Interface(ifaceBus).Call(ctx, "AddMatch", rule, nil)
+	}
+	if err != nil {
+		// Forget the rule entirely, including the references of
+		// callers waiting on this AddMatch, who all fail with err.
+		c.matchMu.Lock()
+		delete(c.matchRefs, rule)
+		c.matchMu.Unlock()
+	}
+	st.err = err
+	close(st.added)
+	return err
 }
 
+// removeMatch releases one reference to m's match rule, and removes
+// the rule from the bus if it is no longer used.
 func (c *Conn) removeMatch(ctx context.Context, m *Match) error {
 	rule := m.filterString()
-	return c.bus.Interface(ifaceBus).Call(ctx, "RemoveMatch", rule, nil)
+	if !c.releaseMatch(rule) || c.peerToPeer {
+		return nil
+	}
+	return c.bus.Interface(ifaceBus).Call(withContextCleanup(ctx), "RemoveMatch", rule, nil)
+}
+
+// releaseMatch releases one reference to rule, and reports whether
+// that was the last reference.
+func (c *Conn) releaseMatch(rule string) bool {
+	c.matchMu.Lock()
+	defer c.matchMu.Unlock()
+	st := c.matchRefs[rule]
+	if st == nil {
+		return false
+	}
+	st.refs--
+	if st.refs > 0 {
+		return false
+	}
+	delete(c.matchRefs, rule)
+	return true
+}
+
+// WatchOwner watches the bus for changes in ownership of the given
+// bus name, and delivers the name's current owner on the returned
+// channel whenever it changes. A nil Peer indicates that the name has
+// no owner.
+//
+// The first value delivered on the channel is the owner at the time
+// of the WatchOwner call.
+//
+// The returned cancel function stops the watch and closes the
+// channel. The watch also stops if ctx is canceled.
+func (c *Conn) WatchOwner(ctx context.Context, name string) (owners <-chan *Peer, cancel func(), err error) {
+	w, err := c.Watch()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := w.Match(MatchNotification[NameOwnerChanged]().ArgStr(0, name)); err != nil {
+		w.Close()
+		return nil, nil, err
+	}
+
+	// Query the current owner after the match is in place, so that
+	// no ownership changes can be missed.
+	var initial *Peer
+	owner, err := c.Peer(name).Owner(ctx)
+	if err == nil {
+		initial = &owner
+	} else if !errors.Is(err, ErrNameHasNoOwner) {
+		w.Close()
+		return nil, nil, err
+	}
+
+	ret := make(chan *Peer)
+	stop := make(chan struct{})
+	go func() {
+		defer close(ret)
+		first, last := true, ""
+		send := func(p *Peer) bool {
+			cur := ""
+			if p != nil {
+				cur = p.Name()
+			}
+			if !first && cur == last {
+				return true
+			}
+			first, last = false, cur
+			select {
+			case ret <- p:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		if !send(initial) {
+			return
+		}
+		for n := range w.Chan() {
+			v, ok := n.Body.(*NameOwnerChanged)
+			if !ok || v.Name != name {
+				continue
+			}
+			if !send(v.New) {
+				return
+			}
+		}
+	}()
+
+	shutdown := sync.OnceFunc(func() {
+		close(stop)
+		w.Close()
+	})
+	// Unregister from ctx on cancel, so that long-lived contexts
+	// don't accumulate callbacks for finished watches.
+	stopAfter := context.AfterFunc(ctx, shutdown)
+	cancel = func() {
+		stopAfter()
+		shutdown()
+	}
+	return ret, cancel, nil
 }
 
 // NameOwnerChanged signals that a name has changed owners.
@@ -0,0 +1,199 @@
+package dbus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/danderson/dbus/fragments"
+)
+
+// SwapByteOrder converts body, the wire encoding of values matching
+// sig, from one byte order to another.
+//
+// SwapByteOrder is intended for proxies that relay message bodies
+// between connections without decoding them, for example with
+// [Interface.CallRaw]. It walks body according to sig and reverses
+// the bytes of every multi-byte value in place, so the returned
+// slice shares its storage with body. If from and to are the same
+// byte order, body is returned unmodified.
+//
+// SwapByteOrder returns an error if body is not a well-formed
+// encoding of sig. In that case, body may have been partially
+// converted.
+func SwapByteOrder(sig Signature, body []byte, from, to fragments.ByteOrder) ([]byte, error) {
+	if sameByteOrder(from, to) {
+		return body, nil
+	}
+	s := &swapper{buf: body, from: from}
+	if err := s.values(sig.String(), 0); err != nil {
+		return nil, err
+	}
+	if s.off != len(body) {
+		return nil, fmt.Errorf("body has %d bytes of trailing data after values of signature %q", len(body)-s.off, sig)
+	}
+	return body, nil
+}
+
+// sameByteOrder reports whether a and b are the same byte order.
+func sameByteOrder(a, b fragments.ByteOrder) bool {
+	probe := []byte{1, 0}
+	return a.Uint16(probe) == b.Uint16(probe)
+}
+
+// swapper reverses the byte order of DBus values in buf.
+type swapper struct {
+	buf  []byte
+	off  int
+	from fragments.ByteOrder
+}
+
+// values swaps one value of each complete type in sig. depth is the
+// number of containers that the values are nested within.
+func (s *swapper) values(sig string, depth int) error {
+	for sig != "" {
+		n, err := fragments.NextType(sig)
+		if err != nil {
+			return err
+		}
+		if err := s.value(sig[:n], depth); err != nil {
+			return err
+		}
+		sig = sig[n:]
+	}
+	return nil
+}
+
+// value swaps a value of the single complete type sig.
+func (s *swapper) value(sig string, depth int) error {
+	if depth > fragments.MaxDepth {
+		return fmt.Errorf("containers nested more than %d deep", fragments.MaxDepth)
+	}
+	switch sig[0] {
+	case 'y':
+		_, err := s.take(1)
+		return err
+	case 'n', 'q', 'b', 'i', 'u', 'h', 'x', 't', 'd':
+		_, err := s.swap(fragments.TypeAlignment(sig[0]))
+		return err
+	case 's', 'o':
+		ln, err := s.uint32()
+		if err != nil {
+			return err
+		}
+		_, err = s.take(int(ln) + 1)
+		return err
+	case 'g':
+		_, err := s.signature()
+		return err
+	case 'v':
+		inner, err := s.signature()
+		if err != nil {
+			return err
+		}
+		if n, err := fragments.NextType(inner); err != nil {
+			return fmt.Errorf("invalid variant signature %q: %w", inner, err)
+		} else if n != len(inner) {
+			return fmt.Errorf("variant signature %q is not a single complete type", inner)
+		}
+		return s.value(inner, depth+1)
+	case 'a':
+		ln, err := s.uint32()
+		if err != nil {
+			return err
+		}
+		if ln > fragments.MaxArrayLength {
+			return fmt.Errorf("array length %d exceeds maximum of %d bytes", ln, fragments.MaxArrayLength)
+		}
+		if err := s.pad(fragments.TypeAlignment(sig[1])); err != nil {
+			return err
+		}
+		end := s.off + int(ln)
+		if end > len(s.buf) {
+			return io.ErrUnexpectedEOF
+		}
+		for s.off < end {
+			before := s.off
+			if err := s.value(sig[1:], depth+1); err != nil {
+				return err
+			}
+			if s.off == before {
+				return errors.New("array element consumed no input")
+			}
+		}
+		if s.off != end {
+			return errors.New("array elements overrun array length")
+		}
+		return nil
+	case '(', '{':
+		if err := s.pad(8); err != nil {
+			return err
+		}
+		return s.values(sig[1:len(sig)-1], depth+1)
+	default:
+		return fmt.Errorf("unknown type specifier %q", sig[0])
+	}
+}
+
+// take consumes and returns the next n bytes of the buffer.
+func (s *swapper) take(n int) ([]byte, error) {
+	if n < 0 || n > len(s.buf)-s.off {
+		return nil, io.ErrUnexpectedEOF
+	}
+	ret := s.buf[s.off : s.off+n]
+	s.off += n
+	return ret, nil
+}
+
+// pad consumes padding up to the next multiple of align.
+func (s *swapper) pad(align int) error {
+	if extra := s.off % align; extra != 0 {
+		_, err := s.take(align - extra)
+		return err
+	}
+	return nil
+}
+
+// swap aligns to and consumes an n-byte value, reversing its bytes.
+// It returns the value as decoded in the source byte order.
+func (s *swapper) swap(n int) (uint64, error) {
+	if err := s.pad(n); err != nil {
+		return 0, err
+	}
+	bs, err := s.take(n)
+	if err != nil {
+		return 0, err
+	}
+	var ret uint64
+	switch n {
+	case 2:
+		ret = uint64(s.from.Uint16(bs))
+	case 4:
+		ret = uint64(s.from.Uint32(bs))
+	case 8:
+		ret = s.from.Uint64(bs)
+	}
+	for i, j := 0, len(bs)-1; i < j; i, j = i+1, j-1 {
+		bs[i], bs[j] = bs[j], bs[i]
+	}
+	return ret, nil
+}
+
+// uint32 swaps a uint32 and returns its value.
+func (s *swapper) uint32() (uint32, error) {
+	v, err := s.swap(4)
+	return uint32(v), err
+}
+
+// signature consumes a signature value and returns it.
+func (s *swapper) signature() (string, error) {
+	ln, err := s.take(1)
+	if err != nil {
+		return "", err
+	}
+	bs, err := s.take(int(ln[0]) + 1)
+	if err != nil {
+		return "", err
+	}
+	return string(bs[:len(bs)-1]), nil
+}
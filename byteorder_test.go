@@ -0,0 +1,101 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/danderson/dbus/fragments"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSwapByteOrder(t *testing.T) {
+	type value struct {
+		A byte
+		B int16
+		C string
+		D []uint64
+		E map[string]any
+		F ObjectPath
+		G Signature
+		H float64
+		I []Simple
+	}
+	in := value{
+		A: 1,
+		B: -2,
+		C: "foo",
+		D: []uint64{3, 4},
+		E: map[string]any{
+			"x": uint32(5),
+			"y": []int16{6, 7},
+		},
+		F: "/bar",
+		G: mustParseSignature("a{sv}"),
+		H: 8.5,
+		I: []Simple{{9, true}, {10, false}},
+	}
+	sig, err := SignatureOf(in)
+	if err != nil {
+		t.Fatalf("SignatureOf() failed: %v", err)
+	}
+
+	enc := fragments.Encoder{
+		Order:  fragments.BigEndian,
+		Mapper: encoderFor,
+	}
+	if err := enc.Value(context.Background(), in); err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	orig := bytes.Clone(enc.Out)
+
+	if got, err := SwapByteOrder(sig, enc.Out, fragments.BigEndian, fragments.BigEndian); err != nil {
+		t.Fatalf("SwapByteOrder() to same order failed: %v", err)
+	} else if !bytes.Equal(got, orig) {
+		t.Fatal("SwapByteOrder() to same order modified body")
+	}
+
+	swapped, err := SwapByteOrder(sig, enc.Out, fragments.BigEndian, fragments.LittleEndian)
+	if err != nil {
+		t.Fatalf("SwapByteOrder() failed: %v", err)
+	}
+	dec := fragments.Decoder{
+		Order:  fragments.LittleEndian,
+		Mapper: decoderFor,
+		In:     bytes.NewReader(swapped),
+	}
+	var got value
+	if err := dec.Value(context.Background(), &got); err != nil {
+		t.Fatalf("decoding swapped body failed: %v", err)
+	}
+	sigt := cmp.Transformer("sig", func(s Signature) string {
+		return s.String()
+	})
+	if diff := cmp.Diff(got, in, sigt); diff != "" {
+		t.Errorf("swapped body decoded wrong (-got+want):\n%s", diff)
+	}
+
+	back, err := SwapByteOrder(sig, swapped, fragments.LittleEndian, fragments.BigEndian)
+	if err != nil {
+		t.Fatalf("SwapByteOrder() back failed: %v", err)
+	}
+	if !bytes.Equal(back, orig) {
+		t.Errorf("swapping back did not restore original body:\n  got: % x\n want: % x", back, orig)
+	}
+
+	bad := []struct {
+		name string
+		sig  Signature
+		body []byte
+	}{
+		{"truncated", sig, orig[:len(orig)-1]},
+		{"trailing data", sig, append(bytes.Clone(orig), 0)},
+		{"wrong signature", mustParseSignature("as"), bytes.Clone(orig)},
+		{"empty signature", Signature{}, []byte{1}},
+	}
+	for _, tc := range bad {
+		if _, err := SwapByteOrder(tc.sig, tc.body, fragments.BigEndian, fragments.LittleEndian); err == nil {
+			t.Errorf("SwapByteOrder() with %s succeeded, want error", tc.name)
+		}
+	}
+}
@@ -70,7 +70,17 @@ type Claim struct {
 // Claiming a name does not guarantee ownership of the name. Callers
 // must monitor [Claim.Chan] to find out if and when the name gets
 // assigned to them.
+//
+// Claim returns an error if name is not a valid well-known bus name.
 func (c *Conn) Claim(name string, opts ClaimOptions) (*Claim, error) {
+	p := c.Peer(name)
+	if err := p.Valid(); err != nil {
+		return nil, err
+	}
+	if p.IsUniqueName() {
+		return nil, fmt.Errorf("cannot claim unique bus name %q", name)
+	}
+
 	w, err := c.Watch()
 	if err != nil {
 		return nil, err
@@ -145,23 +155,19 @@ func (c *Conn) removeClaim(cl *Claim) {
 // Request only returns a non-nil error if sending the updated claim
 // request fails. Failure to acquire ownership is not an error.
 func (c *Claim) Request(opts ClaimOptions) error {
-	var req struct {
-		Name  string
-		Flags uint32
-	}
-	req.Name = c.name
+	var flags RequestNameFlags
 	if opts.AllowReplacement {
-		req.Flags |= 0x1
+		flags |= RequestNameAllowReplacement
 	}
 	if opts.TryReplace {
-		req.Flags |= 0x2
+		flags |= RequestNameReplaceExisting
 	}
 	if opts.NoQueue {
-		req.Flags |= 0x4
+		flags |= RequestNameDoNotQueue
 	}
 
-	var resp uint32
-	return c.conn.bus.Interface(ifaceBus).Call(context.Background(), "RequestName", req, &resp)
+	_, err := c.conn.RequestName(context.Background(), c.name, flags)
+	return err
 }
 
 // Close abandons the claim.
@@ -178,8 +184,8 @@ func (c *Claim) close() error {
 	c.watch.Close()
 	<-c.pumpStopped
 
-	var ignore uint32
-	return c.conn.bus.Interface(ifaceBus).Call(context.Background(), "ReleaseName", c.name, &ignore)
+	_, err := c.conn.ReleaseName(withContextCleanup(context.Background()), c.name)
+	return err
 }
 
 // Name returns the claim's bus name.
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/fragments"
+)
+
+// parseArgs parses textual args according to sig, and returns a value
+// that marshals to a message body of that signature.
+//
+// The textual form is the same as busctl's: basic values are single
+// arguments, arrays and dicts are an element count followed by that
+// many elements (or key/value pairs), structs are their fields in
+// order, and variants are a signature followed by a value of that
+// signature. For example, the signature "sa{sv}" with the arguments
+// "foo 2 bar s baz qux u 42" produces the values "foo" and
+// {"bar":"baz", "qux":uint32(42)}.
+func parseArgs(sig string, args []string) (any, error) {
+	p := argParser{args: args}
+	var fs []reflect.StructField
+	var vs []reflect.Value
+	for rest := sig; rest != ""; {
+		var (
+			one string
+			err error
+		)
+		one, rest, err = splitType(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature %q: %w", sig, err)
+		}
+		v, err := p.value(one)
+		if err != nil {
+			return nil, fmt.Errorf("parsing argument %d (%s): %w", len(vs), one, err)
+		}
+		fs = append(fs, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", len(fs)),
+			Type: v.Type(),
+		})
+		vs = append(vs, v)
+	}
+	if len(p.args) > 0 {
+		return nil, fmt.Errorf("%d unused arguments after parsing signature %q: %q", len(p.args), sig, p.args)
+	}
+	if len(vs) == 0 {
+		return nil, nil
+	}
+
+	// Always wrap the values in a struct, which is encoded as a
+	// sequence of values in the message body. This also ensures that
+	// a lone struct argument isn't mistaken for several arguments.
+	ret := reflect.New(reflect.StructOf(fs)).Elem()
+	for i, v := range vs {
+		ret.Field(i).Set(v)
+	}
+	return ret.Interface(), nil
+}
+
+// parseValue parses args as a single value of type sig, in the same
+// textual form as parseArgs.
+func parseValue(sig string, args []string) (any, error) {
+	one, rest, err := splitType(sig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature %q: %w", sig, err)
+	}
+	if one == "" || rest != "" {
+		return nil, fmt.Errorf("signature %q must be a single type", sig)
+	}
+	p := argParser{args: args}
+	v, err := p.value(one)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.args) > 0 {
+		return nil, fmt.Errorf("%d unused arguments after parsing signature %q: %q", len(p.args), sig, p.args)
+	}
+	return v.Interface(), nil
+}
+
+type argParser struct {
+	args []string
+}
+
+func (p *argParser) next() (string, error) {
+	if len(p.args) == 0 {
+		return "", errors.New("not enough arguments")
+	}
+	ret := p.args[0]
+	p.args = p.args[1:]
+	return ret, nil
+}
+
+func (p *argParser) count() (int, error) {
+	s, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid element count %q", s)
+	}
+	return n, nil
+}
+
+// value parses one value of the single complete type sig.
+func (p *argParser) value(sig string) (reflect.Value, error) {
+	s, err := dbus.ParseSignature(sig)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	t := s.Type()
+	ret := reflect.New(t).Elem()
+
+	switch sig[0] {
+	case 'a':
+		n, err := p.count()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if sig[1] == '{' {
+			k, rest, err := splitType(sig[2 : len(sig)-1])
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			ret.Set(reflect.MakeMapWithSize(t, n))
+			for range n {
+				kv, err := p.value(k)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				vv, err := p.value(rest)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				ret.SetMapIndex(kv, vv)
+			}
+			return ret, nil
+		}
+		ret.Set(reflect.MakeSlice(t, n, n))
+		for i := range n {
+			v, err := p.value(sig[1:])
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			ret.Index(i).Set(v)
+		}
+		return ret, nil
+	case '(':
+		rest := sig[1 : len(sig)-1]
+		for i := 0; rest != ""; i++ {
+			var f string
+			f, rest, err = splitType(rest)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v, err := p.value(f)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			ret.Field(i).Set(v)
+		}
+		return ret, nil
+	case 'v':
+		inner, err := p.next()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if one, rest, err := splitType(inner); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid variant signature %q: %w", inner, err)
+		} else if one == "" || rest != "" {
+			return reflect.Value{}, fmt.Errorf("variant signature %q must be a single type", inner)
+		}
+		v, err := p.value(inner)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ret.Set(v)
+		return ret, nil
+	case 'h':
+		return reflect.Value{}, errors.New("file descriptors are not supported")
+	}
+
+	arg, err := p.next()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	switch sig[0] {
+	case 'g':
+		s, err := dbus.ParseSignature(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ret.Set(reflect.ValueOf(s))
+	case 'o':
+		o := dbus.ObjectPath(arg)
+		if err := o.Valid(); err != nil {
+			return reflect.Value{}, err
+		}
+		ret.Set(reflect.ValueOf(o))
+	case 's':
+		ret.SetString(arg)
+	case 'b':
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ret.SetBool(b)
+	case 'y', 'q', 'u', 't':
+		u, err := strconv.ParseUint(arg, 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ret.SetUint(u)
+	case 'n', 'i', 'x':
+		i, err := strconv.ParseInt(arg, 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ret.SetInt(i)
+	case 'd':
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ret.SetFloat(f)
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported type %q", sig)
+	}
+	return ret, nil
+}
+
+// splitType splits the first complete type off the front of sig.
+func splitType(sig string) (one, rest string, err error) {
+	if sig == "" {
+		return "", "", nil
+	}
+	n, err := fragments.NextType(sig)
+	if err != nil {
+		return "", "", err
+	}
+	return sig[:n], sig[n:], nil
+}
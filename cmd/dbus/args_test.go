@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danderson/dbus"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		sig     string
+		args    string
+		wantSig string
+		wantErr bool
+	}{
+		{"", "", "", false},
+		{"s", "foo", "(s)", false},
+		{"su", "foo 42", "(su)", false},
+		{"bynqiuxtd", "true 1 -2 3 -4 5 -6 7 1.5", "(bynqiuxtd)", false},
+		{"og", "/foo/bar a{sv}", "(og)", false},
+		{"as", "3 a b c", "(as)", false},
+		{"aas", "2 1 a 0", "(aas)", false},
+		{"a{sv}", "2 foo s bar baz au 2 1 2", "(a{sv})", false},
+		{"(si)", "foo 42", "((si))", false},
+		{"a(si)", "2 foo 1 bar 2", "(a(si))", false},
+		{"v", "(sv) foo v b true", "(v)", false},
+
+		{"s", "", "", true},
+		{"s", "foo bar", "", true},
+		{"u", "-1", "", true},
+		{"y", "256", "", true},
+		{"b", "maybe", "", true},
+		{"o", "not/a/path", "", true},
+		{"as", "2 foo", "", true},
+		{"v", "su foo 1", "", true},
+		{"h", "0", "", true},
+		{"(s", "foo", "", true},
+		{"a{vs}", "0", "", true},
+		{"z", "foo", "", true},
+	}
+
+	for _, tc := range tests {
+		args := strings.Fields(tc.args)
+		got, err := parseArgs(tc.sig, args)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseArgs(%q, %q) succeeded, want error", tc.sig, args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseArgs(%q, %q) failed: %v", tc.sig, args, err)
+			continue
+		}
+		if tc.wantSig == "" {
+			if got != nil {
+				t.Errorf("parseArgs(%q, %q) = %#v, want nil", tc.sig, args, got)
+			}
+			continue
+		}
+		sig, err := dbus.SignatureOf(got)
+		if err != nil {
+			t.Errorf("SignatureOf(parseArgs(%q, %q)) failed: %v", tc.sig, args, err)
+			continue
+		}
+		if sig.String() != tc.wantSig {
+			t.Errorf("parseArgs(%q, %q) has signature %q, want %q", tc.sig, args, sig, tc.wantSig)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		sig     string
+		args    string
+		wantSig string
+		wantErr bool
+	}{
+		{"s", "foo", "s", false},
+		{"as", "2 foo bar", "as", false},
+		{"(si)", "foo 42", "(si)", false},
+		{"v", "u 42", "u", false},
+
+		{"", "", "", true},
+		{"su", "foo 42", "", true},
+		{"s", "foo bar", "", true},
+	}
+
+	for _, tc := range tests {
+		args := strings.Fields(tc.args)
+		got, err := parseValue(tc.sig, args)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseValue(%q, %q) succeeded, want error", tc.sig, args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseValue(%q, %q) failed: %v", tc.sig, args, err)
+			continue
+		}
+		sig, err := dbus.SignatureOf(got)
+		if err != nil {
+			t.Errorf("SignatureOf(parseValue(%q, %q)) failed: %v", tc.sig, args, err)
+			continue
+		}
+		if sig.String() != tc.wantSig {
+			t.Errorf("parseValue(%q, %q) has signature %q, want %q", tc.sig, args, sig, tc.wantSig)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/danderson/dbus"
+)
+
+// printJSON writes v to stdout as indented JSON. DBus values within v
+// should first be encoded with [dbus.MarshalJSON].
+func printJSON(v any) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+	_, err = os.Stdout.Write(bs)
+	return err
+}
+
+// jsonValue converts v, a Go value such as an introspection
+// description, into a form that encoding/json can represent.
+//
+// Signatures are converted to their string form, and structs are
+// converted to objects of their exported fields.
+func jsonValue(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == reflect.TypeFor[dbus.Signature]() {
+		return v.Interface().(dbus.Signature).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return jsonValue(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		ret := make([]any, v.Len())
+		for i := range v.Len() {
+			ret[i] = jsonValue(v.Index(i))
+		}
+		return ret
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		ret := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			ret[fmt.Sprint(iter.Key().Interface())] = jsonValue(iter.Value())
+		}
+		return ret
+	case reflect.Struct:
+		t := v.Type()
+		ret := map[string]any{}
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() && !f.Anonymous {
+				ret[f.Name] = jsonValue(v.Field(i))
+			}
+		}
+		return ret
+	default:
+		return v.Interface()
+	}
+}
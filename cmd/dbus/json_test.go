@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/danderson/dbus"
+)
+
+func TestJSONValue(t *testing.T) {
+	sig, err := dbus.ParseSignature("a{sv}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := &dbus.MethodDescription{
+		Name: "Frob",
+		In: []dbus.ArgumentDescription{
+			{Name: "opts", Type: sig},
+		},
+	}
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"foo", `"foo"`},
+		{sig, `"a{sv}"`},
+		{[]string{"a", "b"}, `["a","b"]`},
+		{map[uint32]any{1: "a"}, `{"1":"a"}`},
+		{desc, `{"Deprecated":false,"Doc":"","In":[{"Keys":null,"Name":"opts","Type":"a{sv}"}],"Name":"Frob","NoReply":false,"Out":null}`},
+	}
+
+	for _, tc := range tests {
+		bs, err := json.Marshal(jsonValue(reflect.ValueOf(tc.in)))
+		if err != nil {
+			t.Errorf("json.Marshal(jsonValue(%#v)) failed: %v", tc.in, err)
+			continue
+		}
+		if got := string(bs); got != tc.want {
+			t.Errorf("json.Marshal(jsonValue(%#v)) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
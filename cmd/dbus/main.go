@@ -3,12 +3,14 @@ package main
 import (
 	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"os"
 	"os/signal"
+	"reflect"
 	"regexp"
 	"slices"
 	"strings"
@@ -17,9 +19,9 @@ import (
 
 	"github.com/creachadair/command"
 	"github.com/creachadair/flax"
-	"github.com/creachadair/mds/heapq"
 	"github.com/creachadair/mds/slice"
 	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/fragments"
 	"github.com/danderson/dbus/freedesktop/background"
 	"github.com/danderson/dbus/internal/dbusgen"
 	"github.com/kr/pretty"
@@ -28,16 +30,21 @@ import (
 var globalArgs struct {
 	UseSessionBus bool   `flag:"session,Connect to session bus instead of system bus"`
 	Names         string `flag:"names,Comma-separated list of bus names to claim"`
+	JSON          bool   `flag:"json,Output JSON instead of human-readable text, where supported"`
 }
 
 func busConn(ctx context.Context) (*dbus.Conn, error) {
-	var mk func(context.Context) (*dbus.Conn, error)
+	var mk func(context.Context, dbus.DialOptions) (*dbus.Conn, error)
 	if globalArgs.UseSessionBus {
-		mk = dbus.SessionBus
+		mk = dbus.SessionBusWithOptions
 	} else {
-		mk = dbus.SystemBus
+		mk = dbus.SystemBusWithOptions
 	}
-	conn, err := mk(ctx)
+	// The CLI's commands are short-lived, and several of them walk
+	// object trees, introspecting each object more than once.
+	conn, err := mk(ctx, dbus.DialOptions{
+		IntrospectionCacheTTL: time.Minute,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -125,6 +132,55 @@ interfaces that most objects implement:
 				Help:  "Ping a peer.",
 				Run:   command.Adapt(runPing),
 			},
+			{
+				Name:  "call",
+				Usage: "call peer object interface method [signature args...]",
+				Help: `Call a method and print its reply.
+
+The method's arguments are given as a DBus type signature, followed
+by textual values for that signature, in the same format as busctl:
+basic values are single arguments, arrays and dicts are an element
+count followed by the elements (or key/value pairs), structs are
+their fields in order, and variants are a signature followed by a
+value of that signature. For example:
+
+  dbus call org.example / org.example.Foo Frob 'sa{sv}' hello 1 count u 42
+
+The reply is decoded according to the method's introspection data.
+`,
+				Run: runCall,
+			},
+			{
+				Name:  "emit",
+				Usage: "emit object interface signal [signature args...]",
+				Help: `Broadcast a signal from this connection.
+
+The signal's values are given as a DBus type signature, followed by
+textual values for that signature, in the same form as the call
+command.
+
+For best results, combine with --names to emit from a well-known bus
+name that other tools can match on.
+`,
+				Run: runEmit,
+			},
+			{
+				Name:  "get",
+				Usage: "get peer object interface property",
+				Help:  "Print the value of a single property.",
+				Run:   command.Adapt(runGet),
+			},
+			{
+				Name:  "set",
+				Usage: "set peer object interface property value...",
+				Help: `Set the value of a single property.
+
+The value is parsed according to the property's type, as given by
+the object's introspection data. Values use the same textual form as
+the call command.
+`,
+				Run: runSet,
+			},
 			{
 				Name:  "whois",
 				Usage: "whois peer",
@@ -134,8 +190,14 @@ interfaces that most objects implement:
 			{
 				Name:  "listen",
 				Usage: "listen",
-				Help:  "Listen to bus signals.",
-				Run:   command.Adapt(runListen),
+				Help: `Listen to bus signals.
+
+By default, all signals are shown. The --sender, --path, --interface
+and --member flags restrict the output to matching signals. Each flag
+accepts a comma-separated list of values, any of which may match.
+`,
+				SetFlags: command.Flags(flax.MustBind, &listenArgs),
+				Run:      command.Adapt(runListen),
 			},
 			{
 				Name:  "features",
@@ -224,6 +286,22 @@ func runListPeers(env *command.Env) error {
 		})
 	}
 
+	if globalArgs.JSON {
+		type peerJSON struct {
+			Name    string   `json:"name"`
+			Aliases []string `json:"aliases,omitempty"`
+		}
+		out := make([]peerJSON, 0, len(peers))
+		for _, p := range peers {
+			pj := peerJSON{Name: p.Name()}
+			for _, a := range aliases[p] {
+				pj.Aliases = append(pj.Aliases, a.Name())
+			}
+			out = append(out, pj)
+		}
+		return printJSON(out)
+	}
+
 	for _, p := range peers {
 		alias := aliases[p]
 		if len(alias) == 0 {
@@ -261,22 +339,31 @@ func runListInterfaces(env *command.Env) error {
 	ctx, cancel := context.WithTimeout(env.Context(), time.Minute)
 	defer cancel()
 
+	type ifaceJSON struct {
+		Peer        string          `json:"peer"`
+		Owner       string          `json:"owner,omitempty"`
+		Object      dbus.ObjectPath `json:"object"`
+		Interface   string          `json:"interface"`
+		Description any             `json:"description,omitempty"`
+	}
+	jsonOut := []ifaceJSON{}
+
 	var out indenter
 	var prev dbus.Interface
 	for p, err := range listPeers(ctx, conn, args[0]) {
 		if err != nil {
-			out.v(err)
+			out.err(err)
 		}
 		var ownerName string
-		owner, err := p.Owner(ctx)
-		if err != nil {
-			ownerName = fmt.Sprintf("getting owner: %v", err)
+		owner, ownerErr := p.Owner(ctx)
+		if ownerErr != nil {
+			ownerName = fmt.Sprintf("getting owner: %v", ownerErr)
 		} else {
 			ownerName = owner.Name()
 		}
 		for iface, err := range listInterfaces(ctx, p, args[1], args[2]) {
 			if err != nil {
-				out.v(err)
+				out.err(err)
 				continue
 			}
 			if listInterfacesArgs.Short {
@@ -285,6 +372,21 @@ func runListInterfaces(env *command.Env) error {
 					continue
 				}
 			}
+			if globalArgs.JSON {
+				ij := ifaceJSON{
+					Peer:      iface.Peer().Name(),
+					Object:    iface.Object().Path(),
+					Interface: iface.Name(),
+				}
+				if ownerErr == nil {
+					ij.Owner = ownerName
+				}
+				if !listInterfacesArgs.Short {
+					ij.Description = jsonValue(reflect.ValueOf(iface.Description))
+				}
+				jsonOut = append(jsonOut, ij)
+				continue
+			}
 			if iface.Peer() != prev.Peer() {
 				out.indent(0)
 				if prev.Peer() != (dbus.Peer{}) {
@@ -309,6 +411,9 @@ func runListInterfaces(env *command.Env) error {
 		}
 	}
 
+	if globalArgs.JSON {
+		return printJSON(jsonOut)
+	}
 	return nil
 }
 
@@ -327,18 +432,27 @@ func runListProps(env *command.Env) error {
 
 	ctx, cancel := context.WithTimeout(env.Context(), 10*time.Second)
 	defer cancel()
+
+	type propsJSON struct {
+		Peer       string          `json:"peer"`
+		Object     dbus.ObjectPath `json:"object"`
+		Interface  string          `json:"interface"`
+		Properties json.RawMessage `json:"properties"`
+	}
+	jsonOut := []propsJSON{}
+
 	var out indenter
 	var prev dbus.Interface
 	for p, err := range listPeers(ctx, conn, args[0]) {
 		if err != nil {
 			out.indent(0)
-			out.v(err)
+			out.err(err)
 			continue
 		}
 		for iface, err := range listInterfaces(ctx, p, args[1], args[2]) {
 			if err != nil {
 				out.indent(0)
-				out.v(err)
+				out.err(err)
 				continue
 			}
 			if len(iface.Description.Properties) == 0 {
@@ -348,7 +462,7 @@ func runListProps(env *command.Env) error {
 			props, err := iface.GetAllProperties(ctx)
 			if err != nil {
 				out.indent(0)
-				out.v(fmt.Errorf("listing properties of %s: %w", iface, err))
+				out.err(fmt.Errorf("listing properties of %s: %w", iface, err))
 				continue
 			}
 			ks := slices.Sorted(maps.Keys(props))
@@ -357,6 +471,25 @@ func runListProps(env *command.Env) error {
 				continue
 			}
 
+			if globalArgs.JSON {
+				vals := map[string]any{}
+				for _, k := range ks {
+					vals[k] = props[k]
+				}
+				bs, err := dbus.MarshalJSON(vals)
+				if err != nil {
+					out.err(fmt.Errorf("encoding properties of %s: %w", iface, err))
+					continue
+				}
+				jsonOut = append(jsonOut, propsJSON{
+					Peer:       iface.Peer().Name(),
+					Object:     iface.Object().Path(),
+					Interface:  iface.Name(),
+					Properties: bs,
+				})
+				continue
+			}
+
 			if iface.Peer() != prev.Peer() {
 				out.indent(0)
 				out.v(iface.Peer().Name())
@@ -378,6 +511,10 @@ func runListProps(env *command.Env) error {
 			}
 		}
 	}
+
+	if globalArgs.JSON {
+		return printJSON(jsonOut)
+	}
 	return nil
 }
 
@@ -395,6 +532,230 @@ func runPing(env *command.Env, peer string) error {
 	return nil
 }
 
+func runCall(env *command.Env) error {
+	if len(env.Args) < 4 {
+		return env.Usagef("call requires a peer, object, interface and method.")
+	}
+	peer, ifaceName, method := env.Args[0], env.Args[2], env.Args[3]
+	path := dbus.ObjectPath(env.Args[1])
+	var (
+		sig  string
+		args []string
+	)
+	if len(env.Args) > 4 {
+		sig, args = env.Args[4], env.Args[5:]
+	}
+	if err := path.Valid(); err != nil {
+		return err
+	}
+	body, err := parseArgs(sig, args)
+	if err != nil {
+		return err
+	}
+
+	conn, err := busConn(env.Context())
+	if err != nil {
+		return fmt.Errorf("connecting to bus: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(env.Context(), time.Minute)
+	defer cancel()
+
+	obj := conn.Peer(peer).Object(path)
+	outs, err := methodOutputs(ctx, obj, ifaceName, method)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\nReply will be decoded using its raw signature.\n", err)
+	}
+
+	var resp reflect.Value
+	var respPtr any
+	var raw *rawReply
+	if err != nil {
+		raw = &rawReply{}
+		respPtr = raw
+	} else if len(outs) > 0 {
+		fs := make([]reflect.StructField, len(outs))
+		for i, out := range outs {
+			fs[i] = reflect.StructField{
+				Name: fmt.Sprintf("Field%d", i),
+				Type: out.Type.Type(),
+			}
+		}
+		resp = reflect.New(reflect.StructOf(fs))
+		respPtr = resp.Interface()
+		resp = resp.Elem()
+	}
+
+	if err := obj.Interface(ifaceName).Call(ctx, method, body, respPtr); err != nil {
+		return fmt.Errorf("calling %s.%s: %w", ifaceName, method, err)
+	}
+
+	if raw != nil {
+		if !raw.sig.IsZero() {
+			fmt.Printf("Reply (signature %q):\n  %# v\n", raw.sig, pretty.Formatter(raw.body))
+		}
+		return nil
+	}
+	for i, out := range outs {
+		v := resp.Field(i).Interface()
+		if out.Name != "" {
+			fmt.Printf("%s: %# v\n", out.Name, pretty.Formatter(v))
+		} else {
+			fmt.Printf("%# v\n", pretty.Formatter(v))
+		}
+	}
+	return nil
+}
+
+// methodOutputs returns the output arguments of method, as described
+// by obj's introspection data.
+func methodOutputs(ctx context.Context, obj dbus.Object, ifaceName, method string) ([]dbus.ArgumentDescription, error) {
+	desc, err := obj.Introspect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("introspecting %s: %w", obj, err)
+	}
+	iface := desc.Interfaces[ifaceName]
+	if iface == nil {
+		return nil, fmt.Errorf("%s does not implement %s", obj, ifaceName)
+	}
+	m, ok := iface.Method(method)
+	if !ok {
+		return nil, fmt.Errorf("%s has no method %s", ifaceName, method)
+	}
+	return m.Out, nil
+}
+
+// rawReply decodes a method reply according to the body signature
+// in the reply's header, for methods whose output types are unknown.
+type rawReply struct {
+	sig  dbus.Signature
+	body any
+}
+
+// SignatureDBus returns the zero Signature, since the reply's real
+// signature is only known once it arrives.
+func (*rawReply) SignatureDBus() dbus.Signature { return dbus.Signature{} }
+
+func (r *rawReply) UnmarshalDBus(ctx context.Context, d *fragments.Decoder) error {
+	hdr, ok := dbus.ContextHeader(ctx)
+	if !ok {
+		return errors.New("reply header not available")
+	}
+	r.sig = hdr.Signature
+	if r.sig.IsZero() {
+		return nil
+	}
+	v := reflect.New(r.sig.Type())
+	if err := d.Value(ctx, v.Interface()); err != nil {
+		return err
+	}
+	r.body = v.Elem().Interface()
+	return nil
+}
+
+func runEmit(env *command.Env) error {
+	if len(env.Args) < 3 {
+		return env.Usagef("emit requires an object, interface and signal.")
+	}
+	path, ifaceName, signal := dbus.ObjectPath(env.Args[0]), env.Args[1], env.Args[2]
+	var (
+		sig  string
+		args []string
+	)
+	if len(env.Args) > 3 {
+		sig, args = env.Args[3], env.Args[4:]
+	}
+	if err := path.Valid(); err != nil {
+		return err
+	}
+	body, err := parseArgs(sig, args)
+	if err != nil {
+		return err
+	}
+
+	conn, err := busConn(env.Context())
+	if err != nil {
+		return fmt.Errorf("connecting to bus: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.EmitRawSignal(env.Context(), path, ifaceName, signal, body); err != nil {
+		return fmt.Errorf("emitting %s.%s: %w", ifaceName, signal, err)
+	}
+	fmt.Printf("Emitted %s.%s from %s on object %s\n", ifaceName, signal, conn.LocalName(), path)
+	return nil
+}
+
+func runGet(env *command.Env, peer, object, iface, prop string) error {
+	path := dbus.ObjectPath(object)
+	if err := path.Valid(); err != nil {
+		return err
+	}
+
+	conn, err := busConn(env.Context())
+	if err != nil {
+		return fmt.Errorf("connecting to bus: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(env.Context(), time.Minute)
+	defer cancel()
+
+	var val any
+	if err := conn.Peer(peer).Object(path).Interface(iface).GetProperty(ctx, prop, &val); err != nil {
+		return fmt.Errorf("getting property %s.%s: %w", iface, prop, err)
+	}
+	fmt.Printf("%# v\n", pretty.Formatter(val))
+	return nil
+}
+
+func runSet(env *command.Env) error {
+	if len(env.Args) < 5 {
+		return env.Usagef("set requires a peer, object, interface, property and value.")
+	}
+	peer, ifaceName, prop := env.Args[0], env.Args[2], env.Args[3]
+	path := dbus.ObjectPath(env.Args[1])
+	if err := path.Valid(); err != nil {
+		return err
+	}
+
+	conn, err := busConn(env.Context())
+	if err != nil {
+		return fmt.Errorf("connecting to bus: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(env.Context(), time.Minute)
+	defer cancel()
+
+	obj := conn.Peer(peer).Object(path)
+	desc, err := obj.Introspect(ctx)
+	if err != nil {
+		return fmt.Errorf("introspecting %s: %w", obj, err)
+	}
+	iface := desc.Interfaces[ifaceName]
+	if iface == nil {
+		return fmt.Errorf("%s does not implement %s", obj, ifaceName)
+	}
+	pd, ok := iface.Property(prop)
+	if !ok {
+		return fmt.Errorf("%s has no property %s", ifaceName, prop)
+	}
+	if !pd.Writable {
+		return fmt.Errorf("property %s.%s is read-only", ifaceName, prop)
+	}
+
+	val, err := parseValue(pd.Type.String(), env.Args[4:])
+	if err != nil {
+		return fmt.Errorf("parsing value for %s.%s (%s): %w", ifaceName, prop, pd.Type, err)
+	}
+	if err := obj.Interface(ifaceName).SetProperty(ctx, prop, val); err != nil {
+		return fmt.Errorf("setting property %s.%s: %w", ifaceName, prop, err)
+	}
+	return nil
+}
+
 func runWhois(env *command.Env, peer string) error {
 	conn, err := busConn(env.Context())
 	if err != nil {
@@ -406,6 +767,39 @@ func runWhois(env *command.Env, peer string) error {
 	if err != nil {
 		return fmt.Errorf("getting credentials of %s: %w", peer, err)
 	}
+	defer creds.Close()
+
+	if globalArgs.JSON {
+		type whoisJSON struct {
+			PID           *uint32         `json:"pid,omitempty"`
+			UID           *uint32         `json:"uid,omitempty"`
+			GIDs          []uint32        `json:"gids,omitempty"`
+			PIDFD         any             `json:"pidfd,omitempty"`
+			SecurityLabel string          `json:"security_label,omitempty"`
+			SecurityLSM   string          `json:"security_module,omitempty"`
+			Unknown       json.RawMessage `json:"unknown,omitempty"`
+		}
+		out := whoisJSON{
+			PID:           creds.PID,
+			UID:           creds.UID,
+			GIDs:          creds.GIDs,
+			SecurityLabel: string(creds.SecurityLabel),
+		}
+		if lsm := creds.SecurityModule(); lsm != dbus.SecurityModuleUnknown {
+			out.SecurityLSM = lsm.String()
+		}
+		if creds.PIDFD != nil {
+			out.PIDFD = creds.PIDFD.Fd()
+		}
+		if len(creds.Unknown) > 0 {
+			bs, err := dbus.MarshalJSON(creds.Unknown)
+			if err != nil {
+				return fmt.Errorf("encoding credentials: %w", err)
+			}
+			out.Unknown = bs
+		}
+		return printJSON(out)
+	}
 
 	if creds.PID != nil {
 		fmt.Println("PID:", *creds.PID)
@@ -418,7 +812,11 @@ func runWhois(env *command.Env, peer string) error {
 		fmt.Println("PIDFD:", creds.PIDFD.Fd())
 	}
 	if creds.SecurityLabel != nil {
-		fmt.Println("Security label:", string(creds.SecurityLabel))
+		if lsm := creds.SecurityModule(); lsm != dbus.SecurityModuleUnknown {
+			fmt.Printf("Security label: %s (%s)\n", creds.SecurityLabel, lsm)
+		} else {
+			fmt.Println("Security label:", string(creds.SecurityLabel))
+		}
 	}
 	for _, k := range slices.Sorted(maps.Keys(creds.Unknown)) {
 		fmt.Println(k, "(?):", creds.Unknown[k])
@@ -427,6 +825,107 @@ func runWhois(env *command.Env, peer string) error {
 	return nil
 }
 
+var listenArgs struct {
+	Senders    string `flag:"sender,Comma-separated list of senders to listen to"`
+	Paths      string `flag:"path,Comma-separated list of object paths to listen to"`
+	Interfaces string `flag:"interface,Comma-separated list of interfaces to listen to"`
+	Members    string `flag:"member,Comma-separated list of signal names to listen to"`
+	Eavesdrop  bool   `flag:"eavesdrop,Also listen to unicast signals addressed to other peers (legacy buses only)"`
+}
+
+// maxListenMatches is the maximum number of matches that listen
+// adds. Buses limit the number of match rules per connection, and
+// each match costs the bus some work for every signal.
+const maxListenMatches = 32
+
+// listenMatches returns the matches described by listenArgs.
+func listenMatches(ctx context.Context, conn *dbus.Conn) ([]*dbus.Match, error) {
+	// Each filter is a set of alternative restrictions to apply to a
+	// match. The returned matches are every combination of one
+	// restriction from each filter.
+	type restrict func(*dbus.Match) *dbus.Match
+	var filters [][]restrict
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, ",")
+	}
+
+	var fs []restrict
+	for _, s := range split(listenArgs.Senders) {
+		p := conn.Peer(s)
+		if !p.IsUniqueName() {
+			// Signals always carry the sender's unique name, so
+			// resolve well-known names up front.
+			owner, err := p.Owner(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("getting owner of %s: %w", s, err)
+			}
+			p = owner
+		}
+		fs = append(fs, func(m *dbus.Match) *dbus.Match { return m.Peer(p) })
+	}
+	filters = append(filters, fs)
+
+	fs = nil
+	for _, s := range split(listenArgs.Paths) {
+		path := dbus.ObjectPath(s)
+		if err := path.Valid(); err != nil {
+			return nil, err
+		}
+		fs = append(fs, func(m *dbus.Match) *dbus.Match { return m.Object(path) })
+	}
+	filters = append(filters, fs)
+
+	fs = nil
+	for _, s := range split(listenArgs.Interfaces) {
+		fs = append(fs, func(m *dbus.Match) *dbus.Match { return m.Interface(s) })
+	}
+	filters = append(filters, fs)
+
+	fs = nil
+	for _, s := range split(listenArgs.Members) {
+		fs = append(fs, func(m *dbus.Match) *dbus.Match { return m.Member(s) })
+	}
+	filters = append(filters, fs)
+
+	n := 1
+	for _, f := range filters {
+		n *= max(len(f), 1)
+	}
+	if n > maxListenMatches {
+		return nil, fmt.Errorf("filters combine into %d match rules, the maximum is %d; listen with fewer filters", n, maxListenMatches)
+	}
+
+	combos := [][]restrict{nil}
+	for _, f := range filters {
+		if len(f) == 0 {
+			continue
+		}
+		var next [][]restrict
+		for _, c := range combos {
+			for _, r := range f {
+				next = append(next, append(slices.Clip(c), r))
+			}
+		}
+		combos = next
+	}
+
+	var ret []*dbus.Match
+	for _, c := range combos {
+		m := dbus.MatchAllSignals()
+		for _, r := range c {
+			m = r(m)
+		}
+		if listenArgs.Eavesdrop {
+			m = m.Eavesdrop()
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
 func runListen(env *command.Env) error {
 	conn, err := busConn(env.Context())
 	if err != nil {
@@ -434,13 +933,20 @@ func runListen(env *command.Env) error {
 	}
 	defer conn.Close()
 
+	matches, err := listenMatches(env.Context(), conn)
+	if err != nil {
+		return err
+	}
+
 	w, err := conn.Watch()
 	if err != nil {
 		return fmt.Errorf("creating watcher: %w", err)
 	}
 	defer w.Close()
-	if _, err := w.Match(dbus.MatchAllSignals()); err != nil {
-		return fmt.Errorf("watching all signals: %w", err)
+	for _, m := range matches {
+		if _, err := w.Match(m); err != nil {
+			return fmt.Errorf("adding signal match: %w", err)
+		}
 	}
 	fmt.Println("Listening for signals...")
 	for {
@@ -448,7 +954,7 @@ func runListen(env *command.Env) error {
 		case <-env.Context().Done():
 			return nil
 		case sig := <-w.Chan():
-			fmt.Printf("Signal %s.%s from %s on object %s:\n  %# v\n\n", sig.Sender.Name(), sig.Name, sig.Sender.Peer().Name(), sig.Sender.Object().Path(), pretty.Formatter(sig.Body))
+			fmt.Printf("Signal %s.%s (signature %q) from %s on object %s:\n  %# v\n\n", sig.Sender.Name(), sig.Name, sig.Signature, sig.Sender.Peer().Name(), sig.Sender.Object().Path(), pretty.Formatter(sig.Body))
 			if sig.Overflow {
 				fmt.Println("OVERFLOW, some signals lost")
 			}
@@ -505,37 +1011,29 @@ func runServePeer(env *command.Env) error {
 var generateArgs struct {
 	PackageName string `flag:"package,default=client,Package name to output"`
 	OutFile     string `flag:"out,default=gen.go,Output file path"`
+	VarDicts    bool   `flag:"vardict-structs,Generate structs for named a{sv} method arguments"`
 }
 
-func findInterface(ctx context.Context, peer dbus.Peer, wantName string) (*dbus.InterfaceDescription, error) {
+func findInterface(ctx context.Context, peer dbus.Peer, wantName string) (dbus.Object, *dbus.InterfaceDescription, error) {
 	var errs []error
-	objs := heapq.New(func(a, b dbus.Object) int {
-		return cmp.Compare(a.Path(), b.Path())
-	})
-	objs.Add(peer.Object("/"))
-	for !objs.IsEmpty() {
-		obj, _ := objs.Pop()
-		introCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
-		desc, err := obj.Introspect(introCtx)
+	for obj, err := range peer.WalkObjects(ctx) {
 		if err != nil {
-			errs = append(errs, fmt.Errorf("introspecting %s: %w", obj, err))
+			errs = append(errs, err)
 			continue
 		}
-		for name, iface := range desc.Interfaces {
-			if name == wantName {
-				fmt.Printf("Found definition of %s at %s\n", name, obj)
-				return iface, nil
-			}
+		// The walk already introspected obj, this is answered by
+		// the Conn's introspection cache.
+		desc, err := obj.Introspect(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
 		}
-		for _, child := range desc.Children {
-			objs.Add(obj.Child(child))
+		if iface := desc.Interfaces[wantName]; iface != nil {
+			fmt.Printf("Found definition of %s at %s\n", iface.Name, obj)
+			return obj, iface, nil
 		}
 	}
-	if len(errs) > 0 {
-		return nil, errors.Join(errs...)
-	}
-	return nil, nil
+	return dbus.Object{}, nil, errors.Join(errs...)
 }
 
 func runGenerate(env *command.Env) error {
@@ -548,7 +1046,10 @@ func runGenerate(env *command.Env) error {
 	ctx, cancel := context.WithTimeout(env.Context(), time.Minute)
 	defer cancel()
 
-	var desc *dbus.InterfaceDescription
+	var (
+		obj  dbus.Object
+		desc *dbus.InterfaceDescription
+	)
 	switch len(env.Args) {
 	case 0:
 		return env.Usagef("generate requires at least one argument.")
@@ -561,7 +1062,7 @@ func runGenerate(env *command.Env) error {
 			if peer.IsUniqueName() {
 				continue
 			}
-			desc, err = findInterface(ctx, peer, env.Args[0])
+			obj, desc, err = findInterface(ctx, peer, env.Args[0])
 			if err != nil {
 				fmt.Println(err)
 				continue
@@ -574,7 +1075,7 @@ func runGenerate(env *command.Env) error {
 			return fmt.Errorf("could not find an object that implements %s on the bus", env.Args[0])
 		}
 	case 2:
-		desc, err = findInterface(ctx, conn.Peer(env.Args[0]), env.Args[1])
+		obj, desc, err = findInterface(ctx, conn.Peer(env.Args[0]), env.Args[1])
 		if err != nil {
 			return err
 		}
@@ -597,7 +1098,11 @@ import (
   "github.com/danderson/dbus"
 )
 `, generateArgs.PackageName)
-	code, err := dbusgen.Interface(desc)
+	code, err := dbusgen.InterfaceWithOptions(desc, dbusgen.Options{
+		VarDictStructs: generateArgs.VarDicts,
+		Peer:           obj.Peer().Name(),
+		Object:         obj.Path(),
+	})
 	if _, err := io.WriteString(f, code); err != nil {
 		return fmt.Errorf("writing generated code: %w", err)
 	}
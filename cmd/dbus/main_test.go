@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/dbustest"
+)
+
+func TestListenMatches(t *testing.T) {
+	saved := listenArgs
+	defer func() { listenArgs = saved }()
+
+	list := func(prefix string, n int) string {
+		var ret []string
+		for i := range n {
+			ret = append(ret, prefix+strings.Repeat("x", i+1))
+		}
+		return strings.Join(ret, ",")
+	}
+
+	listenArgs.Interfaces = list("org.test.", 4)
+	listenArgs.Members = list("Signal", 3)
+	ms, err := listenMatches(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("listenMatches() failed: %v", err)
+	}
+	if len(ms) != 12 {
+		t.Errorf("listenMatches() returned %d matches, want 12", len(ms))
+	}
+
+	listenArgs.Interfaces = list("org.test.", 8)
+	listenArgs.Members = list("Signal", 8)
+	if ms, err := listenMatches(context.Background(), nil); err == nil {
+		t.Errorf("listenMatches() with 64 combinations returned %d matches, want error", len(ms))
+	}
+}
+
+func TestRawReply(t *testing.T) {
+	f := dbustest.NewFake(t)
+	err := f.Handle("org.test.Service", "org.test", "Get", func(context.Context, dbus.ObjectPath) (map[string]uint32, error) {
+		return map[string]uint32{"answer": 42}, nil
+	})
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	err = f.Handle("org.test.Service", "org.test", "Nothing", func(context.Context, dbus.ObjectPath) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	iface := f.MustConn(t).Peer("org.test.Service").Object("/").Interface("org.test")
+	var got rawReply
+	if err := iface.Call(context.Background(), "Get", nil, &got); err != nil {
+		t.Fatalf("Call(Get) failed: %v", err)
+	}
+	if got.sig.String() != "a{su}" {
+		t.Errorf("reply signature is %q, want %q", got.sig, "a{su}")
+	}
+	if m, ok := got.body.(map[string]uint32); !ok || m["answer"] != 42 {
+		t.Errorf("reply body is %#v, want map[answer:42]", got.body)
+	}
+
+	got = rawReply{}
+	if err := iface.Call(context.Background(), "Nothing", nil, &got); err != nil {
+		t.Fatalf("Call(Nothing) failed: %v", err)
+	}
+	if !got.sig.IsZero() || got.body != nil {
+		t.Errorf("empty reply decoded as %q %#v, want nothing", got.sig, got.body)
+	}
+}
@@ -6,13 +6,10 @@ import (
 	"fmt"
 	"io"
 	"iter"
-	"maps"
 	"os"
 	"regexp"
-	"slices"
 	"strings"
 
-	"github.com/creachadair/mds/heapq"
 	"github.com/danderson/dbus"
 )
 
@@ -25,6 +22,16 @@ func (i *indenter) v(v any) {
 	fmt.Fprintf(i, "%v\n", v)
 }
 
+// err reports a non-fatal error. In JSON mode, errors are written to
+// stderr to keep stdout parseable.
+func (i *indenter) err(err error) {
+	if globalArgs.JSON {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	i.v(err)
+}
+
 func (i *indenter) s(msg string) {
 	io.WriteString(i, msg+"\n")
 }
@@ -112,30 +119,27 @@ func listInterfaces(ctx context.Context, peer dbus.Peer, objectFilter, interface
 			return
 		}
 
-		objs := heapq.New(dbus.Object.Compare)
-		objs.Add(peer.Object("/"))
-		for !objs.IsEmpty() {
-			obj, _ := objs.Pop()
-			desc, err := obj.Introspect(ctx)
+		for obj, err := range peer.WalkObjects(ctx) {
 			if err != nil {
 				if !yield(objectInterface{}, err) {
 					return
 				}
 				continue
 			}
-			for _, child := range desc.Children {
-				objs.Add(obj.Child(child))
-			}
 			if !om.MatchString(string(obj.Path())) {
 				continue
 			}
-			ks := slices.Sorted(maps.Keys(desc.Interfaces))
-			for _, k := range ks {
-				if !im.MatchString(k) {
+			for desc, err := range obj.Interfaces(ctx) {
+				if err != nil {
+					if !yield(objectInterface{}, err) {
+						return
+					}
+					break
+				}
+				if !im.MatchString(desc.Name) {
 					continue
 				}
-				iface := obj.Interface(k)
-				if !yield(objectInterface{iface, desc.Interfaces[k]}, nil) {
+				if !yield(objectInterface{obj.Interface(desc.Name), desc}, nil) {
 					return
 				}
 			}
@@ -2,20 +2,26 @@ package dbus
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"iter"
-	"log"
+	"log/slog"
 	"maps"
 	"net"
 	"os"
 	"reflect"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	lru "github.com/creachadair/mds/cache"
 	"github.com/creachadair/mds/mapset"
 	"github.com/danderson/dbus/fragments"
 	"github.com/danderson/dbus/internal/transport"
@@ -28,6 +34,12 @@ type Conn struct {
 	bus      Object
 
 	closeOnce func() error
+	done      chan struct{} // closed when readLoop exits
+
+	msgsSent       atomic.Uint64
+	msgsReceived   atomic.Uint64
+	strictBools    atomic.Bool // decode incoming booleans strictly
+	lenientStrings atomic.Bool // don't validate incoming strings
 
 	// Only touched by writeMsg.
 	writeMu sync.Mutex
@@ -35,23 +47,74 @@ type Conn struct {
 	encBody []byte
 	encHdr  []byte
 
+	// Guards matchRefs. Not held across AddMatch and RemoveMatch calls.
+	matchMu   sync.Mutex
+	matchRefs map[string]*matchState // match rule -> state
+
 	mu         sync.Mutex
-	closing    bool // no new Watch or Claim
-	closed     bool // no new RPCs at all
+	closing    bool  // no new Watch or Claim
+	draining   bool  // no new calls, except cleanup
+	closed     bool  // no new RPCs at all
+	err        error // reason for closed, if set
 	calls      map[uint32]*pendingCall
+	drained    chan struct{} // closed when draining and idle
 	lastSerial uint32
 	watchers   mapset.Set[*Watcher]
 	claims     mapset.Set[*Claim]
 	handlers   map[interfaceMember]handlerFunc
+	features   []string // bus features, nil until first fetched
+
+	peerToPeer bool // connected to a single peer, not a bus
+
+	logger *slog.Logger       // nil means slog.Default()
+	trace  func(MessageTrace) // if non-nil, called for every message
+
+	// Machine IDs of peers with unique names, which never change.
+	machineIDs *lru.Cache[string, string]
+	// Introspection XML of objects, if introspectTTL is positive.
+	introspectTTL  time.Duration
+	introspections *lru.Cache[introspectKey, cachedIntrospection]
+
+	handleOpts  HandleOptions
+	callWorkers int          // goroutines running dispatchCall
+	queuedCalls []queuedCall // calls waiting for a free worker
+	// Calls waiting for a LimitsExceeded reply, and whether a
+	// goroutine is running rejectWorker to send them.
+	rejectedCalls []queuedCall
+	rejecting     bool
+}
+
+// maxRejectedCalls is the maximum number of LimitsExceeded replies
+// waiting to be written. Calls rejected beyond that are dropped
+// without a reply.
+const maxRejectedCalls = 64
+
+// queuedCall is an incoming method call waiting for a handler
+// slot.
+type queuedCall struct {
+	ctx context.Context
+	msg *msg
 }
 
 // SystemBus connects to the system bus.
 func SystemBus(ctx context.Context) (*Conn, error) {
-	return Dial(ctx, "/run/dbus/system_bus_socket")
+	return SystemBusWithOptions(ctx, DialOptions{})
+}
+
+// SystemBusWithOptions is like [SystemBus], but configures the Conn
+// according to opts.
+func SystemBusWithOptions(ctx context.Context, opts DialOptions) (*Conn, error) {
+	return DialWithOptions(ctx, "/run/dbus/system_bus_socket", opts)
 }
 
 // SessionBus connects to the current user's session bus.
 func SessionBus(ctx context.Context) (*Conn, error) {
+	return SessionBusWithOptions(ctx, DialOptions{})
+}
+
+// SessionBusWithOptions is like [SessionBus], but configures the
+// Conn according to opts.
+func SessionBusWithOptions(ctx context.Context, opts DialOptions) (*Conn, error) {
 	path := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
 	if path == "" {
 		return nil, errors.New("session bus not available")
@@ -61,7 +124,7 @@ func SessionBus(ctx context.Context) (*Conn, error) {
 		if !ok {
 			continue
 		}
-		return Dial(ctx, addr)
+		return DialWithOptions(ctx, addr, opts)
 	}
 	return nil, fmt.Errorf("could not find usable session bus address in DBUS_SESSION_BUS_ADDRESS value %q", path)
 }
@@ -73,52 +136,244 @@ func SessionBus(ctx context.Context) (*Conn, error) {
 // development. Most users should use [SessionBus] or [SystemBus]
 // instead.
 func Dial(ctx context.Context, path string) (*Conn, error) {
+	return DialWithOptions(ctx, path, DialOptions{})
+}
+
+// DialOptions configures a Conn created by [DialWithOptions] or
+// [ConnectWithOptions].
+type DialOptions struct {
+	// PeerToPeer indicates that the other end of the connection is
+	// a single DBus peer, rather than a message bus.
+	//
+	// A peer-to-peer Conn does not register with a bus, and so has
+	// an empty [Conn.LocalName]. Method calls and signals are
+	// exchanged directly with the peer, which is addressed as
+	// Peer(""). Watchers receive all signals that the peer emits,
+	// without registering match rules. Methods that query or
+	// modify the state of a bus, such as claiming names, fail.
+	//
+	// [Listener] accepts peer-to-peer connections from clients.
+	PeerToPeer bool
+	// KeepaliveInterval is how often the Conn pings the bus to check
+	// that the connection is still healthy. If zero, the Conn does
+	// not send keepalive pings.
+	//
+	// A Unix socket connection can become unresponsive without
+	// reporting an error, for example if the bus process is stuck. In
+	// that state, method calls hang until their context expires.
+	// With keepalives, the Conn instead shuts down once the bus
+	// fails to respond to KeepaliveFailures consecutive pings,
+	// failing all pending calls with an error.
+	KeepaliveInterval time.Duration
+	// KeepaliveTimeout is how long the Conn waits for the bus to
+	// respond to a keepalive ping. If zero, KeepaliveInterval is
+	// used.
+	KeepaliveTimeout time.Duration
+	// KeepaliveFailures is the number of consecutive failed
+	// keepalive pings after which the Conn shuts down. If zero, a
+	// default of 3 is used.
+	KeepaliveFailures int
+	// StrictBooleans makes the Conn reject incoming messages that
+	// contain booleans encoded as values other than 0 or 1. See
+	// [WithContextStrictBooleans].
+	StrictBooleans bool
+	// LenientStrings makes the Conn accept incoming messages that
+	// contain strings that are not valid UTF-8, or that contain NUL
+	// bytes. See [WithContextStrictStrings].
+	LenientStrings bool
+	// ByteOrder is the byte order in which the Conn encodes the
+	// messages it sends. If nil, the Conn uses
+	// [fragments.NativeEndian].
+	//
+	// DBus peers accept messages in either byte order, so this is
+	// only useful for testing peers' handling of foreign byte
+	// orders, or for proxies that relay message bodies in a peer's
+	// byte order with [Interface.CallRaw].
+	ByteOrder fragments.ByteOrder
+	// MachineID, if non-nil, provides the machine ID that the Conn
+	// reports to peers that call
+	// org.freedesktop.DBus.Peer.GetMachineId. It is called for every
+	// such call, and any error is returned to the caller.
+	//
+	// If nil, the Conn reports the contents of /etc/machine-id, or
+	// /var/lib/dbus/machine-id if the former doesn't exist.
+	MachineID func() (string, error)
+	// Logger, if non-nil, receives the Conn's diagnostic logs, such
+	// as failures to dispatch incoming messages and panics in method
+	// handlers. If nil, logs go to [slog.Default].
+	//
+	// To discard logs, use a Logger whose handler writes to
+	// [io.Discard].
+	Logger *slog.Logger
+	// Trace, if non-nil, is called with a summary of every message
+	// that the Conn sends or receives, after it is successfully
+	// written or read.
+	//
+	// Trace is called synchronously from the Conn's send and receive
+	// paths, possibly concurrently. It must return quickly, and must
+	// not call methods on the Conn.
+	Trace func(MessageTrace)
+	// IntrospectionCacheTTL, if positive, makes the Conn cache the
+	// results of [Object.Introspect] for this long. If zero,
+	// every call to Introspect queries the object.
+	//
+	// Cached descriptions are not updated when a peer reconfigures
+	// its objects, or when a well-known name changes owner, so
+	// Introspect may return stale results until the TTL expires.
+	// Use [Object.InvalidateIntrospection] to discard a cached
+	// description early.
+	IntrospectionCacheTTL time.Duration
+}
+
+// defaultKeepaliveFailures is the number of failed keepalive pings
+// that kill a Conn, if DialOptions.KeepaliveFailures is zero.
+const defaultKeepaliveFailures = 3
+
+// DialWithOptions is like [Dial], but configures the Conn according
+// to opts.
+func DialWithOptions(ctx context.Context, path string, opts DialOptions) (*Conn, error) {
+	if err := opts.valid(); err != nil {
+		return nil, err
+	}
 	t, err := transport.DialUnix(ctx, path)
 	if err != nil {
 		return nil, err
 	}
-	ret := &Conn{
-		t: t,
-		enc: fragments.Encoder{
-			Order:  fragments.NativeEndian,
-			Mapper: encoderFor,
-		},
-		calls:    map[uint32]*pendingCall{},
-		handlers: map[interfaceMember]handlerFunc{},
+	return newConn(ctx, t, opts)
+}
+
+// Connect returns a Conn that speaks DBus over conn, which must
+// already be connected to a message bus.
+//
+// If conn is a *net.UnixConn, the Conn can send and receive file
+// descriptors. Most users should use [SessionBus] or [SystemBus]
+// instead.
+func Connect(ctx context.Context, conn net.Conn) (*Conn, error) {
+	return ConnectWithOptions(ctx, conn, DialOptions{})
+}
+
+// ConnectWithOptions is like [Connect], but configures the Conn
+// according to opts.
+//
+// If opts.PeerToPeer is set, conn must be connected to a DBus peer
+// that acts as the authentication server, rather than to a message
+// bus.
+func ConnectWithOptions(ctx context.Context, conn net.Conn, opts DialOptions) (*Conn, error) {
+	if err := opts.valid(); err != nil {
+		return nil, err
+	}
+	var (
+		t   transport.Transport
+		err error
+	)
+	if uc, ok := conn.(*net.UnixConn); ok {
+		t, err = transport.NewUnix(ctx, uc)
+	} else {
+		t, err = transport.NewStream(ctx, conn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newConn(ctx, t, opts)
+}
+
+// valid returns an error if opts contains invalid settings.
+func (opts DialOptions) valid() error {
+	if opts.KeepaliveInterval < 0 || opts.KeepaliveTimeout < 0 || opts.KeepaliveFailures < 0 {
+		return errors.New("invalid negative keepalive option")
+	}
+	if opts.IntrospectionCacheTTL < 0 {
+		return errors.New("invalid negative introspection cache TTL")
+	}
+	return nil
+}
+
+// newConn returns a Conn that uses t, configured according to the
+// parts of opts that affect message handling.
+func newConn(ctx context.Context, t transport.Transport, opts DialOptions) (*Conn, error) {
+	ret := newUnstartedConn(t)
+	ret.peerToPeer = opts.PeerToPeer
+	ret.logger = opts.Logger
+	ret.trace = opts.Trace
+	ret.strictBools.Store(opts.StrictBooleans)
+	ret.lenientStrings.Store(opts.LenientStrings)
+	if opts.ByteOrder != nil {
+		ret.enc.Order = opts.ByteOrder
+	}
+	if opts.IntrospectionCacheTTL > 0 {
+		ret.introspectTTL = opts.IntrospectionCacheTTL
+		ret.introspections = lru.New(lru.LRU[introspectKey, cachedIntrospection](maxCachedIntrospections))
 	}
-	ret.closeOnce = sync.OnceValue(ret.close)
-	ret.bus = ret.
-		Peer("org.freedesktop.DBus").
-		Object("/org/freedesktop/DBus")
 
 	go ret.readLoop()
 
-	if err := ret.bus.Interface(ifaceBus).Call(ctx, "Hello", nil, &ret.clientID); err != nil {
-		ret.Close()
-		return nil, fmt.Errorf("getting DBus client ID: %w", err)
+	if !ret.peerToPeer {
+		if err := ret.bus.Interface(ifaceBus).Call(ctx, "Hello", nil, &ret.clientID); err != nil {
+			ret.Close()
+			return nil, fmt.Errorf("getting DBus client ID: %w", err)
+		}
 	}
 
 	// Implement the Peer interface, on all objects.
 	ret.Handle("org.freedesktop.DBus.Peer", "Ping", func(context.Context, ObjectPath) error {
 		return nil
 	})
-	uuid := sync.OnceValues(func() (string, error) {
-		bs, err := os.ReadFile("/etc/machine-id")
-		if errors.Is(err, fs.ErrNotExist) {
-			bs, err = os.ReadFile("/var/lib/dbus/machine-id")
-		}
-		if err != nil {
-			return "", err
-		}
-		return strings.TrimSpace(string(bs)), nil
-	})
+	uuid := opts.MachineID
+	if uuid == nil {
+		uuid = sync.OnceValues(func() (string, error) {
+			bs, err := os.ReadFile("/etc/machine-id")
+			if errors.Is(err, fs.ErrNotExist) {
+				bs, err = os.ReadFile("/var/lib/dbus/machine-id")
+			}
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSpace(string(bs)), nil
+		})
+	}
 	ret.Handle("org.freedesktop.DBus.Peer", "GetMachineId", func(context.Context, ObjectPath) (string, error) {
 		return uuid()
 	})
 
+	if opts.KeepaliveInterval > 0 {
+		timeout := cmp.Or(opts.KeepaliveTimeout, opts.KeepaliveInterval)
+		failures := cmp.Or(opts.KeepaliveFailures, defaultKeepaliveFailures)
+		go ret.keepalive(opts.KeepaliveInterval, timeout, failures)
+	}
+
 	return ret, nil
 }
 
+// maxCachedMachineIDs is the number of peer machine IDs that a Conn
+// caches.
+const maxCachedMachineIDs = 256
+
+// maxCachedIntrospections is the number of object descriptions that
+// a Conn caches, if DialOptions.IntrospectionCacheTTL is set.
+const maxCachedIntrospections = 1024
+
+// newUnstartedConn returns a Conn that uses t, without starting its
+// read loop or performing any bus setup.
+func newUnstartedConn(t transport.Transport) *Conn {
+	ret := &Conn{
+		t: t,
+		enc: fragments.Encoder{
+			Order:  fragments.NativeEndian,
+			Mapper: encoderFor,
+		},
+		done:     make(chan struct{}),
+		calls:    map[uint32]*pendingCall{},
+		handlers: map[interfaceMember]handlerFunc{},
+
+		machineIDs: lru.New(lru.LRU[string, string](maxCachedMachineIDs)),
+	}
+	ret.closeOnce = sync.OnceValue(ret.close)
+	ret.bus = ret.
+		Peer("org.freedesktop.DBus").
+		Object("/org/freedesktop/DBus")
+	return ret
+}
+
 type interfaceMember struct {
 	Interface string
 	Member    string
@@ -131,24 +386,82 @@ func (im interfaceMember) String() string {
 type pendingCall struct {
 	notify chan struct{}
 	resp   any
+	decode decodeOptions // caller's options for decoding resp
 	err    error
 }
 
-func (c *Conn) lockedWatchers() iter.Seq[*Watcher] {
-	return func(yield func(*Watcher) bool) {
+// currentWatchers returns the Watchers that are currently registered.
+//
+// The returned sequence is a snapshot, and does not hold c.mu while
+// iterating, so that delivering to Watchers does not prevent other
+// uses of the Conn, such as closing the Watcher or the Conn.
+func (c *Conn) currentWatchers() iter.Seq[*Watcher] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Values(slices.Collect(maps.Keys(c.watchers)))
+}
+
+// Close closes the DBus connection.
+//
+// Close is abrupt: pending calls fail immediately with
+// [net.ErrClosed], and method calls being handled by the Conn do not
+// get a reply. Use [Conn.Shutdown] to close the connection gracefully.
+func (c *Conn) Close() error {
+	return c.closeOnce()
+}
+
+// Shutdown gracefully closes the DBus connection.
+//
+// Shutdown first stops accepting new calls: outgoing calls fail with
+// [net.ErrClosed], and incoming method calls receive an error
+// reply. It then waits for in-flight calls to complete, both those
+// made by the Conn and those being handled by it. Once the Conn is
+// idle, Shutdown closes all [Watcher]s and [Claim]s, which releases
+// the Conn's bus names so that the bus can promptly pass them on to
+// other claimants, and finally closes the connection.
+//
+// If ctx expires before the in-flight calls complete, Shutdown
+// proceeds as if by [Conn.Close], and returns the context's error.
+func (c *Conn) Shutdown(ctx context.Context) error {
+	drained := func() chan struct{} {
 		c.mu.Lock()
 		defer c.mu.Unlock()
-		for w := range c.watchers {
-			if !yield(w) {
-				return
-			}
+		c.draining = true
+		if c.drained == nil {
+			c.drained = make(chan struct{})
+			c.checkDrainedLocked()
 		}
+		return c.drained
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-c.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if closeErr := c.Close(); err == nil {
+		err = closeErr
 	}
+	return err
 }
 
-// Close closes the DBus connection.
-func (c *Conn) Close() error {
-	return c.closeOnce()
+// checkDrainedLocked closes c.drained if the Conn is shutting down
+// and has no more calls in flight. Incoming calls are in flight
+// until they have been replied to, including calls that are queued
+// for a worker or waiting for a rejection reply.
+//
+// c.mu must be held.
+func (c *Conn) checkDrainedLocked() {
+	if c.drained == nil || len(c.calls) > 0 || c.callWorkers > 0 || len(c.queuedCalls) > 0 || c.rejecting {
+		return
+	}
+	select {
+	case <-c.drained:
+	default:
+		close(c.drained)
+	}
 }
 
 func (c *Conn) startClose() (mapset.Set[*Watcher], mapset.Set[*Claim]) {
@@ -171,9 +484,24 @@ func (c *Conn) close() error {
 	for c := range claim {
 		c.Close()
 	}
+	return c.shutdown(net.ErrClosed)
+}
 
+// shutdown stops all RPCs, fails pending calls, and closes the
+// transport. cause is recorded as the reason for the shutdown, and
+// reported by [Conn.Err].
+//
+// Unlike close, shutdown does not interact with the bus, and so is
+// safe to call from the read loop after the transport has failed.
+func (c *Conn) shutdown(cause error) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = cause
+	}
+	if c.closed {
+		return nil
+	}
 	c.closed = true
 	for c := range maps.Values(c.calls) {
 		c.err = net.ErrClosed
@@ -183,11 +511,75 @@ func (c *Conn) close() error {
 	return c.t.Close()
 }
 
+// Err returns the reason the connection stopped working, or nil if
+// it is still usable.
+//
+// After [Conn.Close], Err returns [net.ErrClosed]. If the connection
+// failed on its own, for example because the bus disconnected it,
+// Err returns an error describing the failure.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Done returns a channel that is closed when the connection stops
+// receiving messages, either because it was closed or because it
+// failed. Once Done is closed, [Conn.Err] reports the reason.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// ConnStats are statistics about a [Conn].
+type ConnStats struct {
+	// MessagesSent is the number of messages written to the
+	// connection.
+	MessagesSent uint64
+	// MessagesReceived is the number of messages read from the
+	// connection.
+	MessagesReceived uint64
+	// PendingCalls is the number of method calls awaiting a reply.
+	PendingCalls int
+	// ActiveHandlers is the number of incoming method calls being
+	// handled.
+	ActiveHandlers int
+	// QueuedCalls is the number of incoming method calls waiting for
+	// a handler to become available. See [HandleOptions].
+	QueuedCalls int
+}
+
+// Stats returns statistics about the connection.
+func (c *Conn) Stats() ConnStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ConnStats{
+		MessagesSent:     c.msgsSent.Load(),
+		MessagesReceived: c.msgsReceived.Load(),
+		PendingCalls:     len(c.calls),
+		ActiveHandlers:   c.callWorkers,
+		QueuedCalls:      len(c.queuedCalls),
+	}
+}
+
+// log returns the logger for the Conn's diagnostics.
+func (c *Conn) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return slog.Default()
+}
+
 // LocalName returns the connection's unique bus name.
 func (c *Conn) LocalName() string {
 	return c.clientID
 }
 
+// ByteOrder returns the byte order in which the Conn encodes the
+// messages it sends.
+func (c *Conn) ByteOrder() fragments.ByteOrder {
+	return c.enc.Order
+}
+
 // Peer returns a Peer for the given bus name.
 //
 // The returned value is a local handle only. It does not indicate
@@ -200,29 +592,56 @@ func (c *Conn) Peer(name string) Peer {
 }
 
 func (c *Conn) writeMsg(ctx context.Context, hdr *header, body any) error {
+	var files []*os.File
+	return c.writeMsgFiles(ctx, hdr, body, &files)
+}
+
+// writeReply writes a handler's reply to a method call, and closes
+// the files contained in resp once the reply has been written, or
+// has failed to write.
+func (c *Conn) writeReply(ctx context.Context, hdr *header, resp any) error {
+	var files []*os.File
+	err := c.writeMsgFiles(ctx, hdr, resp, &files)
+	for _, f := range files {
+		f.Close()
+	}
+	return err
+}
+
+// writeMsgFiles is like writeMsg, and additionally stores in files
+// the files that body contains, as far as encoding body got.
+func (c *Conn) writeMsgFiles(ctx context.Context, hdr *header, body any, files *[]*os.File) error {
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
-	if c.closed {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
 		return net.ErrClosed
 	}
 
-	var files []*os.File
 	c.encBody = c.encBody[:0]
-	if body != nil {
-		bodyCtx := withContextHeader(ctx, c, hdr)
-		bodyCtx = withContextFiles(bodyCtx, &files)
-		c.enc.Out = c.encBody
-		if err := c.enc.Value(bodyCtx, body); err != nil {
+	bodyBytes := c.encBody
+	if raw, ok := body.(rawBody); ok {
+		hdr.Length = uint32(len(raw.body))
+		hdr.Signature = raw.sig
+		bodyBytes = raw.body
+	} else if body != nil {
+		be, err := bodyEncoderFor(reflect.TypeOf(body))
+		if err != nil {
 			return err
 		}
-		sig, err := SignatureOf(body)
-		if err != nil {
+		bodyCtx := withContextHeader(ctx, c, hdr)
+		bodyCtx = withContextFiles(bodyCtx, files)
+		c.enc.Out = c.encBody
+		if err := be.enc(bodyCtx, &c.enc, reflect.ValueOf(body)); err != nil {
 			return err
 		}
 		hdr.Length = uint32(len(c.enc.Out))
-		hdr.Signature = sig.asMsgBody()
-		hdr.NumFDs = uint32(len(files))
+		hdr.Signature = be.sig
+		hdr.NumFDs = uint32(len(*files))
 		c.encBody = c.enc.Out
+		bodyBytes = c.encBody
 	}
 
 	c.enc.Out = c.encHdr[:0]
@@ -230,27 +649,129 @@ func (c *Conn) writeMsg(ctx context.Context, hdr *header, body any) error {
 		return err
 	}
 	c.encHdr = c.enc.Out
+	if n := len(c.encHdr) + len(bodyBytes); n > maxMessageSize {
+		return fmt.Errorf("message length %d exceeds maximum of %d bytes", n, maxMessageSize)
+	}
 
-	if _, err := c.t.WriteWithFiles(c.encHdr, files); err != nil {
+	if _, err := c.t.WriteWithFiles(c.encHdr, *files); err != nil {
 		return err
 	}
-	if _, err := c.t.Write(c.encBody); err != nil {
-		return err
+	if len(bodyBytes) > 0 {
+		if _, err := c.t.Write(bodyBytes); err != nil {
+			return err
+		}
+	}
+	c.msgsSent.Add(1)
+	if c.trace != nil {
+		c.trace(newMessageTrace(hdr, true))
 	}
 
 	return nil
 }
 
 func (c *Conn) readLoop() {
+	defer close(c.done)
 	for {
-		if err := c.dispatchMsg(); errors.Is(err, net.ErrClosed) {
+		err := c.dispatchMsg()
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, net.ErrClosed) {
 			// Conn was shut down.
 			return
-		} else if err != nil {
-			// Errors that bubble out here represent a failure to
-			// conform to the DBus protocol, and is fatal to the
-			// Conn.
-			log.Printf("read error: %v", err)
+		}
+
+		// Errors that bubble out here are either the other end
+		// hanging up, or a failure to conform to the DBus protocol
+		// that leaves the stream in an unknown state. Either way,
+		// the Conn is unusable. Stop RPCs immediately, then clean up
+		// watchers and claims in the background, since they may be
+		// blocked on delivering signals.
+		switch {
+		case errors.Is(err, io.EOF):
+			// The other end hung up cleanly between messages,
+			// which is routine when a bus or peer goes away.
+			err = fmt.Errorf("connection lost: %w", err)
+			c.log().Debug("DBus connection closed by peer", "err", err)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			err = fmt.Errorf("connection lost: %w", err)
+			c.log().Error("DBus connection failed", "err", err)
+		default:
+			err = fmt.Errorf("DBus protocol error: %w", err)
+			c.log().Error("DBus connection failed", "err", err)
+		}
+		c.shutdown(err)
+		go c.closeOnce()
+		return
+	}
+}
+
+// keepaliveTarget returns the object that keepalive pings: the bus,
+// or the peer of a peer-to-peer Conn.
+func (c *Conn) keepaliveTarget() Object {
+	if c.peerToPeer {
+		return c.Peer("").Object("/")
+	}
+	return c.bus
+}
+
+// keepalive pings the bus every interval, and shuts down the Conn if
+// maxFailures consecutive pings fail or take longer than timeout.
+func (c *Conn) keepalive(interval, timeout time.Duration, maxFailures int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		failures int
+		// inflight is the result of the latest ping, if it hasn't
+		// completed yet. A ping can block indefinitely while
+		// writing to a stuck socket, so it runs in the background
+		// and is not retried until it completes.
+		inflight chan error
+	)
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		if inflight == nil {
+			inflight = make(chan error, 1)
+			go func(ret chan<- error) {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				ret <- c.keepaliveTarget().Interface(ifacePeer).Call(ctx, "Ping", nil)
+			}(inflight)
+		}
+
+		var err error
+		timer := time.NewTimer(timeout)
+		select {
+		case err = <-inflight:
+			inflight = nil
+		case <-timer.C:
+			err = context.DeadlineExceeded
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if errors.Is(err, net.ErrClosed) {
+			// Conn closed normally, the read loop will notice
+			// shortly.
+			return
+		}
+		failures++
+		if failures >= maxFailures {
+			c.shutdown(fmt.Errorf("connection lost: bus did not respond to %d keepalive pings: %w", failures, err))
+			go c.closeOnce()
+			return
 		}
 	}
 }
@@ -273,70 +794,168 @@ func (m msg) Decoder() *fragments.Decoder {
 // readMsg reads one complete DBus message from c.t. Must not be
 // called concurrently (Conn.dispatchMsg ensures this).
 func (c *Conn) readMsg() (*msg, error) {
-	dec := fragments.Decoder{
-		Order:  fragments.NativeEndian,
-		Mapper: decoderFor,
-		In:     c.t,
-	}
-	var ret msg
-	err := dec.Value(context.Background(), &ret.header)
-	if err != nil {
-		return nil, err
-	}
-	ret.body, err = io.ReadAll(io.LimitReader(c.t, int64(ret.header.Length)))
+	ret, err := decodeMsg(c.t)
 	if err != nil {
 		return nil, err
 	}
-	ret.order = dec.Order
 	ret.files, err = c.t.GetFiles(int(ret.header.NumFDs))
 	if err != nil {
 		return nil, err
 	}
-	return &ret, nil
+	c.msgsReceived.Add(1)
+	return ret, nil
 }
 
+// dispatchMsg reads and processes one message. It returns an error
+// only if the Conn can no longer be used, such as a transport failure
+// or a malformed message header. Errors in processing individual
+// messages are reported to the affected caller, or logged.
 func (c *Conn) dispatchMsg() error {
 	msg, err := c.readMsg()
 	if err != nil {
 		return err
 	}
-	if err := msg.Valid(); err != nil {
+	if err := msg.valid(!c.peerToPeer); err != nil {
 		return fmt.Errorf("received invalid header: %w", err)
 	}
+	if c.trace != nil {
+		c.trace(newMessageTrace(&msg.header, false))
+	}
 
 	ctx := withContextHeader(context.Background(), c, &msg.header)
+	if c.peerToPeer && msg.Sender == "" {
+		// Peers don't label their messages with a sender, but on a
+		// peer-to-peer connection there's only one possible sender.
+		ctx = withContextSender(ctx, c.Peer(""), &msg.header)
+	}
+	ctx = withContextIncomingHeader(ctx, msg)
+	ctx = withDecodeOptions(ctx, decodeOptions{
+		strictBools:    c.strictBools.Load(),
+		lenientStrings: c.lenientStrings.Load(),
+	})
 	if len(msg.files) > 0 {
 		ctx = withContextFiles(ctx, &msg.files)
 	}
 
 	switch msg.Type {
 	case msgTypeCall:
-		go c.dispatchCall(ctx, msg)
+		c.queueCall(ctx, msg)
 	case msgTypeReturn:
-		return c.dispatchReturn(ctx, msg)
+		c.dispatchReturn(ctx, msg)
 	case msgTypeError:
-		return c.dispatchErr(msg)
+		c.dispatchErr(msg)
 	case msgTypeSignal:
-		return c.dispatchSignal(ctx, msg)
+		if err := c.dispatchSignal(ctx, msg); err != nil {
+			c.log().Warn("dispatching signal failed", "interface", msg.Interface, "member", msg.Member, "err", err)
+		}
 	}
 	return nil
 }
 
+// queueCall arranges for msg to be dispatched to its handler, subject
+// to the Conn's HandleOptions.
+func (c *Conn) queueCall(ctx context.Context, msg *msg) {
+	c.mu.Lock()
+	switch {
+	case c.handleOpts.MaxConcurrent <= 0 || c.callWorkers < c.handleOpts.MaxConcurrent:
+		c.callWorkers++
+		c.mu.Unlock()
+		go c.callWorker(ctx, msg)
+	case len(c.queuedCalls) < c.handleOpts.MaxQueued:
+		c.queuedCalls = append(c.queuedCalls, queuedCall{ctx, msg})
+		c.mu.Unlock()
+	default:
+		if !msg.WantReply() {
+			c.mu.Unlock()
+			c.log().Warn("dropped one-way call", "interface", msg.Interface, "member", msg.Member, "err", "too many concurrent calls")
+			return
+		}
+		// Reply from a single goroutine rather than the read loop,
+		// so that a slow peer cannot stall the read loop, and a
+		// flood of calls cannot spawn unbounded goroutines.
+		if len(c.rejectedCalls) >= maxRejectedCalls {
+			c.mu.Unlock()
+			c.log().Warn("dropped call without reply", "interface", msg.Interface, "member", msg.Member, "err", "too many concurrent calls")
+			return
+		}
+		c.rejectedCalls = append(c.rejectedCalls, queuedCall{ctx, msg})
+		start := !c.rejecting
+		c.rejecting = true
+		c.mu.Unlock()
+		if start {
+			go c.rejectWorker()
+		}
+	}
+}
+
+// rejectWorker replies to rejected calls with ErrLimitsExceeded,
+// until no rejected calls remain.
+func (c *Conn) rejectWorker() {
+	for {
+		c.mu.Lock()
+		if len(c.rejectedCalls) == 0 {
+			c.rejecting = false
+			c.checkDrainedLocked()
+			c.mu.Unlock()
+			return
+		}
+		next := c.rejectedCalls[0]
+		c.rejectedCalls[0] = queuedCall{}
+		c.rejectedCalls = c.rejectedCalls[1:]
+		c.lastSerial++
+		serial := c.lastSerial
+		c.mu.Unlock()
+
+		c.writeMsg(next.ctx, &header{
+			Type:        msgTypeError,
+			Version:     1,
+			Serial:      serial,
+			Destination: next.msg.Sender,
+			ReplySerial: next.msg.Serial,
+			ErrName:     ErrLimitsExceeded.Name,
+		}, "too many concurrent calls")
+	}
+}
+
+// callWorker dispatches msg, then any queued calls, until the call
+// queue is empty.
+func (c *Conn) callWorker(ctx context.Context, msg *msg) {
+	for {
+		c.dispatchCall(ctx, msg)
+
+		c.mu.Lock()
+		if len(c.queuedCalls) == 0 || (c.handleOpts.MaxConcurrent > 0 && c.callWorkers > c.handleOpts.MaxConcurrent) {
+			c.callWorkers--
+			c.checkDrainedLocked()
+			c.mu.Unlock()
+			return
+		}
+		next := c.queuedCalls[0]
+		c.queuedCalls[0] = queuedCall{}
+		c.queuedCalls = c.queuedCalls[1:]
+		c.mu.Unlock()
+		ctx, msg = next.ctx, next.msg
+	}
+}
+
 func (c *Conn) dispatchCall(ctx context.Context, msg *msg) {
-	handler, serial := func() (handlerFunc, uint32) {
+	handler, serial, draining, opts := func() (handlerFunc, uint32, bool, HandleOptions) {
 		c.mu.Lock()
 		defer c.mu.Unlock()
 		if c.closed {
-			return nil, 0
+			return nil, 0, false, HandleOptions{}
 		}
 		handler := c.handlers[interfaceMember{msg.Interface, msg.Member}]
 		c.lastSerial++
-		return handler, c.lastSerial
+		return handler, c.lastSerial, c.draining, c.handleOpts
 	}()
 	if serial == 0 {
 		return
 	}
 
+	// The spec forbids replying to calls that don't expect a reply,
+	// so the most we can do for those is log failures.
+	wantReply := msg.WantReply()
 	respHdr := &header{
 		Type:        msgTypeReturn,
 		Version:     1,
@@ -344,24 +963,98 @@ func (c *Conn) dispatchCall(ctx context.Context, msg *msg) {
 		Destination: msg.Sender,
 		ReplySerial: msg.Serial,
 	}
-	if handler == nil {
+	if draining {
+		if !wantReply {
+			return
+		}
 		respHdr.Type = msgTypeError
 		respHdr.ErrName = "org.freedesktop.DBus.Error.Failed"
+		c.writeMsg(ctx, respHdr, "connection is shutting down")
+		return
+	}
+	if handler == nil {
+		if !wantReply {
+			c.log().Warn("dropped one-way call", "interface", msg.Interface, "member", msg.Member, "err", "no such method")
+			return
+		}
+		respHdr.Type = msgTypeError
+		respHdr.ErrName = ErrUnknownMethod.Name
 		c.writeMsg(ctx, respHdr, "no such method")
 		return
 	}
 
-	resp, err := handler(ctx, msg.Path, msg.Decoder())
+	handlerCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		handlerCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	resp, err := func() (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				c.log().Error("panic in method handler", "interface", msg.Interface, "member", msg.Member, "panic", r, "stack", string(debug.Stack()))
+				// Don't leak the panic value to the caller, it may
+				// contain sensitive internal details.
+				err = CallError{
+					Name:   cmp.Or(opts.PanicErrorName, ErrFailed.Name),
+					Detail: "internal error in method handler",
+				}
+			}
+		}()
+		return handler(handlerCtx, msg.Path, msg.Decoder())
+	}()
+	if err != nil && errors.Is(handlerCtx.Err(), context.DeadlineExceeded) && !errors.As(err, new(CallError)) {
+		err = CallError{
+			Name:   ErrTimeout.Name,
+			Detail: "method handler timed out",
+		}
+	}
+	if !wantReply {
+		if err != nil {
+			c.log().Warn("one-way call failed", "interface", msg.Interface, "member", msg.Member, "err", err)
+		} else {
+			closeFiles(ctx, resp)
+		}
+		return
+	}
 	if err != nil {
 		respHdr.Type = msgTypeError
-		respHdr.ErrName = "org.freedesktop.DBus.Error.Failed"
-		c.writeMsg(ctx, respHdr, err.Error())
+		respHdr.ErrName = ErrFailed.Name
+		detail := err.Error()
+		// Handlers can return a CallError to reply with a specific
+		// DBus error name.
+		if callErr := (CallError{}); errors.As(err, &callErr) && callErr.Name != "" {
+			respHdr.ErrName = callErr.Name
+			detail = callErr.Detail
+		}
+		c.writeMsg(ctx, respHdr, detail)
+		return
+	}
+	c.writeReply(ctx, respHdr, resp)
+}
+
+// closeFiles closes the files contained in v, a handler's reply to a
+// call that does not expect a reply.
+func closeFiles(ctx context.Context, v any) {
+	if v == nil {
 		return
 	}
-	c.writeMsg(ctx, respHdr, resp)
+	be, err := bodyEncoderFor(reflect.TypeOf(v))
+	if err != nil {
+		return
+	}
+	var files []*os.File
+	enc := fragments.Encoder{
+		Order:  fragments.NativeEndian,
+		Mapper: encoderFor,
+	}
+	be.enc(withContextFiles(ctx, &files), &enc, reflect.ValueOf(v))
+	for _, f := range files {
+		f.Close()
+	}
 }
 
-func (c *Conn) dispatchReturn(ctx context.Context, msg *msg) error {
+func (c *Conn) dispatchReturn(ctx context.Context, msg *msg) {
 	pending := func() *pendingCall {
 		c.mu.Lock()
 		defer c.mu.Unlock()
@@ -375,19 +1068,19 @@ func (c *Conn) dispatchReturn(ctx context.Context, msg *msg) error {
 
 	if pending == nil {
 		// Response to a canceled call
-		return nil
+		return
 	}
 
 	if pending.resp != nil {
+		ctx = withDecodeOptions(ctx, pending.decode)
 		if err := msg.Decoder().Value(ctx, pending.resp); err != nil {
-			return err
+			pending.err = fmt.Errorf("decoding response: %w", err)
 		}
 	}
 	close(pending.notify)
-	return nil
 }
 
-func (c *Conn) dispatchErr(msg *msg) error {
+func (c *Conn) dispatchErr(msg *msg) {
 	pending := func() *pendingCall {
 		c.mu.Lock()
 		defer c.mu.Unlock()
@@ -401,7 +1094,7 @@ func (c *Conn) dispatchErr(msg *msg) error {
 
 	if pending == nil {
 		// Response to a canceled call
-		return nil
+		return
 	}
 
 	errStr := func() string {
@@ -423,7 +1116,6 @@ func (c *Conn) dispatchErr(msg *msg) error {
 		Detail: errStr,
 	}
 	close(pending.notify)
-	return nil
 }
 
 func (c *Conn) dispatchSignal(ctx context.Context, msg *msg) error {
@@ -447,7 +1139,7 @@ func (c *Conn) dispatchSignal(ctx context.Context, msg *msg) error {
 		return errors.Join(propErr, err)
 	}
 
-	for w := range c.lockedWatchers() {
+	for w := range c.currentWatchers() {
 		w.deliverSignal(emitter, &msg.header, signal)
 	}
 
@@ -485,12 +1177,12 @@ func (c *Conn) dispatchPropChange(ctx context.Context, msg *msg) error {
 			} else {
 				v = reflect.New(propSig.Type())
 			}
-			if err := body.Value(ctx, t); err != nil {
+			if err := body.Value(ctx, v.Interface()); err != nil {
 				return err
 			}
 			if t != nil {
-				for w := range c.lockedWatchers() {
-					w.deliverProp(emitter, &msg.header, interfaceMember{iface, propName}, v)
+				for w := range c.currentWatchers() {
+					w.deliverProp(emitter, &msg.header, interfaceMember{iface, propName}, propSig, v)
 				}
 			}
 			return nil
@@ -512,8 +1204,11 @@ func (c *Conn) dispatchPropChange(ctx context.Context, msg *msg) error {
 		if t == nil {
 			continue
 		}
-		for w := range c.lockedWatchers() {
-			w.deliverProp(emitter, &msg.header, interfaceMember{iface, prop}, reflect.New(t))
+		// Invalidated properties carry no value, report the
+		// signature of the registered type instead.
+		sig, _ := signatureFor(t, nil)
+		for w := range c.currentWatchers() {
+			w.deliverProp(emitter, &msg.header, interfaceMember{iface, prop}, sig, reflect.New(t))
 		}
 	}
 	return nil
@@ -532,7 +1227,7 @@ func (c *Conn) call(ctx context.Context, destination string, path ObjectPath, if
 	serial, pending := func() (uint32, *pendingCall) {
 		c.mu.Lock()
 		defer c.mu.Unlock()
-		if c.closed {
+		if c.closed || (c.draining && !contextIsCleanup(ctx)) {
 			return 0, nil
 		}
 
@@ -540,6 +1235,7 @@ func (c *Conn) call(ctx context.Context, destination string, path ObjectPath, if
 		pend := &pendingCall{
 			notify: make(chan struct{}, 1),
 			resp:   response,
+			decode: contextDecodeOptions(ctx),
 		}
 		c.calls[c.lastSerial] = pend
 		return c.lastSerial, pend
@@ -553,6 +1249,7 @@ func (c *Conn) call(ctx context.Context, destination string, path ObjectPath, if
 		if c.calls[serial] == pending {
 			delete(c.calls, serial)
 		}
+		c.checkDrainedLocked()
 	}()
 
 	hdr := header{
@@ -566,13 +1263,16 @@ func (c *Conn) call(ctx context.Context, destination string, path ObjectPath, if
 		Member:      method,
 	}
 	if noReply {
-		hdr.Flags |= 0x1
+		hdr.Flags |= flagNoReplyExpected
 	}
-	if err := hdr.Valid(); err != nil {
+	if err := hdr.valid(!c.peerToPeer); err != nil {
 		return err
 	}
 
-	if err := c.writeMsg(context.Background(), &hdr, body); err != nil {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.writeMsg(ctx, &hdr, body); err != nil {
 		return err // TODO: close transport?
 	}
 
@@ -593,11 +1293,50 @@ func (c *Conn) call(ctx context.Context, destination string, path ObjectPath, if
 // The signal's type must be registered in advance with
 // [RegisterSignalType].
 func (c *Conn) EmitSignal(ctx context.Context, obj ObjectPath, signal any) error {
+	return c.emitSignal(ctx, "", obj, signal)
+}
+
+// EmitSignalTo sends signal from obj to a single destination peer,
+// rather than broadcasting it.
+//
+// The message bus delivers unicast signals only to dest, regardless
+// of the match rules registered by other peers.
+//
+// The signal's type must be registered in advance with
+// [RegisterSignalType].
+func (c *Conn) EmitSignalTo(ctx context.Context, dest string, obj ObjectPath, signal any) error {
+	if err := c.Peer(dest).Valid(); err != nil {
+		return err
+	}
+	return c.emitSignal(ctx, dest, obj, signal)
+}
+
+// EmitRawSignal broadcasts the signal interfaceName.signalName from
+// obj, with the given body.
+//
+// This is a low-level API for emitting signals whose types are not
+// known at compile time. Unlike [Conn.EmitSignal], body's type need
+// not be registered, and it is the caller's responsibility to match
+// body to the signal's signature. Body may be nil for signals that
+// carry no values.
+func (c *Conn) EmitRawSignal(ctx context.Context, obj ObjectPath, interfaceName, signalName string, body any) error {
+	return c.writeSignal(ctx, "", obj, interfaceMember{interfaceName, signalName}, body)
+}
+
+// emitSignal sends signal from obj. If dest is non-empty, the signal
+// is delivered only to that peer.
+func (c *Conn) emitSignal(ctx context.Context, dest string, obj ObjectPath, signal any) error {
 	t := reflect.TypeOf(signal)
 	k, ok := signalNameFor(t)
 	if !ok {
 		return fmt.Errorf("unknown signal type %s", t)
 	}
+	return c.writeSignal(ctx, dest, obj, k, signal)
+}
+
+// writeSignal sends the signal k from obj with the given body. If
+// dest is non-empty, the signal is delivered only to that peer.
+func (c *Conn) writeSignal(ctx context.Context, dest string, obj ObjectPath, k interfaceMember, body any) error {
 	serial := func() uint32 {
 		c.mu.Lock()
 		defer c.mu.Unlock()
@@ -612,18 +1351,23 @@ func (c *Conn) EmitSignal(ctx context.Context, obj ObjectPath, signal any) error
 	}
 
 	hdr := header{
-		Type:      msgTypeSignal,
-		Version:   1,
-		Serial:    serial,
-		Path:      obj,
-		Interface: k.Interface,
-		Member:    k.Member,
+		Type:        msgTypeSignal,
+		Version:     1,
+		Serial:      serial,
+		Path:        obj,
+		Interface:   k.Interface,
+		Member:      k.Member,
+		Destination: dest,
+	}
+	if err := hdr.Valid(); err != nil {
+		return err
 	}
-	return c.writeMsg(ctx, &hdr, signal)
+	return c.writeMsg(ctx, &hdr, body)
 }
 
 // Handle calls fn to handle incoming method calls to methodName on
-// interfaceName.
+// interfaceName. Handlers for different calls run concurrently,
+// subject to the limits set by [Conn.SetHandleOptions].
 //
 // fn must have one of the following type signatures, where ReqType
 // and RetType determine the method's [Signature].
@@ -633,6 +1377,24 @@ func (c *Conn) EmitSignal(ctx context.Context, obj ObjectPath, signal any) error
 //	func(context.Context, dbus.ObjectPath, ReqType) error
 //	func(context.Context, dbus.ObjectPath, ReqType) (RetType, error)
 //
+// If fn returns a [CallError], the caller receives an error reply
+// with the CallError's name and detail. Other errors are reported as
+// org.freedesktop.DBus.Error.Failed. If the caller indicated that it
+// does not expect a reply, fn's return values are discarded, and
+// errors are logged.
+//
+// If fn's return value contains [*os.File] values, the reply carries
+// the files' descriptors to the caller. Returning a file with a nil
+// error transfers its ownership to the Conn, which closes the file
+// once the reply has been sent, or has failed to send. fn must not
+// close or otherwise use returned files after returning them. To
+// keep using a file, return a duplicate of it instead. If fn returns
+// a non-nil error, its other return values are discarded, and fn
+// retains ownership of any files in them.
+//
+// If fn panics, the panic is recovered and logged, and the caller
+// receives an error reply. See [HandleOptions.PanicErrorName].
+//
 // Handle panics if fn is not one of the above type signatures.
 func (c *Conn) Handle(interfaceName, methodName string, fn any) {
 	handler := handlerForFunc(fn)
@@ -644,6 +1406,68 @@ func (c *Conn) Handle(interfaceName, methodName string, fn any) {
 	c.handlers[interfaceMember{interfaceName, methodName}] = handler
 }
 
+// HandleOptions configures the execution of method handlers
+// registered with [Conn.Handle].
+type HandleOptions struct {
+	// MaxConcurrent is the maximum number of method handlers that
+	// can run concurrently. If zero, the number of concurrent
+	// handlers is unlimited.
+	MaxConcurrent int
+	// MaxQueued is the maximum number of incoming calls that can wait
+	// for a handler slot, when MaxConcurrent handlers are already
+	// running. Calls received when the queue is full are rejected
+	// with [ErrLimitsExceeded]. Queued calls are handled in the order
+	// they were received.
+	//
+	// MaxQueued has no effect if MaxConcurrent is zero.
+	MaxQueued int
+	// PanicErrorName is the DBus error name of the reply sent when a
+	// handler panics. If empty,
+	// org.freedesktop.DBus.Error.Failed is used.
+	//
+	// Handler panics are always recovered and logged, and never
+	// crash the program. The reply's error detail does not include
+	// the panic value.
+	PanicErrorName string
+	// Timeout is the maximum time that a method handler can run. If
+	// nonzero, the context passed to handlers is canceled after
+	// Timeout, and a handler that then returns an error replies to
+	// the caller with [ErrTimeout], unless the error is a
+	// [CallError].
+	//
+	// The Conn cannot forcibly stop a handler, so handlers that do
+	// expensive work must observe their context's cancellation for
+	// Timeout to take effect.
+	Timeout time.Duration
+}
+
+// SetHandleOptions configures the execution of method handlers.
+//
+// By default, each incoming method call runs its handler in a new
+// goroutine, so a burst of calls can start an unbounded number of
+// goroutines. Services that may face heavy load should bound handler
+// concurrency with SetHandleOptions.
+//
+// New options apply to calls received after SetHandleOptions
+// returns. Handlers that are already running are not affected.
+func (c *Conn) SetHandleOptions(opts HandleOptions) error {
+	if opts.MaxConcurrent < 0 || opts.MaxQueued < 0 || opts.Timeout < 0 {
+		return errors.New("invalid negative handler limit")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handleOpts = opts
+	// Start queued calls that fit under the new limit.
+	for len(c.queuedCalls) > 0 && (opts.MaxConcurrent == 0 || c.callWorkers < opts.MaxConcurrent) {
+		next := c.queuedCalls[0]
+		c.queuedCalls[0] = queuedCall{}
+		c.queuedCalls = c.queuedCalls[1:]
+		c.callWorkers++
+		go c.callWorker(next.ctx, next.msg)
+	}
+	return nil
+}
+
 type handlerFunc func(ctx context.Context, object ObjectPath, req *fragments.Decoder) (any, error)
 
 func handlerForFunc(fn any) handlerFunc {
@@ -678,7 +1502,7 @@ func handlerForFunc(fn any) handlerFunc {
 	if ni == 3 {
 		reqDec, err = decoderFor(t.In(2))
 		if err != nil {
-			panic(fmt.Errorf("request type %s is not a valid DBus type: %w", t.In(1), err))
+			panic(fmt.Errorf("request type %s is not a valid DBus type: %w", t.In(2), err))
 		}
 	}
 	if no == 2 {
@@ -692,9 +1516,12 @@ func handlerForFunc(fn any) handlerFunc {
 	type s struct{ numIn, numOut int }
 	switch (s{ni, no}) {
 	case s{2, 1}:
-		handler := fn.(func(context.Context, ObjectPath) error)
 		return func(ctx context.Context, obj ObjectPath, req *fragments.Decoder) (any, error) {
-			return nil, handler(ctx, obj)
+			rets := v.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(obj)})
+			if err, ok := rets[0].Interface().(error); ok && err != nil {
+				return nil, err
+			}
+			return nil, nil
 		}
 	case s{2, 2}:
 		return func(ctx context.Context, obj ObjectPath, req *fragments.Decoder) (any, error) {
@@ -706,30 +1533,30 @@ func handlerForFunc(fn any) handlerFunc {
 		}
 	case s{3, 1}:
 		return func(ctx context.Context, obj ObjectPath, req *fragments.Decoder) (any, error) {
-			body := reflect.New(t.In(1))
+			body := reflect.New(t.In(2)).Elem()
 			if err := reqDec(ctx, req, body); err != nil {
 				return nil, err
 			}
 			rets := v.Call([]reflect.Value{
 				reflect.ValueOf(ctx),
 				reflect.ValueOf(obj),
-				body.Elem(),
+				body,
 			})
 			if err, ok := rets[0].Interface().(error); ok && err != nil {
 				return nil, err
 			}
-			return rets[1].Interface(), nil
+			return nil, nil
 		}
 	case s{3, 2}:
 		return func(ctx context.Context, obj ObjectPath, req *fragments.Decoder) (any, error) {
-			body := reflect.New(t.In(1))
+			body := reflect.New(t.In(2)).Elem()
 			if err := reqDec(ctx, req, body); err != nil {
 				return nil, err
 			}
 			rets := v.Call([]reflect.Value{
 				reflect.ValueOf(ctx),
 				reflect.ValueOf(obj),
-				body.Elem(),
+				body,
 			})
 			if err, ok := rets[1].Interface().(error); ok && err != nil {
 				return nil, err
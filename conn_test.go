@@ -0,0 +1,1221 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danderson/dbus/fragments"
+	"github.com/danderson/dbus/internal/transport"
+	"golang.org/x/sys/unix"
+)
+
+// discardTransport is a transport.Transport that discards all writes.
+type discardTransport struct{}
+
+func (discardTransport) Read(bs []byte) (int, error)                          { return 0, os.ErrClosed }
+func (discardTransport) Write(bs []byte) (int, error)                         { return len(bs), nil }
+func (discardTransport) Close() error                                         { return nil }
+func (discardTransport) GetFiles(n int) ([]*os.File, error)                   { return nil, nil }
+func (discardTransport) WriteWithFiles(bs []byte, fs []*os.File) (int, error) { return len(bs), nil }
+func (discardTransport) PeerCredentials() (*transport.Credentials, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// pipeTransport is a transport.Transport over a net.Conn, without
+// authentication or file descriptor support.
+type pipeTransport struct {
+	net.Conn
+}
+
+func (p pipeTransport) Read(bs []byte) (int, error) {
+	n, err := p.Conn.Read(bs)
+	if errors.Is(err, io.ErrClosedPipe) {
+		err = net.ErrClosed
+	}
+	return n, err
+}
+
+func (p pipeTransport) PeerCredentials() (*transport.Credentials, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (p pipeTransport) GetFiles(n int) ([]*os.File, error) {
+	if n > 0 {
+		return nil, errors.New("file descriptors not supported")
+	}
+	return nil, nil
+}
+
+func (p pipeTransport) WriteWithFiles(bs []byte, fs []*os.File) (int, error) {
+	if len(fs) > 0 {
+		return 0, errors.New("file descriptors not supported")
+	}
+	return p.Write(bs)
+}
+
+// newPipeConns returns two Conns connected directly to each other,
+// with no bus in between.
+func newPipeConns(t *testing.T) (*Conn, *Conn) {
+	a, b := net.Pipe()
+	ca, cb := newUnstartedConn(pipeTransport{a}), newUnstartedConn(pipeTransport{b})
+	go ca.readLoop()
+	go cb.readLoop()
+	t.Cleanup(func() {
+		ca.Close()
+		cb.Close()
+	})
+	return ca, cb
+}
+
+func waitDone(t *testing.T, c *Conn) {
+	t.Helper()
+	select {
+	case <-c.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for Conn to shut down")
+	}
+}
+
+func TestConnStatsAndErr(t *testing.T) {
+	client, server := newPipeConns(t)
+	server.Handle("org.test", "Ping", func(context.Context, ObjectPath) error {
+		return nil
+	})
+
+	if err := client.Err(); err != nil {
+		t.Fatalf("Err() on new Conn = %v, want nil", err)
+	}
+	select {
+	case <-client.Done():
+		t.Fatal("Done() closed on new Conn")
+	default:
+	}
+
+	ctx := context.Background()
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	for range 3 {
+		if err := iface.Call(ctx, "Ping", nil); err != nil {
+			t.Fatalf("Call() failed: %v", err)
+		}
+	}
+	want := ConnStats{MessagesSent: 3, MessagesReceived: 3}
+	if got := client.Stats(); got != want {
+		t.Errorf("client Stats() = %+v, want %+v", got, want)
+	}
+	// The server may not have finished accounting for its last
+	// reply yet, but must have seen all the calls.
+	if got := server.Stats().MessagesReceived; got != 3 {
+		t.Errorf("server Stats().MessagesReceived = %d, want 3", got)
+	}
+
+	// Closing one end should make the other notice that it's
+	// dead, even though nobody closed it.
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	waitDone(t, server)
+	if err := server.Err(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Err() after Close() = %v, want net.ErrClosed", err)
+	}
+
+	waitDone(t, client)
+	if err := client.Err(); !errors.Is(err, io.EOF) {
+		t.Errorf("Err() after peer hangup = %v, want io.EOF", err)
+	}
+	if err := iface.Call(ctx, "Ping", nil); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Call() after peer hangup = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestConnCorruptStream(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := newUnstartedConn(pipeTransport{a})
+	go c.readLoop()
+	defer c.Close()
+	go io.Copy(io.Discard, b)
+
+	callErr := make(chan error, 1)
+	go func() {
+		callErr <- c.Peer("org.test.Server").Object("/").Interface("org.test").Call(context.Background(), "Ping", nil)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().PendingCalls == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for call to be sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 'x' is not a valid byte order flag.
+	go b.Write([]byte("xxxxxxxxxxxxxxxxxxxxxxxx"))
+
+	waitDone(t, c)
+	err := c.Err()
+	if err == nil || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+		t.Errorf("Err() after corrupt header = %v, want protocol error", err)
+	}
+	select {
+	case err := <-callErr:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("pending Call() = %v, want net.ErrClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pending call did not fail after corrupt header")
+	}
+}
+
+func TestConnMessageLimits(t *testing.T) {
+	// The header's field array adds one more level of nesting, taking
+	// this value over the limit.
+	deepArray := reflect.ValueOf(uint32(42))
+	for range fragments.MaxArrayDepth {
+		arr := reflect.MakeSlice(reflect.SliceOf(deepArray.Type()), 1, 1)
+		arr.Index(0).Set(deepArray)
+		deepArray = arr
+	}
+
+	tests := []struct {
+		name    string
+		hdr     header
+		wantErr string
+	}{
+		{
+			"body too large",
+			header{
+				Type:        msgTypeReturn,
+				Version:     1,
+				Serial:      1,
+				ReplySerial: 1,
+				Length:      maxMessageSize + 1,
+			},
+			"exceeds maximum",
+		},
+		{
+			"header and body too large",
+			header{
+				Type:        msgTypeReturn,
+				Version:     1,
+				Serial:      1,
+				ReplySerial: 1,
+				Length:      maxMessageSize,
+			},
+			"exceeds maximum",
+		},
+		{
+			"header too deep",
+			header{
+				Type:        msgTypeReturn,
+				Version:     1,
+				Serial:      1,
+				ReplySerial: 1,
+				Unknown:     map[uint8]any{200: deepArray.Interface()},
+			},
+			"nested more than",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer b.Close()
+			c := newUnstartedConn(pipeTransport{a})
+			go c.readLoop()
+			defer c.Close()
+
+			enc := fragments.Encoder{
+				Order:  fragments.NativeEndian,
+				Mapper: encoderFor,
+			}
+			if err := enc.Value(context.Background(), &tc.hdr); err != nil {
+				t.Fatalf("encoding header: %v", err)
+			}
+			go b.Write(enc.Out)
+
+			waitDone(t, c)
+			if err := c.Err(); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Err() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestConnBadReplyNotFatal(t *testing.T) {
+	client, server := newPipeConns(t)
+	server.Handle("org.test", "Ping", func(context.Context, ObjectPath) error {
+		return nil
+	})
+
+	ctx := context.Background()
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	var s string
+	if err := iface.Call(ctx, "Ping", nil, &s); err == nil {
+		t.Fatal("Call() with mismatched response type succeeded")
+	}
+	if err := iface.Call(ctx, "Ping", nil); err != nil {
+		t.Fatalf("Call() after bad reply failed: %v", err)
+	}
+	if err := client.Err(); err != nil {
+		t.Fatalf("Err() after bad reply = %v, want nil", err)
+	}
+}
+
+func TestConnShutdown(t *testing.T) {
+	client, server := newPipeConns(t)
+	release := make(chan struct{})
+	server.Handle("org.test", "Slow", func(context.Context, ObjectPath) error {
+		<-release
+		return nil
+	})
+	server.Handle("org.test", "Ping", func(context.Context, ObjectPath) error {
+		return nil
+	})
+
+	ctx := context.Background()
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	slowErr := make(chan error, 1)
+	go func() {
+		slowErr <- iface.Call(ctx, "Slow", nil)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Stats().PendingCalls == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for call to be sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- client.Shutdown(ctx)
+	}()
+	for {
+		err := iface.Call(ctx, "Ping", nil)
+		if err == nil {
+			if time.Now().After(deadline) {
+				t.Fatal("Call() still accepted after Shutdown()")
+			}
+			continue
+		}
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Call() during Shutdown() = %v, want net.ErrClosed", err)
+		}
+		break
+	}
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown() returned %v with a call in flight", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if err := <-slowErr; err != nil {
+		t.Errorf("in-flight Call() failed: %v", err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown() failed: %v", err)
+	}
+	waitDone(t, client)
+	if err := client.Err(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Err() after Shutdown() = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestConnShutdownTimeout(t *testing.T) {
+	client, server := newPipeConns(t)
+	release := make(chan struct{})
+	defer close(release)
+	server.Handle("org.test", "Hang", func(context.Context, ObjectPath) error {
+		<-release
+		return nil
+	})
+
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	callErr := make(chan error, 1)
+	go func() {
+		callErr <- iface.Call(context.Background(), "Hang", nil)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Stats().PendingCalls == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for call to be sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := client.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want context.DeadlineExceeded", err)
+	}
+	if err := <-callErr; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("in-flight Call() after Shutdown() timeout = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestConnShutdownQueuedCalls(t *testing.T) {
+	client, server := newPipeConns(t)
+	if err := server.SetHandleOptions(HandleOptions{MaxConcurrent: 1, MaxQueued: 1}); err != nil {
+		t.Fatalf("SetHandleOptions() failed: %v", err)
+	}
+	release := make(chan struct{})
+	server.Handle("org.test", "Block", func(context.Context, ObjectPath) error {
+		<-release
+		return nil
+	})
+
+	waitQueued := func(queued int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for server.Stats().QueuedCalls != queued {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %d queued calls", queued)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	running, queued := make(chan error, 1), make(chan error, 1)
+	go func() { running <- iface.Call(context.Background(), "Block", nil) }()
+	for server.Stats().ActiveHandlers != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() { queued <- iface.Call(context.Background(), "Block", nil) }()
+	waitQueued(1)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown() returned %v with a call queued", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+
+	wait := func(ch <-chan error) error {
+		t.Helper()
+		select {
+		case err := <-ch:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Call() did not get a reply")
+			return nil
+		}
+	}
+	if err := wait(running); err != nil {
+		t.Errorf("running Call() failed: %v", err)
+	}
+	if err := wait(queued); !errors.Is(err, ErrFailed) {
+		t.Errorf("queued Call() during Shutdown() got err %v, want ErrFailed", err)
+	}
+	if err := wait(shutdownErr); err != nil {
+		t.Errorf("Shutdown() failed: %v", err)
+	}
+}
+
+func TestContextHeaderInHandler(t *testing.T) {
+	client, server := newPipeConns(t)
+	headers := make(chan *MessageHeader, 2)
+	server.Handle("org.test", "Get", func(ctx context.Context, _ ObjectPath) error {
+		hdr, ok := ContextHeader(ctx)
+		if !ok {
+			return errors.New("no header in context")
+		}
+		// Modifying the returned header must not affect what later
+		// callers see.
+		hdr.Member = "Modified"
+		hdr, _ = ContextHeader(ctx)
+		headers <- hdr
+		return nil
+	})
+
+	iface := client.Peer("org.test.Server").Object("/obj").Interface("org.test")
+	ctx := WithContextAutostart(context.Background(), false)
+	if err := iface.Call(ctx, "Get", nil); err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if err := iface.Call(context.Background(), "Get", nil); err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+
+	for _, wantFlags := range []MessageFlags{FlagNoAutoStart, 0} {
+		hdr := <-headers
+		if hdr.Type != MessageCall || hdr.Path != "/obj" || hdr.Interface != "org.test" || hdr.Member != "Get" || hdr.Destination != "org.test.Server" {
+			t.Errorf("ContextHeader() = %+v, want call to org.test.Server /obj org.test.Get", hdr)
+		}
+		if hdr.Flags != wantFlags {
+			t.Errorf("ContextHeader().Flags = %v, want %v", hdr.Flags, wantFlags)
+		}
+		if hdr.Serial == 0 {
+			t.Error("ContextHeader().Serial is zero")
+		}
+	}
+
+	if hdr, ok := ContextHeader(context.Background()); ok {
+		t.Errorf("ContextHeader() on empty context = %+v, want none", hdr)
+	}
+}
+
+func TestConnNoReplyExpected(t *testing.T) {
+	a, b := net.Pipe()
+	c := newUnstartedConn(pipeTransport{a})
+	go c.readLoop()
+	defer c.Close()
+	defer b.Close()
+
+	called := make(chan string, 2)
+	c.Handle("org.test", "Ok", func(context.Context, ObjectPath) (string, error) {
+		called <- "Ok"
+		return "hello", nil
+	})
+	c.Handle("org.test", "Fail", func(context.Context, ObjectPath) error {
+		called <- "Fail"
+		return errors.New("oops")
+	})
+
+	call := func(serial uint32, method string, flags byte) {
+		t.Helper()
+		raw := encodeTestMsg(t, fragments.NativeEndian, header{
+			Type:        msgTypeCall,
+			Flags:       flags,
+			Version:     1,
+			Serial:      serial,
+			Path:        "/",
+			Interface:   "org.test",
+			Member:      method,
+			Destination: "org.test.Server",
+		}, nil)
+		if _, err := b.Write(raw); err != nil {
+			t.Fatalf("writing call: %v", err)
+		}
+	}
+	call(1, "Ok", flagNoReplyExpected)
+	call(2, "Fail", flagNoReplyExpected)
+	call(3, "Missing", flagNoReplyExpected)
+	for range 2 {
+		<-called
+	}
+	// A regular call, whose reply must be the only one sent.
+	call(4, "Ok", 0)
+
+	b.SetReadDeadline(time.Now().Add(2 * time.Second))
+	m, err := DecodeMessage(b)
+	if err != nil {
+		t.Fatalf("reading reply: %v", err)
+	}
+	if m.Type != MessageReturn || m.ReplySerial != 4 {
+		t.Fatalf("got %s reply to serial %d, want Return to serial 4", m.Type, m.ReplySerial)
+	}
+	<-called
+
+	b.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if m, err := DecodeMessage(b); err == nil {
+		t.Errorf("got unexpected %s reply to serial %d", m.Type, m.ReplySerial)
+	}
+}
+
+func TestConnOneWayErrors(t *testing.T) {
+	a, b := net.Pipe()
+	c := newUnstartedConn(pipeTransport{a})
+	go c.readLoop()
+	defer c.Close()
+	iface := c.Peer("org.test").Object("/").Interface("org.test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := iface.OneWay(ctx, "Foo", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("OneWay with canceled context got err %v, want context.Canceled", err)
+	}
+	b.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if m, err := DecodeMessage(b); err == nil {
+		t.Errorf("canceled OneWay sent %s message", m.Type)
+	}
+
+	got := make(chan *Message, 1)
+	go func() {
+		b.SetReadDeadline(time.Now().Add(2 * time.Second))
+		m, err := DecodeMessage(b)
+		if err != nil {
+			t.Errorf("reading one-way call: %v", err)
+		}
+		got <- m
+	}()
+	if err := iface.OneWay(context.Background(), "Foo", nil); err != nil {
+		t.Fatalf("OneWay failed: %v", err)
+	}
+	if m := <-got; m != nil && (m.Member != "Foo" || m.Flags&FlagNoReplyExpected == 0) {
+		t.Errorf("got call to %s with flags %v, want one-way call to Foo", m.Member, m.Flags)
+	}
+
+	b.Close()
+	if err := iface.OneWay(context.Background(), "Foo", nil); err == nil {
+		t.Error("OneWay on broken connection succeeded")
+	}
+}
+
+func TestConnHandleOptions(t *testing.T) {
+	client, server := newPipeConns(t)
+	if err := server.SetHandleOptions(HandleOptions{MaxConcurrent: 1, MaxQueued: 1}); err != nil {
+		t.Fatalf("SetHandleOptions() failed: %v", err)
+	}
+	release := make(chan struct{})
+	server.Handle("org.test", "Block", func(context.Context, ObjectPath) error {
+		<-release
+		return nil
+	})
+
+	waitStats := func(active, queued int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			st := server.Stats()
+			if st.ActiveHandlers == active && st.QueuedCalls == queued {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %d active and %d queued calls, got %d and %d", active, queued, st.ActiveHandlers, st.QueuedCalls)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	errs := make(chan error, 2)
+	call := func() {
+		errs <- iface.Call(context.Background(), "Block", nil)
+	}
+	go call()
+	waitStats(1, 0)
+	go call()
+	waitStats(1, 1)
+
+	if err := iface.Call(context.Background(), "Block", nil); !errors.Is(err, ErrLimitsExceeded) {
+		t.Errorf("Call() over limit got err %v, want ErrLimitsExceeded", err)
+	}
+
+	release <- struct{}{}
+	waitStats(1, 0)
+	release <- struct{}{}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("Call() failed: %v", err)
+		}
+	}
+	waitStats(0, 0)
+
+	if err := server.SetHandleOptions(HandleOptions{MaxConcurrent: -1}); err == nil {
+		t.Error("SetHandleOptions() with negative limit succeeded, want error")
+	}
+}
+
+func TestConnHandlerTimeout(t *testing.T) {
+	client, server := newPipeConns(t)
+	if err := server.SetHandleOptions(HandleOptions{Timeout: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("SetHandleOptions() failed: %v", err)
+	}
+	server.Handle("org.test", "Slow", func(ctx context.Context, _ ObjectPath) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	server.Handle("org.test", "SlowCallError", func(ctx context.Context, _ ObjectPath) error {
+		<-ctx.Done()
+		return CallError{Name: "org.test.Error.Custom"}
+	})
+	server.Handle("org.test", "Fast", func(ctx context.Context, _ ObjectPath) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("handler context has no deadline")
+		}
+		return nil
+	})
+
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	ctx := context.Background()
+	if err := iface.Call(ctx, "Slow", nil); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Call(Slow) got err %v, want ErrTimeout", err)
+	}
+	if err := iface.Call(ctx, "SlowCallError", nil); !errors.Is(err, CallError{Name: "org.test.Error.Custom"}) {
+		t.Errorf("Call(SlowCallError) got err %v, want org.test.Error.Custom", err)
+	}
+	if err := iface.Call(ctx, "Fast", nil); err != nil {
+		t.Errorf("Call(Fast) failed: %v", err)
+	}
+
+	if err := server.SetHandleOptions(HandleOptions{Timeout: -1}); err == nil {
+		t.Error("SetHandleOptions() with negative timeout succeeded, want error")
+	}
+}
+
+func TestConnTrace(t *testing.T) {
+	a, b := net.Pipe()
+	client, server := newUnstartedConn(pipeTransport{a}), newUnstartedConn(pipeTransport{b})
+	var (
+		mu     sync.Mutex
+		traces []MessageTrace
+	)
+	client.trace = func(m MessageTrace) {
+		mu.Lock()
+		defer mu.Unlock()
+		traces = append(traces, m)
+	}
+	go client.readLoop()
+	go server.readLoop()
+	defer client.Close()
+	defer server.Close()
+
+	server.Handle("org.test", "Echo", func(_ context.Context, _ ObjectPath, s string) (string, error) {
+		return s, nil
+	})
+	var got string
+	if err := client.Peer("org.test.Server").Object("/foo").Interface("org.test").Call(context.Background(), "Echo", "hello", &got); err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(traces) != 2 {
+		t.Fatalf("got %d traces, want 2: %+v", len(traces), traces)
+	}
+	call, ret := traces[0], traces[1]
+	if !call.Sent || call.Type != MessageCall || call.Path != "/foo" || call.Interface != "org.test" || call.Member != "Echo" || call.Signature.String() != "s" || call.Length != 10 {
+		t.Errorf("wrong trace for sent call: %+v", call)
+	}
+	if ret.Sent || ret.Type != MessageReturn || ret.ReplySerial != call.Serial || ret.Signature.String() != "s" {
+		t.Errorf("wrong trace for received return: %+v", ret)
+	}
+}
+
+func TestConnVoidCall(t *testing.T) {
+	a, b := net.Pipe()
+	client, server := newUnstartedConn(pipeTransport{a}), newUnstartedConn(pipeTransport{b})
+	var (
+		mu     sync.Mutex
+		traces []MessageTrace
+	)
+	client.trace = func(m MessageTrace) {
+		mu.Lock()
+		defer mu.Unlock()
+		traces = append(traces, m)
+	}
+	go client.readLoop()
+	go server.readLoop()
+	defer client.Close()
+	defer server.Close()
+
+	called := false
+	server.Handle("org.test", "Void", func(context.Context, ObjectPath) error {
+		called = true
+		return nil
+	})
+	iface := client.Peer("org.test.Server").Object("/foo").Interface("org.test")
+	if err := iface.Call(context.Background(), "Void", nil, nil); err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if !called {
+		t.Error("handler was not called")
+	}
+
+	// Empty structs are also void message bodies.
+	server.Handle("org.test", "Empty", func(context.Context, ObjectPath, struct{}) (struct{}, error) {
+		return struct{}{}, nil
+	})
+	var resp struct{}
+	if err := iface.Call(context.Background(), "Empty", struct{}{}, &resp); err != nil {
+		t.Fatalf("Call() with empty struct body failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(traces) != 4 {
+		t.Fatalf("got %d traces, want 4: %+v", len(traces), traces)
+	}
+	for _, tr := range traces {
+		if !tr.Signature.IsZero() || tr.Length != 0 {
+			t.Errorf("void message has signature %q and length %d, want empty", tr.Signature, tr.Length)
+		}
+	}
+}
+
+func TestConnCallReuseBytes(t *testing.T) {
+	client, server := newPipeConns(t)
+	server.Handle("org.test", "Bytes", func(context.Context, ObjectPath) ([]byte, error) {
+		return []byte{1, 2, 3}, nil
+	})
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+
+	call := func(ctx context.Context) (got, prev []byte) {
+		prev = make([]byte, 1, 8)
+		got = prev
+		if err := iface.Call(ctx, "Bytes", nil, &got); err != nil {
+			t.Fatalf("Call() failed: %v", err)
+		}
+		if !slices.Equal(got, []byte{1, 2, 3}) {
+			t.Fatalf("Call() got %v, want [1 2 3]", got)
+		}
+		return got, prev
+	}
+
+	got, prev := call(context.Background())
+	if &got[0] == &prev[0] {
+		t.Error("Call() reused []byte storage without opt-in")
+	}
+	got, prev = call(WithContextReuseBytes(context.Background(), true))
+	if &got[0] != &prev[0] {
+		t.Error("Call() with WithContextReuseBytes did not reuse []byte storage")
+	}
+}
+
+// rawBool encodes as a DBus boolean with an arbitrary wire value.
+type rawBool uint32
+
+func (rawBool) SignatureDBus() Signature { return mustParseSignature("b") }
+
+func (b rawBool) MarshalDBus(ctx context.Context, e *fragments.Encoder) error {
+	e.Uint32(uint32(b))
+	return nil
+}
+
+func TestConnCallStrictBooleans(t *testing.T) {
+	client, server := newPipeConns(t)
+	server.Handle("org.test", "Bool", func(context.Context, ObjectPath) (rawBool, error) {
+		return 2, nil
+	})
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+
+	var got bool
+	if err := iface.Call(context.Background(), "Bool", nil, &got); err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if !got {
+		t.Error("Call() decoded boolean 2 as false, want true")
+	}
+
+	ctx := WithContextStrictBooleans(context.Background(), true)
+	if err := iface.Call(ctx, "Bool", nil, &got); err == nil {
+		t.Error("Call() with WithContextStrictBooleans accepted boolean 2, want error")
+	}
+}
+
+// rawString encodes as a DBus string without validating its
+// contents.
+type rawString string
+
+func (rawString) SignatureDBus() Signature { return mustParseSignature("s") }
+
+func (s rawString) MarshalDBus(ctx context.Context, e *fragments.Encoder) error {
+	e.String(string(s))
+	return nil
+}
+
+func TestConnCallStrictStrings(t *testing.T) {
+	client, server := newPipeConns(t)
+	server.Handle("org.test", "String", func(context.Context, ObjectPath) (rawString, error) {
+		return "bad\xff", nil
+	})
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+
+	var got string
+	if err := iface.Call(context.Background(), "String", nil, &got); err == nil {
+		t.Errorf("Call() accepted invalid UTF-8 %q, want error", got)
+	}
+
+	ctx := WithContextStrictStrings(context.Background(), false)
+	if err := iface.Call(ctx, "String", nil, &got); err != nil {
+		t.Fatalf("Call() with lenient strings failed: %v", err)
+	}
+	if want := "bad\xff"; got != want {
+		t.Errorf("Call() with lenient strings got %q, want %q", got, want)
+	}
+}
+
+// sortProbe encodes as a uint32, and records whether the encoding
+// context asked for sorted maps.
+type sortProbe struct{ sorted chan bool }
+
+func (sortProbe) SignatureDBus() Signature { return mustParseSignature("u") }
+
+func (p sortProbe) MarshalDBus(ctx context.Context, e *fragments.Encoder) error {
+	p.sorted <- contextSortMaps(ctx)
+	e.Uint32(42)
+	return nil
+}
+
+func TestConnCallEncodingContext(t *testing.T) {
+	client, server := newPipeConns(t)
+	server.Handle("org.test", "Take", func(context.Context, ObjectPath, uint32) error {
+		return nil
+	})
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+
+	probe := sortProbe{make(chan bool, 1)}
+	ctx := WithContextSortedMaps(context.Background(), false)
+	if err := iface.Call(ctx, "Take", probe); err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if <-probe.sorted {
+		t.Error("Call() encoded its body without the caller's context")
+	}
+	if err := iface.OneWay(ctx, "Take", probe); err != nil {
+		t.Fatalf("OneWay() failed: %v", err)
+	}
+	if <-probe.sorted {
+		t.Error("OneWay() encoded its body without the caller's context")
+	}
+}
+
+func TestConnCallRaw(t *testing.T) {
+	client, server := newPipeConns(t)
+	type req struct {
+		S string
+		N uint32
+	}
+	server.Handle("org.test", "Repeat", func(_ context.Context, _ ObjectPath, r req) (string, error) {
+		return strings.Repeat(r.S, int(r.N)), nil
+	})
+
+	enc := fragments.Encoder{Order: fragments.NativeEndian}
+	enc.String("ab")
+	enc.Uint32(3)
+	sig := mustParseSignature("su")
+
+	ctx := context.Background()
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+	var got string
+	if err := iface.CallRaw(ctx, "Repeat", sig, enc.Out, &got); err != nil {
+		t.Fatalf("CallRaw() failed: %v", err)
+	}
+	if want := "ababab"; got != want {
+		t.Errorf("CallRaw() got %q, want %q", got, want)
+	}
+
+	bad := []struct {
+		name string
+		sig  Signature
+		body []byte
+	}{
+		{"truncated body", sig, enc.Out[:len(enc.Out)-1]},
+		{"trailing data", sig, append(slices.Clone(enc.Out), 0)},
+		{"body without signature", Signature{}, enc.Out},
+		{"file descriptors", mustParseSignature("h"), []byte{0, 0, 0, 0}},
+	}
+	for _, tc := range bad {
+		if err := iface.CallRaw(ctx, "Repeat", tc.sig, tc.body, &got); err == nil {
+			t.Errorf("CallRaw() with %s succeeded, want error", tc.name)
+		}
+	}
+}
+
+// socketPair returns a connected pair of Unix sockets.
+func socketPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	t.Helper()
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("creating socket pair: %v", err)
+	}
+	var ret [2]*net.UnixConn
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("creating socket conn: %v", err)
+		}
+		ret[i] = c.(*net.UnixConn)
+		t.Cleanup(func() { c.Close() })
+	}
+	return ret[0], ret[1]
+}
+
+// fakeServerAuth performs the server side of a minimal DBus auth
+// handshake on c, accepting whatever the client offers.
+func fakeServerAuth(c net.Conn) error {
+	var got []byte
+	buf := make([]byte, 1)
+	for !bytes.HasSuffix(got, []byte("BEGIN\r\n")) {
+		if _, err := c.Read(buf); err != nil {
+			return err
+		}
+		got = append(got, buf[0])
+	}
+	resp := "OK 0123456789abcdef0123456789abcdef\r\n"
+	if bytes.Contains(got, []byte("NEGOTIATE_UNIX_FD")) {
+		resp += "AGREE_UNIX_FD\r\n"
+	}
+	_, err := io.WriteString(c, resp)
+	return err
+}
+
+func TestConnPeerToPeer(t *testing.T) {
+	a, b := socketPair(t)
+	authErr := make(chan error, 1)
+	go func() { authErr <- fakeServerAuth(b) }()
+
+	ctx := context.Background()
+	client, err := ConnectWithOptions(ctx, a, DialOptions{PeerToPeer: true})
+	if err != nil {
+		t.Fatalf("ConnectWithOptions() failed: %v", err)
+	}
+	defer client.Close()
+	if err := <-authErr; err != nil {
+		t.Fatalf("server auth failed: %v", err)
+	}
+	server := newUnstartedConn(pipeTransport{b})
+	server.peerToPeer = true
+	go server.readLoop()
+	defer server.Close()
+
+	if got := client.LocalName(); got != "" {
+		t.Errorf("peer-to-peer LocalName() = %q, want empty", got)
+	}
+
+	server.Handle("org.test", "Echo", func(_ context.Context, _ ObjectPath, s string) (string, error) {
+		return s, nil
+	})
+	var got string
+	if err := client.Peer("").Object("/").Interface("org.test").Call(ctx, "Echo", "hello", &got); err != nil {
+		t.Fatalf("Call() to peer failed: %v", err)
+	}
+	if got != "hello" {
+		t.Errorf("Call() to peer got %q, want %q", got, "hello")
+	}
+
+	// Calls in the other direction work too, using the built-in
+	// Peer interface.
+	if err := server.Peer("").Ping(ctx); err != nil {
+		t.Errorf("Ping() of client failed: %v", err)
+	}
+
+	w, err := client.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Close()
+	if _, err := w.Match(MatchAllSignals().Interface("org.test")); err != nil {
+		t.Fatalf("adding match without a bus failed: %v", err)
+	}
+	if err := server.EmitRawSignal(ctx, "/foo", "org.test", "Hello", "world"); err != nil {
+		t.Fatalf("EmitRawSignal() failed: %v", err)
+	}
+	select {
+	case n := <-w.Chan():
+		if n.Name != "Hello" || n.Sender.Object().Path() != "/foo" {
+			t.Errorf("got signal %s from %s, want Hello from /foo", n.Name, n.Sender.Object().Path())
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("timed out waiting for signal from peer")
+	}
+}
+
+func TestConnHandlerPanic(t *testing.T) {
+	a, b := net.Pipe()
+	client, server := newUnstartedConn(pipeTransport{a}), newUnstartedConn(pipeTransport{b})
+	var logs bytes.Buffer
+	server.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	go client.readLoop()
+	go server.readLoop()
+	defer client.Close()
+	defer server.Close()
+
+	server.Handle("org.test", "Panic", func(context.Context, ObjectPath) error {
+		panic("secret internal state")
+	})
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+
+	err := iface.Call(context.Background(), "Panic", nil)
+	if !errors.Is(err, ErrFailed) {
+		t.Errorf("Call() to panicking handler got err %v, want ErrFailed", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "secret") {
+		t.Errorf("Call() error %q includes panic value", err)
+	}
+	if got := logs.String(); !strings.Contains(got, "panic in method handler") || !strings.Contains(got, "secret internal state") {
+		t.Errorf("handler panic not logged to Conn logger, got logs: %s", got)
+	}
+
+	want := CallError{Name: "org.test.Error.Panicked"}
+	server.SetHandleOptions(HandleOptions{PanicErrorName: want.Name})
+	if err := iface.Call(context.Background(), "Panic", nil); !errors.Is(err, want) {
+		t.Errorf("Call() to panicking handler got err %v, want %v", err, want)
+	}
+
+	// The server must still be working.
+	if err := server.Err(); err != nil {
+		t.Errorf("server failed after handler panic: %v", err)
+	}
+}
+
+func TestConnHangupLogLevel(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		hangup    func(net.Conn)
+		wantLevel string
+	}{
+		{"clean hangup", func(c net.Conn) { c.Close() }, "level=DEBUG"},
+		{"protocol error", func(c net.Conn) { c.Write([]byte("garbage!garbage!")) }, "level=ERROR"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer b.Close()
+			conn := newUnstartedConn(pipeTransport{a})
+			var logs bytes.Buffer
+			conn.logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			go conn.readLoop()
+			defer conn.Close()
+
+			tc.hangup(b)
+			waitDone(t, conn)
+			if got := logs.String(); !strings.Contains(got, tc.wantLevel) {
+				t.Errorf("connection loss not logged at %s, got logs: %s", tc.wantLevel, got)
+			}
+			if tc.wantLevel == "level=DEBUG" && strings.Contains(logs.String(), "level=ERROR") {
+				t.Errorf("clean hangup logged as an error: %s", logs.String())
+			}
+		})
+	}
+}
+
+func TestConnKeepalive(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		client, bus := newPipeConns(t)
+		bus.Handle(ifacePeer, "Ping", func(context.Context, ObjectPath) error {
+			return nil
+		})
+		go client.keepalive(5*time.Millisecond, time.Second, 2)
+		time.Sleep(50 * time.Millisecond)
+		if err := client.Err(); err != nil {
+			t.Fatalf("Conn with healthy keepalive failed: %v", err)
+		}
+		if got := bus.Stats().MessagesReceived; got == 0 {
+			t.Error("bus received no keepalive pings")
+		}
+	})
+
+	t.Run("unresponsive", func(t *testing.T) {
+		// A "bus" that accepts writes, but never responds.
+		a, b := net.Pipe()
+		go io.Copy(io.Discard, b)
+		client := newUnstartedConn(pipeTransport{a})
+		go client.readLoop()
+		t.Cleanup(func() {
+			client.Close()
+			b.Close()
+		})
+
+		iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+		callErr := make(chan error, 1)
+		go func() {
+			callErr <- iface.Call(context.Background(), "Hang", nil)
+		}()
+
+		// Keep writing while keepalive shuts the Conn down, so that
+		// the race detector sees shutdown racing with writes.
+		stopWriting := make(chan struct{})
+		defer close(stopWriting)
+		go func() {
+			for {
+				select {
+				case <-stopWriting:
+					return
+				default:
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				iface.Call(ctx, "Spin", nil)
+				cancel()
+			}
+		}()
+
+		go client.keepalive(5*time.Millisecond, 5*time.Millisecond, 3)
+		waitDone(t, client)
+		if err := client.Err(); err == nil || !strings.Contains(err.Error(), "keepalive") {
+			t.Errorf("Err() after failed keepalive = %v, want keepalive error", err)
+		}
+		select {
+		case err := <-callErr:
+			if !errors.Is(err, net.ErrClosed) {
+				t.Errorf("pending Call() after failed keepalive = %v, want net.ErrClosed", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("pending Call() did not fail after failed keepalive")
+		}
+	})
+}
+
+func BenchmarkWriteMsg(b *testing.B) {
+	c := &Conn{
+		t: discardTransport{},
+		enc: fragments.Encoder{
+			Order:  fragments.NativeEndian,
+			Mapper: encoderFor,
+		},
+	}
+	hdr := header{
+		Type:        msgTypeCall,
+		Version:     1,
+		Path:        "/org/test/Object",
+		Interface:   "org.test.Interface",
+		Member:      "Method",
+		Destination: "org.test.Peer",
+	}
+	body := Simple{A: 42, B: true}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := range b.N {
+		hdr.Serial = uint32(i + 1)
+		if err := c.writeMsg(ctx, &hdr, body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestConnHandlerErrors(t *testing.T) {
+	client, server := newPipeConns(t)
+	ctx := context.Background()
+
+	custom := CallError{Name: "org.test.Error.Custom", Detail: "custom detail"}
+	server.Handle("org.test", "CallError", func(context.Context, ObjectPath) error {
+		return custom
+	})
+	server.Handle("org.test", "Wrapped", func(context.Context, ObjectPath) error {
+		return fmt.Errorf("wrapped: %w", custom)
+	})
+	server.Handle("org.test", "Plain", func(context.Context, ObjectPath) error {
+		return errors.New("plain failure")
+	})
+	iface := client.Peer("org.test.Server").Object("/").Interface("org.test")
+
+	tests := []struct {
+		method string
+		want   CallError
+	}{
+		{"CallError", custom},
+		{"Wrapped", custom},
+		{"Plain", CallError{Name: ErrFailed.Name, Detail: "plain failure"}},
+		{"Missing", CallError{Name: ErrUnknownMethod.Name, Detail: "no such method"}},
+	}
+	for _, tc := range tests {
+		err := iface.Call(ctx, tc.method, nil)
+		var got CallError
+		if !errors.As(err, &got) {
+			t.Errorf("Call(%s) got err %v, want CallError", tc.method, err)
+			continue
+		}
+		if got.Name != tc.want.Name || got.Detail != tc.want.Detail {
+			t.Errorf("Call(%s) got error %q (%q), want %q (%q)", tc.method, got.Name, got.Detail, tc.want.Name, tc.want.Detail)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"os"
+
+	"github.com/danderson/dbus/fragments"
 )
 
 func getCtx[T any](ctx context.Context, key any) (ret T, ok bool) {
@@ -19,10 +21,7 @@ func getCtx[T any](ctx context.Context, key any) (ret T, ok bool) {
 
 func withContextHeader(ctx context.Context, conn *Conn, hdr *header) context.Context {
 	if hdr.Sender != "" {
-		ctx = context.WithValue(ctx, senderContextKey{}, conn.Peer(hdr.Sender))
-		if hdr.Type == msgTypeSignal && hdr.Path != "" && hdr.Interface != "" {
-			ctx = context.WithValue(ctx, emitterContextKey{}, conn.Peer(hdr.Sender).Object(hdr.Path).Interface(hdr.Interface))
-		}
+		ctx = withContextSender(ctx, conn.Peer(hdr.Sender), hdr)
 	}
 	if hdr.Destination != "" {
 		ctx = context.WithValue(ctx, destContextKey{}, conn.Peer(hdr.Destination))
@@ -30,6 +29,51 @@ func withContextHeader(ctx context.Context, conn *Conn, hdr *header) context.Con
 	return ctx
 }
 
+// withContextSender augments ctx with sender as the sender of the
+// message described by hdr.
+func withContextSender(ctx context.Context, sender Peer, hdr *header) context.Context {
+	ctx = context.WithValue(ctx, senderContextKey{}, sender)
+	if hdr.Type == msgTypeSignal && hdr.Path != "" && hdr.Interface != "" {
+		ctx = context.WithValue(ctx, emitterContextKey{}, sender.Object(hdr.Path).Interface(hdr.Interface))
+	}
+	return ctx
+}
+
+// headerContextKey is the context key that carries the header of an
+// incoming DBus message.
+type headerContextKey struct{}
+
+// contextHeader is the value carried by headerContextKey.
+type contextHeader struct {
+	hdr   *header
+	order fragments.ByteOrder
+}
+
+// withContextIncomingHeader augments ctx with the header of an
+// incoming message.
+func withContextIncomingHeader(ctx context.Context, msg *msg) context.Context {
+	return context.WithValue(ctx, headerContextKey{}, contextHeader{&msg.header, msg.order})
+}
+
+// ContextHeader returns the header of the incoming message found in
+// ctx, and reports whether a header was found.
+//
+// The header is available to method handlers registered with
+// [Conn.Handle], and in the context of [Unmarshaler]'s UnmarshalDBus
+// method when receiving messages. For example, a handler can check
+// the header's [FlagNoReplyExpected] flag to skip work whose only
+// purpose is to produce a reply.
+//
+// The returned MessageHeader is a copy, which the caller may modify
+// freely.
+func ContextHeader(ctx context.Context) (*MessageHeader, bool) {
+	h, ok := getCtx[contextHeader](ctx, headerContextKey{})
+	if !ok {
+		return nil, false
+	}
+	return newMessageHeader(h.hdr, h.order), true
+}
+
 func withContextEmitter(ctx context.Context, emitter Interface) context.Context {
 	return context.WithValue(ctx, emitterContextKey{}, emitter)
 }
@@ -160,10 +204,164 @@ func WithContextAutostart(ctx context.Context, allow bool) context.Context {
 
 func contextCallFlags(ctx context.Context) (flags byte) {
 	if v, ok := ctx.Value(allowInteractionContextKey{}).(bool); ok && v {
-		flags |= 0x4
+		flags |= flagAllowInteractiveAuthz
 	}
 	if v, ok := ctx.Value(blockAutostartContextKey{}).(bool); ok && v {
-		flags |= 0x2
+		flags |= flagNoAutoStart
 	}
 	return flags
 }
+
+type unsortedMapsContextKey struct{}
+
+// WithContextSortedMaps returns a copy of the parent context with
+// map key sorting set according to sorted.
+//
+// By default, maps are encoded with their keys in sorted order, so
+// that the encoding of a value is deterministic. For very large maps,
+// sorting can be a significant fraction of the encoding cost. If the
+// recipient doesn't care about ordering, WithContextSortedMaps can
+// disable sorting, in which case maps are encoded in Go's unspecified
+// map iteration order.
+//
+// Go maps do not record insertion order, so no setting preserves it.
+// Types that need a specific ordering can implement [Marshaler].
+func WithContextSortedMaps(parent context.Context, sorted bool) context.Context {
+	return context.WithValue(parent, unsortedMapsContextKey{}, !sorted)
+}
+
+// contextSortMaps reports whether maps should be encoded with sorted
+// keys.
+func contextSortMaps(ctx context.Context) bool {
+	unsorted, _ := getCtx[bool](ctx, unsortedMapsContextKey{})
+	return !unsorted
+}
+
+type strictBooleansContextKey struct{}
+
+// WithContextStrictBooleans returns a copy of the parent context with
+// boolean decoding strictness set according to strict.
+//
+// The DBus specification requires booleans to be encoded as exactly
+// 0 or 1. By default, decoding is lenient and treats any nonzero
+// value as true. With strict decoding, other values are rejected
+// with an error, which can help find encoding bugs in peers.
+//
+// For method calls, strictness applies to the decoding of the
+// response when set on the context passed to [Interface.Call] and
+// its variants.
+func WithContextStrictBooleans(parent context.Context, strict bool) context.Context {
+	return context.WithValue(parent, strictBooleansContextKey{}, strict)
+}
+
+// contextStrictBooleans reports whether booleans should be decoded
+// strictly.
+func contextStrictBooleans(ctx context.Context) bool {
+	ret, _ := getCtx[bool](ctx, strictBooleansContextKey{})
+	return ret
+}
+
+type reuseBytesContextKey struct{}
+
+// WithContextReuseBytes returns a copy of the parent context with
+// byte slice storage reuse set according to reuse.
+//
+// By default, decoding a DBus byte array into a []byte always
+// allocates a new slice. With reuse enabled, the bytes are instead
+// written into the destination slice's existing backing array when
+// it has enough capacity, which avoids allocating when repeatedly
+// decoding into the same value.
+//
+// Reuse overwrites the previous contents of the backing array, so
+// every other slice that shares it, such as a slice kept from an
+// earlier decode, sees the new bytes as well. Only enable reuse
+// when the destination's storage is not shared.
+//
+// For method calls, reuse applies to the decoding of the response
+// when set on the context passed to [Interface.Call] and its
+// variants.
+func WithContextReuseBytes(parent context.Context, reuse bool) context.Context {
+	return context.WithValue(parent, reuseBytesContextKey{}, reuse)
+}
+
+// contextReuseBytes reports whether decoding a byte array may reuse
+// the destination's storage.
+func contextReuseBytes(ctx context.Context) bool {
+	ret, _ := getCtx[bool](ctx, reuseBytesContextKey{})
+	return ret
+}
+
+type lenientStringsContextKey struct{}
+
+// WithContextStrictStrings returns a copy of the parent context with
+// string validation set according to strict.
+//
+// The DBus specification requires strings to be valid UTF-8 with no
+// embedded NUL bytes. By default, decoding a string that violates
+// these rules returns an error. For interoperability with peers that
+// send malformed strings, WithContextStrictStrings can disable the
+// check, in which case such strings are decoded as-is.
+//
+// For method calls, validation applies to the decoding of the
+// response according to the context passed to [Interface.Call] and
+// its variants.
+func WithContextStrictStrings(parent context.Context, strict bool) context.Context {
+	return context.WithValue(parent, lenientStringsContextKey{}, !strict)
+}
+
+// contextStrictStrings reports whether decoded strings should be
+// validated.
+func contextStrictStrings(ctx context.Context) bool {
+	lenient, _ := getCtx[bool](ctx, lenientStringsContextKey{})
+	return !lenient
+}
+
+// decodeOptions are the options for decoding a message body that a
+// context carries. The zero value is the default behavior.
+type decodeOptions struct {
+	reuseBytes     bool // see WithContextReuseBytes
+	strictBools    bool // see WithContextStrictBooleans
+	lenientStrings bool // see WithContextStrictStrings
+}
+
+// contextDecodeOptions returns the decoding options set on ctx.
+func contextDecodeOptions(ctx context.Context) decodeOptions {
+	return decodeOptions{
+		reuseBytes:     contextReuseBytes(ctx),
+		strictBools:    contextStrictBooleans(ctx),
+		lenientStrings: !contextStrictStrings(ctx),
+	}
+}
+
+// withDecodeOptions returns a copy of ctx with the non-default
+// options in opts applied. Options that opts leaves at their default
+// keep the value that ctx already has.
+func withDecodeOptions(ctx context.Context, opts decodeOptions) context.Context {
+	if opts.reuseBytes {
+		ctx = WithContextReuseBytes(ctx, true)
+	}
+	if opts.strictBools {
+		ctx = WithContextStrictBooleans(ctx, true)
+	}
+	if opts.lenientStrings {
+		ctx = WithContextStrictStrings(ctx, false)
+	}
+	return ctx
+}
+
+// cleanupContextKey is the context key that marks a call as part of
+// releasing the Conn's bus resources.
+type cleanupContextKey struct{}
+
+// withContextCleanup marks ctx as belonging to a cleanup call, such
+// as removing a match rule or releasing a bus name. Cleanup calls are
+// allowed while [Conn.Shutdown] is refusing new calls.
+func withContextCleanup(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cleanupContextKey{}, true)
+}
+
+// contextIsCleanup reports whether ctx belongs to a cleanup call.
+func contextIsCleanup(ctx context.Context) bool {
+	ret, _ := getCtx[bool](ctx, cleanupContextKey{})
+	return ret
+}
@@ -170,3 +170,48 @@ func TestContextFile(t *testing.T) {
 		t.Fatalf("wrong file received, got %p, want %p", got, add)
 	}
 }
+
+func TestContextCallFlags(t *testing.T) {
+	bg := context.Background()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want byte
+	}{
+		{"default", bg, 0},
+		{"interaction", WithContextUserInteraction(bg, true), flagAllowInteractiveAuthz},
+		{"no interaction", WithContextUserInteraction(bg, false), 0},
+		{"autostart", WithContextAutostart(bg, true), 0},
+		{"no autostart", WithContextAutostart(bg, false), flagNoAutoStart},
+		{"both", WithContextAutostart(WithContextUserInteraction(bg, true), false), flagAllowInteractiveAuthz | flagNoAutoStart},
+		{"override", WithContextAutostart(WithContextAutostart(bg, false), true), 0},
+	}
+
+	for _, tc := range tests {
+		if got := contextCallFlags(tc.ctx); got != tc.want {
+			t.Errorf("contextCallFlags(%s) = %#x, want %#x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestContextDecodeOptions(t *testing.T) {
+	if got := contextDecodeOptions(context.Background()); got != (decodeOptions{}) {
+		t.Errorf("contextDecodeOptions(Background) = %+v, want defaults", got)
+	}
+
+	caller := WithContextReuseBytes(context.Background(), true)
+	caller = WithContextStrictStrings(caller, false)
+	opts := contextDecodeOptions(caller)
+	want := decodeOptions{reuseBytes: true, lenientStrings: true}
+	if opts != want {
+		t.Errorf("contextDecodeOptions() = %+v, want %+v", opts, want)
+	}
+
+	// Applying options keeps those already set on the target.
+	reply := WithContextStrictBooleans(context.Background(), true)
+	reply = withDecodeOptions(reply, opts)
+	want.strictBools = true
+	if got := contextDecodeOptions(reply); got != want {
+		t.Errorf("contextDecodeOptions(withDecodeOptions()) = %+v, want %+v", got, want)
+	}
+}
@@ -10,10 +10,13 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -47,6 +50,9 @@ type Bus struct {
 	stop       chan struct{}
 	busStopped chan struct{}
 	monStopped chan struct{}
+
+	emitterMu sync.Mutex
+	emitter   *dbus.Conn
 }
 
 // New launches a DBus instance dedicated to the calling test.
@@ -113,13 +119,18 @@ func New(t *testing.T, logMonitor bool) *Bus {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	// The socket file appears when the bus binds it, slightly before
+	// the bus starts listening, so wait for a connection to succeed
+	// rather than for the file to exist.
 	for ctx.Err() == nil {
-		if _, err := os.Stat(ret.sock); err == nil {
+		conn, err := net.Dial("unix", ret.sock)
+		if err == nil {
+			conn.Close()
 			break
-		} else if errors.Is(err, fs.ErrNotExist) {
+		} else if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
 			time.Sleep(10 * time.Millisecond)
 			continue
-		} else if err != nil {
+		} else {
 			t.Fatalf("waiting for bus socket: %v", err)
 		}
 	}
@@ -156,6 +167,11 @@ func New(t *testing.T, logMonitor bool) *Bus {
 }
 
 func (b *Bus) close() {
+	b.emitterMu.Lock()
+	if b.emitter != nil {
+		b.emitter.Close()
+	}
+	b.emitterMu.Unlock()
 	close(b.stop)
 	b.bus.Process.Kill()
 	if b.mon != nil {
@@ -192,6 +208,25 @@ func (b *Bus) MustConn(t *testing.T) *dbus.Conn {
 	return ret
 }
 
+// EmitSignal broadcasts signal from obj on the bus. It causes an
+// immediate test failure with t.Fatal if the signal cannot be sent.
+//
+// The signal is sent by a connection dedicated to emitting signals,
+// so that watchers on other connections receive it as they would a
+// signal from an unrelated peer. The signal's type must be registered
+// with [dbus.RegisterSignalType].
+func (b *Bus) EmitSignal(t *testing.T, obj dbus.ObjectPath, signal any) {
+	t.Helper()
+	b.emitterMu.Lock()
+	defer b.emitterMu.Unlock()
+	if b.emitter == nil {
+		b.emitter = b.MustConn(t)
+	}
+	if err := b.emitter.EmitSignal(context.Background(), obj, signal); err != nil {
+		t.Fatalf("emitting signal %T from %s: %v", signal, obj, err)
+	}
+}
+
 type logWriter struct {
 	output chan struct{}
 	t      *testing.T
@@ -3,7 +3,9 @@ package dbustest_test
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/danderson/dbus"
 	"github.com/danderson/dbus/dbustest"
 )
 
@@ -14,3 +16,25 @@ func TestBus(t *testing.T) {
 		t.Fatalf("failed to ping test bus: %v", err)
 	}
 }
+
+func TestBusEmitSignal(t *testing.T) {
+	b := dbustest.New(t, false)
+	conn := b.MustConn(t)
+	defer conn.Close()
+
+	sigs, cancel, err := dbus.Signals[fakeSignal](conn)
+	if err != nil {
+		t.Fatalf("Signals() failed: %v", err)
+	}
+	defer cancel()
+
+	b.EmitSignal(t, "/test", fakeSignal{"injected"})
+	select {
+	case got := <-sigs:
+		if got.Msg != "injected" {
+			t.Fatalf("got signal %q, want %q", got.Msg, "injected")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for signal")
+	}
+}
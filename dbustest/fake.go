@@ -0,0 +1,773 @@
+package dbustest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/fragments"
+)
+
+// Fake is an in-process imitation of a message bus, for fast unit
+// tests that don't need a real dbus-daemon.
+//
+// Fake routes messages between the connections returned by
+// [Fake.MustConn], and implements a subset of the
+// org.freedesktop.DBus interface: Hello, AddMatch, RemoveMatch,
+// GetId, GetNameOwner, NameHasOwner, ListNames, RequestName and
+// ReleaseName.
+//
+// Fake is not a complete bus implementation. Broadcast signals are
+// delivered to connections with a matching match rule, but the Fake
+// does not support eavesdropping. Name ownership has no queueing:
+// RequestName either acquires an unowned name or reports that the
+// name already has an owner. Connections to a Fake cannot send or
+// receive file descriptors.
+type Fake struct {
+	id string
+
+	mu         sync.Mutex
+	closed     bool
+	lastID     int
+	lastSerial uint32
+	clients    map[string]*fakeClient // unique name -> client
+	owners     map[string]*fakeClient // well-known name -> owner
+	servers    map[string]*dbus.Conn  // well-known name -> Handle conn
+	conns      []*dbus.Conn
+	emitter    *dbus.Conn
+}
+
+// NewFake returns a new Fake bus, which is shut down when the calling
+// test completes.
+func NewFake(t *testing.T) *Fake {
+	var id [16]byte
+	rand.Read(id[:])
+	ret := &Fake{
+		id:      hex.EncodeToString(id[:]),
+		clients: map[string]*fakeClient{},
+		owners:  map[string]*fakeClient{},
+		servers: map[string]*dbus.Conn{},
+	}
+	t.Cleanup(ret.close)
+	return ret
+}
+
+func (f *Fake) close() {
+	f.mu.Lock()
+	f.closed = true
+	conns := f.conns
+	clients := slices.Collect(maps.Values(f.clients))
+	f.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+	for _, c := range clients {
+		c.conn.Close()
+	}
+}
+
+// MustConn returns a connection to the fake bus. It causes an
+// immediate test failure with t.Fatal if it is unable to connect.
+//
+// The connection is closed when the fake bus shuts down, if it is
+// still open.
+func (f *Fake) MustConn(t *testing.T) *dbus.Conn {
+	t.Helper()
+	ret, err := f.conn()
+	if err != nil {
+		t.Fatalf("connecting to fake bus: %v", err)
+	}
+	return ret
+}
+
+func (f *Fake) conn() (*dbus.Conn, error) {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	f.mu.Unlock()
+
+	client, server := net.Pipe()
+	go f.serve(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ret, err := dbus.Connect(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conns = append(f.conns, ret)
+	return ret, nil
+}
+
+// Handle serves calls to methodName on interfaceName, for objects
+// offered by busName. fn must have one of the forms accepted by
+// [dbus.Conn.Handle].
+//
+// The first call to Handle for a given busName creates a new
+// connection to the fake bus that owns busName. Handle returns an
+// error if that connection cannot be created, or if busName is
+// already owned by a connection that Handle did not create.
+func (f *Fake) Handle(busName, interfaceName, methodName string, fn any) error {
+	f.mu.Lock()
+	conn := f.servers[busName]
+	f.mu.Unlock()
+	if conn == nil {
+		newConn, err := f.conn()
+		if err != nil {
+			return fmt.Errorf("connecting to fake bus: %w", err)
+		}
+		f.mu.Lock()
+		if conn = f.servers[busName]; conn != nil {
+			// A concurrent Handle call got there first.
+			f.mu.Unlock()
+			newConn.Close()
+		} else if f.owners[busName] != nil {
+			f.mu.Unlock()
+			newConn.Close()
+			return fmt.Errorf("cannot serve %q on fake bus, name is already owned", busName)
+		} else {
+			conn = newConn
+			f.owners[busName] = f.clients[conn.LocalName()]
+			f.servers[busName] = conn
+			f.mu.Unlock()
+		}
+	}
+	conn.Handle(interfaceName, methodName, fn)
+	return nil
+}
+
+// EmitSignal broadcasts signal from obj on the fake bus, like
+// [Bus.EmitSignal].
+func (f *Fake) EmitSignal(t *testing.T, obj dbus.ObjectPath, signal any) {
+	t.Helper()
+	f.mu.Lock()
+	emitter := f.emitter
+	f.mu.Unlock()
+	if emitter == nil {
+		emitter = f.MustConn(t)
+		f.mu.Lock()
+		if f.emitter == nil {
+			f.emitter = emitter
+		} else {
+			emitter.Close()
+			emitter = f.emitter
+		}
+		f.mu.Unlock()
+	}
+	if err := emitter.EmitSignal(context.Background(), obj, signal); err != nil {
+		t.Fatalf("emitting signal %T from %s: %v", signal, obj, err)
+	}
+}
+
+// fakeClient is a connection to a Fake.
+type fakeClient struct {
+	name string
+	conn net.Conn
+
+	writeMu sync.Mutex
+
+	// Protected by Fake.mu.
+	matches map[string]*fakeMatch // rule string -> parsed rule
+}
+
+func (c *fakeClient) write(m *dbus.Message) error {
+	order := m.Order
+	if order == nil {
+		order = fragments.NativeEndian
+	}
+	bs, err := dbus.EncodeMessage(m, order)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.conn.Write(bs)
+	return err
+}
+
+func (f *Fake) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if err := f.auth(conn, r); err != nil {
+		return
+	}
+
+	client := &fakeClient{
+		conn:    conn,
+		matches: map[string]*fakeMatch{},
+	}
+	defer f.disconnect(client)
+
+	for {
+		m, err := dbus.DecodeMessage(r)
+		if err != nil {
+			return
+		}
+		if client.name == "" && !isCall(m, "org.freedesktop.DBus", "Hello") {
+			// Clients must say Hello before anything else.
+			return
+		}
+		m.Sender = client.name
+		if m.Destination == "org.freedesktop.DBus" {
+			f.handleBusCall(client, m)
+		} else {
+			f.route(client, m)
+		}
+	}
+}
+
+// auth performs the server side of the DBus authentication
+// handshake. Fake accepts all clients.
+func (f *Fake) auth(w io.Writer, r *bufio.Reader) error {
+	nul, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if nul != 0 {
+		return errors.New("missing NUL byte before authentication")
+	}
+	var resp strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch cmd, _, _ := strings.Cut(strings.TrimSpace(line), " "); cmd {
+		case "AUTH":
+			fmt.Fprintf(&resp, "OK %s\r\n", f.id)
+		case "NEGOTIATE_UNIX_FD":
+			resp.WriteString("ERROR file descriptor passing not supported\r\n")
+		case "BEGIN":
+			// Reply only once the client is done writing, since
+			// the client blasts out the entire handshake before
+			// reading responses.
+			_, err := io.WriteString(w, resp.String())
+			return err
+		default:
+			resp.WriteString("ERROR unknown command\r\n")
+		}
+	}
+}
+
+// disconnect removes client from the bus, and releases all names it
+// owns.
+func (f *Fake) disconnect(client *fakeClient) {
+	f.mu.Lock()
+	if client.name == "" {
+		f.mu.Unlock()
+		return
+	}
+	delete(f.clients, client.name)
+	var released []string
+	for name, owner := range f.owners {
+		if owner == client {
+			delete(f.owners, name)
+			released = append(released, name)
+		}
+	}
+	f.mu.Unlock()
+
+	for _, name := range released {
+		f.broadcastOwnerChanged(name, client.name, "")
+	}
+	f.broadcastOwnerChanged(client.name, client.name, "")
+}
+
+// route delivers m, received from client, to its destination.
+func (f *Fake) route(client *fakeClient, m *dbus.Message) {
+	if m.Destination == "" {
+		if m.Type == dbus.MessageSignal {
+			f.broadcast(m)
+		}
+		return
+	}
+
+	f.mu.Lock()
+	target := f.lookup(m.Destination)
+	f.mu.Unlock()
+	if target == nil {
+		f.replyErr(client, m, "org.freedesktop.DBus.Error.ServiceUnknown", fmt.Sprintf("The name %s was not provided by any .service files", m.Destination))
+		return
+	}
+	target.write(m)
+}
+
+// broadcast delivers m to every client that has a match rule that
+// matches m.
+func (f *Fake) broadcast(m *dbus.Message) {
+	args := sync.OnceValue(func() map[int]string { return stringArgs(m) })
+	f.mu.Lock()
+	var targets []*fakeClient
+	for _, c := range f.clients {
+		for _, rule := range c.matches {
+			if rule.matches(f, m, args) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	f.mu.Unlock()
+	for _, c := range targets {
+		c.write(m)
+	}
+}
+
+// lookup returns the client that owns name, or nil if name has no
+// owner. f.mu must be held.
+func (f *Fake) lookup(name string) *fakeClient {
+	if strings.HasPrefix(name, ":") {
+		return f.clients[name]
+	}
+	return f.owners[name]
+}
+
+func (f *Fake) nextSerial() uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastSerial++
+	return f.lastSerial
+}
+
+// handleBusCall processes m, a call from client to the bus itself.
+func (f *Fake) handleBusCall(client *fakeClient, m *dbus.Message) {
+	if m.Type != dbus.MessageCall {
+		return
+	}
+	if isCall(m, "org.freedesktop.DBus.Peer", "Ping") {
+		f.reply(client, m, "", nil)
+		return
+	}
+	if m.Interface != "org.freedesktop.DBus" && m.Interface != "" {
+		f.replyErr(client, m, "org.freedesktop.DBus.Error.UnknownInterface", fmt.Sprintf("Unknown interface %q", m.Interface))
+		return
+	}
+
+	resp := fragments.Encoder{Order: fragments.NativeEndian}
+	var (
+		sig      string
+		afterFns []func()
+	)
+
+	switch m.Member {
+	case "Hello":
+		if client.name != "" {
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.Failed", "Already handled an Hello message")
+			return
+		}
+		f.mu.Lock()
+		f.lastID++
+		client.name = fmt.Sprintf(":1.%d", f.lastID)
+		f.clients[client.name] = client
+		f.mu.Unlock()
+		m.Sender = client.name
+		sig = "s"
+		resp.String(client.name)
+		afterFns = append(afterFns, func() {
+			f.busSignal(client, "NameAcquired", client.name)
+			f.broadcastOwnerChanged(client.name, "", client.name)
+		})
+	case "GetId":
+		sig = "s"
+		resp.String(f.id)
+	case "AddMatch", "RemoveMatch":
+		var rule string
+		if err := m.DecodeBody(context.Background(), &rule); err != nil {
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.InvalidArgs", err.Error())
+			return
+		}
+		f.mu.Lock()
+		match := client.matches[rule]
+		switch {
+		case m.Member == "AddMatch" && match == nil:
+			var err error
+			if match, err = parseFakeMatch(rule); err != nil {
+				f.mu.Unlock()
+				f.replyErr(client, m, "org.freedesktop.DBus.Error.MatchRuleInvalid", err.Error())
+				return
+			}
+			client.matches[rule] = match
+			match.refs++
+		case m.Member == "AddMatch":
+			match.refs++
+		case match == nil:
+			f.mu.Unlock()
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.MatchRuleNotFound", "The given match rule wasn't found and can't be removed")
+			return
+		default:
+			if match.refs--; match.refs == 0 {
+				delete(client.matches, rule)
+			}
+		}
+		f.mu.Unlock()
+	case "GetNameOwner", "NameHasOwner":
+		var name string
+		if err := m.DecodeBody(context.Background(), &name); err != nil {
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.InvalidArgs", err.Error())
+			return
+		}
+		owner := "org.freedesktop.DBus"
+		if name != owner {
+			f.mu.Lock()
+			if c := f.lookup(name); c != nil {
+				owner = c.name
+			} else {
+				owner = ""
+			}
+			f.mu.Unlock()
+		}
+		if m.Member == "NameHasOwner" {
+			sig = "b"
+			resp.Uint32(boolToUint32(owner != ""))
+		} else if owner == "" {
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.NameHasNoOwner", fmt.Sprintf("Could not get owner of name '%s': no such name", name))
+			return
+		} else {
+			sig = "s"
+			resp.String(owner)
+		}
+	case "ListNames":
+		f.mu.Lock()
+		names := []string{"org.freedesktop.DBus"}
+		for name := range f.clients {
+			names = append(names, name)
+		}
+		for name := range f.owners {
+			names = append(names, name)
+		}
+		f.mu.Unlock()
+		slices.Sort(names)
+		sig = "as"
+		resp.Array(false, func() error {
+			for _, name := range names {
+				resp.String(name)
+			}
+			return nil
+		})
+	case "RequestName":
+		var name string
+		if err := m.DecodeBody(context.Background(), &name); err != nil {
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.InvalidArgs", err.Error())
+			return
+		}
+		if strings.HasPrefix(name, ":") || name == "org.freedesktop.DBus" {
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.InvalidArgs", fmt.Sprintf("Cannot acquire a service named '%s'", name))
+			return
+		}
+		f.mu.Lock()
+		var ret uint32
+		switch owner := f.owners[name]; owner {
+		case nil:
+			f.owners[name] = client
+			ret = 1 // primary owner
+			afterFns = append(afterFns, func() {
+				f.busSignal(client, "NameAcquired", name)
+				f.broadcastOwnerChanged(name, "", client.name)
+			})
+		case client:
+			ret = 4 // already owner
+		default:
+			ret = 3 // exists
+		}
+		f.mu.Unlock()
+		sig = "u"
+		resp.Uint32(ret)
+	case "ReleaseName":
+		var name string
+		if err := m.DecodeBody(context.Background(), &name); err != nil {
+			f.replyErr(client, m, "org.freedesktop.DBus.Error.InvalidArgs", err.Error())
+			return
+		}
+		f.mu.Lock()
+		var ret uint32
+		switch owner := f.owners[name]; owner {
+		case nil:
+			ret = 2 // non-existent
+		case client:
+			delete(f.owners, name)
+			ret = 1 // released
+			afterFns = append(afterFns, func() {
+				f.busSignal(client, "NameLost", name)
+				f.broadcastOwnerChanged(name, client.name, "")
+			})
+		default:
+			ret = 3 // not owner
+		}
+		f.mu.Unlock()
+		sig = "u"
+		resp.Uint32(ret)
+	default:
+		f.replyErr(client, m, "org.freedesktop.DBus.Error.UnknownMethod", fmt.Sprintf("Unknown method %q on fake bus", m.Member))
+		return
+	}
+
+	f.reply(client, m, sig, resp.Out)
+	for _, fn := range afterFns {
+		fn()
+	}
+}
+
+// reply sends a method return for call to client, if call wants a
+// reply.
+func (f *Fake) reply(client *fakeClient, call *dbus.Message, sig string, body []byte) {
+	if !wantReply(call) {
+		return
+	}
+	ret := f.newBusMsg(dbus.MessageReturn, client.name, sig, body)
+	ret.ReplySerial = call.Serial
+	client.write(ret)
+}
+
+// replyErr sends an error return for call to client, if call wants a
+// reply.
+func (f *Fake) replyErr(client *fakeClient, call *dbus.Message, name, detail string) {
+	if !wantReply(call) {
+		return
+	}
+	body := fragments.Encoder{Order: fragments.NativeEndian}
+	body.String(detail)
+	ret := f.newBusMsg(dbus.MessageError, client.name, "s", body.Out)
+	ret.ErrName = name
+	ret.ReplySerial = call.Serial
+	client.write(ret)
+}
+
+// busSignal sends the bus signal member, whose body is the single
+// string arg, to client.
+func (f *Fake) busSignal(client *fakeClient, member, arg string) {
+	body := fragments.Encoder{Order: fragments.NativeEndian}
+	body.String(arg)
+	client.write(f.newBusSignal(member, client.name, "s", body.Out))
+}
+
+// broadcastOwnerChanged broadcasts a NameOwnerChanged signal for
+// name.
+func (f *Fake) broadcastOwnerChanged(name, prevOwner, newOwner string) {
+	body := fragments.Encoder{Order: fragments.NativeEndian}
+	body.String(name)
+	body.String(prevOwner)
+	body.String(newOwner)
+	f.broadcast(f.newBusSignal("NameOwnerChanged", "", "sss", body.Out))
+}
+
+func (f *Fake) newBusSignal(member, dest, sig string, body []byte) *dbus.Message {
+	ret := f.newBusMsg(dbus.MessageSignal, dest, sig, body)
+	ret.Path = "/org/freedesktop/DBus"
+	ret.Interface = "org.freedesktop.DBus"
+	ret.Member = member
+	return ret
+}
+
+// newBusMsg returns a message of type typ from the bus to dest, with
+// the given body. body must be encoded in native byte order.
+func (f *Fake) newBusMsg(typ dbus.MessageType, dest, sig string, body []byte) *dbus.Message {
+	ret := &dbus.Message{
+		MessageHeader: dbus.MessageHeader{
+			Order:       fragments.NativeEndian,
+			Type:        typ,
+			Flags:       dbus.FlagNoReplyExpected,
+			Serial:      f.nextSerial(),
+			Destination: dest,
+			Sender:      "org.freedesktop.DBus",
+		},
+		Body: body,
+	}
+	if sig != "" {
+		var err error
+		if ret.Signature, err = dbus.ParseSignature(sig); err != nil {
+			panic(fmt.Sprintf("invalid fake bus message signature %q: %v", sig, err))
+		}
+	}
+	return ret
+}
+
+func boolToUint32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func isCall(m *dbus.Message, iface, member string) bool {
+	return m.Type == dbus.MessageCall && m.Interface == iface && m.Member == member
+}
+
+func wantReply(m *dbus.Message) bool {
+	return m.Type == dbus.MessageCall && m.Flags&dbus.FlagNoReplyExpected == 0
+}
+
+// stringArgs returns the values of m's body arguments that are
+// strings, object paths or signatures, keyed by argument index.
+func stringArgs(m *dbus.Message) map[int]string {
+	ret := map[int]string{}
+	d := fragments.Decoder{
+		Order: m.Order,
+		In:    bytes.NewReader(m.Body),
+	}
+	sig := m.Signature.String()
+	for i := 0; sig != ""; i++ {
+		n, err := fragments.NextType(sig)
+		if err != nil {
+			return ret
+		}
+		switch sig[0] {
+		case 's', 'o':
+			ret[i], err = d.String()
+		case 'g':
+			ret[i], err = d.Signature()
+		default:
+			err = d.Skip(sig[:n])
+		}
+		if err != nil {
+			return ret
+		}
+		sig = sig[n:]
+	}
+	return ret
+}
+
+// fakeMatch is a parsed match rule.
+type fakeMatch struct {
+	refs int // number of AddMatch calls not yet undone
+
+	hdr      map[string]string // header and arg0namespace conditions
+	args     map[int]string    // argN conditions
+	argPaths map[int]string    // argNpath conditions
+}
+
+// parseFakeMatch parses a match rule string, as described in the
+// DBus specification.
+func parseFakeMatch(rule string) (*fakeMatch, error) {
+	ret := &fakeMatch{
+		hdr:      map[string]string{},
+		args:     map[int]string{},
+		argPaths: map[int]string{},
+	}
+	for rest := rule; rest != ""; {
+		key, val, ok := strings.Cut(rest, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing = in match rule %q", rule)
+		}
+		val, rest, ok = cutMatchValue(val)
+		if !ok {
+			return nil, fmt.Errorf("unterminated quote in match rule %q", rule)
+		}
+		switch key {
+		case "type", "sender", "interface", "member", "path", "path_namespace", "destination", "arg0namespace":
+			ret.hdr[key] = val
+		case "eavesdrop":
+		default:
+			idx, isPath := strings.CutSuffix(strings.TrimPrefix(key, "arg"), "path")
+			i, err := strconv.Atoi(idx)
+			if !strings.HasPrefix(key, "arg") || err != nil || i < 0 || i > 63 {
+				return nil, fmt.Errorf("unknown key %q in match rule %q", key, rule)
+			}
+			if isPath {
+				ret.argPaths[i] = val
+			} else {
+				ret.args[i] = val
+			}
+		}
+	}
+	return ret, nil
+}
+
+// cutMatchValue unescapes the match rule value at the start of s, and
+// returns it along with the remainder of the rule after the value's
+// terminating comma.
+func cutMatchValue(s string) (val, rest string, ok bool) {
+	var ret strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\'':
+			quoted = false
+		case quoted:
+			ret.WriteByte(c)
+		case c == '\'':
+			quoted = true
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\'':
+			ret.WriteByte('\'')
+			i++
+		case c == ',':
+			return ret.String(), s[i+1:], true
+		default:
+			ret.WriteByte(c)
+		}
+	}
+	return ret.String(), "", !quoted
+}
+
+var matchTypes = map[dbus.MessageType]string{
+	dbus.MessageCall:   "method_call",
+	dbus.MessageReturn: "method_return",
+	dbus.MessageError:  "error",
+	dbus.MessageSignal: "signal",
+}
+
+// matches reports whether m matches the rule. args returns m's
+// string arguments, as returned by stringArgs. f.mu must be held.
+func (r *fakeMatch) matches(f *Fake, m *dbus.Message, args func() map[int]string) bool {
+	for key, want := range r.hdr {
+		var ok bool
+		switch key {
+		case "type":
+			ok = matchTypes[m.Type] == want
+		case "sender":
+			ok = m.Sender == want
+			if !ok {
+				owner := f.lookup(want)
+				ok = owner != nil && owner.name == m.Sender
+			}
+		case "interface":
+			ok = m.Interface == want
+		case "member":
+			ok = m.Member == want
+		case "path":
+			ok = string(m.Path) == want
+		case "path_namespace":
+			ok = want == "/" || string(m.Path) == want || strings.HasPrefix(string(m.Path), want+"/")
+		case "destination":
+			ok = m.Destination == want
+		case "arg0namespace":
+			got, isStr := args()[0]
+			ok = isStr && (got == want || strings.HasPrefix(got, want+"."))
+		}
+		if !ok {
+			return false
+		}
+	}
+	for i, want := range r.args {
+		if got, ok := args()[i]; !ok || got != want {
+			return false
+		}
+	}
+	for i, want := range r.argPaths {
+		got, ok := args()[i]
+		if !ok {
+			return false
+		}
+		if got != want && !(strings.HasSuffix(want, "/") && strings.HasPrefix(got, want)) && !(strings.HasSuffix(got, "/") && strings.HasPrefix(want, got)) {
+			return false
+		}
+	}
+	return true
+}
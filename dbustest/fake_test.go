@@ -0,0 +1,120 @@
+package dbustest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/dbustest"
+)
+
+type fakeSignal struct {
+	Msg string
+}
+
+func init() {
+	dbus.RegisterSignalType[fakeSignal]("org.test.Fake", "Signal")
+}
+
+func TestFake(t *testing.T) {
+	f := dbustest.NewFake(t)
+	ctx := context.Background()
+
+	conn := f.MustConn(t)
+	if conn.LocalName() == "" {
+		t.Fatal("fake bus did not assign a unique name")
+	}
+	if err := conn.Peer("org.freedesktop.DBus").Ping(ctx); err != nil {
+		t.Fatalf("failed to ping fake bus: %v", err)
+	}
+	if _, err := conn.BusID(ctx); err != nil {
+		t.Fatalf("getting fake bus ID: %v", err)
+	}
+
+	// Canned method responses.
+	err := f.Handle("org.test.Service", "org.test.Service", "Greet", func(ctx context.Context, obj dbus.ObjectPath, name string) (string, error) {
+		return "hello " + name + " from " + string(obj), nil
+	})
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	iface := conn.Peer("org.test.Service").Object("/foo").Interface("org.test.Service")
+	var greeting string
+	if err := iface.Call(ctx, "Greet", "bob", &greeting); err != nil {
+		t.Fatalf("calling fake service: %v", err)
+	}
+	if want := "hello bob from /foo"; greeting != want {
+		t.Fatalf("fake service returned %q, want %q", greeting, want)
+	}
+	if err := iface.Call(ctx, "Nope", nil); err == nil {
+		t.Fatal("calling unhandled method on fake service succeeded")
+	}
+	if err := conn.Peer("org.test.Missing").Ping(ctx); err == nil {
+		t.Fatal("pinging nonexistent peer succeeded")
+	}
+
+	// Name ownership.
+	other := f.MustConn(t)
+	if got, err := other.RequestName(ctx, "org.test.Name", 0); err != nil {
+		t.Fatalf("RequestName failed: %v", err)
+	} else if got != dbus.RequestNamePrimaryOwner {
+		t.Fatalf("RequestName = %v, want %v", got, dbus.RequestNamePrimaryOwner)
+	}
+	owner, err := conn.Peer("org.test.Name").Owner(ctx)
+	if err != nil {
+		t.Fatalf("getting owner of org.test.Name: %v", err)
+	}
+	if owner.Name() != other.LocalName() {
+		t.Fatalf("org.test.Name owner is %q, want %q", owner.Name(), other.LocalName())
+	}
+	if err := f.Handle("org.test.Name", "org.test.Name", "Greet", func() error { return nil }); err == nil {
+		t.Fatal("Handle on a name owned by another connection succeeded, want error")
+	}
+
+	// Signals.
+	sigs, cancel, err := dbus.Signals[fakeSignal](conn)
+	if err != nil {
+		t.Fatalf("Signals() failed: %v", err)
+	}
+	defer cancel()
+	if err := other.EmitSignal(ctx, "/test", fakeSignal{"hi"}); err != nil {
+		t.Fatalf("EmitSignal failed: %v", err)
+	}
+	select {
+	case got := <-sigs:
+		if got.Msg != "hi" {
+			t.Fatalf("got signal %q, want %q", got.Msg, "hi")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for signal")
+	}
+
+	// Injected signals.
+	f.EmitSignal(t, "/test", fakeSignal{"injected"})
+	select {
+	case got := <-sigs:
+		if got.Msg != "injected" {
+			t.Fatalf("got signal %q, want %q", got.Msg, "injected")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for injected signal")
+	}
+
+	// Disconnecting releases names.
+	other.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		exists, err := conn.Peer("org.test.Name").Exists(ctx)
+		if err != nil {
+			t.Fatalf("checking org.test.Name existence: %v", err)
+		}
+		if !exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("org.test.Name still owned after owner disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,101 @@
+package dbustest
+
+import (
+	"testing"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/fragments"
+)
+
+func TestFakeMatch(t *testing.T) {
+	body := fragments.Encoder{Order: fragments.NativeEndian}
+	body.String("org.test.Name")
+	body.Uint32(42)
+	body.String("/org/test/obj")
+	sig, err := dbus.ParseSignature("sus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &dbus.Message{
+		MessageHeader: dbus.MessageHeader{
+			Order:     fragments.NativeEndian,
+			Type:      dbus.MessageSignal,
+			Path:      "/org/test/obj",
+			Interface: "org.test.Iface",
+			Member:    "Changed",
+			Sender:    ":1.1",
+			Signature: sig,
+		},
+		Body: body.Out,
+	}
+
+	f := &Fake{
+		clients: map[string]*fakeClient{":1.1": {name: ":1.1"}},
+		owners:  map[string]*fakeClient{},
+	}
+	f.owners["org.test.Owner"] = f.clients[":1.1"]
+
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"type='signal'", true},
+		{"type='method_call'", false},
+		{"type='signal',interface='org.test.Iface',member='Changed'", true},
+		{"type='signal',member='Other'", false},
+		{"sender=':1.1'", true},
+		{"sender='org.test.Owner'", true},
+		{"sender=':1.2'", false},
+		{"path='/org/test/obj'", true},
+		{"path='/org/test'", false},
+		{"path_namespace='/org/test'", true},
+		{"path_namespace='/org/te'", false},
+		{"path_namespace='/'", true},
+		{"arg0='org.test.Name'", true},
+		{"arg0='org.test.Other'", false},
+		{"arg1='42'", false},
+		{"arg2path='/org/test/'", true},
+		{"arg2path='/org/'", true},
+		{"arg2path='/org/te'", false},
+		{"arg0namespace='org.test'", true},
+		{"arg0namespace='org.te'", false},
+		{"arg3=''", false},
+		{`interface='org.test.Iface',member=Changed`, true},
+		{`member='It'\''s'`, false},
+	}
+	for _, tc := range tests {
+		r, err := parseFakeMatch(tc.rule)
+		if err != nil {
+			t.Errorf("parseFakeMatch(%q) got err: %v", tc.rule, err)
+			continue
+		}
+		args := func() map[int]string { return stringArgs(m) }
+		if got := r.matches(f, m, args); got != tc.want {
+			t.Errorf("rule %q matches = %v, want %v", tc.rule, got, tc.want)
+		}
+	}
+
+	for _, rule := range []string{"nope='x'", "member", "member='unterminated", "arg64='x'", "argx='x'"} {
+		if _, err := parseFakeMatch(rule); err == nil {
+			t.Errorf("parseFakeMatch(%q) succeeded, want error", rule)
+		}
+	}
+}
+
+func TestCutMatchValue(t *testing.T) {
+	tests := []struct {
+		in, val, rest string
+	}{
+		{"'foo',bar", "foo", "bar"},
+		{"foo", "foo", ""},
+		{`'it'\''s'`, "it's", ""},
+		{"'a,b',c", "a,b", "c"},
+		{"''", "", ""},
+	}
+	for _, tc := range tests {
+		val, rest, ok := cutMatchValue(tc.in)
+		if !ok || val != tc.val || rest != tc.rest {
+			t.Errorf("cutMatchValue(%q) = %q, %q, %v, want %q, %q, true", tc.in, val, rest, ok, tc.val, tc.rest)
+		}
+	}
+}
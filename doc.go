@@ -22,6 +22,11 @@ package dbus
 // fields in the outer struct, subject to the usual Go visibility
 // rules.
 //
+// DBus has no empty struct type, so structs with no exported fields
+// cannot be encoded, except as a method call, method return or
+// signal body, where they encode as a body with no values, the same
+// as a nil body.
+//
 // Map values encode as a DBus dictionary, i.e. an array of key/value
 // pairs. The map's key underlying type must be uint{8,16,32,64},
 // int{16,32,64}, float64, bool, or string.
@@ -54,7 +59,8 @@ package dbus
 //
 // 'any' values encode as DBus variants. The interface's inner value
 // must be a valid value according to these rules, or Marshal will
-// return a [TypeError].
+// return a [TypeError]. A *any inside an 'any' encodes as a variant
+// nested within a variant.
 //
 // int8, int, uint, uintptr, complex64, complex128, interface,
 // channel, and function values cannot be encoded. Attempting to
@@ -133,7 +139,9 @@ package dbus
 // value is determined by the type signature carried in the
 // message. Variants containing a struct are decoded into an anonymous
 // struct with fields named Field0, Field1, ..., FieldN in message
-// order.
+// order. Variants nested within variants are decoded into a *any
+// holding the inner variant's value, so that they encode back to the
+// same nested variants.
 //
 // int8, int, uint, uintptr, complex64, complex128, interface,
 // channel, and function values cannot decode any DBus type.
@@ -0,0 +1,106 @@
+package dbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/creachadair/mds/mapset"
+	"github.com/danderson/dbus/fragments"
+)
+
+var (
+	enumsMu    sync.Mutex
+	enumValues = map[reflect.Type]mapset.Set[uint64]{}
+)
+
+// enumInteger is the set of integer types that can be registered as
+// enums.
+type enumInteger interface {
+	~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64
+}
+
+// RegisterEnum registers T as an enum type whose only valid values
+// are the given values. Decoding any other value into T returns a
+// [TypeError].
+//
+// T must be a named integer type. RegisterEnum must be called before
+// T is first decoded, typically in an init function.
+//
+// RegisterEnum panics if T is not a named integer type, if T is
+// already registered, or if T has already been decoded. Use
+// [TryRegisterEnum] to get an error instead.
+func RegisterEnum[T enumInteger](values ...T) {
+	if err := TryRegisterEnum(values...); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegisterEnum is like [RegisterEnum], but returns an error
+// instead of panicking.
+func TryRegisterEnum[T enumInteger](values ...T) error {
+	t := reflect.TypeFor[T]()
+	if t.PkgPath() == "" {
+		return fmt.Errorf("cannot register %s as an enum, enums must be named types", t)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("cannot register %s as an enum with no valid values", t)
+	}
+
+	valid := mapset.New[uint64]()
+	for _, v := range values {
+		valid.Add(enumKey(reflect.ValueOf(v)))
+	}
+
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	if _, ok := enumValues[t]; ok {
+		return fmt.Errorf("duplicate enum registration for %s", t)
+	}
+	if _, err := decoders.Get(t); !errors.Is(err, errNotFound) {
+		return fmt.Errorf("cannot register %s as an enum after it has been decoded", t)
+	}
+	enumValues[t] = valid
+	return nil
+}
+
+// enumKey returns v's value as a uint64, for use as a key in the set
+// of valid enum values. Signed values are converted with the usual
+// two's complement wraparound, which preserves distinctness.
+func enumKey(v reflect.Value) uint64 {
+	if v.CanInt() {
+		return uint64(v.Int())
+	}
+	return v.Uint()
+}
+
+// isEnum reports whether t is a registered enum type.
+func isEnum(t reflect.Type) bool {
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	_, ok := enumValues[t]
+	return ok
+}
+
+// withEnumCheck returns dec wrapped to reject values of t that are
+// not valid enum values, if t is a registered enum. Otherwise, it
+// returns dec unmodified.
+func withEnumCheck(t reflect.Type, dec fragments.DecoderFunc) fragments.DecoderFunc {
+	enumsMu.Lock()
+	valid, ok := enumValues[t]
+	enumsMu.Unlock()
+	if !ok {
+		return dec
+	}
+	return func(ctx context.Context, d *fragments.Decoder, v reflect.Value) error {
+		if err := dec(ctx, d, v); err != nil {
+			return err
+		}
+		if !valid.Has(enumKey(v)) {
+			return typeErr(t, "invalid enum value %v", v)
+		}
+		return nil
+	}
+}
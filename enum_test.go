@@ -0,0 +1,140 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/danderson/dbus/fragments"
+)
+
+type testEnum uint32
+
+const (
+	testEnumA testEnum = 1
+	testEnumB testEnum = 5
+)
+
+type testSignedEnum int16
+
+type testByteEnum uint8
+
+func init() {
+	RegisterEnum(testEnumA, testEnumB)
+	RegisterEnum[testSignedEnum](-1, 1)
+	RegisterEnum[testByteEnum](1, 2)
+}
+
+func TestEnum(t *testing.T) {
+	decode := func(in, out any) error {
+		enc := fragments.Encoder{
+			Order:  fragments.BigEndian,
+			Mapper: encoderFor,
+		}
+		if err := enc.Value(context.Background(), in); err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		dec := fragments.Decoder{
+			Order:  fragments.BigEndian,
+			Mapper: decoderFor,
+			In:     bytes.NewReader(enc.Out),
+		}
+		return dec.Value(context.Background(), out)
+	}
+
+	var got testEnum
+	if err := decode(uint32(5), &got); err != nil {
+		t.Errorf("decoding valid enum value failed: %v", err)
+	} else if got != testEnumB {
+		t.Errorf("decoding valid enum value got %v, want %v", got, testEnumB)
+	}
+	var te TypeError
+	if err := decode(uint32(2), &got); !errors.As(err, &te) {
+		t.Errorf("decoding invalid enum value got err %v, want TypeError", err)
+	}
+
+	var gotSigned testSignedEnum
+	if err := decode(int16(-1), &gotSigned); err != nil {
+		t.Errorf("decoding valid signed enum value failed: %v", err)
+	}
+	if err := decode(int16(0), &gotSigned); err == nil {
+		t.Error("decoding invalid signed enum value succeeded, want error")
+	}
+
+	type withEnum struct {
+		A string
+		E testEnum
+	}
+	var gotStruct withEnum
+	if err := decode(struct {
+		A string
+		E uint32
+	}{"foo", 3}, &gotStruct); err == nil {
+		t.Error("decoding struct with invalid enum value succeeded, want error")
+	}
+
+	// Slices of enums check every element, including byte-sized
+	// enums that would otherwise decode as a byte array.
+	var gotSlice []testEnum
+	if err := decode([]uint32{1, 5}, &gotSlice); err != nil {
+		t.Errorf("decoding valid enum slice failed: %v", err)
+	}
+	if err := decode([]uint32{1, 2}, &gotSlice); err == nil {
+		t.Error("decoding enum slice with invalid value succeeded, want error")
+	}
+	var gotBytes []testByteEnum
+	if err := decode([]byte{1, 2}, &gotBytes); err != nil {
+		t.Errorf("decoding valid byte enum slice failed: %v", err)
+	} else if want := []testByteEnum{1, 2}; !reflect.DeepEqual(gotBytes, want) {
+		t.Errorf("decoding valid byte enum slice got %v, want %v", gotBytes, want)
+	}
+	if err := decode([]byte{1, 3}, &gotBytes); err == nil {
+		t.Error("decoding byte enum slice with invalid value succeeded, want error")
+	}
+
+	// Unrelated types with the same representation aren't affected.
+	var u uint32
+	if err := decode(uint32(2), &u); err != nil {
+		t.Errorf("decoding uint32 failed: %v", err)
+	}
+}
+
+func TestRegisterEnumErrors(t *testing.T) {
+	if err := TryRegisterEnum(testEnumA); err == nil {
+		t.Error("duplicate enum registration succeeded")
+	}
+	if err := TryRegisterEnum[uint32](1, 2); err == nil {
+		t.Error("registering uint32 as an enum succeeded")
+	}
+
+	type empty uint8
+	if err := TryRegisterEnum[empty](); err == nil {
+		t.Error("registering enum with no values succeeded")
+	}
+
+	type used uint16
+	if _, err := decoderFor(reflect.TypeFor[used]()); err != nil {
+		t.Fatalf("getting decoder for used failed: %v", err)
+	}
+	if err := TryRegisterEnum[used](1); err == nil {
+		t.Error("registering enum after first use succeeded")
+	}
+
+	// Decoding a slice counts as using its element type, even when
+	// the slice is decoded in bulk.
+	type usedInSlice uint16
+	type usedInBytes uint8
+	for _, typ := range []reflect.Type{reflect.TypeFor[[]usedInSlice](), reflect.TypeFor[[]usedInBytes]()} {
+		if _, err := decoderFor(typ); err != nil {
+			t.Fatalf("getting decoder for %s failed: %v", typ, err)
+		}
+	}
+	if err := TryRegisterEnum[usedInSlice](1); err == nil {
+		t.Error("registering enum after first use in a slice succeeded")
+	}
+	if err := TryRegisterEnum[usedInBytes](1); err == nil {
+		t.Error("registering enum after first use in a byte slice succeeded")
+	}
+}
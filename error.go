@@ -1,6 +1,7 @@
 package dbus
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 )
@@ -45,3 +46,66 @@ func (e CallError) Error() string {
 	}
 	return fmt.Sprintf("call error %s: %s", e.Name, e.Detail)
 }
+
+// Is reports whether e matches target. A CallError with no Detail,
+// such as [ErrAccessDenied], matches any CallError with the same
+// Name, so that callers can use [errors.Is] to classify errors:
+//
+//	if errors.Is(err, dbus.ErrAccessDenied) {
+//		...
+//	}
+func (e CallError) Is(target error) bool {
+	t, ok := target.(CallError)
+	if !ok {
+		return false
+	}
+	return t.Name == e.Name && (t.Detail == "" || t.Detail == e.Detail)
+}
+
+// Standard DBus errors, for use with [errors.Is]. The bus and many
+// peers return these errors in addition to their own
+// application-specific errors.
+var (
+	ErrFailed                  = CallError{Name: "org.freedesktop.DBus.Error.Failed"}
+	ErrNoMemory                = CallError{Name: "org.freedesktop.DBus.Error.NoMemory"}
+	ErrServiceUnknown          = CallError{Name: "org.freedesktop.DBus.Error.ServiceUnknown"}
+	ErrNameHasNoOwner          = CallError{Name: "org.freedesktop.DBus.Error.NameHasNoOwner"}
+	ErrNoReply                 = CallError{Name: "org.freedesktop.DBus.Error.NoReply"}
+	ErrIOError                 = CallError{Name: "org.freedesktop.DBus.Error.IOError"}
+	ErrBadAddress              = CallError{Name: "org.freedesktop.DBus.Error.BadAddress"}
+	ErrNotSupported            = CallError{Name: "org.freedesktop.DBus.Error.NotSupported"}
+	ErrLimitsExceeded          = CallError{Name: "org.freedesktop.DBus.Error.LimitsExceeded"}
+	ErrAccessDenied            = CallError{Name: "org.freedesktop.DBus.Error.AccessDenied"}
+	ErrAuthFailed              = CallError{Name: "org.freedesktop.DBus.Error.AuthFailed"}
+	ErrNoServer                = CallError{Name: "org.freedesktop.DBus.Error.NoServer"}
+	ErrTimeout                 = CallError{Name: "org.freedesktop.DBus.Error.Timeout"}
+	ErrNoNetwork               = CallError{Name: "org.freedesktop.DBus.Error.NoNetwork"}
+	ErrAddressInUse            = CallError{Name: "org.freedesktop.DBus.Error.AddressInUse"}
+	ErrDisconnected            = CallError{Name: "org.freedesktop.DBus.Error.Disconnected"}
+	ErrInvalidArgs             = CallError{Name: "org.freedesktop.DBus.Error.InvalidArgs"}
+	ErrFileNotFound            = CallError{Name: "org.freedesktop.DBus.Error.FileNotFound"}
+	ErrFileExists              = CallError{Name: "org.freedesktop.DBus.Error.FileExists"}
+	ErrUnknownMethod           = CallError{Name: "org.freedesktop.DBus.Error.UnknownMethod"}
+	ErrUnknownObject           = CallError{Name: "org.freedesktop.DBus.Error.UnknownObject"}
+	ErrUnknownInterface        = CallError{Name: "org.freedesktop.DBus.Error.UnknownInterface"}
+	ErrUnknownProperty         = CallError{Name: "org.freedesktop.DBus.Error.UnknownProperty"}
+	ErrPropertyReadOnly        = CallError{Name: "org.freedesktop.DBus.Error.PropertyReadOnly"}
+	ErrTimedOut                = CallError{Name: "org.freedesktop.DBus.Error.TimedOut"}
+	ErrMatchRuleNotFound       = CallError{Name: "org.freedesktop.DBus.Error.MatchRuleNotFound"}
+	ErrMatchRuleInvalid        = CallError{Name: "org.freedesktop.DBus.Error.MatchRuleInvalid"}
+	ErrInvalidSignature        = CallError{Name: "org.freedesktop.DBus.Error.InvalidSignature"}
+	ErrInconsistentMessage     = CallError{Name: "org.freedesktop.DBus.Error.InconsistentMessage"}
+	ErrInteractiveAuthRequired = CallError{Name: "org.freedesktop.DBus.Error.InteractiveAuthorizationRequired"}
+)
+
+// IsRetryable reports whether err is a [CallError] indicating a
+// transient failure that may succeed if retried, such as calling a
+// service that is restarting.
+//
+// The retryable errors are [ErrServiceUnknown], [ErrNoReply] and
+// [ErrNameHasNoOwner].
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrServiceUnknown) ||
+		errors.Is(err, ErrNoReply) ||
+		errors.Is(err, ErrNameHasNoOwner)
+}
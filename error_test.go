@@ -0,0 +1,42 @@
+package dbus
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCallErrorIs(t *testing.T) {
+	denied := CallError{
+		Name:   "org.freedesktop.DBus.Error.AccessDenied",
+		Detail: "go away",
+	}
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"sentinel", denied, ErrAccessDenied, true},
+		{"wrapped", fmt.Errorf("doing thing: %w", denied), ErrAccessDenied, true},
+		{"other sentinel", denied, ErrUnknownMethod, false},
+		{"same detail", denied, denied, true},
+		{"other detail", denied, CallError{Name: denied.Name, Detail: "nope"}, false},
+		{"sentinel is not detailed", ErrAccessDenied, denied, false},
+		{"not a CallError", errors.New("access denied"), ErrAccessDenied, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := errors.Is(tc.err, tc.target); got != tc.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tc.err, tc.target, got, tc.want)
+			}
+		})
+	}
+
+	if !IsRetryable(CallError{Name: ErrNoReply.Name, Detail: "timeout"}) {
+		t.Error("IsRetryable(NoReply) = false, want true")
+	}
+	if IsRetryable(denied) {
+		t.Error("IsRetryable(AccessDenied) = true, want false")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 )
 
 // A DecoderFunc reads a value into val.
@@ -29,14 +30,91 @@ type Decoder struct {
 	// In is the input stream to read.
 	In io.Reader
 
-	// Offset tracks the current alignment of Decoder.In, to compute
-	// appropriate padding.
+	// Offset tracks the number of bytes consumed from Decoder.In,
+	// to compute appropriate padding.
 	offset int
+
+	// Container nesting depths of the value being read.
+	arrayDepth  int
+	structDepth int
+	depth       int // arrays, structs and variants
+}
+
+// MaxArrayLength is the maximum length in bytes of a DBus array, as
+// set by the DBus specification.
+const MaxArrayLength = 1 << 26
+
+// Maximum container nesting depths, as set by the DBus
+// specification.
+const (
+	// MaxArrayDepth is the maximum nesting depth of arrays.
+	MaxArrayDepth = 32
+	// MaxStructDepth is the maximum nesting depth of structs and
+	// dict entries.
+	MaxStructDepth = 32
+	// MaxDepth is the maximum total nesting depth of arrays,
+	// structs, dict entries and variants.
+	MaxDepth = 64
+)
+
+// readChunk is the largest buffer that Read allocates up front when
+// the amount of remaining input is unknown. Larger reads grow their
+// buffer as data arrives, so that a bogus length prefix cannot force
+// a huge allocation.
+const readChunk = 64 << 10
+
+// nest calls fn with the nesting depth of one kind of container
+// increased by one. It returns an error without calling fn if either
+// that kind's depth or the total depth would exceed its limit.
+func (d *Decoder) nest(kindDepth *int, kindMax int, kind string, fn func() error) error {
+	if kindDepth != nil {
+		if *kindDepth >= kindMax {
+			return fmt.Errorf("%s nested more than %d deep", kind, kindMax)
+		}
+		*kindDepth++
+		defer func() { *kindDepth-- }()
+	}
+	if d.depth >= MaxDepth {
+		return fmt.Errorf("containers nested more than %d deep", MaxDepth)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	return fn()
+}
+
+// remaining returns the number of bytes left in d.In, or -1 if it
+// cannot be determined.
+func (d *Decoder) remaining() int64 {
+	switch r := d.In.(type) {
+	case *io.LimitedReader:
+		return r.N
+	case interface{ Len() int }:
+		return int64(r.Len())
+	default:
+		return -1
+	}
+}
+
+// checkLength returns an error if the input cannot possibly contain n
+// more bytes.
+func (d *Decoder) checkLength(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid negative length %d", n)
+	}
+	if rem := d.remaining(); rem >= 0 && int64(n) > rem {
+		return fmt.Errorf("length %d exceeds remaining input (%d bytes): %w", n, rem, io.ErrUnexpectedEOF)
+	}
+	return nil
 }
 
 // Pad consumes padding bytes as needed to make the next read happen
 // at a multiple of align bytes. If the decoder is already correctly
 // aligned, no bytes are consumed.
+//
+// Pad accepts any positive alignment, not just the alignments used
+// by DBus types. Custom [github.com/danderson/dbus.Unmarshaler]
+// implementations can use it to match the padding inserted by
+// [Encoder.Pad].
 func (d *Decoder) Pad(align int) error {
 	extra := d.offset % align
 	if extra == 0 {
@@ -46,17 +124,35 @@ func (d *Decoder) Pad(align int) error {
 	if _, err := io.CopyN(io.Discard, d.In, int64(skip)); err != nil {
 		return err
 	}
-	d.offset = (d.offset + skip) % 8
+	d.offset += skip
 	return nil
 }
 
 // Read reads n bytes, with no framing or padding.
+//
+// Read returns an error without reading anything if d.In is known to
+// contain fewer than n bytes.
 func (d *Decoder) Read(n int) ([]byte, error) {
-	bs := make([]byte, n)
-	if _, err := io.ReadFull(d.In, bs); err != nil {
+	if err := d.checkLength(n); err != nil {
 		return nil, err
 	}
-	d.offset = (d.offset + n) % 8
+	if n <= readChunk || d.remaining() >= 0 {
+		bs := make([]byte, n)
+		if _, err := io.ReadFull(d.In, bs); err != nil {
+			return nil, err
+		}
+		d.offset += n
+		return bs, nil
+	}
+
+	bs, err := io.ReadAll(io.LimitReader(d.In, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	d.offset += n
 	return bs, nil
 }
 
@@ -69,6 +165,58 @@ func (d *Decoder) Bytes() ([]byte, error) {
 	return d.Read(int(ln))
 }
 
+// BytesTo reads a DBus byte array and copies its contents to w,
+// without buffering the entire array in memory. It returns the number
+// of bytes copied.
+func (d *Decoder) BytesTo(w io.Writer) (int, error) {
+	ln, err := d.Uint32()
+	if err != nil {
+		return 0, err
+	}
+	n := int(ln)
+	if err := d.checkLength(n); err != nil {
+		return 0, err
+	}
+	copied, err := io.CopyN(w, d.In, int64(n))
+	d.offset += int(copied)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return int(copied), err
+}
+
+// AppendBytes reads a DBus byte array and appends it to buf,
+// returning the extended buffer. Reusing buf across calls avoids
+// allocating when buf has sufficient spare capacity.
+//
+// When buf has spare capacity, the bytes are written into buf's
+// backing array, overwriting whatever other slices sharing that
+// array held there. Use [Decoder.Bytes] to always get fresh storage.
+func (d *Decoder) AppendBytes(buf []byte) ([]byte, error) {
+	ln, err := d.Uint32()
+	if err != nil {
+		return nil, err
+	}
+	n := int(ln)
+	if err := d.checkLength(n); err != nil {
+		return nil, err
+	}
+	if n > readChunk && d.remaining() < 0 {
+		bs, err := d.Read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append(buf, bs...), nil
+	}
+	buf = slices.Grow(buf, n)
+	bs := buf[len(buf) : len(buf)+n]
+	if _, err := io.ReadFull(d.In, bs); err != nil {
+		return nil, err
+	}
+	d.offset += n
+	return buf[:len(buf)+n], nil
+}
+
 // String reads a DBus string.
 func (d *Decoder) String() (string, error) {
 	ln, err := d.Uint32()
@@ -174,11 +322,17 @@ func (d *Decoder) Value(ctx context.Context, v any) error {
 // containsStructs only affects the size and alignment of the struct
 // header. When reading an array of structs, the caller must also use
 // [Decoder.Struct] appropriately to align the reads of each element.
+//
+// Array returns an error without reading any elements if the array is
+// longer than [MaxArrayLength], or longer than the remaining input.
 func (d *Decoder) Array(containsStructs bool, readElement func(int) error) (int, error) {
 	ln, err := d.Uint32()
 	if err != nil {
 		return 0, err
 	}
+	if ln > MaxArrayLength {
+		return 0, fmt.Errorf("array length %d exceeds maximum of %d bytes", ln, MaxArrayLength)
+	}
 	if containsStructs {
 		if err := d.Pad(8); err != nil {
 			return 0, err
@@ -187,6 +341,9 @@ func (d *Decoder) Array(containsStructs bool, readElement func(int) error) (int,
 	if ln == 0 {
 		return 0, nil
 	}
+	if err := d.checkLength(int(ln)); err != nil {
+		return 0, err
+	}
 	outerReader := d.In
 	limit := &io.LimitedReader{
 		R: outerReader,
@@ -197,15 +354,113 @@ func (d *Decoder) Array(containsStructs bool, readElement func(int) error) (int,
 		d.In = outerReader
 	}()
 	idx := 0
-	for limit.N > 0 {
-		if err := readElement(idx); err != nil {
-			return idx, err
+	err = d.nest(&d.arrayDepth, MaxArrayDepth, "arrays", func() error {
+		for limit.N > 0 {
+			before := limit.N
+			if err := readElement(idx); err != nil {
+				return err
+			}
+			if limit.N == before {
+				return fmt.Errorf("array element %d consumed no input", idx)
+			}
+			idx++
 		}
-		idx++
+		return nil
+	})
+	if err != nil {
+		return idx, err
 	}
 	return idx + 1, nil
 }
 
+// ArrayStream reads an array, calling readElement once for each
+// array element.
+//
+// ArrayStream is like [Decoder.Array], but additionally aligns the
+// start of each element to an 8 byte boundary if containsStructs is
+// true, and passes the decoder to readElement. This makes it
+// convenient to process large arrays incrementally, without
+// buffering all elements in memory.
+//
+// ArrayStream returns the total number of array elements that were
+// processed.
+func (d *Decoder) ArrayStream(containsStructs bool, readElement func(idx int, d *Decoder) error) (int, error) {
+	n := 0
+	_, err := d.Array(containsStructs, func(idx int) error {
+		if containsStructs {
+			if err := d.Pad(8); err != nil {
+				return err
+			}
+		}
+		if err := readElement(idx, d); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// FixedArray reads a DBus array whose elements are fixed-size numeric
+// values of elemSize bytes, which must be 2, 4 or 8.
+//
+// FixedArray calls buf with the number of elements in the array, and
+// reads the array's contents directly into the returned slice, which
+// must be exactly that many elements long. Elements are converted
+// in place from d.Order to the machine's native byte order, such that
+// the returned buffer can be reinterpreted as a slice of native
+// numeric values.
+//
+// FixedArray is equivalent to reading the array with [Decoder.Array]
+// one element at a time, but much faster for large arrays.
+func (d *Decoder) FixedArray(elemSize int, buf func(n int) []byte) error {
+	switch elemSize {
+	case 2, 4, 8:
+	default:
+		return fmt.Errorf("invalid fixed array element size %d", elemSize)
+	}
+	ln, err := d.Uint32()
+	if err != nil {
+		return err
+	}
+	if ln > MaxArrayLength {
+		return fmt.Errorf("array length %d exceeds maximum of %d bytes", ln, MaxArrayLength)
+	}
+	if err := d.Pad(elemSize); err != nil {
+		return err
+	}
+	n := int(ln)
+	if n%elemSize != 0 {
+		return fmt.Errorf("array length %d is not a multiple of element size %d", n, elemSize)
+	}
+	if err := d.checkLength(n); err != nil {
+		return err
+	}
+	return d.nest(&d.arrayDepth, MaxArrayDepth, "arrays", func() error {
+		var bs []byte
+		if n > readChunk && d.remaining() < 0 {
+			// Don't trust the length prefix with a huge allocation,
+			// until the data has actually arrived.
+			raw, err := d.Read(n)
+			if err != nil {
+				return err
+			}
+			bs = buf(n / elemSize)
+			copy(bs, raw)
+		} else {
+			bs = buf(n / elemSize)
+			if _, err := io.ReadFull(d.In, bs); err != nil {
+				return err
+			}
+			d.offset += n
+		}
+		if !isNative(d.Order) {
+			swapBytes(bs, elemSize)
+		}
+		return nil
+	})
+}
+
 // Struct reads a struct.
 //
 // Struct fields must be read within the provided fields function.
@@ -213,7 +468,121 @@ func (d *Decoder) Struct(fields func() error) error {
 	if err := d.Pad(8); err != nil {
 		return err
 	}
-	return fields()
+	return d.nest(&d.structDepth, MaxStructDepth, "structs", fields)
+}
+
+// Variant reads a variant's value, which must be read within the
+// provided value function after reading the variant's signature.
+//
+// Variant only tracks the container nesting depth, so that
+// maliciously deep nesting of variants is rejected.
+func (d *Decoder) Variant(value func() error) error {
+	return d.nest(nil, 0, "variants", value)
+}
+
+// Skip reads and discards one value of each complete type in sig, in
+// order. sig is a DBus type signature, such as "a{sv}" or "ibs".
+//
+// Skip applies the same alignment and padding rules as the other
+// Decoder methods, but does not allocate values for the skipped
+// data, or validate the contents of skipped strings and arrays.
+// Custom [github.com/danderson/dbus.Unmarshaler] implementations can
+// use it to cheaply ignore fields they don't care about.
+func (d *Decoder) Skip(sig string) error {
+	for sig != "" {
+		n, err := NextType(sig)
+		if err != nil {
+			return err
+		}
+		if err := d.skip(sig[:n]); err != nil {
+			return err
+		}
+		sig = sig[n:]
+	}
+	return nil
+}
+
+// skip reads and discards a value of the single complete type sig.
+func (d *Decoder) skip(sig string) error {
+	switch sig[0] {
+	case 'y':
+		return d.discard(1)
+	case 'n', 'q':
+		if err := d.Pad(2); err != nil {
+			return err
+		}
+		return d.discard(2)
+	case 'b', 'i', 'u', 'h':
+		if err := d.Pad(4); err != nil {
+			return err
+		}
+		return d.discard(4)
+	case 'x', 't', 'd':
+		if err := d.Pad(8); err != nil {
+			return err
+		}
+		return d.discard(8)
+	case 's', 'o':
+		ln, err := d.Uint32()
+		if err != nil {
+			return err
+		}
+		return d.discard(int(ln) + 1)
+	case 'g':
+		ln, err := d.Uint8()
+		if err != nil {
+			return err
+		}
+		return d.discard(int(ln) + 1)
+	case 'v':
+		inner, err := d.Signature()
+		if err != nil {
+			return err
+		}
+		if n, err := NextType(inner); err != nil {
+			return err
+		} else if n != len(inner) {
+			return fmt.Errorf("variant signature %q is not a single complete type", inner)
+		}
+		return d.Variant(func() error {
+			return d.skip(inner)
+		})
+	case 'a':
+		// Arrays carry their length in bytes, so the elements can be
+		// skipped in one go once the header is consumed.
+		ln, err := d.Uint32()
+		if err != nil {
+			return err
+		}
+		if ln > MaxArrayLength {
+			return fmt.Errorf("array length %d exceeds maximum of %d bytes", ln, MaxArrayLength)
+		}
+		if err := d.Pad(TypeAlignment(sig[1])); err != nil {
+			return err
+		}
+		return d.discard(int(ln))
+	case '(', '{':
+		return d.Struct(func() error {
+			return d.Skip(sig[1 : len(sig)-1])
+		})
+	default:
+		return fmt.Errorf("unknown type %q in signature", sig[0])
+	}
+}
+
+// discard reads and discards n bytes.
+func (d *Decoder) discard(n int) error {
+	if err := d.checkLength(n); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(io.Discard, d.In, int64(n)); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	d.offset += n
+	return nil
 }
 
 // ByteOrderFlag reads a DBus byte order flag byte, and sets the
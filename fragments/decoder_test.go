@@ -3,8 +3,12 @@ package fragments_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"runtime"
+	"slices"
 	"testing"
 
 	"github.com/danderson/dbus/fragments"
@@ -42,6 +46,22 @@ func (d *mustDecoder) MustBytes(want []byte) {
 	}
 }
 
+func (d *mustDecoder) MustAppendBytes(buf, want []byte) {
+	got, err := d.AppendBytes(buf)
+	if err != nil {
+		d.t.Fatalf("AppendBytes() got err: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		d.t.Fatalf("AppendBytes() wrong output:\n  got: % x\n want: % x", got, want)
+	}
+	if len(buf) > 0 && cap(buf) >= len(want) && &got[0] != &buf[0] {
+		d.t.Fatal("AppendBytes() reallocated buffer with sufficient capacity")
+	}
+	if testing.Verbose() {
+		d.t.Logf("AppendBytes() = % x", got)
+	}
+}
+
 func (d *mustDecoder) MustString(want string) {
 	got, err := d.String()
 	if err != nil {
@@ -120,6 +140,12 @@ func (d *mustDecoder) MustUint64(want uint64) {
 	}
 }
 
+func (d *mustDecoder) MustPad(align int) {
+	if err := d.Pad(align); err != nil {
+		d.t.Fatalf("Pad(%d) got err: %v", align, err)
+	}
+}
+
 func (d *mustDecoder) MustValue(want any) {
 	got := reflect.New(reflect.TypeOf(want).Elem()).Interface()
 	if err := d.Value(context.Background(), got); err != nil {
@@ -197,6 +223,23 @@ func TestDecoder(t *testing.T) {
 			},
 		},
 
+		{
+			"append byte array",
+			[]byte{
+				0x00, 0x00, 0x00, 0x03,
+				0x01, 0x02, 0x03,
+				0x00, // pad
+				0x00, 0x00, 0x00, 0x02,
+				0x04, 0x05,
+			},
+			func(d *mustDecoder) {
+				buf := make([]byte, 1, 16)
+				buf[0] = 0xff
+				d.MustAppendBytes(buf, []byte{0xff, 1, 2, 3})
+				d.MustAppendBytes(nil, []byte{4, 5})
+			},
+		},
+
 		{
 			"string",
 			[]byte{
@@ -238,6 +281,31 @@ func TestDecoder(t *testing.T) {
 			},
 		},
 
+		{
+			"explicit padding",
+			[]byte{
+				0x01,
+				0x00, 0x00, // pad
+				0x02,
+				0x00, 0x00, // pad
+				0x03,
+				0x00, 0x00, 0x00, // pad
+				0x04,
+				0x00, 0x00, 0x00, 0x00, 0x00, // pad
+			},
+			func(d *mustDecoder) {
+				d.MustUint8(1)
+				d.MustPad(3)
+				d.MustUint8(2)
+				d.MustPad(3)
+				d.MustUint8(3)
+				d.MustPad(1)
+				d.MustPad(10)
+				d.MustUint8(4)
+				d.MustPad(16)
+			},
+		},
+
 		{
 			"uints padding",
 			[]byte{
@@ -338,6 +406,40 @@ func TestDecoder(t *testing.T) {
 			},
 		},
 
+		{
+			"struct array stream",
+			[]byte{
+				0x00, 0x00, 0x00, 0x0a, // length
+				0x00, 0x00, 0x00, 0x00, // pad
+				0x00, 0x01,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // pad
+				0x00, 0x02,
+			},
+			func(d *mustDecoder) {
+				var got []uint16
+				n, err := d.ArrayStream(true, func(idx int, dec *fragments.Decoder) error {
+					if idx != len(got) {
+						d.t.Fatalf("ArrayStream() gave index %d, want %d", idx, len(got))
+					}
+					v, err := dec.Uint16()
+					if err != nil {
+						return err
+					}
+					got = append(got, v)
+					return nil
+				})
+				if err != nil {
+					d.t.Fatalf("ArrayStream() got err: %v", err)
+				}
+				if n != 2 {
+					d.t.Fatalf("ArrayStream() got size %d, want 2", n)
+				}
+				if want := []uint16{1, 2}; !slices.Equal(got, want) {
+					d.t.Fatalf("ArrayStream() read %v, want %v", got, want)
+				}
+			},
+		},
+
 		{
 			"empty struct array",
 			[]byte{
@@ -407,3 +509,282 @@ func TestDecoder(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoderLimits(t *testing.T) {
+	hugeLen := []byte{0xff, 0xff, 0xff, 0x0f, 0x01, 0x02, 0x03}
+	// opaqueReader hides the remaining input length from the
+	// Decoder, as when reading from a network stream.
+	type opaqueReader struct{ io.Reader }
+
+	tests := []struct {
+		name   string
+		in     io.Reader
+		decode func(d *fragments.Decoder) error
+	}{
+		{
+			"read past end",
+			bytes.NewReader([]byte{1, 2, 3}),
+			func(d *fragments.Decoder) error {
+				_, err := d.Read(1 << 30)
+				return err
+			},
+		},
+		{
+			"huge byte array",
+			bytes.NewReader(hugeLen),
+			func(d *fragments.Decoder) error {
+				_, err := d.Bytes()
+				return err
+			},
+		},
+		{
+			"huge byte array from stream",
+			opaqueReader{bytes.NewReader(hugeLen)},
+			func(d *fragments.Decoder) error {
+				_, err := d.AppendBytes(nil)
+				return err
+			},
+		},
+		{
+			"huge string from stream",
+			opaqueReader{bytes.NewReader(hugeLen)},
+			func(d *fragments.Decoder) error {
+				_, err := d.String()
+				return err
+			},
+		},
+		{
+			"array longer than input",
+			bytes.NewReader([]byte{0x00, 0x00, 0x10, 0x00, 0x01}),
+			func(d *fragments.Decoder) error {
+				_, err := d.Array(false, func(int) error {
+					return errors.New("readElement called for impossible array")
+				})
+				return err
+			},
+		},
+		{
+			"array longer than maximum",
+			opaqueReader{bytes.NewReader(hugeLen)},
+			func(d *fragments.Decoder) error {
+				_, err := d.Array(false, func(int) error {
+					return errors.New("readElement called for oversized array")
+				})
+				return err
+			},
+		},
+		{
+			"fixed array longer than input",
+			bytes.NewReader([]byte{0x00, 0x10, 0x00, 0x00, 0x01}),
+			func(d *fragments.Decoder) error {
+				return d.FixedArray(4, func(n int) []byte {
+					t.Error("buf called for impossible array")
+					return make([]byte, n*4)
+				})
+			},
+		},
+		{
+			"fixed array from stream",
+			opaqueReader{bytes.NewReader([]byte{0x00, 0x10, 0x00, 0x00, 0x01})},
+			func(d *fragments.Decoder) error {
+				return d.FixedArray(4, func(n int) []byte {
+					return make([]byte, n*4)
+				})
+			},
+		},
+		{
+			"array element reads nothing",
+			bytes.NewReader([]byte{0x00, 0x00, 0x00, 0x01, 0x01}),
+			func(d *fragments.Decoder) error {
+				_, err := d.Array(false, func(int) error { return nil })
+				return err
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &fragments.Decoder{
+				Order: fragments.BigEndian,
+				In:    tc.in,
+			}
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			err := tc.decode(d)
+			runtime.ReadMemStats(&after)
+			if err == nil {
+				t.Fatal("decode succeeded, want error")
+			}
+			if testing.Verbose() {
+				t.Logf("decode error: %v", err)
+			}
+			if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+				t.Errorf("decode allocated %d bytes before failing", alloc)
+			}
+		})
+	}
+}
+
+func TestDecoderDepth(t *testing.T) {
+	// nestedArrays returns the big-endian encoding of n nested
+	// arrays, with a single uint32 at the bottom.
+	nestedArrays := func(n int) []byte {
+		ret := []byte{0, 0, 0, 42}
+		for range n {
+			ret = append([]byte{0, 0, 0, byte(len(ret))}, ret...)
+		}
+		return ret
+	}
+	arrays := func(n int) func(d *fragments.Decoder) error {
+		return func(d *fragments.Decoder) error {
+			var read func(int) error
+			read = func(depth int) error {
+				_, err := d.Array(false, func(int) error {
+					if depth == n {
+						_, err := d.Uint32()
+						return err
+					}
+					return read(depth + 1)
+				})
+				return err
+			}
+			return read(1)
+		}
+	}
+
+	structs := func(n int) func(d *fragments.Decoder) error {
+		return func(d *fragments.Decoder) error {
+			var read func(int) error
+			read = func(depth int) error {
+				return d.Struct(func() error {
+					if depth == n {
+						_, err := d.Uint8()
+						return err
+					}
+					return read(depth + 1)
+				})
+			}
+			return read(1)
+		}
+	}
+
+	variants := func(n int) func(d *fragments.Decoder) error {
+		return func(d *fragments.Decoder) error {
+			var read func(int) error
+			read = func(depth int) error {
+				return d.Variant(func() error {
+					if depth == n {
+						_, err := d.Uint8()
+						return err
+					}
+					return read(depth + 1)
+				})
+			}
+			return read(1)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		in      []byte
+		decode  func(d *fragments.Decoder) error
+		wantErr bool
+	}{
+		{"max arrays", nestedArrays(fragments.MaxArrayDepth), arrays(fragments.MaxArrayDepth), false},
+		{"too many arrays", nestedArrays(fragments.MaxArrayDepth + 1), arrays(fragments.MaxArrayDepth + 1), true},
+		{"max structs", []byte{1}, structs(fragments.MaxStructDepth), false},
+		{"too many structs", []byte{1}, structs(fragments.MaxStructDepth + 1), true},
+		{"max variants", []byte{1}, variants(fragments.MaxDepth), false},
+		{"too many variants", []byte{1}, variants(fragments.MaxDepth + 1), true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &fragments.Decoder{
+				Order: fragments.BigEndian,
+				In:    bytes.NewReader(tc.in),
+			}
+			err := tc.decode(d)
+			if tc.wantErr && err == nil {
+				t.Fatal("decode succeeded, want error")
+			} else if !tc.wantErr && err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if testing.Verbose() && err != nil {
+				t.Logf("decode error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecoderSkip(t *testing.T) {
+	enc := fragments.Encoder{Order: fragments.BigEndian}
+	enc.Uint8(1)
+	enc.String("foo")
+	enc.Struct(func() error {
+		enc.Uint16(2)
+		enc.Uint64(3)
+		return nil
+	})
+	enc.Array(true, func() error {
+		for _, s := range []string{"a", "bc"} {
+			enc.Struct(func() error {
+				enc.Uint8(4)
+				enc.Signature("s")
+				enc.String(s)
+				return nil
+			})
+		}
+		return nil
+	})
+	enc.FixedArray(8, make([]byte, 16))
+	enc.Signature("ai")
+	enc.Array(false, func() error {
+		enc.Uint32(5)
+		return nil
+	})
+	enc.Uint32(42)
+	enc.Uint8(1)
+
+	tests := []struct {
+		name    string
+		sig     string
+		wantErr bool
+	}{
+		{"all", "ys(qt)a(yv)atv", false},
+		{"empty", "", false},
+		{"wrong type", "ys(qt)a(yv)atvs", true},
+		{"unknown type", "yz", true},
+		{"incomplete array", "ysa", true},
+		{"unclosed struct", "ys(qt", true},
+		{"unopened struct", "ys)", true},
+		{"mismatched struct", "ys(qt}", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &fragments.Decoder{
+				Order: fragments.BigEndian,
+				In:    bytes.NewReader(enc.Out),
+			}
+			err := d.Skip(tc.sig)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Skip(%q) succeeded, want error", tc.sig)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Skip(%q) failed: %v", tc.sig, err)
+			}
+			if tc.sig == "" {
+				return
+			}
+			got, err := d.Uint32()
+			if err != nil {
+				t.Fatalf("reading value after skipped data: %v", err)
+			}
+			if got != 42 {
+				t.Errorf("value after skipped data is %d, want 42", got)
+			}
+		})
+	}
+}
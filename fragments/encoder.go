@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 )
 
@@ -11,10 +12,26 @@ import (
 type EncoderFunc func(ctx context.Context, enc *Encoder, val reflect.Value) error
 
 // An Encoder provides utilities to write a DBus wire format message
-// to a byte slice.
+// to a byte slice, or to an [io.Writer].
 //
 // Methods insert padding as needed to conform to DBus alignment
 // rules, except for [Encoder.Write] which outputs bytes verbatim.
+//
+// If W is set, the Encoder streams its output: Out becomes a buffer
+// whose contents are periodically written to W, and the caller must
+// call [Encoder.Flush] once encoding is complete. Because a DBus
+// array begins with its length in bytes, output within an
+// [Encoder.Array] is held in Out until the array is complete. Use
+// [Encoder.SizedArray] or [Encoder.BytesFrom] to stream arrays whose
+// size is known in advance without buffering them.
+//
+// Streaming is only available to callers that drive an Encoder
+// directly, for example to write DBus-encoded data to a file or
+// socket. [github.com/danderson/dbus.Conn] does not stream: it always
+// encodes complete message bodies in memory, because the message
+// header that precedes the body on the wire includes the body's
+// length, and the maximum message size limits bodies to 128MiB
+// anyway.
 type Encoder struct {
 	// Order is the byte order to use when encoding multi-byte values.
 	Order ByteOrder
@@ -22,26 +39,81 @@ type Encoder struct {
 	// Encoder.Value. If mapper is nil, the Encoder functions normally
 	// except that Encoder.Value always returns an error.
 	Mapper func(reflect.Type) (EncoderFunc, error)
-	// Out is the encoded output.
+	// Out is the encoded output. If W is set, Out holds only the
+	// output that has not yet been written to W.
 	Out []byte
+	// W, if set, receives the encoded output incrementally.
+	W io.Writer
+
+	flushed  int   // bytes written to W
+	unsized  int   // number of open Arrays, which block flushing
+	writeErr error // first error returned by W
+}
+
+// flushThreshold is the amount of buffered output at which a
+// streaming Encoder writes to W.
+const flushThreshold = 64 << 10
+
+// offset returns the total number of bytes encoded so far.
+func (e *Encoder) offset() int {
+	return e.flushed + len(e.Out)
+}
+
+// Flush writes any buffered output to W. It returns an error if W
+// returned an error for any write, or if called within
+// [Encoder.Array]. Flush does nothing if W is nil.
+//
+// Once W has returned an error, the Encoder stops writing to W and
+// discards further output instead of buffering it, and Flush returns
+// that first error.
+func (e *Encoder) Flush() error {
+	if e.W == nil {
+		return nil
+	}
+	if e.unsized > 0 {
+		return errors.New("cannot flush Encoder within an unsized Array")
+	}
+	if e.writeErr == nil && len(e.Out) > 0 {
+		if _, err := e.W.Write(e.Out); err != nil {
+			e.writeErr = err
+		}
+	}
+	e.flushed += len(e.Out)
+	e.Out = e.Out[:0]
+	return e.writeErr
+}
+
+// maybeFlush flushes buffered output if there is enough of it, and
+// flushing is allowed.
+func (e *Encoder) maybeFlush() {
+	if e.W != nil && e.unsized == 0 && len(e.Out) >= flushThreshold {
+		e.Flush()
+	}
 }
 
 // Pad inserts padding bytes as needed to make the next write start at
 // a multiple of align bytes. If the message is already correctly
 // aligned, no padding is inserted.
+//
+// Pad accepts any positive alignment, not just the alignments used
+// by DBus types. Custom [github.com/danderson/dbus.Marshaler]
+// implementations can use it to align values to arbitrary
+// boundaries.
 func (e *Encoder) Pad(align int) {
-	extra := len(e.Out) % align
+	extra := e.offset() % align
 	if extra == 0 {
 		return
 	}
-	var pad [8]byte
-	e.Out = append(e.Out, pad[:align-extra]...)
+	for range align - extra {
+		e.Out = append(e.Out, 0)
+	}
 }
 
 // Write writes bs as-is to the output. It is the caller's
 // responsibility to ensure correct padding and encoding.
 func (e *Encoder) Write(bs []byte) {
 	e.Out = append(e.Out, bs...)
+	e.maybeFlush()
 }
 
 // Bytes writes a DBus byte array.
@@ -49,6 +121,78 @@ func (e *Encoder) Bytes(bs []byte) {
 	e.Pad(4)
 	e.Uint32(uint32(len(bs)))
 	e.Out = append(e.Out, bs...)
+	e.maybeFlush()
+}
+
+// FixedArray writes a DBus array whose elements are fixed-size
+// numeric values of elemSize bytes, which must be 2, 4 or 8.
+//
+// bs is the array's contents, as elements in the machine's native
+// byte order, such as a slice of uint32 reinterpreted as bytes.
+// FixedArray converts the elements to e.Order as it writes them.
+//
+// FixedArray is equivalent to writing the elements one at a time
+// within [Encoder.Array], but much faster for large arrays.
+func (e *Encoder) FixedArray(elemSize int, bs []byte) error {
+	switch elemSize {
+	case 2, 4, 8:
+	default:
+		return fmt.Errorf("invalid fixed array element size %d", elemSize)
+	}
+	if len(bs)%elemSize != 0 {
+		return fmt.Errorf("fixed array length %d is not a multiple of element size %d", len(bs), elemSize)
+	}
+	e.Pad(4)
+	e.Uint32(uint32(len(bs)))
+	e.Pad(elemSize)
+	start := len(e.Out)
+	e.Out = append(e.Out, bs...)
+	if !isNative(e.Order) {
+		swapBytes(e.Out[start:], elemSize)
+	}
+	e.maybeFlush()
+	return nil
+}
+
+// BytesFrom writes a DBus byte array of n bytes read from r.
+//
+// If the Encoder is streaming to [Encoder.W] and is not within an
+// [Encoder.Array], the bytes are copied to W in chunks, without
+// buffering the entire array in memory.
+//
+// BytesFrom returns an error without writing anything if n is
+// negative or larger than [MaxArrayLength]. If reading from r fails,
+// BytesFrom removes the partially written array from the output and
+// returns the error. If part of the array was already written to W,
+// the output cannot be repaired, and the Encoder stops writing to W
+// as if W had returned the error.
+func (e *Encoder) BytesFrom(r io.Reader, n int) error {
+	if err := checkArrayLength(n); err != nil {
+		return err
+	}
+	start, flushed := len(e.Out), e.flushed
+	e.Pad(4)
+	e.Uint32(uint32(n))
+	for n > 0 {
+		if e.writeErr != nil {
+			return e.writeErr
+		}
+		chunk := min(n, flushThreshold)
+		off := len(e.Out)
+		e.Out = append(e.Out, make([]byte, chunk)...)
+		if _, err := io.ReadFull(r, e.Out[off:]); err != nil {
+			if e.flushed == flushed {
+				e.Out = e.Out[:start]
+			} else {
+				e.Out = e.Out[:0]
+				e.writeErr = fmt.Errorf("reading byte array after partial write: %w", err)
+			}
+			return err
+		}
+		n -= chunk
+		e.maybeFlush()
+	}
+	return nil
 }
 
 // String writes a DBus string.
@@ -57,6 +201,7 @@ func (e *Encoder) String(s string) {
 	e.Uint32(uint32(len(s)))
 	e.Out = append(e.Out, s...)
 	e.Out = append(e.Out, 0)
+	e.maybeFlush()
 }
 
 // Signature writes a DBus signature.
@@ -99,7 +244,11 @@ func (e *Encoder) Value(ctx context.Context, v any) error {
 	if err != nil {
 		return fmt.Errorf("getting encoder for %T: %w", v, err)
 	}
-	return fn(ctx, e, reflect.ValueOf(v))
+	if err := fn(ctx, e, reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	e.maybeFlush()
+	return nil
 }
 
 // Array writes an array to the output.
@@ -110,6 +259,10 @@ func (e *Encoder) Value(ctx context.Context, v any) error {
 //
 // containsStructs indicates whether the array's elements are structs,
 // so that the array header can be padded accordingly.
+//
+// The array's length is filled in once all elements have been
+// written, so a streaming Encoder buffers the entire array in
+// memory. Use [Encoder.SizedArray] to avoid this.
 func (e *Encoder) Array(containsStructs bool, elements func() error) error {
 	e.Pad(4)
 	offset := len(e.Out)
@@ -118,14 +271,58 @@ func (e *Encoder) Array(containsStructs bool, elements func() error) error {
 		e.Pad(8)
 	}
 
+	e.unsized++
 	start := len(e.Out)
 	err := elements()
 	end := len(e.Out)
+	e.unsized--
 	e.Order.PutUint32(e.Out[offset:], uint32(end-start))
 
 	return err
 }
 
+// SizedArray writes an array whose encoded length in bytes is known in
+// advance.
+//
+// SizedArray is like [Encoder.Array], except that the array length is
+// written before the elements. This allows a streaming Encoder to
+// write the elements to [Encoder.W] as they are encoded. SizedArray
+// returns an error if the elements function does not write exactly
+// size bytes, not counting the header padding requested by
+// containsStructs. Like [Encoder.BytesFrom], it returns an error
+// without writing anything if size is out of range.
+func (e *Encoder) SizedArray(size int, containsStructs bool, elements func() error) error {
+	if err := checkArrayLength(size); err != nil {
+		return err
+	}
+	e.Pad(4)
+	e.Uint32(uint32(size))
+	if containsStructs {
+		e.Pad(8)
+	}
+
+	start := e.offset()
+	if err := elements(); err != nil {
+		return err
+	}
+	if got := e.offset() - start; got != size {
+		return fmt.Errorf("SizedArray elements wrote %d bytes, want %d", got, size)
+	}
+	return nil
+}
+
+// checkArrayLength returns an error if n is not a valid DBus array
+// length.
+func checkArrayLength(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid negative array length %d", n)
+	}
+	if n > MaxArrayLength {
+		return fmt.Errorf("array length %d exceeds maximum of %d bytes", n, MaxArrayLength)
+	}
+	return nil
+}
+
 // Struct writes a struct to the output.
 //
 // Struct fields must be added within the provided elements function.
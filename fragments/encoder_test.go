@@ -3,7 +3,11 @@ package fragments_test
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
+	"io"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/danderson/dbus/fragments"
@@ -99,6 +103,31 @@ func TestEncoder(t *testing.T) {
 			},
 		},
 
+		{
+			"explicit padding",
+			func(e *fragments.Encoder) {
+				e.Uint8(1)
+				e.Pad(3)
+				e.Uint8(2)
+				e.Pad(3)
+				e.Uint8(3)
+				e.Pad(1)
+				e.Pad(10)
+				e.Uint8(4)
+				e.Pad(16)
+			},
+			[]byte{
+				0x01,
+				0x00, 0x00, // pad
+				0x02,
+				0x00, 0x00, // pad
+				0x03,
+				0x00, 0x00, 0x00, // pad
+				0x04,
+				0x00, 0x00, 0x00, 0x00, 0x00, // pad
+			},
+		},
+
 		{
 			"struct padding",
 			func(e *fragments.Encoder) {
@@ -252,6 +281,35 @@ func TestEncoder(t *testing.T) {
 			},
 		},
 
+		{
+			"fixed array",
+			func(e *fragments.Encoder) {
+				e.Uint8(1)
+				var bs []byte
+				bs = binary.NativeEndian.AppendUint16(bs, 0x0203)
+				bs = binary.NativeEndian.AppendUint16(bs, 0x0405)
+				e.FixedArray(2, bs)
+			},
+			[]byte{
+				0x01,             // uint8
+				0x00, 0x00, 0x00, // pad
+				0x00, 0x00, 0x00, 0x04, // length
+				0x02, 0x03, 0x04, 0x05, // vals
+			},
+		},
+
+		{
+			"fixed array of 64-bit values",
+			func(e *fragments.Encoder) {
+				e.FixedArray(8, binary.NativeEndian.AppendUint64(nil, 0x0102030405060708))
+			},
+			[]byte{
+				0x00, 0x00, 0x00, 0x08, // length
+				0x00, 0x00, 0x00, 0x00, // pad, not included in length
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // val
+			},
+		},
+
 		{
 			"byte order flag",
 			func(e *fragments.Encoder) {
@@ -278,3 +336,175 @@ func TestEncoder(t *testing.T) {
 		})
 	}
 }
+
+func TestEncoderStreaming(t *testing.T) {
+	data := make([]byte, 1<<20)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	u32s := []uint32{1, 2, 3, 4, 5}
+
+	var want fragments.Encoder
+	want.Order = fragments.BigEndian
+	want.Uint8(42)
+	want.Bytes(data)
+	want.Array(false, func() error {
+		for _, u := range u32s {
+			want.Uint32(u)
+		}
+		return nil
+	})
+	want.String("end")
+
+	var (
+		out       bytes.Buffer
+		maxBuffer int
+	)
+	e := fragments.Encoder{
+		Order: fragments.BigEndian,
+		W:     &out,
+	}
+	e.Uint8(42)
+	if err := e.BytesFrom(bytes.NewReader(data), len(data)); err != nil {
+		t.Fatalf("BytesFrom failed: %v", err)
+	}
+	maxBuffer = max(maxBuffer, cap(e.Out))
+	err := e.SizedArray(4*len(u32s), false, func() error {
+		for _, u := range u32s {
+			e.Uint32(u)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SizedArray failed: %v", err)
+	}
+	e.String("end")
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), want.Out) {
+		t.Fatalf("streamed output differs from buffered output (got %d bytes, want %d)", out.Len(), len(want.Out))
+	}
+	if maxBuffer >= len(data) {
+		t.Errorf("streaming Encoder buffered %d bytes, want less than %d", maxBuffer, len(data))
+	}
+
+	d := fragments.Decoder{
+		Order: fragments.BigEndian,
+		In:    bytes.NewReader(out.Bytes()),
+	}
+	if _, err := d.Uint8(); err != nil {
+		t.Fatalf("Uint8 failed: %v", err)
+	}
+	var got bytes.Buffer
+	if n, err := d.BytesTo(&got); err != nil {
+		t.Fatalf("BytesTo failed: %v", err)
+	} else if n != len(data) || !bytes.Equal(got.Bytes(), data) {
+		t.Fatalf("BytesTo copied %d bytes, want %d matching bytes", n, len(data))
+	}
+}
+
+func TestEncoderStreamingErrors(t *testing.T) {
+	e := fragments.Encoder{
+		Order: fragments.BigEndian,
+		W:     io.Discard,
+	}
+	err := e.SizedArray(8, false, func() error {
+		e.Uint32(1)
+		return nil
+	})
+	if err == nil {
+		t.Error("SizedArray with wrong size succeeded, want error")
+	}
+
+	e.Array(false, func() error {
+		if err := e.Flush(); err == nil {
+			t.Error("Flush within Array succeeded, want error")
+		}
+		return nil
+	})
+
+	before := slices.Clone(e.Out)
+	if err := e.BytesFrom(bytes.NewReader([]byte{1, 2}), 3); err == nil {
+		t.Error("BytesFrom with short reader succeeded, want error")
+	}
+	if !bytes.Equal(e.Out, before) {
+		t.Errorf("BytesFrom with short reader left output % x, want % x", e.Out, before)
+	}
+
+	var out bytes.Buffer
+	e = fragments.Encoder{
+		Order: fragments.BigEndian,
+		W:     &out,
+	}
+	if err := e.BytesFrom(bytes.NewReader(make([]byte, 100<<10)), 200<<10); err == nil {
+		t.Error("BytesFrom with short reader succeeded, want error")
+	}
+	e.String("more")
+	if err := e.Flush(); err == nil {
+		t.Error("Flush after partially written BytesFrom succeeded, want error")
+	}
+	if out.Len() > 100<<10 {
+		t.Errorf("Encoder wrote %d bytes after failed BytesFrom, want at most %d", out.Len(), 100<<10)
+	}
+
+	for _, n := range []int{-1, fragments.MaxArrayLength + 1} {
+		e := fragments.Encoder{Order: fragments.BigEndian}
+		if err := e.BytesFrom(bytes.NewReader(nil), n); err == nil {
+			t.Errorf("BytesFrom with length %d succeeded, want error", n)
+		}
+		if err := e.SizedArray(n, false, func() error { return nil }); err == nil {
+			t.Errorf("SizedArray with length %d succeeded, want error", n)
+		}
+		if len(e.Out) != 0 {
+			t.Errorf("invalid array lengths wrote % x, want no output", e.Out)
+		}
+	}
+}
+
+// failWriter accepts limit bytes, then fails every write.
+type failWriter struct {
+	limit  int
+	writes int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failWriter) Write(bs []byte) (int, error) {
+	w.writes++
+	if len(bs) > w.limit {
+		w.limit = 0
+		return 0, errWriteFailed
+	}
+	w.limit -= len(bs)
+	return len(bs), nil
+}
+
+func TestEncoderStreamingWriteError(t *testing.T) {
+	chunk := make([]byte, 1<<10)
+	w := &failWriter{limit: 100 << 10}
+	e := fragments.Encoder{
+		Order: fragments.BigEndian,
+		W:     w,
+	}
+	maxBuffer := 0
+	for range 1 << 10 {
+		e.Bytes(chunk)
+		maxBuffer = max(maxBuffer, len(e.Out))
+	}
+	if maxBuffer >= 256<<10 {
+		t.Errorf("Encoder buffered %d bytes after write error, want less than %d", maxBuffer, 256<<10)
+	}
+	writes := w.writes
+
+	if err := e.BytesFrom(bytes.NewReader(chunk), len(chunk)); !errors.Is(err, errWriteFailed) {
+		t.Errorf("BytesFrom after write error = %v, want %v", err, errWriteFailed)
+	}
+	if err := e.Flush(); !errors.Is(err, errWriteFailed) {
+		t.Errorf("Flush after write error = %v, want %v", err, errWriteFailed)
+	}
+	if w.writes != writes {
+		t.Errorf("Encoder wrote to W %d more times after error", w.writes-writes)
+	}
+}
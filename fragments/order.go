@@ -2,6 +2,8 @@ package fragments
 
 import (
 	"encoding/binary"
+	"fmt"
+	"math/bits"
 
 	"golang.org/x/sys/cpu"
 )
@@ -41,3 +43,29 @@ var (
 	LittleEndian = wrapStd{binary.LittleEndian}
 	NativeEndian = wrapStd{binary.NativeEndian}
 )
+
+// isNative reports whether o is the machine's native byte order.
+func isNative(o ByteOrder) bool {
+	return o.dbusFlag() == NativeEndian.dbusFlag()
+}
+
+// swapBytes reverses the byte order of each elemSize-byte value in
+// bs. elemSize must be 2, 4 or 8.
+func swapBytes(bs []byte, elemSize int) {
+	switch elemSize {
+	case 2:
+		for i := 0; i < len(bs); i += 2 {
+			bs[i], bs[i+1] = bs[i+1], bs[i]
+		}
+	case 4:
+		for i := 0; i < len(bs); i += 4 {
+			binary.NativeEndian.PutUint32(bs[i:], bits.ReverseBytes32(binary.NativeEndian.Uint32(bs[i:])))
+		}
+	case 8:
+		for i := 0; i < len(bs); i += 8 {
+			binary.NativeEndian.PutUint64(bs[i:], bits.ReverseBytes64(binary.NativeEndian.Uint64(bs[i:])))
+		}
+	default:
+		panic(fmt.Sprintf("invalid element size %d", elemSize))
+	}
+}
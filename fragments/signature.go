@@ -0,0 +1,105 @@
+package fragments
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NextType returns the length of the first complete type in the DBus
+// type signature sig.
+//
+// NextType returns an error if sig does not start with a valid
+// complete type, or if that type's containers are nested deeper than
+// the DBus specification allows.
+func NextType(sig string) (int, error) {
+	rest, err := scanType(sig, false, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	return len(sig) - len(rest), nil
+}
+
+// TypeAlignment returns the DBus wire alignment of values whose type
+// signature starts with c. It is always one of 1, 2, 4 or 8.
+func TypeAlignment(c byte) int {
+	switch c {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	default:
+		// y, g, v
+		return 1
+	}
+}
+
+const (
+	// singleTypes are the type codes that are complete types on
+	// their own.
+	singleTypes = "ybnqiuxtdsogvh"
+	// dictKeyTypes are the type codes allowed as dict entry keys.
+	dictKeyTypes = "ybnqiuxtdsogh"
+)
+
+// scanType consumes the first complete type from the front of sig and
+// returns the remainder of the type string. inArray reports whether
+// the type is an array element, and arrays and structs are the number
+// of arrays and structs that the type is nested within.
+func scanType(sig string, inArray bool, arrays, structs int) (rest string, err error) {
+	if sig == "" {
+		return "", errors.New("unexpected end of signature")
+	}
+	if strings.IndexByte(singleTypes, sig[0]) >= 0 {
+		return sig[1:], nil
+	}
+
+	switch sig[0] {
+	case 'a':
+		if arrays >= MaxArrayDepth {
+			return "", fmt.Errorf("arrays nested more than %d deep", MaxArrayDepth)
+		}
+		return scanType(sig[1:], true, arrays+1, structs)
+	case '(':
+		if structs >= MaxStructDepth {
+			return "", fmt.Errorf("structs nested more than %d deep", MaxStructDepth)
+		}
+		rest = sig[1:]
+		for rest != "" && rest[0] != ')' {
+			if rest, err = scanType(rest, false, arrays, structs+1); err != nil {
+				return "", err
+			}
+		}
+		if rest == "" {
+			return "", fmt.Errorf("missing closing ) in struct definition")
+		}
+		if len(rest) == len(sig)-1 {
+			return "", errors.New("empty struct definition")
+		}
+		return rest[1:], nil
+	case '{':
+		if !inArray {
+			return "", errors.New("dict entry type found outside array")
+		}
+		if structs >= MaxStructDepth {
+			return "", fmt.Errorf("structs nested more than %d deep", MaxStructDepth)
+		}
+		if rest, err = scanType(sig[1:], false, arrays, structs+1); err != nil {
+			return "", err
+		}
+		if strings.IndexByte(dictKeyTypes, sig[1]) < 0 {
+			return "", fmt.Errorf("invalid dict entry key type %q, must be a dbus basic type", sig[1])
+		}
+		if rest, err = scanType(rest, false, arrays, structs+1); err != nil {
+			return "", err
+		}
+		if rest == "" || rest[0] != '}' {
+			return "", errors.New("missing closing } in dict entry definition")
+		}
+		return rest[1:], nil
+	default:
+		return "", fmt.Errorf("unknown type specifier %q", sig[0])
+	}
+}
@@ -0,0 +1,48 @@
+package fragments_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danderson/dbus/fragments"
+)
+
+func TestNextType(t *testing.T) {
+	tests := []struct {
+		sig     string
+		want    int
+		wantErr string
+	}{
+		{"i", 1, ""},
+		{"is", 1, ""},
+		{"a{sv}i", 5, ""},
+		{"(ias)s", 5, ""},
+		{"aa{gh}", 6, ""},
+		{"v", 1, ""},
+
+		{"", 0, "unexpected end"},
+		{"a", 0, "unexpected end"},
+		{"()", 0, "empty struct"},
+		{"(i", 0, "missing closing )"},
+		{"{sv}", 0, "outside array"},
+		{"a{vs}", 0, "invalid dict entry key"},
+		{"a{sv", 0, "missing closing }"},
+		{"z", 0, "unknown type"},
+		{strings.Repeat("a", 33) + "i", 0, "arrays nested more than 32"},
+		{strings.Repeat("(", 33) + "i" + strings.Repeat(")", 33), 0, "structs nested more than 32"},
+	}
+	for _, tc := range tests {
+		got, err := fragments.NextType(tc.sig)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("NextType(%q) got err %v, want error containing %q", tc.sig, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NextType(%q) got err: %v", tc.sig, err)
+		} else if got != tc.want {
+			t.Errorf("NextType(%q) = %d, want %d", tc.sig, got, tc.want)
+		}
+	}
+}
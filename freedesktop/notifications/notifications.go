@@ -0,0 +1,536 @@
+// Package notifications provides an interface to the Freedesktop
+// desktop notifications DBus API.
+//
+// This corresponds to the org.freedesktop.Notifications service on
+// the session bus, which displays passive popup notifications to the
+// user. Notifications can offer actions, such as "Reply" or "Open",
+// that the user can invoke to respond to the notification.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/danderson/dbus"
+)
+
+type Notifications struct{ iface dbus.Interface }
+
+// New returns an interface to the desktop notifications service.
+func New(conn *dbus.Conn) Notifications {
+	obj := conn.Peer("org.freedesktop.Notifications").Object("/org/freedesktop/Notifications")
+	return Interface(obj)
+}
+
+// Interface returns a desktop notifications interface on the given
+// object.
+func Interface(obj dbus.Object) Notifications {
+	return Notifications{
+		iface: obj.Interface("org.freedesktop.Notifications"),
+	}
+}
+
+// ServerInfo describes the notification server.
+type ServerInfo struct {
+	// Name is the product name of the server, for example
+	// "gnome-shell".
+	Name string
+	// Vendor is the name of the server's vendor, for example
+	// "GNOME".
+	Vendor string
+	// Version is the server's version.
+	Version string
+	// SpecVersion is the version of the notifications specification
+	// that the server implements.
+	SpecVersion string
+}
+
+// ServerInfo returns information about the notification server.
+func (iface Notifications) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	var ret ServerInfo
+	err := iface.iface.Call(ctx, "GetServerInformation", nil, &ret)
+	return ret, err
+}
+
+// Capabilities returns the optional features that the notification
+// server supports, such as "actions", "body-markup" or "persistence".
+func (iface Notifications) Capabilities(ctx context.Context) ([]string, error) {
+	var ret []string
+	if err := iface.iface.Call(ctx, "GetCapabilities", nil, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Urgency is the urgency level of a notification.
+type Urgency int
+
+const (
+	// UrgencyNormal is the urgency of most notifications, such as a
+	// new chat message or a completed download.
+	UrgencyNormal Urgency = iota
+	// UrgencyLow is for informational notifications that the user
+	// can safely ignore, such as a contact coming online.
+	UrgencyLow
+	// UrgencyCritical is for notifications that the user must not
+	// miss, such as a low battery warning. Servers typically don't
+	// expire critical notifications on their own.
+	UrgencyCritical
+)
+
+func (u Urgency) String() string {
+	switch u {
+	case UrgencyNormal:
+		return "Normal"
+	case UrgencyLow:
+		return "Low"
+	case UrgencyCritical:
+		return "Critical"
+	default:
+		return fmt.Sprintf("Urgency(%d)", int(u))
+	}
+}
+
+// wire returns the urgency's value for the "urgency" hint.
+func (u Urgency) wire() (uint8, error) {
+	switch u {
+	case UrgencyLow:
+		return 0, nil
+	case UrgencyNormal:
+		return 1, nil
+	case UrgencyCritical:
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("invalid notification urgency %s", u)
+	}
+}
+
+// Action is a user action offered by a notification.
+type Action struct {
+	// Key identifies the action in [ActiveNotification.Actions]. The
+	// key "default" is the action invoked when the user clicks the
+	// notification itself.
+	Key string
+	// Label is the human-readable name of the action, shown to the
+	// user.
+	Label string
+}
+
+// NeverExpire is a [Notification.Timeout] that keeps the notification
+// visible until the user dismisses it.
+const NeverExpire time.Duration = -1
+
+// Notification describes a notification to show.
+type Notification struct {
+	// AppName is the human-readable name of the application sending
+	// the notification.
+	AppName string
+	// Replaces, if nonzero, is the ID of an existing notification
+	// that the new notification atomically replaces.
+	Replaces uint32
+	// Icon is the name of a themed icon, or a file:// URI of an
+	// image, to show with the notification.
+	Icon string
+	// Summary is a single line overview of the notification.
+	Summary string
+	// Body is a more detailed description of the notification. Some
+	// servers support a subset of HTML markup in the body, which is
+	// reported by the "body-markup" capability.
+	Body string
+	// Actions are the actions that the user can invoke on the
+	// notification. Actions are only shown by servers that report
+	// the "actions" capability.
+	Actions []Action
+	// Timeout is how long the notification is shown before it
+	// expires. If zero, the server picks a timeout. If
+	// [NeverExpire], the notification is shown until the user
+	// dismisses it.
+	Timeout time.Duration
+
+	// Urgency is the urgency level of the notification.
+	Urgency Urgency
+	// Category is the type of notification, such as "email.arrived"
+	// or "device.added".
+	Category string
+	// Resident, if true, keeps the notification around after the
+	// user invokes one of its actions. By default, invoking an
+	// action closes the notification.
+	Resident bool
+	// Transient, if true, asks the server to not save the
+	// notification in its notification history.
+	Transient bool
+
+	// Hints are additional hints for the notification server, keyed
+	// by hint name. Hints overrides the values of the typed hint
+	// fields above.
+	Hints map[string]any
+}
+
+// ImageData is the value of the "image-data" notification hint,
+// which is an image to show with the notification.
+//
+// Use [ImageHint] to construct an ImageData from an [image.Image].
+type ImageData struct {
+	Width  int32
+	Height int32
+	// RowStride is the number of bytes between the start of
+	// consecutive rows in Data.
+	RowStride int32
+	// HasAlpha is whether pixels have an alpha channel.
+	HasAlpha bool
+	// BitsPerSample is the size of each color channel in
+	// bits. ImageHint always uses 8 bits per sample.
+	BitsPerSample int32
+	// Channels is the number of channels per pixel: 3 for RGB, or 4
+	// for RGBA.
+	Channels int32
+	// Data is the image's pixels, in row-major order. Alpha is not
+	// premultiplied.
+	Data []byte
+}
+
+// ImageHint returns the hint name and value that attach img to a
+// notification. The results can be added directly to
+// [Notification.Hints].
+//
+// If img reports itself as opaque, ImageHint encodes it as RGB to
+// save space. Otherwise, it is encoded as non-premultiplied RGBA.
+func ImageHint(img image.Image) (name string, value any) {
+	b := img.Bounds()
+	channels := 4
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		channels = 3
+	}
+	stride := b.Dx() * channels
+	data := make([]byte, 0, stride*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			data = append(data, c.R, c.G, c.B)
+			if channels == 4 {
+				data = append(data, c.A)
+			}
+		}
+	}
+	return "image-data", ImageData{
+		Width:         int32(b.Dx()),
+		Height:        int32(b.Dy()),
+		RowStride:     int32(stride),
+		HasAlpha:      channels == 4,
+		BitsPerSample: 8,
+		Channels:      int32(channels),
+		Data:          data,
+	}
+}
+
+type notifyRequest struct {
+	AppName  string
+	Replaces uint32
+	Icon     string
+	Summary  string
+	Body     string
+	Actions  []string
+	Hints    map[string]any
+	Timeout  int32
+}
+
+func (n *Notification) request() (*notifyRequest, error) {
+	urgency, err := n.Urgency.wire()
+	if err != nil {
+		return nil, err
+	}
+	ret := &notifyRequest{
+		AppName:  n.AppName,
+		Replaces: n.Replaces,
+		Icon:     n.Icon,
+		Summary:  n.Summary,
+		Body:     n.Body,
+		Actions:  make([]string, 0, 2*len(n.Actions)),
+		Hints: map[string]any{
+			"urgency": urgency,
+		},
+	}
+	for _, a := range n.Actions {
+		ret.Actions = append(ret.Actions, a.Key, a.Label)
+	}
+	if n.Category != "" {
+		ret.Hints["category"] = n.Category
+	}
+	if n.Resident {
+		ret.Hints["resident"] = true
+	}
+	if n.Transient {
+		ret.Hints["transient"] = true
+	}
+	for k, v := range n.Hints {
+		ret.Hints[k] = v
+	}
+	switch {
+	case n.Timeout == 0:
+		ret.Timeout = -1
+	case n.Timeout < 0:
+		ret.Timeout = 0
+	default:
+		ret.Timeout = int32(min(n.Timeout.Milliseconds(), math.MaxInt32))
+		if ret.Timeout == 0 {
+			// Sub-millisecond timeout, round up rather than
+			// accidentally requesting no expiry.
+			ret.Timeout = 1
+		}
+	}
+	return ret, nil
+}
+
+// Notify shows n and returns its ID.
+//
+// Notify does not report the user's response to the notification.
+// Use [Notifications.Show] to receive invoked actions.
+func (iface Notifications) Notify(ctx context.Context, n Notification) (id uint32, err error) {
+	req, err := n.request()
+	if err != nil {
+		return 0, err
+	}
+	err = iface.iface.Call(ctx, "Notify", req, &id)
+	return id, err
+}
+
+// CloseNotification closes the notification with the given ID.
+func (iface Notifications) CloseNotification(ctx context.Context, id uint32) error {
+	return iface.iface.Call(ctx, "CloseNotification", id, nil)
+}
+
+// Show shows n, and returns an ActiveNotification that reports the
+// actions that the user invokes on it.
+func (iface Notifications) Show(ctx context.Context, n Notification) (*ActiveNotification, error) {
+	// Subscribe to signals before showing the notification, so that
+	// no responses are missed if the user is very quick. If signals
+	// arrive faster than they can be delivered, drop old ones, so
+	// that the final NotificationClosed is never lost.
+	//
+	// Only the server's signals are relevant, and other peers must
+	// not be able to act on the notification. Matches compare the
+	// sender's unique name, so look it up first.
+	server, err := serverPeer(ctx, iface.iface.Peer())
+	if err != nil {
+		return nil, err
+	}
+	w, err := iface.iface.Conn().WatchWithOptions(dbus.WatchOptions{Policy: dbus.WatchDropOldest})
+	if err != nil {
+		return nil, err
+	}
+	path := iface.iface.Object().Path()
+	for _, m := range []*dbus.Match{
+		dbus.MatchNotification[ActionInvoked]().Peer(server).Object(path),
+		dbus.MatchNotification[NotificationClosed]().Peer(server).Object(path),
+	} {
+		if _, err := w.Match(m); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	id, err := iface.Notify(ctx, n)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	ret := &ActiveNotification{
+		ID:      id,
+		iface:   iface,
+		w:       w,
+		actions: make(chan string, maxPendingActions),
+		done:    make(chan struct{}),
+	}
+	go ret.run()
+	return ret, nil
+}
+
+// serverPeer returns the unique name of the peer that owns p's name,
+// starting the service that provides p if necessary.
+func serverPeer(ctx context.Context, p dbus.Peer) (dbus.Peer, error) {
+	if owner, err := p.Owner(ctx); err == nil {
+		return owner, nil
+	}
+	// Notify would start an activatable server anyway, but only
+	// after the signal matches are in place.
+	if _, err := p.StartService(ctx); err != nil {
+		return dbus.Peer{}, err
+	}
+	return p.Owner(ctx)
+}
+
+// maxPendingActions is the number of invoked actions that an
+// ActiveNotification buffers for a slow reader, before it starts
+// discarding the oldest ones.
+const maxPendingActions = 16
+
+// ActiveNotification is a notification that is being shown to the
+// user.
+type ActiveNotification struct {
+	// ID is the notification's ID.
+	ID uint32
+
+	iface   Notifications
+	w       *dbus.Watcher
+	actions chan string
+	done    chan struct{} // closed when run returns
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	reason    CloseReason
+}
+
+// run delivers the notification's signals until it is closed.
+func (n *ActiveNotification) run() {
+	defer close(n.done)
+	defer close(n.actions)
+	defer n.w.Close()
+	for sig := range n.w.Chan() {
+		switch body := sig.Body.(type) {
+		case *ActionInvoked:
+			if body.ID != n.ID {
+				continue
+			}
+			n.sendAction(body.ActionKey)
+		case *NotificationClosed:
+			if body.ID != n.ID {
+				continue
+			}
+			n.mu.Lock()
+			n.reason = body.Reason
+			n.mu.Unlock()
+			return
+		}
+	}
+}
+
+// sendAction queues action for delivery on n.actions, discarding the
+// oldest queued action if the reader has fallen behind. It never
+// blocks, so that a slow reader cannot stall the notification's
+// Watcher.
+func (n *ActiveNotification) sendAction(action string) {
+	for {
+		select {
+		case n.actions <- action:
+			return
+		default:
+		}
+		select {
+		case <-n.actions:
+		default:
+		}
+	}
+}
+
+// Actions returns a channel that receives the keys of actions that
+// the user invokes on the notification. The channel is closed when
+// the notification is closed.
+//
+// The channel buffers a small number of actions. If the caller
+// falls behind, the oldest undelivered actions are discarded.
+func (n *ActiveNotification) Actions() <-chan string {
+	return n.actions
+}
+
+// Done returns a channel that is closed when the notification has
+// been closed.
+func (n *ActiveNotification) Done() <-chan struct{} {
+	return n.done
+}
+
+// Reason returns the reason the notification was closed. Reason
+// returns 0 if the notification has not closed yet, or if it was
+// closed by [ActiveNotification.Close].
+func (n *ActiveNotification) Reason() CloseReason {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.reason
+}
+
+// Close closes the notification and stops delivering its actions.
+func (n *ActiveNotification) Close(ctx context.Context) error {
+	select {
+	case <-n.done:
+		return nil
+	default:
+	}
+	n.closeOnce.Do(func() {
+		n.w.Close()
+	})
+	return n.iface.CloseNotification(ctx, n.ID)
+}
+
+// CloseReason is the reason a notification was closed.
+type CloseReason uint32
+
+const (
+	// CloseReasonExpired indicates that the notification's timeout
+	// elapsed.
+	CloseReasonExpired CloseReason = 1
+	// CloseReasonDismissed indicates that the user dismissed the
+	// notification.
+	CloseReasonDismissed CloseReason = 2
+	// CloseReasonClosed indicates that the notification was closed
+	// by a call to CloseNotification.
+	CloseReasonClosed CloseReason = 3
+	// CloseReasonUndefined indicates that the server did not provide
+	// a reason.
+	CloseReasonUndefined CloseReason = 4
+)
+
+func (r CloseReason) String() string {
+	switch r {
+	case CloseReasonExpired:
+		return "Expired"
+	case CloseReasonDismissed:
+		return "Dismissed"
+	case CloseReasonClosed:
+		return "Closed"
+	case CloseReasonUndefined:
+		return "Undefined"
+	default:
+		return fmt.Sprintf("CloseReason(%d)", uint32(r))
+	}
+}
+
+// NotificationClosed signals that a notification was closed.
+//
+// NotificationClosed implements the signal
+// org.freedesktop.Notifications.NotificationClosed.
+type NotificationClosed struct {
+	ID     uint32
+	Reason CloseReason
+}
+
+// ActionInvoked signals that the user invoked one of a
+// notification's actions.
+//
+// ActionInvoked implements the signal
+// org.freedesktop.Notifications.ActionInvoked.
+type ActionInvoked struct {
+	ID        uint32
+	ActionKey string
+}
+
+// ActivationToken provides an activation token for an action that
+// the user is about to invoke, which the application can use to
+// bring its window to the foreground.
+//
+// ActivationToken implements the signal
+// org.freedesktop.Notifications.ActivationToken.
+type ActivationToken struct {
+	ID    uint32
+	Token string
+}
+
+func init() {
+	dbus.RegisterSignalType[NotificationClosed]("org.freedesktop.Notifications", "NotificationClosed")
+	dbus.RegisterSignalType[ActionInvoked]("org.freedesktop.Notifications", "ActionInvoked")
+	dbus.RegisterSignalType[ActivationToken]("org.freedesktop.Notifications", "ActivationToken")
+}
@@ -0,0 +1,261 @@
+package notifications_test
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/dbustest"
+	"github.com/danderson/dbus/freedesktop/notifications"
+	"github.com/google/go-cmp/cmp"
+)
+
+type notifyRequest struct {
+	AppName  string
+	Replaces uint32
+	Icon     string
+	Summary  string
+	Body     string
+	Actions  []string
+	Hints    map[string]any
+	Timeout  int32
+}
+
+func TestShow(t *testing.T) {
+	bus := dbustest.New(t, false)
+	server, client := bus.MustConn(t), bus.MustConn(t)
+	defer server.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	if _, err := server.RequestName(ctx, "org.freedesktop.Notifications", 0); err != nil {
+		t.Fatalf("RequestName() failed: %v", err)
+	}
+	reqs := make(chan notifyRequest, 1)
+	server.Handle("org.freedesktop.Notifications", "Notify", func(_ context.Context, _ dbus.ObjectPath, req notifyRequest) (uint32, error) {
+		reqs <- req
+		return 42, nil
+	})
+	closed := make(chan uint32, 1)
+	server.Handle("org.freedesktop.Notifications", "CloseNotification", func(_ context.Context, _ dbus.ObjectPath, id uint32) error {
+		closed <- id
+		return nil
+	})
+	emit := func(sig any) {
+		t.Helper()
+		if err := server.EmitSignal(ctx, "/org/freedesktop/Notifications", sig); err != nil {
+			t.Fatalf("EmitSignal(%T) failed: %v", sig, err)
+		}
+	}
+
+	n, err := notifications.New(client).Show(ctx, notifications.Notification{
+		AppName: "test",
+		Summary: "Hello",
+		Body:    "World",
+		Actions: []notifications.Action{
+			{"default", "Open"},
+			{"reply", "Reply"},
+		},
+		Timeout:   5 * time.Second,
+		Urgency:   notifications.UrgencyCritical,
+		Category:  "im.received",
+		Transient: true,
+		Hints:     map[string]any{"x-test": "foo"},
+	})
+	if err != nil {
+		t.Fatalf("Show() failed: %v", err)
+	}
+	if n.ID != 42 {
+		t.Errorf("Show() returned ID %d, want 42", n.ID)
+	}
+
+	want := notifyRequest{
+		AppName: "test",
+		Summary: "Hello",
+		Body:    "World",
+		Actions: []string{"default", "Open", "reply", "Reply"},
+		Hints: map[string]any{
+			"urgency":   uint8(2),
+			"category":  "im.received",
+			"transient": true,
+			"x-test":    "foo",
+		},
+		Timeout: 5000,
+	}
+	if diff := cmp.Diff(<-reqs, want); diff != "" {
+		t.Errorf("wrong Notify request (-got+want):\n%s", diff)
+	}
+
+	// Signals from peers other than the server must be ignored.
+	impostor := bus.MustConn(t)
+	defer impostor.Close()
+	for _, sig := range []any{
+		notifications.ActionInvoked{ID: 42, ActionKey: "impostor"},
+		notifications.NotificationClosed{ID: 42, Reason: notifications.CloseReasonExpired},
+	} {
+		if err := impostor.EmitSignal(ctx, "/org/freedesktop/Notifications", sig); err != nil {
+			t.Fatalf("EmitSignal(%T) from impostor failed: %v", sig, err)
+		}
+	}
+	// Once the bus has answered the impostor, it has already routed
+	// the impostor's signals.
+	if _, err := impostor.Peer("org.freedesktop.DBus").Owner(ctx); err != nil {
+		t.Fatalf("Owner() failed: %v", err)
+	}
+
+	// Signals for other notifications must be ignored.
+	emit(notifications.ActionInvoked{ID: 1, ActionKey: "other"})
+	emit(notifications.ActionInvoked{ID: 42, ActionKey: "reply"})
+	emit(notifications.NotificationClosed{ID: 1, Reason: notifications.CloseReasonExpired})
+	emit(notifications.NotificationClosed{ID: 42, Reason: notifications.CloseReasonDismissed})
+
+	timeout := time.After(5 * time.Second)
+	var got []string
+	for done := false; !done; {
+		select {
+		case key, ok := <-n.Actions():
+			if !ok {
+				done = true
+				break
+			}
+			got = append(got, key)
+		case <-timeout:
+			t.Fatal("timed out waiting for notification to close")
+		}
+	}
+	if diff := cmp.Diff(got, []string{"reply"}); diff != "" {
+		t.Errorf("wrong invoked actions (-got+want):\n%s", diff)
+	}
+	<-n.Done()
+	if got, want := n.Reason(), notifications.CloseReasonDismissed; got != want {
+		t.Errorf("Reason() = %s, want %s", got, want)
+	}
+
+	// Closing explicitly asks the server to close the notification.
+	n, err = notifications.New(client).Show(ctx, notifications.Notification{Summary: "Again"})
+	if err != nil {
+		t.Fatalf("Show() failed: %v", err)
+	}
+	<-reqs
+	if err := n.Close(ctx); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if id := <-closed; id != 42 {
+		t.Errorf("CloseNotification() got ID %d, want 42", id)
+	}
+	select {
+	case <-n.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Done after Close")
+	}
+	if _, ok := <-n.Actions(); ok {
+		t.Error("Actions() channel not closed after Close")
+	}
+}
+
+func TestShowSlowReader(t *testing.T) {
+	bus := dbustest.New(t, false)
+	server, client := bus.MustConn(t), bus.MustConn(t)
+	defer server.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	if _, err := server.RequestName(ctx, "org.freedesktop.Notifications", 0); err != nil {
+		t.Fatalf("RequestName() failed: %v", err)
+	}
+	server.Handle("org.freedesktop.Notifications", "Notify", func(_ context.Context, _ dbus.ObjectPath, req notifyRequest) (uint32, error) {
+		return 42, nil
+	})
+
+	n, err := notifications.New(client).Show(ctx, notifications.Notification{Summary: "Hello"})
+	if err != nil {
+		t.Fatalf("Show() failed: %v", err)
+	}
+
+	// Invoke more actions than the notification buffers, without
+	// reading any. Delivery must not stall, and only the newest
+	// actions are kept.
+	var want []string
+	for i := range 40 {
+		key := fmt.Sprintf("action-%d", i)
+		if err := server.EmitSignal(ctx, "/org/freedesktop/Notifications", notifications.ActionInvoked{ID: 42, ActionKey: key}); err != nil {
+			t.Fatalf("EmitSignal(ActionInvoked) failed: %v", err)
+		}
+		want = append(want, key)
+	}
+	if err := server.EmitSignal(ctx, "/org/freedesktop/Notifications", notifications.NotificationClosed{ID: 42, Reason: notifications.CloseReasonDismissed}); err != nil {
+		t.Fatalf("EmitSignal(NotificationClosed) failed: %v", err)
+	}
+
+	select {
+	case <-n.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification to close")
+	}
+	var got []string
+	for key := range n.Actions() {
+		got = append(got, key)
+	}
+	if len(got) == 0 || len(got) >= len(want) {
+		t.Fatalf("got %d actions, want some but not all %d", len(got), len(want))
+	}
+	if diff := cmp.Diff(got, want[len(want)-len(got):]); diff != "" {
+		t.Errorf("wrong invoked actions (-got+want):\n%s", diff)
+	}
+}
+
+func TestImageHint(t *testing.T) {
+	alpha := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	alpha.SetNRGBA(0, 0, color.NRGBA{1, 2, 3, 4})
+	alpha.SetNRGBA(1, 0, color.NRGBA{5, 6, 7, 255})
+
+	opaque := image.NewRGBA(image.Rect(10, 10, 11, 12))
+	opaque.SetRGBA(10, 10, color.RGBA{1, 2, 3, 255})
+	opaque.SetRGBA(10, 11, color.RGBA{4, 5, 6, 255})
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want notifications.ImageData
+	}{
+		{"alpha", alpha, notifications.ImageData{
+			Width:         2,
+			Height:        1,
+			RowStride:     8,
+			HasAlpha:      true,
+			BitsPerSample: 8,
+			Channels:      4,
+			Data:          []byte{1, 2, 3, 4, 5, 6, 7, 255},
+		}},
+		{"opaque", opaque, notifications.ImageData{
+			Width:         1,
+			Height:        2,
+			RowStride:     3,
+			BitsPerSample: 8,
+			Channels:      3,
+			Data:          []byte{1, 2, 3, 4, 5, 6},
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			name, val := notifications.ImageHint(tc.img)
+			if name != "image-data" {
+				t.Errorf("ImageHint() name = %q, want image-data", name)
+			}
+			if diff := cmp.Diff(val, any(tc.want)); diff != "" {
+				t.Errorf("ImageHint() wrong value (-got+want):\n%s", diff)
+			}
+			sig, err := dbus.SignatureOf(val)
+			if err != nil {
+				t.Fatalf("SignatureOf(ImageHint()) failed: %v", err)
+			}
+			if got, want := sig.String(), "(iiibiiay)"; got != want {
+				t.Errorf("ImageHint() value has signature %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Package filechooser provides an interface to the XDG desktop
+// portal file chooser.
+//
+// This corresponds to the org.freedesktop.portal.FileChooser
+// interface of the org.freedesktop.portal.Desktop service on the
+// session bus. It lets applications, including sandboxed ones, ask
+// the user to pick files to open or a location to save to, using the
+// desktop environment's native file chooser dialog.
+package filechooser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/freedesktop/portal"
+)
+
+type FileChooser struct{ iface dbus.Interface }
+
+// New returns an interface to the desktop portal's file chooser.
+func New(conn *dbus.Conn) FileChooser {
+	obj := conn.Peer("org.freedesktop.portal.Desktop").Object("/org/freedesktop/portal/desktop")
+	return Interface(obj)
+}
+
+// Interface returns a file chooser interface on the given object.
+func Interface(obj dbus.Object) FileChooser {
+	return FileChooser{
+		iface: obj.Interface("org.freedesktop.portal.FileChooser"),
+	}
+}
+
+// ErrCancelled is returned when the user dismisses the file chooser
+// without selecting any files.
+var ErrCancelled = portal.ErrCancelled
+
+// FilterKind is the kind of pattern in a [FilterRule].
+type FilterKind uint32
+
+const (
+	// FilterGlob matches file names with a shell-style glob, such as
+	// "*.png".
+	FilterGlob FilterKind = 0
+	// FilterMIMEType matches files by MIME type, such as
+	// "image/png".
+	FilterMIMEType FilterKind = 1
+)
+
+// FilterRule is one pattern of a [Filter].
+type FilterRule struct {
+	Kind    FilterKind
+	Pattern string
+}
+
+// Filter is a named set of patterns that restricts which files are
+// shown in the file chooser.
+type Filter struct {
+	// Name is the human-readable name of the filter, such as
+	// "Images".
+	Name string
+	// Rules are the filter's patterns. A file is shown if it matches
+	// any rule.
+	Rules []FilterRule
+}
+
+// OpenFileOptions are options for [FileChooser.OpenFile].
+type OpenFileOptions struct {
+	// ParentWindow is the identifier of the application window that
+	// the dialog belongs to, in the format described by the portal
+	// documentation, such as "x11:1234". If empty, the dialog is not
+	// attached to any window.
+	ParentWindow string
+	// AcceptLabel is the label of the dialog's accept button. If
+	// empty, the dialog uses a default label such as "Open".
+	AcceptLabel string
+	// Multiple allows the user to select more than one file.
+	Multiple bool
+	// Directory selects directories instead of files.
+	Directory bool
+	// Filters are the file filters that the user can choose from.
+	Filters []Filter
+	// CurrentFilter is the filter that is selected initially.
+	CurrentFilter *Filter
+	// CurrentFolder is the directory that the dialog starts in.
+	CurrentFolder string
+}
+
+// SaveFileOptions are options for [FileChooser.SaveFile].
+type SaveFileOptions struct {
+	// ParentWindow is the identifier of the application window that
+	// the dialog belongs to. See [OpenFileOptions].
+	ParentWindow string
+	// AcceptLabel is the label of the dialog's accept button. If
+	// empty, the dialog uses a default label such as "Save".
+	AcceptLabel string
+	// Filters are the file filters that the user can choose from.
+	Filters []Filter
+	// CurrentFilter is the filter that is selected initially.
+	CurrentFilter *Filter
+	// CurrentName is the suggested name of the file to save.
+	CurrentName string
+	// CurrentFolder is the directory that the dialog starts in.
+	CurrentFolder string
+	// CurrentFile is the path of an existing file to save over, for
+	// example when implementing "Save As" for an already saved
+	// document.
+	CurrentFile string
+}
+
+type chooserOptions struct {
+	_ dbus.InlineLayout
+
+	HandleToken   string   `dbus:"key=handle_token"`
+	AcceptLabel   string   `dbus:"key=accept_label"`
+	Multiple      bool     `dbus:"key=multiple"`
+	Directory     bool     `dbus:"key=directory"`
+	Filters       []Filter `dbus:"key=filters"`
+	CurrentFilter *Filter  `dbus:"key=current_filter"`
+	CurrentName   string   `dbus:"key=current_name"`
+	CurrentFolder []byte   `dbus:"key=current_folder"`
+	CurrentFile   []byte   `dbus:"key=current_file"`
+
+	Other map[string]any `dbus:"vardict"`
+}
+
+type chooserRequest struct {
+	ParentWindow string
+	Title        string
+	Options      chooserOptions
+}
+
+// pathBytes returns path in the NUL-terminated form that the portal
+// uses for file paths, or nil if path is empty.
+func pathBytes(path string) []byte {
+	if path == "" {
+		return nil
+	}
+	return append([]byte(path), 0)
+}
+
+// OpenFile asks the user to select files to open, and returns the
+// URIs of the selected files.
+//
+// OpenFile blocks until the user closes the dialog. If ctx is
+// canceled, OpenFile closes the dialog and returns ctx.Err(). If the
+// user dismisses the dialog, OpenFile returns [ErrCancelled].
+func (f FileChooser) OpenFile(ctx context.Context, title string, opts *OpenFileOptions) (uris []string, err error) {
+	if opts == nil {
+		opts = &OpenFileOptions{}
+	}
+	req := chooserRequest{
+		ParentWindow: opts.ParentWindow,
+		Title:        title,
+		Options: chooserOptions{
+			AcceptLabel:   opts.AcceptLabel,
+			Multiple:      opts.Multiple,
+			Directory:     opts.Directory,
+			Filters:       opts.Filters,
+			CurrentFilter: opts.CurrentFilter,
+			CurrentFolder: pathBytes(opts.CurrentFolder),
+		},
+	}
+	return f.choose(ctx, "OpenFile", &req)
+}
+
+// SaveFile asks the user to choose a location to save a file, and
+// returns the chosen URI.
+//
+// SaveFile blocks until the user closes the dialog. If ctx is
+// canceled, SaveFile closes the dialog and returns ctx.Err(). If the
+// user dismisses the dialog, SaveFile returns [ErrCancelled].
+func (f FileChooser) SaveFile(ctx context.Context, title string, opts *SaveFileOptions) (uri string, err error) {
+	if opts == nil {
+		opts = &SaveFileOptions{}
+	}
+	req := chooserRequest{
+		ParentWindow: opts.ParentWindow,
+		Title:        title,
+		Options: chooserOptions{
+			AcceptLabel:   opts.AcceptLabel,
+			Filters:       opts.Filters,
+			CurrentFilter: opts.CurrentFilter,
+			CurrentName:   opts.CurrentName,
+			CurrentFolder: pathBytes(opts.CurrentFolder),
+			CurrentFile:   pathBytes(opts.CurrentFile),
+		},
+	}
+	uris, err := f.choose(ctx, "SaveFile", &req)
+	if err != nil {
+		return "", err
+	}
+	if len(uris) != 1 {
+		return "", fmt.Errorf("portal returned %d files from SaveFile, want 1", len(uris))
+	}
+	return uris[0], nil
+}
+
+// choose calls method, and returns the URIs from the portal's
+// response.
+func (f FileChooser) choose(ctx context.Context, method string, req *chooserRequest) ([]string, error) {
+	results, err := portal.Call(ctx, f.iface, method, req)
+	if err != nil {
+		return nil, err
+	}
+	uris, _ := results["uris"].([]string)
+	return uris, nil
+}
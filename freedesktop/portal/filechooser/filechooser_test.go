@@ -0,0 +1,189 @@
+package filechooser_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/dbustest"
+	"github.com/danderson/dbus/freedesktop/portal/filechooser"
+	"github.com/google/go-cmp/cmp"
+)
+
+type chooserRequest struct {
+	ParentWindow string
+	Title        string
+	Options      map[string]any
+}
+
+type response struct {
+	Response uint32
+	Results  map[string]any
+}
+
+// fakePortal is a minimal implementation of the desktop portal's
+// FileChooser.
+type fakePortal struct {
+	t      *testing.T
+	conn   *dbus.Conn
+	reqs   chan chooserRequest
+	closed chan dbus.ObjectPath
+
+	mu sync.Mutex
+	// respond is the Response signal the portal sends for each
+	// request, or nil to leave requests pending.
+	respond func(chooserRequest) *response
+}
+
+// setRespond sets the portal's response to future requests.
+func (p *fakePortal) setRespond(respond func(chooserRequest) *response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.respond = respond
+}
+
+func newFakePortal(t *testing.T, bus *dbustest.Bus, client *dbus.Conn) *fakePortal {
+	ret := &fakePortal{
+		t:      t,
+		conn:   bus.MustConn(t),
+		reqs:   make(chan chooserRequest, 1),
+		closed: make(chan dbus.ObjectPath, 1),
+	}
+	t.Cleanup(func() { ret.conn.Close() })
+	if _, err := ret.conn.RequestName(context.Background(), "org.freedesktop.portal.Desktop", 0); err != nil {
+		t.Fatalf("RequestName() failed: %v", err)
+	}
+
+	handle := func(ctx context.Context, _ dbus.ObjectPath, req chooserRequest) (dbus.ObjectPath, error) {
+		ret.reqs <- req
+		token, _ := req.Options["handle_token"].(string)
+		sender := strings.ReplaceAll(strings.TrimPrefix(client.LocalName(), ":"), ".", "_")
+		path := dbus.ObjectPath("/org/freedesktop/portal/desktop/request").Child(sender).Child(token)
+		ret.mu.Lock()
+		respond := ret.respond
+		ret.mu.Unlock()
+		if resp := respond(req); resp != nil {
+			// Respond before returning the handle, to check that
+			// the client subscribes to responses early enough.
+			if err := ret.conn.EmitRawSignal(ctx, path, "org.freedesktop.portal.Request", "Response", resp); err != nil {
+				return "", err
+			}
+		}
+		return path, nil
+	}
+	ret.conn.Handle("org.freedesktop.portal.FileChooser", "OpenFile", handle)
+	ret.conn.Handle("org.freedesktop.portal.FileChooser", "SaveFile", handle)
+	ret.conn.Handle("org.freedesktop.portal.Request", "Close", func(_ context.Context, path dbus.ObjectPath) error {
+		ret.closed <- path
+		return nil
+	})
+	return ret
+}
+
+func TestOpenFile(t *testing.T) {
+	bus := dbustest.New(t, false)
+	client := bus.MustConn(t)
+	defer client.Close()
+	portal := newFakePortal(t, bus, client)
+	fc := filechooser.New(client)
+	ctx := context.Background()
+
+	portal.setRespond(func(chooserRequest) *response {
+		return &response{0, map[string]any{"uris": []string{"file:///a", "file:///b"}}}
+	})
+	filter := filechooser.Filter{
+		Name: "Images",
+		Rules: []filechooser.FilterRule{
+			{filechooser.FilterGlob, "*.png"},
+			{filechooser.FilterMIMEType, "image/jpeg"},
+		},
+	}
+	got, err := fc.OpenFile(ctx, "Pick", &filechooser.OpenFileOptions{
+		Multiple:      true,
+		Filters:       []filechooser.Filter{filter},
+		CurrentFolder: "/tmp",
+	})
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	if diff := cmp.Diff(got, []string{"file:///a", "file:///b"}); diff != "" {
+		t.Errorf("OpenFile() wrong result (-got+want):\n%s", diff)
+	}
+	req := <-portal.reqs
+	if req.Title != "Pick" {
+		t.Errorf("OpenFile() sent title %q, want Pick", req.Title)
+	}
+	if req.Options["multiple"] != true {
+		t.Errorf("OpenFile() sent multiple=%v, want true", req.Options["multiple"])
+	}
+	if got, ok := req.Options["current_folder"].([]byte); !ok || string(got) != "/tmp\x00" {
+		t.Errorf("OpenFile() sent current_folder=%q, want NUL-terminated /tmp", req.Options["current_folder"])
+	}
+	if _, ok := req.Options["filters"]; !ok {
+		t.Error("OpenFile() did not send filters")
+	}
+	for _, k := range []string{"directory", "current_filter", "accept_label"} {
+		if v, ok := req.Options[k]; ok {
+			t.Errorf("OpenFile() sent unset option %s=%v", k, v)
+		}
+	}
+
+	portal.setRespond(func(chooserRequest) *response {
+		return &response{1, nil}
+	})
+	if _, err := fc.OpenFile(ctx, "Pick", nil); !errors.Is(err, filechooser.ErrCancelled) {
+		t.Errorf("OpenFile() with user cancel got err %v, want ErrCancelled", err)
+	}
+	<-portal.reqs
+
+	portal.setRespond(func(chooserRequest) *response {
+		return &response{0, map[string]any{"uris": []string{"file:///saved"}}}
+	})
+	uri, err := fc.SaveFile(ctx, "Save", &filechooser.SaveFileOptions{CurrentName: "foo.txt"})
+	if err != nil {
+		t.Fatalf("SaveFile() failed: %v", err)
+	}
+	if uri != "file:///saved" {
+		t.Errorf("SaveFile() = %q, want file:///saved", uri)
+	}
+	if req := <-portal.reqs; req.Options["current_name"] != "foo.txt" {
+		t.Errorf("SaveFile() sent current_name=%v, want foo.txt", req.Options["current_name"])
+	}
+}
+
+func TestOpenFileCancel(t *testing.T) {
+	bus := dbustest.New(t, false)
+	client := bus.MustConn(t)
+	defer client.Close()
+	portal := newFakePortal(t, bus, client)
+	portal.setRespond(func(chooserRequest) *response { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := filechooser.New(client).OpenFile(ctx, "Pick", nil)
+		errs <- err
+	}()
+	<-portal.reqs
+	cancel()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("OpenFile() got err %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OpenFile() did not return after cancellation")
+	}
+	select {
+	case path := <-portal.closed:
+		if !path.IsChildOf("/org/freedesktop/portal/desktop/request") {
+			t.Errorf("Close called on unexpected object %s", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OpenFile() did not close the request after cancellation")
+	}
+}
@@ -0,0 +1,248 @@
+// Package portal provides support for calling XDG desktop portals.
+//
+// Desktop portals are services, such as
+// org.freedesktop.portal.Desktop on the session bus, that let
+// applications, including sandboxed ones, interact with the desktop
+// environment. Many portal methods involve user interaction, and so
+// don't return their result directly. Instead, they return the path
+// of a Request object, and the result arrives later as a Response
+// signal from that object. [Call] implements this request/response
+// pattern.
+package portal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/danderson/dbus"
+)
+
+// ErrCancelled is returned by [Call] when the user dismisses the
+// portal's dialog without completing the interaction.
+var ErrCancelled = errors.New("portal request cancelled by user")
+
+// Call calls method on iface with the given body, and waits for the
+// portal's response. It returns the results from the portal's
+// Response signal.
+//
+// body must be the method's arguments. Portal methods take a vardict
+// of options as their final argument, so body must be either the
+// options themselves, or a struct or struct pointer whose last field
+// holds the options. The options must be a map[string]any, or a
+// struct with a string field tagged `dbus:"key=handle_token"`. Call
+// fills in the options' handle token, overwriting any existing
+// value, but does not otherwise modify body.
+//
+// Call blocks until the portal responds. If ctx is canceled, Call
+// closes the request, which dismisses any dialog shown to the user,
+// and returns ctx.Err(). If the user dismisses the portal's dialog,
+// Call returns [ErrCancelled].
+func Call(ctx context.Context, iface dbus.Interface, method string, body any) (results map[string]any, err error) {
+	var token [8]byte
+	rand.Read(token[:])
+	handleToken := "dbus_go_" + hex.EncodeToString(token[:])
+	body, err = withHandleToken(body, handleToken)
+	if err != nil {
+		return nil, fmt.Errorf("calling portal method %s.%s: %w", iface.Name(), method, err)
+	}
+
+	// The Request's path is predictable from the caller's bus name
+	// and the handle token, which lets Call subscribe to the
+	// response before making the call, so that it cannot be missed.
+	conn := iface.Conn()
+	sender := strings.ReplaceAll(strings.TrimPrefix(conn.LocalName(), ":"), ".", "_")
+	path := dbus.ObjectPath("/org/freedesktop/portal/desktop/request").Child(sender).Child(handleToken)
+
+	// Anyone on the bus can emit a Response from the Request's
+	// path, only accept responses from the portal itself.
+	server, err := serverPeer(ctx, iface.Peer())
+	if err != nil {
+		return nil, fmt.Errorf("finding owner of portal %s: %w", iface.Peer().Name(), err)
+	}
+
+	w, err := conn.Watch()
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	if _, err := w.Match(dbus.MatchNotification[Response]().Peer(server).Object(path)); err != nil {
+		return nil, err
+	}
+
+	// closeRequest dismisses the dialog, when the caller is no longer
+	// waiting for the answer.
+	closeRequest := func(handle dbus.ObjectPath) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), closeRequestTimeout)
+		defer cancel()
+		req := iface.Peer().Object(handle).Interface("org.freedesktop.portal.Request")
+		req.Call(ctx, "Close", nil, nil)
+	}
+
+	var handle dbus.ObjectPath
+	if err := iface.Call(ctx, method, body, &handle); err != nil {
+		if ctx.Err() != nil {
+			// The portal may have received the call and opened a
+			// dialog before the cancellation.
+			closeRequest(path)
+		}
+		return nil, err
+	}
+	if handle != path {
+		// Very old portals don't implement handle_token, and return
+		// some other path. Follow the portal's lead, at the risk
+		// of missing a very quick response.
+		if _, err := w.Match(dbus.MatchNotification[Response]().Peer(server).Object(handle)); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		select {
+		case sig, ok := <-w.Chan():
+			if !ok {
+				return nil, net.ErrClosed
+			}
+			resp, ok := sig.Body.(*Response)
+			if !ok || sig.Sender.Object().Path() != handle {
+				continue
+			}
+			switch resp.Response {
+			case ResponseSuccess:
+				return resp.Results, nil
+			case ResponseCancelled:
+				return nil, ErrCancelled
+			default:
+				return nil, fmt.Errorf("portal method %s.%s failed", iface.Name(), method)
+			}
+		case <-ctx.Done():
+			closeRequest(handle)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// closeRequestTimeout is how long Call waits for the portal to close
+// a request that the caller stopped waiting for.
+const closeRequestTimeout = 5 * time.Second
+
+// serverPeer returns the unique name of the bus peer that owns p's
+// name, starting it if necessary.
+func serverPeer(ctx context.Context, p dbus.Peer) (dbus.Peer, error) {
+	if owner, err := p.Owner(ctx); err == nil {
+		return owner, nil
+	}
+	// The portal call would start an activatable portal anyway, but
+	// only after the response matches are in place.
+	if _, err := p.StartService(ctx); err != nil {
+		return dbus.Peer{}, err
+	}
+	return p.Owner(ctx)
+}
+
+// withHandleToken returns a copy of body, with its options' handle
+// token set to token.
+func withHandleToken(body any, token string) (any, error) {
+	v := reflect.ValueOf(body)
+	if !v.IsValid() {
+		return nil, errors.New("portal method body must include options")
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, errors.New("portal method body must include options")
+		}
+		v = v.Elem()
+	}
+	ret := reflect.New(v.Type()).Elem()
+	ret.Set(v)
+
+	opts := ret
+	if opts.Kind() == reflect.Struct && !isOptionsStruct(opts.Type()) {
+		if opts.NumField() == 0 {
+			return nil, fmt.Errorf("portal method body %s has no options", v.Type())
+		}
+		opts = opts.Field(opts.NumField() - 1)
+	}
+
+	switch {
+	case opts.Kind() == reflect.Map && opts.Type().Key().Kind() == reflect.String && opts.Type().Elem().Kind() == reflect.Interface:
+		m := reflect.MakeMapWithSize(opts.Type(), opts.Len()+1)
+		for iter := opts.MapRange(); iter.Next(); {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+		m.SetMapIndex(reflect.ValueOf("handle_token").Convert(opts.Type().Key()), reflect.ValueOf(token))
+		opts.Set(m)
+	case opts.Kind() == reflect.Struct && isOptionsStruct(opts.Type()):
+		opts.FieldByIndex(handleTokenField(opts.Type())).SetString(token)
+	default:
+		return nil, fmt.Errorf("portal method options must be a map[string]any or a struct with a handle_token key, not %s", opts.Type())
+	}
+	return ret.Interface(), nil
+}
+
+// isOptionsStruct reports whether t is an options struct with a
+// handle token field.
+func isOptionsStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && handleTokenField(t) != nil
+}
+
+// handleTokenField returns the index of t's handle token field, or
+// nil if t has no such field.
+func handleTokenField(t reflect.Type) []int {
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Type.Kind() != reflect.String {
+			continue
+		}
+		if slices.Contains(strings.Split(f.Tag.Get("dbus"), ","), "key=handle_token") {
+			return f.Index
+		}
+	}
+	return nil
+}
+
+// ResponseCode is the outcome of a portal request.
+type ResponseCode uint32
+
+const (
+	// ResponseSuccess indicates that the request succeeded.
+	ResponseSuccess ResponseCode = 0
+	// ResponseCancelled indicates that the user cancelled the
+	// request.
+	ResponseCancelled ResponseCode = 1
+	// ResponseOther indicates that the request ended in some other
+	// way, for example due to an error.
+	ResponseOther ResponseCode = 2
+)
+
+func (c ResponseCode) String() string {
+	switch c {
+	case ResponseSuccess:
+		return "Success"
+	case ResponseCancelled:
+		return "Cancelled"
+	case ResponseOther:
+		return "Other"
+	default:
+		return fmt.Sprintf("ResponseCode(%d)", uint32(c))
+	}
+}
+
+// Response signals that a portal request has completed.
+//
+// Response implements the signal
+// org.freedesktop.portal.Request.Response.
+type Response struct {
+	Response ResponseCode
+	Results  map[string]any
+}
+
+func init() {
+	dbus.RegisterSignalType[Response]("org.freedesktop.portal.Request", "Response")
+}
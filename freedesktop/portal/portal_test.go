@@ -0,0 +1,122 @@
+package portal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/danderson/dbus"
+	"github.com/danderson/dbus/dbustest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithHandleToken(t *testing.T) {
+	type opts struct {
+		_     dbus.InlineLayout
+		Token string         `dbus:"key=handle_token"`
+		Other map[string]any `dbus:"vardict"`
+	}
+	type mapBody struct {
+		Parent  string
+		Options map[string]any
+	}
+	type structBody struct {
+		Parent  string
+		Options opts
+	}
+
+	orig := map[string]any{"foo": "bar"}
+	tests := []struct {
+		name string
+		in   any
+		want any
+	}{
+		{"map", orig, map[string]any{"foo": "bar", "handle_token": "tok"}},
+		{"nil map", mapBody{Parent: "x"}, mapBody{"x", map[string]any{"handle_token": "tok"}}},
+		{"map field", &mapBody{"x", orig}, mapBody{"x", map[string]any{"foo": "bar", "handle_token": "tok"}}},
+		{"struct", opts{Token: "old"}, opts{Token: "tok"}},
+		{"struct field", &structBody{Parent: "x"}, structBody{"x", opts{Token: "tok"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := withHandleToken(tc.in, "tok")
+			if err != nil {
+				t.Fatalf("withHandleToken(%#v) failed: %v", tc.in, err)
+			}
+			if diff := cmp.Diff(got, tc.want, cmp.AllowUnexported(opts{})); diff != "" {
+				t.Errorf("withHandleToken(%#v) wrong result (-got+want):\n%s", tc.in, diff)
+			}
+		})
+	}
+	if _, ok := orig["handle_token"]; ok {
+		t.Error("withHandleToken modified its input map")
+	}
+
+	for _, bad := range []any{
+		nil,
+		(*mapBody)(nil),
+		"foo",
+		struct{}{},
+		struct{ A, B string }{},
+		struct{ Options map[string]string }{},
+	} {
+		if got, err := withHandleToken(bad, "tok"); err == nil {
+			t.Errorf("withHandleToken(%#v) = %#v, want error", bad, got)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	bus := dbustest.New(t, false)
+	client, server, impostor := bus.MustConn(t), bus.MustConn(t), bus.MustConn(t)
+	defer client.Close()
+	defer server.Close()
+	defer impostor.Close()
+
+	ctx := context.Background()
+	if _, err := server.RequestName(ctx, "org.test.Portal", 0); err != nil {
+		t.Fatalf("RequestName() failed: %v", err)
+	}
+	type request struct {
+		Arg     string
+		Options map[string]any
+	}
+	server.Handle("org.test.Portal", "Do", func(ctx context.Context, _ dbus.ObjectPath, req request) (dbus.ObjectPath, error) {
+		token, _ := req.Options["handle_token"].(string)
+		sender := strings.ReplaceAll(strings.TrimPrefix(client.LocalName(), ":"), ".", "_")
+		path := dbus.ObjectPath("/org/freedesktop/portal/desktop/request").Child(sender).Child(token)
+		resp := Response{ResponseSuccess, map[string]any{"arg": req.Arg}}
+		if req.Arg == "fail" {
+			resp = Response{ResponseOther, nil}
+		}
+		// Unrelated responses must be ignored.
+		if err := server.EmitSignal(ctx, path.Child("other"), Response{ResponseCancelled, nil}); err != nil {
+			return "", err
+		}
+		// So must responses forged by other peers. The Owner round
+		// trip makes sure the bus delivers the forgery first.
+		if err := impostor.EmitSignal(ctx, path, Response{ResponseCancelled, nil}); err != nil {
+			return "", err
+		}
+		if _, err := impostor.Peer("org.freedesktop.DBus").Owner(ctx); err != nil {
+			return "", err
+		}
+		if err := server.EmitSignal(ctx, path, resp); err != nil {
+			return "", err
+		}
+		return path, nil
+	})
+
+	iface := client.Peer("org.test.Portal").Object("/org/freedesktop/portal/desktop").Interface("org.test.Portal")
+	got, err := Call(ctx, iface, "Do", request{Arg: "foo"})
+	if err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if diff := cmp.Diff(got, map[string]any{"arg": "foo"}); diff != "" {
+		t.Errorf("Call() wrong result (-got+want):\n%s", diff)
+	}
+
+	if got, err := Call(ctx, iface, "Do", request{Arg: "fail"}); err == nil {
+		t.Errorf("Call() with failed response = %v, want error", got)
+	}
+}
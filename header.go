@@ -21,7 +21,9 @@ func (*byteOrder) MarshalDBus(ctx context.Context, e *fragments.Encoder) error {
 	return nil
 }
 func (b *byteOrder) UnmarshalDBus(ctx context.Context, d *fragments.Decoder) error {
-	d.ByteOrderFlag()
+	if err := d.ByteOrderFlag(); err != nil {
+		return err
+	}
 	*b = d.Order == fragments.BigEndian
 	return nil
 }
@@ -36,6 +38,13 @@ const (
 	msgTypeSignal
 )
 
+// Message header flag bits.
+const (
+	flagNoReplyExpected       byte = 0x1
+	flagNoAutoStart           byte = 0x2
+	flagAllowInteractiveAuthz byte = 0x4
+)
+
 // structAlign is a zero-length struct field that forces padding to
 // struct alignment. It features at the end of the DBus header, which
 // is specified to contain trailing padding prior to the message body.
@@ -108,6 +117,13 @@ type header struct {
 
 // Valid checks that the message header is valid for its message type.
 func (h *header) Valid() error {
+	return h.valid(true)
+}
+
+// valid is Valid, with the Destination of method calls only required
+// if requireDest is true. Destinations are optional on peer-to-peer
+// connections, where there is only one possible recipient.
+func (h *header) valid(requireDest bool) error {
 	if h.Serial == 0 {
 		return fmt.Errorf("invalid message with zero Serial")
 	}
@@ -124,7 +140,7 @@ func (h *header) Valid() error {
 		if h.Member == "" {
 			return fmt.Errorf("missing required header field Member")
 		}
-		if h.Destination == "" {
+		if requireDest && h.Destination == "" {
 			return fmt.Errorf("missing required header field Destination")
 		}
 	case msgTypeReturn:
@@ -157,5 +173,5 @@ func (h *header) Valid() error {
 
 // WantReply reports whether this message requires a response.
 func (h *header) WantReply() bool {
-	return h.Type == msgTypeCall && h.Flags&0x1 == 0
+	return h.Type == msgTypeCall && h.Flags&flagNoReplyExpected == 0
 }
@@ -3,14 +3,24 @@ package dbus_test
 import (
 	"context"
 	_ "embed"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
 	"reflect"
 	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/danderson/dbus"
 	"github.com/danderson/dbus/dbustest"
+	"github.com/danderson/dbus/fragments"
+	"github.com/google/go-cmp/cmp"
 )
 
 // debugging tests, and the bus monitor output is too much? Turn it
@@ -59,6 +69,7 @@ func TestBus(t *testing.T) {
 		wantPeers := []dbus.Peer{
 			conn.Peer("org.freedesktop.DBus"),
 			conn.Peer("org.test.Activated"),
+			conn.Peer("org.test.Started"),
 		}
 		slices.SortFunc(peers, dbus.Peer.Compare)
 		got := fmt.Sprint(peers)
@@ -88,6 +99,51 @@ func TestBus(t *testing.T) {
 	} else if testing.Verbose() {
 		t.Logf("Features() = %v", features)
 	}
+	// Features returns a copy of its cache, so mutating the result
+	// must not affect later lookups. Which features the bus has
+	// depends on its version, so check for one that it reported.
+	type featureTest struct {
+		name string
+		want bool
+	}
+	tests := []featureTest{{"NotARealFeature", false}}
+	if len(features) > 0 {
+		tests = append(tests, featureTest{features[0], true})
+		features[0] = "mutated"
+	}
+	for _, tc := range tests {
+		if got, err := conn.HasFeature(context.Background(), tc.name); err != nil {
+			t.Errorf("HasFeature(%q) failed: %v", tc.name, err)
+		} else if got != tc.want {
+			t.Errorf("HasFeature(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	env := map[string]string{"DBUS_TEST_VAR": "foo"}
+	if err := conn.UpdateActivationEnvironment(context.Background(), env); err != nil {
+		t.Errorf("UpdateActivationEnvironment() failed: %v", err)
+	}
+
+	stats, err := conn.DebugStats(context.Background())
+	if errors.Is(err, dbus.ErrUnknownInterface) || errors.Is(err, dbus.ErrUnknownMethod) {
+		t.Log("bus does not support Debug.Stats, skipping stats checks")
+		return
+	} else if err != nil {
+		t.Errorf("DebugStats() failed: %v", err)
+	} else if _, ok := stats["Serial"].(uint32); !ok {
+		t.Errorf("DebugStats() is missing Serial, got %v", stats)
+	} else if testing.Verbose() {
+		t.Logf("DebugStats() = %v", stats)
+	}
+
+	stats, err = conn.DebugConnectionStats(context.Background(), conn.Peer(conn.LocalName()))
+	if err != nil {
+		t.Errorf("DebugConnectionStats() failed: %v", err)
+	} else if _, ok := stats["UniqueName"]; !ok {
+		t.Errorf("DebugConnectionStats() is missing UniqueName, got %v", stats)
+	} else if testing.Verbose() {
+		t.Logf("DebugConnectionStats() = %v", stats)
+	}
 }
 
 func TestPeer(t *testing.T) {
@@ -107,6 +163,31 @@ func TestPeer(t *testing.T) {
 		t.Errorf("busPeer.Ping() failed: %v", err)
 	}
 
+	wantID, err := os.ReadFile("/etc/machine-id")
+	if err == nil {
+		id, err := busPeer.MachineID(context.Background())
+		if err != nil {
+			t.Errorf("busPeer.MachineID() failed: %v", err)
+		} else if want := strings.TrimSpace(string(wantID)); id != want {
+			t.Errorf("busPeer.MachineID() = %q, want %q", id, want)
+		}
+
+		other := bus.MustConn(t)
+		otherPeer := conn.Peer(other.LocalName())
+		id, err = otherPeer.MachineID(context.Background())
+		if err != nil {
+			t.Errorf("otherPeer.MachineID() failed: %v", err)
+		} else if want := strings.TrimSpace(string(wantID)); id != want {
+			t.Errorf("otherPeer.MachineID() = %q, want %q", id, want)
+		}
+		// Unique names' machine IDs are cached, and so still
+		// available after the peer goes away.
+		other.Close()
+		if cached, err := otherPeer.MachineID(context.Background()); err != nil || cached != id {
+			t.Errorf("cached otherPeer.MachineID() = (%q, %v), want (%q, nil)", cached, err, id)
+		}
+	}
+
 	creds, err := busPeer.Identity(context.Background())
 	if err != nil {
 		t.Errorf("busPeer.Identity() failed: %v", err)
@@ -143,6 +224,29 @@ func TestPeer(t *testing.T) {
 		t.Error("busPeer.Exists() is false but I'm talking to it!")
 	}
 
+	if _, err := conn.Peer("org.test.DoesNotExist").StartService(context.Background()); err == nil {
+		t.Error("StartService() of non-activatable peer succeeded, want error")
+	}
+
+	noStart := dbus.WithContextAutostart(context.Background(), false)
+	err = conn.Peer("org.test.Activated").Ping(noStart)
+	if !errors.Is(err, dbus.ErrNameHasNoOwner) {
+		t.Errorf("Ping() of activatable peer without autostart got err %v, want NameHasNoOwner", err)
+	}
+
+	claim, err := conn.Claim("org.test.Activated", dbus.ClaimOptions{})
+	if err != nil {
+		t.Fatalf("conn.Claim() failed: %v", err)
+	}
+	defer claim.Close()
+	awaitOwner(t, claim, "", true)
+	started, err := conn.Peer("org.test.Activated").StartService(context.Background())
+	if err != nil {
+		t.Errorf("StartService() of running service failed: %v", err)
+	} else if started {
+		t.Error("StartService() of running service reported newly started, want already running")
+	}
+
 	owner, err := busPeer.Owner(context.Background())
 	if err != nil {
 		t.Errorf("busPeer.Owner() failed: %v", err)
@@ -153,6 +257,124 @@ func TestPeer(t *testing.T) {
 	}
 }
 
+func TestStartService(t *testing.T) {
+	if _, err := exec.LookPath("dbus-send"); err != nil {
+		t.Skip("dbus-send not available, cannot run activatable service")
+	}
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+
+	// org.test.Started's service file runs dbus-send to claim the
+	// name, which completes the activation.
+	started, err := conn.Peer("org.test.Started").StartService(context.Background())
+	if err != nil {
+		t.Fatalf("StartService() of activatable service failed: %v", err)
+	}
+	if !started {
+		t.Error("StartService() of activatable service reported already running, want newly started")
+	}
+}
+
+func TestRemoteIdentity(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+
+	got, err := conn.RemoteIdentity()
+	if err != nil {
+		t.Fatalf("RemoteIdentity() failed: %v", err)
+	}
+	// The remote end of a bus connection is the bus.
+	want, err := conn.Peer("org.freedesktop.DBus").Identity(context.Background())
+	if err != nil {
+		t.Fatalf("getting bus identity: %v", err)
+	}
+	defer want.Close()
+	if got.UID == nil || *got.UID != uint32(os.Getuid()) {
+		t.Errorf("RemoteIdentity().UID = %v, want %d", got.UID, os.Getuid())
+	}
+	if got.PID == nil || want.PID == nil || *got.PID != *want.PID {
+		t.Errorf("RemoteIdentity().PID = %v, want bus PID %v", got.PID, want.PID)
+	}
+}
+
+func TestDialMachineID(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+
+	ctx := context.Background()
+	server, err := dbus.DialWithOptions(ctx, bus.Socket(), dbus.DialOptions{
+		MachineID: func() (string, error) { return "0123456789abcdef0123456789abcdef", nil },
+	})
+	if err != nil {
+		t.Fatalf("DialWithOptions() failed: %v", err)
+	}
+	defer server.Close()
+
+	id, err := conn.Peer(server.LocalName()).MachineID(ctx)
+	if err != nil {
+		t.Fatalf("MachineID() failed: %v", err)
+	}
+	if want := "0123456789abcdef0123456789abcdef"; id != want {
+		t.Errorf("MachineID() = %q, want %q", id, want)
+	}
+}
+
+func TestDialByteOrder(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+
+	// Use whichever byte order the host doesn't, so that both the
+	// bus and the other Conn have to decode a foreign byte order.
+	order := fragments.BigEndian
+	if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+		order = fragments.LittleEndian
+	}
+	ctx := context.Background()
+	server, err := dbus.DialWithOptions(ctx, bus.Socket(), dbus.DialOptions{
+		ByteOrder: order,
+	})
+	if err != nil {
+		t.Fatalf("DialWithOptions() failed: %v", err)
+	}
+	defer server.Close()
+	if got := server.ByteOrder(); got != order {
+		t.Errorf("ByteOrder() = %v, want %v", got, order)
+	}
+
+	type value struct {
+		S string
+		N int64
+		M map[string]any
+	}
+	server.Handle("org.test", "Echo", func(_ context.Context, _ dbus.ObjectPath, v value) (value, error) {
+		return v, nil
+	})
+	want := value{"foo", -42, map[string]any{"x": uint16(1), "y": []uint32{2, 3}}}
+	var got value
+	if err := conn.Peer(server.LocalName()).Object("/").Interface("org.test").Call(ctx, "Echo", want, &got); err != nil {
+		t.Fatalf("Call() to foreign byte order peer failed: %v", err)
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Call() returned wrong value (-got+want):\n%s", diff)
+	}
+
+	names, err := server.Peers(ctx)
+	if err != nil {
+		t.Fatalf("Peers() from foreign byte order conn failed: %v", err)
+	}
+	if !slices.ContainsFunc(names, func(p dbus.Peer) bool { return p.Name() == conn.LocalName() }) {
+		t.Errorf("Peers() = %v, missing %q", names, conn.LocalName())
+	}
+}
+
 func TestObject(t *testing.T) {
 	bus := dbustest.New(t, logBusTraffic)
 
@@ -168,6 +390,145 @@ func TestObject(t *testing.T) {
 		t.Fatal("no interfaces found on DBus object")
 	}
 	t.Log(len(desc.Interfaces))
+
+	iface := desc.Interfaces["org.freedesktop.DBus"]
+	if iface == nil {
+		t.Fatal("org.freedesktop.DBus interface not found on DBus object")
+	}
+	if m, ok := iface.Method("GetNameOwner"); !ok {
+		t.Error("Method(GetNameOwner) not found")
+	} else if m.Name != "GetNameOwner" {
+		t.Errorf("Method(GetNameOwner) returned method %s", m.Name)
+	}
+	if s, ok := iface.Signal("NameOwnerChanged"); !ok {
+		t.Error("Signal(NameOwnerChanged) not found")
+	} else if s.Name != "NameOwnerChanged" {
+		t.Errorf("Signal(NameOwnerChanged) returned signal %s", s.Name)
+	}
+	if p, ok := iface.Property("Features"); !ok {
+		t.Error("Property(Features) not found")
+	} else if p.Name != "Features" {
+		t.Errorf("Property(Features) returned property %s", p.Name)
+	}
+	if _, ok := iface.Method("NoSuchMethod"); ok {
+		t.Error("Method(NoSuchMethod) found")
+	}
+	if _, ok := iface.Signal("NoSuchSignal"); ok {
+		t.Error("Signal(NoSuchSignal) found")
+	}
+	if _, ok := iface.Property("NoSuchProperty"); ok {
+		t.Error("Property(NoSuchProperty) found")
+	}
+}
+
+func TestIntrospectionCache(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	var introspects atomic.Int32
+	ctx := context.Background()
+	conn, err := dbus.DialWithOptions(ctx, bus.Socket(), dbus.DialOptions{
+		IntrospectionCacheTTL: time.Hour,
+		Trace: func(m dbus.MessageTrace) {
+			if m.Sent && m.Member == "Introspect" {
+				introspects.Add(1)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("DialWithOptions() failed: %v", err)
+	}
+	defer conn.Close()
+
+	introspect := func(o dbus.Object, wantCalls int32) {
+		t.Helper()
+		desc, err := o.Introspect(ctx)
+		if err != nil {
+			t.Fatalf("introspecting %s: %v", o, err)
+		}
+		if len(desc.Interfaces) == 0 {
+			t.Errorf("no interfaces found on %s", o)
+		}
+		if got := introspects.Load(); got != wantCalls {
+			t.Errorf("after introspecting %s, sent %d Introspect calls, want %d", o, got, wantCalls)
+		}
+	}
+
+	busObj := conn.Peer("org.freedesktop.DBus").Object("/org/freedesktop/DBus")
+	introspect(busObj, 1)
+	introspect(busObj, 1)
+
+	// Mutating a returned description doesn't affect the cache.
+	desc, err := busObj.Introspect(ctx)
+	if err != nil {
+		t.Fatalf("introspecting %s: %v", busObj, err)
+	}
+	clear(desc.Interfaces)
+	introspect(busObj, 1)
+
+	// Other objects are cached separately.
+	root := conn.Peer("org.freedesktop.DBus").Object("/")
+	introspect(root, 2)
+	introspect(root, 2)
+
+	busObj.InvalidateIntrospection()
+	introspect(busObj, 3)
+	introspect(busObj, 3)
+	introspect(root, 3)
+
+	// Without a TTL, nothing is cached.
+	uncached := bus.MustConn(t)
+	defer uncached.Close()
+	o := uncached.Peer("org.freedesktop.DBus").Object("/org/freedesktop/DBus")
+	o.InvalidateIntrospection()
+	if _, err := o.Introspect(ctx); err != nil {
+		t.Fatalf("introspecting %s: %v", o, err)
+	}
+
+	if _, err := dbus.DialWithOptions(ctx, bus.Socket(), dbus.DialOptions{
+		IntrospectionCacheTTL: -time.Second,
+	}); err == nil {
+		t.Error("DialWithOptions() with negative IntrospectionCacheTTL succeeded")
+	}
+}
+
+func TestWalkObjects(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+
+	var paths []string
+	var ifaces []string
+	for obj, err := range conn.Peer("org.freedesktop.DBus").WalkObjects(context.Background()) {
+		if err != nil {
+			t.Fatalf("walking bus objects: %v", err)
+		}
+		paths = append(paths, obj.Path().String())
+		if obj.Path() != "/org/freedesktop/DBus" {
+			continue
+		}
+		for desc, err := range obj.Interfaces(context.Background()) {
+			if err != nil {
+				t.Fatalf("listing interfaces of %s: %v", obj, err)
+			}
+			ifaces = append(ifaces, desc.Name)
+		}
+	}
+
+	if !slices.IsSorted(paths) {
+		t.Errorf("objects not walked in path order: %q", paths)
+	}
+	for _, want := range []string{"/", "/org/freedesktop/DBus"} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("walk did not visit %s, got %q", want, paths)
+		}
+	}
+	if !slices.IsSorted(ifaces) {
+		t.Errorf("interfaces not listed in name order: %q", ifaces)
+	}
+	if !slices.Contains(ifaces, "org.freedesktop.DBus") {
+		t.Errorf("interfaces of bus object missing org.freedesktop.DBus, got %q", ifaces)
+	}
 }
 
 func TestInterface(t *testing.T) {
@@ -223,6 +584,19 @@ func TestInterface(t *testing.T) {
 		t.Fatalf("busPeer.GetProperty output differs from manual call:\n  got: %v\n want: %v", feats2, feats)
 	}
 
+	// Get property into preallocated storage
+	feats3 := make([]string, 1, len(feats)+1)
+	backing := &feats3[0]
+	if err := busPeer.GetProperty(context.Background(), "Features", &feats3); err != nil {
+		t.Fatalf("busPeer.GetProperty(Features) failed: %v", err)
+	}
+	if !slices.Equal(feats, feats3) {
+		t.Fatalf("busPeer.GetProperty output differs from manual call:\n  got: %v\n want: %v", feats3, feats)
+	}
+	if &feats3[0] != backing {
+		t.Fatal("busPeer.GetProperty did not reuse slice storage")
+	}
+
 	// Get property into any
 	var resp2 any
 	if err := busPeer.GetProperty(context.Background(), "Features", &resp2); err != nil {
@@ -245,6 +619,38 @@ func TestInterface(t *testing.T) {
 		t.Fatal("busPeer.GetAllProperties did not return Interfaces")
 	}
 
+	// Call with multiple retvals into separate pointers
+	conn2 := bus.MustConn(t)
+	defer conn2.Close()
+	type multi struct {
+		A string
+		B uint32
+	}
+	conn2.Handle("org.test.Multi", "Get", func(ctx context.Context, _ dbus.ObjectPath) (multi, error) {
+		return multi{"foo", 42}, nil
+	})
+	multiIface := conn.Peer(conn2.LocalName()).Object("/").Interface("org.test.Multi")
+	var (
+		a string
+		b uint32
+	)
+	if err := multiIface.Call(context.Background(), "Get", nil, &a, &b); err != nil {
+		t.Fatalf("multiIface.Get failed: %v", err)
+	}
+	if a != "foo" || b != 42 {
+		t.Fatalf("multiIface.Get got (%q, %d), want (%q, %d)", a, b, "foo", 42)
+	}
+	var m multi
+	if err := multiIface.Call(context.Background(), "Get", nil, &m); err != nil {
+		t.Fatalf("multiIface.Get into struct failed: %v", err)
+	}
+	if want := (multi{"foo", 42}); m != want {
+		t.Fatalf("multiIface.Get into struct got %v, want %v", m, want)
+	}
+	if err := multiIface.Call(context.Background(), "Get", nil, &a, b); err == nil {
+		t.Fatal("multiIface.Get with non-pointer response succeeded")
+	}
+
 	// Failed call
 	err = busPeer.Call(context.Background(), "FlumpoTron", nil, nil)
 	if err == nil {
@@ -259,6 +665,58 @@ func TestInterface(t *testing.T) {
 	}
 }
 
+func TestSetProperty(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+	server := bus.MustConn(t)
+	defer server.Close()
+
+	type setReq struct {
+		InterfaceName string
+		PropertyName  string
+		Value         any
+	}
+	sets := make(chan setReq, 1)
+	server.Handle("org.freedesktop.DBus.Properties", "Set", func(ctx context.Context, _ dbus.ObjectPath, req setReq) error {
+		sets <- req
+		return nil
+	})
+
+	iface := conn.Peer(server.LocalName()).Object("/test").Interface("org.test.Props")
+	check := func(val any, want any) {
+		t.Helper()
+		if err := iface.SetProperty(context.Background(), "Prop", val); err != nil {
+			t.Fatalf("SetProperty(%v) failed: %v", val, err)
+		}
+		select {
+		case got := <-sets:
+			wantReq := setReq{"org.test.Props", "Prop", want}
+			if diff := cmp.Diff(got, wantReq); diff != "" {
+				t.Fatalf("SetProperty(%v) sent wrong request (-got+want):\n%s", val, diff)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for SetProperty(%v)", val)
+		}
+	}
+
+	check("foo", "foo")
+	check(uint32(42), uint32(42))
+	check([]string{"a", "b"}, []string{"a", "b"})
+	var v any = int64(-5)
+	check(v, int64(-5))
+	check(&v, int64(-5))
+
+	if err := iface.SetProperty(context.Background(), "Prop", nil); err == nil {
+		t.Fatal("SetProperty(nil) succeeded")
+	}
+	var nilAny *any
+	if err := iface.SetProperty(context.Background(), "Prop", nilAny); err == nil {
+		t.Fatal("SetProperty(nil *any) succeeded")
+	}
+}
+
 func awaitOwner(t *testing.T, claim *dbus.Claim, claimName string, wantOwner bool) {
 	t.Helper()
 	if claimName != "" {
@@ -538,3 +996,689 @@ func TestClaim(t *testing.T) {
 		}
 	})
 }
+
+// testSignal is a signal type used by watcher tests.
+type testSignal struct {
+	Msg string
+}
+
+func init() {
+	dbus.RegisterSignalType[testSignal]("org.test.Signals", "Test")
+}
+
+// awaitSignal waits for w to deliver a testSignal with the given
+// message, or for a short timeout if want is false.
+func awaitSignal(t *testing.T, w *dbus.Watcher, name, msg string, want bool) {
+	t.Helper()
+	timeout := 2 * time.Second
+	if !want {
+		timeout = 200 * time.Millisecond
+	}
+	select {
+	case n := <-w.Chan():
+		sig, ok := n.Body.(*testSignal)
+		if !ok {
+			t.Fatalf("%s got unexpected notification %#v", name, n)
+		}
+		if !want {
+			t.Fatalf("%s got unexpected signal %q", name, sig.Msg)
+		}
+		if sig.Msg != msg {
+			t.Fatalf("%s got signal %q, want %q", name, sig.Msg, msg)
+		}
+	case <-time.After(timeout):
+		if want {
+			t.Fatalf("timed out waiting for %s to receive signal %q", name, msg)
+		}
+	}
+}
+
+func TestWatcherUnmatch(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+	emitter := bus.MustConn(t)
+	defer emitter.Close()
+
+	w1, err := conn.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w1.Close()
+	w2, err := conn.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w2.Close()
+
+	m1 := dbus.MatchNotification[testSignal]()
+	if _, err := w1.Match(m1); err != nil {
+		t.Fatalf("w1.Match() failed: %v", err)
+	}
+	// Matching the same *Match twice is harmless.
+	if _, err := w1.Match(m1); err != nil {
+		t.Fatalf("w1.Match() failed: %v", err)
+	}
+	m2 := dbus.MatchNotification[testSignal]()
+	if _, err := w2.Match(m2); err != nil {
+		t.Fatalf("w2.Match() failed: %v", err)
+	}
+
+	emit := func(msg string) {
+		t.Helper()
+		if err := emitter.EmitSignal(context.Background(), "/test", testSignal{msg}); err != nil {
+			t.Fatalf("EmitSignal(%q) failed: %v", msg, err)
+		}
+	}
+
+	emit("one")
+	awaitSignal(t, w1, "w1", "one", true)
+	awaitSignal(t, w2, "w2", "one", true)
+
+	if err := w1.Unmatch(m1); err != nil {
+		t.Fatalf("w1.Unmatch() failed: %v", err)
+	}
+	// Unmatching again is a no-op.
+	if err := w1.Unmatch(m1); err != nil {
+		t.Fatalf("w1.Unmatch() failed: %v", err)
+	}
+
+	emit("two")
+	awaitSignal(t, w2, "w2", "two", true)
+	awaitSignal(t, w1, "w1", "two", false)
+
+	if err := w2.Unmatch(m2); err != nil {
+		t.Fatalf("w2.Unmatch() failed: %v", err)
+	}
+	if _, err := w1.Match(m1); err != nil {
+		t.Fatalf("w1.Match() failed: %v", err)
+	}
+
+	emit("three")
+	awaitSignal(t, w1, "w1", "three", true)
+	awaitSignal(t, w2, "w2", "three", false)
+}
+
+func TestWatcherUnboundedDoesNotStallConn(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+
+	w, err := conn.WatchWithOptions(dbus.WatchOptions{BufferSize: 1, Policy: dbus.WatchUnbounded})
+	if err != nil {
+		t.Fatalf("WatchWithOptions() failed: %v", err)
+	}
+	defer w.Close()
+	if _, err := w.Match(dbus.MatchNotification[dbus.NameOwnerChanged]()); err != nil {
+		t.Fatalf("Match() failed: %v", err)
+	}
+
+	// Each new connection makes the bus broadcast a
+	// NameOwnerChanged for the connection's unique name.
+	const numConns = 5
+	for range numConns {
+		c := bus.MustConn(t)
+		defer c.Close()
+	}
+
+	// The watcher is full and nobody is reading it, but calls on
+	// the same Conn still complete.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.BusID(ctx); err != nil {
+		t.Fatalf("BusID() with full watcher failed: %v", err)
+	}
+
+	for i := range numConns {
+		select {
+		case n := <-w.Chan():
+			if n.Overflow {
+				t.Errorf("notification %d has Overflow set", i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for notification %d", i)
+		}
+	}
+}
+
+func TestSignals(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+	emitter := bus.MustConn(t)
+	defer emitter.Close()
+
+	sigs, cancel, err := dbus.Signals[testSignal](conn)
+	if err != nil {
+		t.Fatalf("Signals() failed: %v", err)
+	}
+	defer cancel()
+
+	for _, msg := range []string{"one", "two"} {
+		if err := emitter.EmitSignal(context.Background(), "/test", testSignal{msg}); err != nil {
+			t.Fatalf("EmitSignal(%q) failed: %v", msg, err)
+		}
+		select {
+		case got := <-sigs:
+			if got.Msg != msg {
+				t.Fatalf("got signal %q, want %q", got.Msg, msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for signal %q", msg)
+		}
+	}
+
+	cancel()
+	select {
+	case got, ok := <-sigs:
+		if ok {
+			t.Fatalf("got signal %q after cancel, want closed channel", got.Msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("signal channel not closed after cancel")
+	}
+}
+
+// testLevel is a property change type used by property watcher tests.
+type testLevel uint32
+
+func init() {
+	dbus.RegisterPropertyChangeType[testLevel]("org.test.Props", "Level")
+}
+
+func TestPropertyChanges(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+	emitter := bus.MustConn(t)
+	defer emitter.Close()
+
+	w, err := conn.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Close()
+	for _, m := range []*dbus.Match{
+		dbus.MatchNotification[testLevel](),
+		dbus.MatchNotification[dbus.PropertiesChanged](),
+	} {
+		if _, err := w.Match(m); err != nil {
+			t.Fatalf("Match() failed: %v", err)
+		}
+	}
+
+	next := func() *dbus.Notification {
+		t.Helper()
+		select {
+		case n := <-w.Chan():
+			return n
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for notification")
+			return nil
+		}
+	}
+	emit := func(changed map[string]any) {
+		t.Helper()
+		body := struct {
+			Interface   string
+			Changed     map[string]any
+			Invalidated []string
+		}{"org.test.Props", changed, nil}
+		if err := emitter.EmitRawSignal(context.Background(), "/test", "org.freedesktop.DBus.Properties", "PropertiesChanged", body); err != nil {
+			t.Fatalf("EmitRawSignal(PropertiesChanged) failed: %v", err)
+		}
+	}
+	checkSignal := func(n *dbus.Notification, wantChanged map[string]any) {
+		t.Helper()
+		sig, ok := n.Body.(*dbus.PropertiesChanged)
+		if !ok {
+			t.Fatalf("got notification %#v, want PropertiesChanged signal", n)
+		}
+		if got := sig.Interface.Name(); got != "org.test.Props" {
+			t.Errorf("PropertiesChanged for interface %q, want org.test.Props", got)
+		}
+		if diff := cmp.Diff(sig.Changed, wantChanged); diff != "" {
+			t.Errorf("wrong changed properties (-got+want):\n%s", diff)
+		}
+	}
+
+	// A registered property is reported both on its own, and as part
+	// of the PropertiesChanged signal.
+	changed := map[string]any{"Level": uint32(42), "Other": "foo"}
+	emit(changed)
+	n := next()
+	if got, ok := n.Body.(*testLevel); !ok || *got != 42 || n.Name != "Level" {
+		t.Fatalf("got notification %#v, want Level property change to 42", n)
+	}
+	checkSignal(next(), changed)
+
+	// Unregistered properties only appear in the signal.
+	changed = map[string]any{"Other": "bar"}
+	emit(changed)
+	checkSignal(next(), changed)
+}
+
+func TestEmitSignalTo(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	target := bus.MustConn(t)
+	defer target.Close()
+	other := bus.MustConn(t)
+	defer other.Close()
+	emitter := bus.MustConn(t)
+	defer emitter.Close()
+
+	var ws []*dbus.Watcher
+	for _, c := range []*dbus.Conn{target, other} {
+		w, err := c.Watch()
+		if err != nil {
+			t.Fatalf("Watch() failed: %v", err)
+		}
+		defer w.Close()
+		if _, err := w.Match(dbus.MatchNotification[testSignal]()); err != nil {
+			t.Fatalf("Match() failed: %v", err)
+		}
+		ws = append(ws, w)
+	}
+
+	if err := emitter.EmitSignalTo(context.Background(), target.LocalName(), "/test", testSignal{"unicast"}); err != nil {
+		t.Fatalf("EmitSignalTo() failed: %v", err)
+	}
+	awaitSignal(t, ws[0], "target", "unicast", true)
+	awaitSignal(t, ws[1], "other", "unicast", false)
+
+	if err := emitter.EmitSignalTo(context.Background(), "", "/test", testSignal{"bad"}); err == nil {
+		t.Fatal("EmitSignalTo() with empty destination succeeded")
+	}
+}
+
+func TestEmitRawSignal(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	listener := bus.MustConn(t)
+	defer listener.Close()
+	emitter := bus.MustConn(t)
+	defer emitter.Close()
+
+	w, err := listener.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Close()
+	if _, err := w.Match(dbus.MatchNotification[testSignal]()); err != nil {
+		t.Fatalf("Match() failed: %v", err)
+	}
+
+	body := struct{ S string }{"raw"}
+	if err := emitter.EmitRawSignal(context.Background(), "/test", "org.test.Signals", "Test", body); err != nil {
+		t.Fatalf("EmitRawSignal() failed: %v", err)
+	}
+	awaitSignal(t, w, "listener", "raw", true)
+
+	if err := emitter.EmitRawSignal(context.Background(), "/test", "", "Test", body); err == nil {
+		t.Fatal("EmitRawSignal() with empty interface succeeded")
+	}
+}
+
+func TestUnregisteredSignal(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	listener := bus.MustConn(t)
+	defer listener.Close()
+	emitter := bus.MustConn(t)
+	defer emitter.Close()
+
+	w, err := listener.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Close()
+	if _, err := w.Match(dbus.MatchAllSignals().Interface("org.test.Unregistered")); err != nil {
+		t.Fatalf("Match() failed: %v", err)
+	}
+
+	body := struct {
+		S string
+		M map[string]any
+	}{"foo", map[string]any{"a": uint32(1)}}
+	if err := emitter.EmitRawSignal(context.Background(), "/test", "org.test.Unregistered", "Thing", body); err != nil {
+		t.Fatalf("EmitRawSignal() failed: %v", err)
+	}
+
+	select {
+	case n := <-w.Chan():
+		if n.Name != "Thing" {
+			t.Errorf("got signal %s, want Thing", n.Name)
+		}
+		if got, want := n.Signature.String(), "sa{sv}"; got != want {
+			t.Errorf("signal Signature = %q, want %q", got, want)
+		}
+		if got, want := reflect.TypeOf(n.Body).Elem(), n.Signature.Type(); got.NumField() != want.NumField() {
+			t.Errorf("signal Body type %s doesn't match Signature type %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for signal")
+	}
+}
+
+func TestRequestName(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn1, conn2 := bus.MustConn(t), bus.MustConn(t)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	ctx := context.Background()
+	const name = "org.test.Bus"
+
+	req := func(conn *dbus.Conn, flags dbus.RequestNameFlags, want dbus.RequestNameReply) {
+		t.Helper()
+		got, err := conn.RequestName(ctx, name, flags)
+		if err != nil {
+			t.Fatalf("RequestName(%q, %d) failed: %v", name, flags, err)
+		}
+		if got != want {
+			t.Fatalf("RequestName(%q, %d) = %s, want %s", name, flags, got, want)
+		}
+	}
+	rel := func(conn *dbus.Conn, want dbus.ReleaseNameReply) {
+		t.Helper()
+		got, err := conn.ReleaseName(ctx, name)
+		if err != nil {
+			t.Fatalf("ReleaseName(%q) failed: %v", name, err)
+		}
+		if got != want {
+			t.Fatalf("ReleaseName(%q) = %s, want %s", name, got, want)
+		}
+	}
+
+	rel(conn1, dbus.ReleaseNameNonExistent)
+	req(conn1, 0, dbus.RequestNamePrimaryOwner)
+	req(conn1, 0, dbus.RequestNameAlreadyOwner)
+	req(conn2, dbus.RequestNameDoNotQueue, dbus.RequestNameExists)
+	rel(conn2, dbus.ReleaseNameNotOwner)
+	req(conn2, 0, dbus.RequestNameInQueue)
+	checkClaim(t, conn1, name, conn1, conn2)
+	rel(conn1, dbus.ReleaseNameReleased)
+	checkClaim(t, conn1, name, conn2)
+	req(conn1, dbus.RequestNameAllowReplacement, dbus.RequestNameInQueue)
+	rel(conn2, dbus.ReleaseNameReleased)
+	req(conn2, dbus.RequestNameReplaceExisting, dbus.RequestNamePrimaryOwner)
+	checkClaim(t, conn1, name, conn2, conn1)
+}
+
+func TestWatchOwner(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn1, conn2 := bus.MustConn(t), bus.MustConn(t)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	ctx := context.Background()
+	const name = "org.test.Bus"
+
+	owners, cancel, err := conn1.WatchOwner(ctx, name)
+	if err != nil {
+		t.Fatalf("WatchOwner(%q) failed: %v", name, err)
+	}
+	defer cancel()
+
+	await := func(want *dbus.Conn) {
+		t.Helper()
+		select {
+		case got := <-owners:
+			switch {
+			case want == nil && got != nil:
+				t.Fatalf("got owner %s, want no owner", got)
+			case want != nil && got == nil:
+				t.Fatalf("got no owner, want %s", want.LocalName())
+			case want != nil && got.Name() != want.LocalName():
+				t.Fatalf("got owner %s, want %s", got, want.LocalName())
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for owner change")
+		}
+	}
+
+	await(nil)
+	if _, err := conn2.RequestName(ctx, name, 0); err != nil {
+		t.Fatalf("RequestName(%q) failed: %v", name, err)
+	}
+	await(conn2)
+	if _, err := conn1.RequestName(ctx, name, 0); err != nil {
+		t.Fatalf("RequestName(%q) failed: %v", name, err)
+	}
+	if _, err := conn2.ReleaseName(ctx, name); err != nil {
+		t.Fatalf("ReleaseName(%q) failed: %v", name, err)
+	}
+	await(conn1)
+	if _, err := conn1.ReleaseName(ctx, name); err != nil {
+		t.Fatalf("ReleaseName(%q) failed: %v", name, err)
+	}
+	await(nil)
+
+	cancel()
+	select {
+	case _, ok := <-owners:
+		if ok {
+			t.Fatal("got owner change after cancel, want closed channel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("owner channel not closed after cancel")
+	}
+}
+
+// afterFuncCtx is a context that is never canceled, and records how
+// many AfterFunc callbacks registered on it are still pending.
+type afterFuncCtx struct {
+	context.Context
+	done    chan struct{}
+	pending atomic.Int32
+}
+
+// Done returns a channel that is not derived from a cancelable
+// context, so that context.AfterFunc uses c.AfterFunc.
+func (c *afterFuncCtx) Done() <-chan struct{} { return c.done }
+
+func (c *afterFuncCtx) AfterFunc(f func()) func() bool {
+	c.pending.Add(1)
+	var once sync.Once
+	return func() bool {
+		stopped := false
+		once.Do(func() {
+			c.pending.Add(-1)
+			stopped = true
+		})
+		return stopped
+	}
+}
+
+func TestWatchOwnerCancelReleasesContext(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn := bus.MustConn(t)
+	defer conn.Close()
+
+	ctx := &afterFuncCtx{Context: context.Background(), done: make(chan struct{})}
+	_, cancel, err := conn.WatchOwner(ctx, "org.test.Bus")
+	if err != nil {
+		t.Fatalf("WatchOwner() failed: %v", err)
+	}
+	if got := ctx.pending.Load(); got != 1 {
+		t.Fatalf("WatchOwner() registered %d context callbacks, want 1", got)
+	}
+	cancel()
+	if got := ctx.pending.Load(); got != 0 {
+		t.Errorf("cancel() left %d context callbacks registered, want 0", got)
+	}
+}
+
+func TestWaitForOwner(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn1, conn2 := bus.MustConn(t), bus.MustConn(t)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	const name = "org.test.Bus"
+	peer := conn1.Peer(name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if owner, err := peer.WaitForOwner(ctx); err == nil {
+		t.Fatalf("WaitForOwner() = %s, want timeout", owner)
+	}
+
+	type result struct {
+		owner dbus.Peer
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		owner, err := peer.WaitForOwner(context.Background())
+		done <- result{owner, err}
+	}()
+
+	claim, err := conn2.Claim(name, dbus.ClaimOptions{})
+	if err != nil {
+		t.Fatalf("Claim(%q) failed: %v", name, err)
+	}
+	defer claim.Close()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("WaitForOwner() failed: %v", res.err)
+		}
+		if got, want := res.owner.Name(), conn2.LocalName(); got != want {
+			t.Fatalf("WaitForOwner() = %s, want %s", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for WaitForOwner")
+	}
+
+	// Already owned names return immediately.
+	owner, err := peer.WaitForOwner(context.Background())
+	if err != nil {
+		t.Fatalf("WaitForOwner() failed: %v", err)
+	}
+	if got, want := owner.Name(), conn2.LocalName(); got != want {
+		t.Fatalf("WaitForOwner() = %s, want %s", got, want)
+	}
+}
+
+func TestCallRetry(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn, service := bus.MustConn(t), bus.MustConn(t)
+	defer conn.Close()
+	defer service.Close()
+
+	ctx := dbus.WithContextAutostart(context.Background(), false)
+	const name = "org.test.Restarting"
+	ping := conn.Peer(name).Object("/").Interface("org.freedesktop.DBus.Peer")
+
+	err := ping.CallRetry(ctx, "Ping", nil, nil, dbus.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	if !dbus.IsRetryable(err) {
+		t.Fatalf("CallRetry() of absent peer = %v, want retryable error", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if _, err := service.RequestName(context.Background(), name, 0); err != nil {
+			t.Errorf("RequestName(%q) failed: %v", name, err)
+		}
+	}()
+	policy := dbus.RetryPolicy{MaxAttempts: 20, Backoff: 5 * time.Millisecond}
+	if err := ping.CallRetry(ctx, "Ping", nil, nil, policy); err != nil {
+		t.Fatalf("CallRetry() of restarting peer failed: %v", err)
+	}
+
+	// Errors from the method itself aren't retried.
+	bogus := conn.Peer(name).Object("/").Interface("org.test.Bogus")
+	start := time.Now()
+	err = bogus.CallRetry(ctx, "Bogus", nil, nil, dbus.RetryPolicy{MaxAttempts: 5, Backoff: time.Second})
+	if err == nil || dbus.IsRetryable(err) {
+		t.Errorf("CallRetry() of unknown method = %v, want non-retryable error", err)
+	}
+	if d := time.Since(start); d >= time.Second {
+		t.Errorf("CallRetry() of unknown method took %v, want no retries", d)
+	}
+
+	// Context expiry interrupts the backoff.
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	absent := conn.Peer("org.test.Absent").Object("/").Interface("org.freedesktop.DBus.Peer")
+	err = absent.CallRetry(tctx, "Ping", nil, nil, dbus.RetryPolicy{MaxAttempts: 5, Backoff: time.Second})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CallRetry() with expiring context = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestHandlerFiles(t *testing.T) {
+	bus := dbustest.New(t, logBusTraffic)
+
+	conn, service := bus.MustConn(t), bus.MustConn(t)
+	defer conn.Close()
+	defer service.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	type fileResp struct {
+		Name string
+		File *os.File
+	}
+	// The Conn owns returned files, and closes them after sending
+	// the reply.
+	service.Handle("org.test.Files", "Get", func(context.Context, dbus.ObjectPath) (fileResp, error) {
+		return fileResp{"pipe", w}, nil
+	})
+
+	var got fileResp
+	iface := conn.Peer(service.LocalName()).Object("/").Interface("org.test.Files")
+	if err := iface.Call(context.Background(), "Get", nil, &got); err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if got.Name != "pipe" || got.File == nil {
+		t.Fatalf("Call() got %+v, want pipe file", got)
+	}
+
+	if _, err := got.File.Write([]byte("hello")); err != nil {
+		t.Fatalf("writing to received file: %v", err)
+	}
+	got.File.Close()
+	// EOF shows that the service's copy of the descriptor was closed
+	// as well.
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading pipe: %v", err)
+	}
+	if string(bs) != "hello" {
+		t.Errorf("read %q from pipe, want %q", bs, "hello")
+	}
+
+	// Files returned to one-way calls are closed without being
+	// sent.
+	r2, w2, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	service.Handle("org.test.Files", "Drop", func(context.Context, dbus.ObjectPath) (*os.File, error) {
+		return w2, nil
+	})
+	if err := iface.OneWay(context.Background(), "Drop", nil); err != nil {
+		t.Fatalf("OneWay() failed: %v", err)
+	}
+	if _, err := io.ReadAll(r2); err != nil {
+		t.Fatalf("reading pipe: %v", err)
+	}
+}
@@ -1,11 +1,14 @@
 package dbus
 
 import (
+	"bytes"
 	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/danderson/dbus/fragments"
 )
@@ -50,9 +53,160 @@ func (f Interface) Compare(other Interface) int {
 // This is a low-level calling API. It is the caller's responsibility
 // to match the body and response types to the signature of the method
 // being invoked. Body may be nil for methods that accept no
-// parameters. Response may be nil for methods that return no values.
-func (f Interface) Call(ctx context.Context, method string, body any, response any) error {
-	return f.Conn().call(ctx, f.Peer().Name(), f.Object().Path(), f.Name(), method, body, response, false)
+// parameters, in which case the call message has an empty body and
+// no signature header field, as the DBus specification requires.
+// Response may be omitted or nil for methods that return no values.
+//
+// For methods that return multiple values, response may either be a
+// single pointer to a struct whose fields match the method's return
+// values, or several pointers that receive successive return values:
+//
+//	var a, b string
+//	var c uint32
+//	err := iface.Call(ctx, "Method", req, &a, &b, &c)
+func (f Interface) Call(ctx context.Context, method string, body any, response ...any) error {
+	resp, err := callResponse(response)
+	if err != nil {
+		return err
+	}
+	return f.Conn().call(ctx, f.Peer().Name(), f.Object().Path(), f.Name(), method, body, resp, false)
+}
+
+// CallRaw calls method on the interface with a pre-encoded request
+// body, and writes the response into response.
+//
+// CallRaw is intended for proxies that relay messages between
+// connections, and can avoid decoding and re-encoding message
+// bodies. body must be the wire encoding of values matching sig, in
+// the Conn's [Conn.ByteOrder]. Use [SwapByteOrder] to convert bodies
+// received in a different byte order. sig is sent verbatim as the
+// message's body signature, so the body of a method that takes
+// several arguments has a multi-type signature such as "sb". body
+// may be nil if sig is the zero Signature.
+//
+// CallRaw checks that body is well formed for sig before sending it.
+// Raw bodies cannot carry file descriptors, so sig must not contain
+// the unix fd type "h".
+func (f Interface) CallRaw(ctx context.Context, method string, sig Signature, body []byte, response any) error {
+	if err := checkRawBody(sig, body, f.Conn().ByteOrder()); err != nil {
+		return err
+	}
+	return f.Conn().call(ctx, f.Peer().Name(), f.Object().Path(), f.Name(), method, rawBody{sig, body}, response, false)
+}
+
+// rawBody is a message body that is already encoded in the Conn's
+// byte order.
+type rawBody struct {
+	sig  Signature
+	body []byte
+}
+
+// checkRawBody returns an error if body is not a valid encoding of
+// values of signature sig in the given byte order.
+func checkRawBody(sig Signature, body []byte, order fragments.ByteOrder) error {
+	if sig.IsZero() {
+		if len(body) != 0 {
+			return errors.New("raw body must be empty when signature is empty")
+		}
+		return nil
+	}
+	if strings.ContainsRune(sig.String(), 'h') {
+		return fmt.Errorf("raw body signature %q contains file descriptors, which raw bodies cannot carry", sig)
+	}
+	if len(body) > maxMessageSize {
+		return fmt.Errorf("raw body length %d exceeds maximum of %d bytes", len(body), maxMessageSize)
+	}
+	r := bytes.NewReader(body)
+	dec := fragments.Decoder{
+		Order: order,
+		In:    r,
+	}
+	if err := dec.Skip(sig.String()); err != nil {
+		return fmt.Errorf("raw body does not match signature %q: %w", sig, err)
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("raw body has %d bytes of trailing data after values of signature %q", r.Len(), sig)
+	}
+	return nil
+}
+
+// RetryPolicy configures the retries of [Interface.CallRetry].
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times to attempt the
+	// call. Values less than 1 mean a single attempt.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. The delay doubles
+	// after each subsequent failed attempt.
+	Backoff time.Duration
+}
+
+// CallRetry is like [Interface.Call], but retries the call according
+// to policy if it fails with an error that [IsRetryable] accepts.
+//
+// CallRetry is intended for calling activatable services, which can
+// transiently disappear from the bus while they restart. Other errors
+// are returned immediately. If ctx expires while waiting to retry,
+// CallRetry returns the context's error.
+func (f Interface) CallRetry(ctx context.Context, method string, body any, response any, policy RetryPolicy) error {
+	delay := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := f.Call(ctx, method, body, response)
+		if err == nil || !IsRetryable(err) || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// callResponse returns the value that [Conn.call] should decode a
+// method's return values into, given the response arguments of
+// [Interface.Call].
+func callResponse(response []any) (any, error) {
+	switch len(response) {
+	case 0:
+		return nil, nil
+	case 1:
+		return response[0], nil
+	}
+	for i, r := range response {
+		v := reflect.ValueOf(r)
+		if !v.IsValid() || v.Kind() != reflect.Pointer || v.IsNil() {
+			return nil, fmt.Errorf("response %d must be a non-nil pointer, got %T", i, r)
+		}
+	}
+	return &multiResponse{outs: response}, nil
+}
+
+// multiResponse decodes successive values of a message body into
+// separate outputs.
+type multiResponse struct {
+	outs []any
+}
+
+// SignatureDBus returns the zero Signature. The real signature
+// depends on the outputs of a particular multiResponse, and
+// signatures are derived from types, not values. This is safe
+// because multiResponse is only ever the decoding target of a method
+// reply, and decoding a reply does not consult the target's
+// signature: UnmarshalDBus decodes each output according to its own
+// type.
+func (*multiResponse) SignatureDBus() Signature { return Signature{} }
+
+func (m *multiResponse) UnmarshalDBus(ctx context.Context, d *fragments.Decoder) error {
+	for i, out := range m.outs {
+		if err := d.Value(ctx, out); err != nil {
+			return fmt.Errorf("decoding response %d: %w", i, err)
+		}
+	}
+	return nil
 }
 
 // OneWay calls method on the interface with the given request body,
@@ -62,6 +216,18 @@ func (f Interface) Call(ctx context.Context, method string, body any, response a
 // the response is suppressed at the bus level, there is no way to
 // know whether the call was delivered to anyone, or acted upon.
 //
+// Messages are written directly to the underlying transport without
+// additional buffering, so a nil error means the entire message was
+// handed to the operating system. Failures to write the message,
+// such as the connection having been closed, are returned
+// synchronously.
+//
+// ctx is only checked before sending: if ctx is already done, OneWay
+// returns its error without sending anything. Once writing begins,
+// OneWay waits for the write to complete regardless of ctx, because
+// abandoning a partially written message would corrupt the
+// connection.
+//
 // This is a low-level calling API. It is the caller's responsibility
 // to match the body to the signature of the method being
 // invoked. Body may be nil for methods that accept no parameters.
@@ -74,6 +240,13 @@ func (f Interface) OneWay(ctx context.Context, method string, body any) error {
 // It is the caller's responsibility to match the value's type to the
 // type offered by the interface. val may also be of type *any to
 // retrieve a property without knowing its type.
+//
+// When val points to an existing slice or map, GetProperty reuses
+// its storage where possible, so that repeatedly polling a property
+// into the same destination does not allocate new backing storage on
+// every call. Byte slices are the exception: they are decoded into
+// fresh storage, unless ctx enables reuse with
+// [WithContextReuseBytes].
 func (f Interface) GetProperty(ctx context.Context, name string, val any) error {
 	want := reflect.ValueOf(val)
 	if !want.IsValid() {
@@ -134,14 +307,34 @@ func (p *propDecoder) UnmarshalDBus(ctx context.Context, d *fragments.Decoder) e
 // SetProperty sets the given property to value.
 //
 // It is the caller's responsibility to match the value's type to the
-// type offered by the interface.
+// type offered by the interface. The value is always sent as a
+// variant containing value's concrete type. To set a property whose
+// type is itself a variant, value may be a *any, which sets the
+// property to the value it points to.
 func (f Interface) SetProperty(ctx context.Context, name string, value any) error {
+	if p, ok := value.(*any); ok {
+		if p == nil {
+			return errors.New("cannot set property to nil pointer")
+		}
+		value = *p
+	}
+	if value == nil {
+		return errors.New("cannot set property to nil value")
+	}
+	sig, err := signatureFor(reflect.TypeOf(value), nil)
+	if err != nil {
+		return fmt.Errorf("invalid property type %T: %w", value, err)
+	}
+	if !sig.isSingleType() {
+		return fmt.Errorf("property value cannot be multi-value type %T (signature %q)", value, sig)
+	}
+
 	req := struct {
 		InterfaceName string
 		PropertyName  string
 		Value         any
 	}{f.name, name, value}
-	return f.Object().Interface(ifaceProps).Call(ctx, "Set", req, nil)
+	return f.Object().Interface(ifaceProps).Call(ctx, "Set", req)
 }
 
 // GetAllProperties returns all the properties exported by the
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"go/format"
+	"go/token"
 	"reflect"
 	"slices"
 	"strings"
@@ -17,14 +18,36 @@ import (
 type generator struct {
 	out   bytes.Buffer
 	iface *dbus.InterfaceDescription
+	opts  Options
 	inits bytes.Buffer
 }
 
+// Options control optional features of the generated code.
+type Options struct {
+	// VarDictStructs generates a struct type for each named a{sv}
+	// method argument, instead of using map[string]any. The struct
+	// uses the vardict layout, with a typed field for each key in
+	// the argument's [dbus.ArgumentDescription.Keys], so that
+	// callers can add typed fields for other known keys.
+	VarDictStructs bool
+
+	// Peer and Object are the bus name and object path at which the
+	// interface is offered. If both are set, the generated code
+	// includes a New function that returns the interface on that
+	// object.
+	Peer   string
+	Object dbus.ObjectPath
+}
+
 func Interface(iface *dbus.InterfaceDescription) (string, error) {
+	return InterfaceWithOptions(iface, Options{})
+}
+
+func InterfaceWithOptions(iface *dbus.InterfaceDescription, opts Options) (string, error) {
 	if iface == nil {
 		return "", errors.New("no interface provided")
 	}
-	g := generator{iface: iface}
+	g := generator{iface: iface, opts: opts}
 	if err := g.Interface(iface); err != nil {
 		return "", err
 	}
@@ -49,17 +72,62 @@ func (g *generator) init(msg string, args ...any) {
 	fmt.Fprintf(&g.inits, msg, args...)
 }
 
-func (g *generator) Interface(iface *dbus.InterfaceDescription) error {
-	g.f(`
-type %[1]s struct { iface dbus.Interface }
+// doc writes a doc comment consisting of summary, followed by the
+// paragraphs of the DBus documentation text doc, and a deprecation
+// notice if deprecated is true.
+func (g *generator) doc(summary, doc string, deprecated bool) {
+	g.s(comment(summary))
+	if doc != "" {
+		for _, para := range strings.Split(doc, "\n\n") {
+			g.s("//\n")
+			g.s(comment(para))
+		}
+	}
+	if deprecated {
+		g.s("//\n// Deprecated: marked as deprecated in the DBus interface definition.\n")
+	}
+}
 
-// New returns an interface to TODO
-func New(conn *dbus.Conn) %[1]s {
-  obj := conn.Peer("TODO").Object("TODO")
+// comment returns s as a line comment, wrapped to a comfortable
+// width.
+func comment(s string) string {
+	const width = 70
+	var (
+		ret  strings.Builder
+		line int
+	)
+	for i, word := range strings.Fields(s) {
+		if i > 0 && line+1+len(word) > width {
+			ret.WriteString("\n")
+			line = 0
+		}
+		if line == 0 {
+			ret.WriteString("//")
+		}
+		ret.WriteString(" ")
+		ret.WriteString(word)
+		line += 1 + len(word)
+	}
+	ret.WriteString("\n")
+	return ret.String()
+}
+
+func (g *generator) Interface(iface *dbus.InterfaceDescription) error {
+	g.s("\n")
+	g.doc(fmt.Sprintf("%s is the DBus interface %s.", publicIdentifier(iface.Name), iface.Name), iface.Doc, false)
+	g.f(`type %[1]s struct { iface dbus.Interface }
+
+`, publicIdentifier(g.iface.Name))
+	if g.opts.Peer != "" && g.opts.Object != "" {
+		g.s(comment(fmt.Sprintf("New returns the %s offered by %s at %s.", publicIdentifier(g.iface.Name), g.opts.Peer, g.opts.Object)))
+		g.f(`func New(conn *dbus.Conn) %[1]s {
+  obj := conn.Peer(%[2]q).Object(%[3]q)
   return Interface(obj)
 }
 
-// Interface returns a %[1]s on the given object.
+`, publicIdentifier(g.iface.Name), g.opts.Peer, g.opts.Object)
+	}
+	g.f(`// Interface returns a %[1]s on the given object.
 func Interface(obj dbus.Object) %[1]s {
   return %[1]s{
     iface: obj.Interface(%[2]q),
@@ -100,9 +168,12 @@ func (g *generator) Method(m *dbus.MethodDescription) {
 	ai := argsIn{mname, m.In}
 	ao := argsOut{mname, m.Out}
 
+	g.writeVarDicts(mname, m.In)
+	g.writeVarDicts(mname, m.Out)
 	ai.writeStruct(g)
 	ao.writeStruct(g)
 
+	g.doc(fmt.Sprintf("%s calls the method %s.%s.", mname, g.iface.Name, m.Name), m.Doc, m.Deprecated)
 	g.f("func (iface %s) %s(", publicIdentifier(g.iface.Name), mname)
 	ai.writeArgs(g)
 	g.s(") (")
@@ -121,19 +192,19 @@ func (g *generator) Method(m *dbus.MethodDescription) {
 
 func (g *generator) Signal(s *dbus.SignalDescription) {
 	sname := publicIdentifier(s.Name)
-	g.f(`
-// %[1]s implements the signal %[2]s.%[3]s.
-type %[1]s %[4]s
+	g.s("\n")
+	g.doc(fmt.Sprintf("%s implements the signal %s.%s.", sname, g.iface.Name, s.Name), s.Doc, s.Deprecated)
+	g.f(`type %[1]s %[2]s
 
-`, sname, g.iface.Name, s.Name, asStruct(s.Args).Type())
+`, sname, asStruct(s.Args))
 	g.init("dbus.RegisterSignalType[%s](%q, %q)\n", publicIdentifier(s.Name), g.iface.Name, s.Name)
 }
 
 func (g *generator) Property(prop *dbus.PropertyDescription) {
 	if prop.Constant || prop.Readable {
-		g.f(`
-// %[2]s returns the value of the property %[4]q.
-func (iface %[1]s) %[2]s(ctx context.Context) (%[3]s, error) {
+		g.s("\n")
+		g.doc(fmt.Sprintf("%s returns the value of the property %q.", publicIdentifier(prop.Name), prop.Name), prop.Doc, prop.Deprecated)
+		g.f(`func (iface %[1]s) %[2]s(ctx context.Context) (%[3]s, error) {
   var ret %[3]s
   err := iface.iface.GetProperty(ctx, %[4]q, &ret)
   return ret, err
@@ -143,9 +214,9 @@ func (iface %[1]s) %[2]s(ctx context.Context) (%[3]s, error) {
 	}
 
 	if prop.Writable {
-		g.f(`
-// %[2]s sets the value of property %[4]q to val.
-func (iface %[1]s) Set%[2]s(ctx context.Context, val %[3]s) error {
+		g.s("\n")
+		g.doc(fmt.Sprintf("Set%s sets the value of property %q to val.", publicIdentifier(prop.Name), prop.Name), prop.Doc, prop.Deprecated)
+		g.f(`func (iface %[1]s) Set%[2]s(ctx context.Context, val %[3]s) error {
   return iface.iface.SetProperty(ctx, %[4]q, val)
 }
 
@@ -170,6 +241,83 @@ type %[1]sChanged struct{}
 	g.init("dbus.RegisterPropertyChangeType[%sChanged](%q, %q)\n", publicIdentifier(prop.Name), g.iface.Name, prop.Name)
 }
 
+// isVarDict reports whether arg should be represented by a generated
+// vardict struct.
+func (g *generator) isVarDict(arg dbus.ArgumentDescription) bool {
+	return g.opts.VarDictStructs && arg.Name != "" && arg.Type.String() == "a{sv}"
+}
+
+// varDictName returns the name of the generated vardict struct for
+// arg of the given method.
+func varDictName(methodName string, n int, arg dbus.ArgumentDescription) string {
+	return methodName + publicIdentifier(argName(n, arg))
+}
+
+// writeVarDicts writes vardict struct types for the arguments of
+// methodName that need them.
+func (g *generator) writeVarDicts(methodName string, args []dbus.ArgumentDescription) {
+	for i, arg := range args {
+		if !g.isVarDict(arg) {
+			continue
+		}
+		g.f(`
+// %[1]s is the %[2]q argument of %[3]s.
+type %[1]s struct {
+  _ dbus.InlineLayout
+
+`, varDictName(methodName, i, arg), arg.Name, methodName)
+		seen := map[string]bool{"Unknown": true}
+		for _, key := range arg.Keys {
+			field := keyFieldName(key.Name)
+			if seen[field] || !token.IsIdentifier(field) {
+				// Leave colliding or unrepresentable keys to the
+				// Unknown map, rather than generating a struct
+				// that doesn't compile.
+				continue
+			}
+			seen[field] = true
+			g.f("%s %s `dbus:\"key=%s\"`\n", field, key.Type.Type(), key.Name)
+		}
+		if len(arg.Keys) > 0 {
+			g.s("\n")
+		}
+		g.s(`  // Unknown collects entries that have no corresponding struct
+  // field.
+  Unknown map[string]any ` + "`dbus:\"vardict\"`" + `
+}
+
+`)
+	}
+}
+
+// keyFieldName returns the name of the vardict struct field for the
+// given dictionary key.
+func keyFieldName(key string) string {
+	ret := publicIdentifier(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+	if r := []rune(ret); len(r) == 0 || !unicode.IsLetter(r[0]) {
+		ret = "Key" + ret
+	}
+	return ret
+}
+
+// argType returns the Go type to use for arg of the given method.
+func (g *generator) argType(methodName string, n int, arg dbus.ArgumentDescription) string {
+	if g.isVarDict(arg) {
+		return varDictName(methodName, n, arg)
+	}
+	return arg.Type.Type().String()
+}
+
+// structType returns a struct type with one field per argument of
+// the given method.
+func (g *generator) structType(methodName string, args []dbus.ArgumentDescription) string {
+	fs := make([]string, len(args))
+	for i, a := range args {
+		fs[i] = fmt.Sprintf("%s %s", publicIdentifier(argName(i, a)), g.argType(methodName, i, a))
+	}
+	return fmt.Sprintf("struct { %s }", strings.Join(fs, "; "))
+}
+
 func argName(n int, arg dbus.ArgumentDescription) string {
 	name := arg.Name
 	if name == "" {
@@ -216,7 +364,7 @@ func publicIdentifier(s string) string {
 	return strings.Title(identifier(s))
 }
 
-func asStruct(args []dbus.ArgumentDescription) dbus.Signature {
+func asStruct(args []dbus.ArgumentDescription) reflect.Type {
 	fs := make([]reflect.StructField, len(args))
 	for i, a := range args {
 		fs[i] = reflect.StructField{
@@ -224,12 +372,7 @@ func asStruct(args []dbus.ArgumentDescription) dbus.Signature {
 			Type: a.Type.Type(),
 		}
 	}
-	st := reflect.StructOf(fs)
-	ret, err := dbus.SignatureOf(reflect.New(st).Elem().Interface())
-	if err != nil {
-		panic(err)
-	}
-	return ret
+	return reflect.StructOf(fs)
 }
 
 type argsIn struct {
@@ -245,7 +388,7 @@ func (a argsIn) writeStruct(g *generator) {
 	if !a.useStruct() {
 		return
 	}
-	g.f("type %sRequest %s\n", a.methodName, asStruct(a.args).Type())
+	g.f("type %sRequest %s\n", a.methodName, g.structType(a.methodName, a.args))
 }
 
 func (a argsIn) writeArgs(g *generator) {
@@ -253,8 +396,8 @@ func (a argsIn) writeArgs(g *generator) {
 		g.f("ctx context.Context, req %sRequest", a.methodName)
 	} else {
 		g.s("ctx context.Context")
-		for i, a := range a.args {
-			g.f(", %s %s", argName(i, a), a.Type.Type())
+		for i, arg := range a.args {
+			g.f(", %s %s", argName(i, arg), g.argType(a.methodName, i, arg))
 		}
 	}
 }
@@ -270,8 +413,7 @@ func (a argsIn) writeMkReq(g *generator) (varName string) {
 		return "req"
 	}
 
-	st := asStruct(a.args)
-	g.f("req := %s{\n", st.Type())
+	g.f("req := %s{\n", g.structType(a.methodName, a.args))
 	for i, a := range a.args {
 		g.f("%s: %s,\n", publicIdentifier(argName(i, a)), argName(i, a))
 	}
@@ -305,8 +447,8 @@ func (a argsOut) useSliceStruct() bool {
 
 func (a argsOut) writeStruct(g *generator) {
 	if a.useStruct() {
-		g.f("type %sResponse %s\n", a.methodName, asStruct(a.args).Type())
-	} else if a.useStruct() {
+		g.f("type %sResponse %s\n", a.methodName, g.structType(a.methodName, a.args))
+	} else if a.useSliceStruct() {
 		g.f("type %sVal %s\n", a.methodName, a.args[0].Type.Type().Elem())
 	}
 }
@@ -319,11 +461,11 @@ func (a argsOut) writeArgs(g *generator) {
 	} else if a.useSliceStruct() {
 		g.f("resp []%sVal, err error", a.methodName)
 	} else {
-		for i, a := range a.args {
+		for i, arg := range a.args {
 			if i > 0 {
 				g.s(",")
 			}
-			g.f("%s %s", argName(i, a), a.Type.Type())
+			g.f("%s %s", argName(i, arg), g.argType(a.methodName, i, arg))
 		}
 		g.s(", err error")
 	}
@@ -333,6 +475,9 @@ func (a argsOut) writeMkRet(g *generator) (varName string) {
 	if len(a.args) == 0 {
 		return "nil"
 	}
+	if a.useSliceStruct() {
+		return "&resp"
+	}
 	if len(a.args) == 1 {
 		return "&" + argName(0, a.args[0])
 	}
@@ -340,20 +485,18 @@ func (a argsOut) writeMkRet(g *generator) (varName string) {
 		g.f("var resp %sResponse\n", a.methodName)
 		return "&resp"
 	}
-	if a.useSliceStruct() {
-		g.f("var resp []%sVal\n", a.methodName)
-		return "&resp"
-	}
-	g.f("var resp %s\n", asStruct(a.args).Type())
+	g.f("var resp %s\n", g.structType(a.methodName, a.args))
 	return "&resp"
 }
 
 func (a argsOut) writeRet(g *generator) {
 	if len(a.args) == 0 {
 		g.s("return err\n")
+	} else if a.useSliceStruct() {
+		g.s("return resp, err\n")
 	} else if len(a.args) == 1 {
 		g.f("return %s, err", argName(0, a.args[0]))
-	} else if a.useStruct() || a.useSliceStruct() {
+	} else if a.useStruct() {
 		g.s("return resp, err\n")
 	} else {
 		g.s("return ")
@@ -3,11 +3,15 @@ package dbusgen_test
 import (
 	"context"
 	"embed"
+	"encoding/xml"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/danderson/dbus"
 	"github.com/danderson/dbus/dbustest"
 	"github.com/danderson/dbus/internal/dbusgen"
 	"github.com/google/go-cmp/cmp"
@@ -34,7 +38,10 @@ func TestGen(t *testing.T) {
 			// expected output still gets written.
 		}
 		want := string(wantBs)
-		got, err := dbusgen.Interface(iface)
+		got, err := dbusgen.InterfaceWithOptions(iface, dbusgen.Options{
+			Peer:   "org.freedesktop.DBus",
+			Object: "/org/freedesktop/DBus",
+		})
 		if err != nil {
 			t.Errorf("generating interface %q: %v", iface.Name, err)
 			continue
@@ -46,3 +53,206 @@ func TestGen(t *testing.T) {
 		}
 	}
 }
+
+func TestGenSliceStruct(t *testing.T) {
+	sig, err := dbus.ParseSignature("a(ii)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iface := &dbus.InterfaceDescription{
+		Name: "org.test.SliceStruct",
+		Methods: []*dbus.MethodDescription{
+			{
+				Name: "Points",
+				Out:  []dbus.ArgumentDescription{{Name: "points", Type: sig}},
+			},
+		},
+	}
+	got, err := dbusgen.Interface(iface)
+	if err != nil {
+		t.Fatalf("generating interface: %v", err)
+	}
+
+	goldenPath := filepath.Join("testdata", iface.Name)
+	want, err := golden.ReadFile(goldenPath)
+	if err != nil {
+		t.Errorf("reading golden file %q: %v", goldenPath, err)
+	}
+	if diff := cmp.Diff(strings.Split(got, "\n"), strings.Split(string(want), "\n")); diff != "" {
+		gotPath := goldenPath + ".got"
+		os.WriteFile(gotPath, []byte(got), 0600)
+		t.Errorf("wrong dbusgen output (-got+want, got file written to %s):\n%s", gotPath, diff)
+	}
+
+	mustBuild(t, got)
+}
+
+// mustBuild checks that src, the output of dbusgen.Interface,
+// compiles as a Go package.
+func mustBuild(t *testing.T, src string) {
+	t.Helper()
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not available, cannot build generated code")
+	}
+
+	// Build in a scratch module that uses this checkout of the dbus
+	// package, so that the test doesn't litter the source tree.
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatalf("finding module root: %v", err)
+	}
+	sums, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatalf("reading go.sum: %v", err)
+	}
+	dir := t.TempDir()
+	gomod := fmt.Sprintf("module gentest\n\ngo 1.23\n\nrequire github.com/danderson/dbus v0.0.0\n\nreplace github.com/danderson/dbus => %s\n", root)
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0600); err != nil {
+		t.Fatalf("writing go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), sums, 0600); err != nil {
+		t.Fatalf("writing go.sum: %v", err)
+	}
+
+	src = "package gentest\n\nimport (\n\t\"context\"\n\n\t\"github.com/danderson/dbus\"\n)\n" + src
+	if err := os.WriteFile(filepath.Join(dir, "gen.go"), []byte(src), 0600); err != nil {
+		t.Fatalf("writing generated code: %v", err)
+	}
+	cmd := exec.Command(gobin, "build", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("building generated code failed: %v\n%s\ncode:\n%s", err, out, src)
+	}
+}
+
+func TestGenDocs(t *testing.T) {
+	const introspection = `
+<node xmlns:doc="http://www.freedesktop.org/dbus/1.0/doc.dtd">
+  <interface name="org.test.Docs">
+    <annotation name="org.gtk.GDBus.DocString" value="Docs is an interface with documentation."/>
+    <method name="Frob">
+      <doc:doc>
+        <doc:summary>Frobs the widget.</doc:summary>
+        <doc:description>
+          <doc:para>Frobbing is idempotent, and may be
+          repeated safely.</doc:para>
+        </doc:description>
+      </doc:doc>
+      <arg name="count" type="u" direction="in"/>
+    </method>
+    <method name="Old">
+      <annotation name="org.freedesktop.DBus.Deprecated" value="true"/>
+    </method>
+    <property name="Level" type="i" access="readwrite">
+      <annotation name="org.gtk.GDBus.DocString" value="The current frobbing level."/>
+    </property>
+    <signal name="Frobbed">
+      <annotation name="org.gtk.GDBus.DocString" value="Emitted after each frob."/>
+      <arg name="count" type="u"/>
+    </signal>
+  </interface>
+</node>`
+	var desc dbus.ObjectDescription
+	if err := xml.Unmarshal([]byte(introspection), &desc); err != nil {
+		t.Fatalf("parsing introspection data: %v", err)
+	}
+	iface := desc.Interfaces["org.test.Docs"]
+	if iface == nil {
+		t.Fatal("org.test.Docs missing from parsed introspection data")
+	}
+	if want := "Docs is an interface with documentation."; iface.Doc != want {
+		t.Errorf("interface doc is %q, want %q", iface.Doc, want)
+	}
+
+	got, err := dbusgen.Interface(iface)
+	if err != nil {
+		t.Fatalf("generating interface: %v", err)
+	}
+	goldenPath := filepath.Join("testdata", iface.Name)
+	want, err := golden.ReadFile(goldenPath)
+	if err != nil {
+		t.Errorf("reading golden file %q: %v", goldenPath, err)
+	}
+	if diff := cmp.Diff(strings.Split(got, "\n"), strings.Split(string(want), "\n")); diff != "" {
+		gotPath := goldenPath + ".got"
+		os.WriteFile(gotPath, []byte(got), 0600)
+		t.Errorf("wrong dbusgen output (-got+want, got file written to %s):\n%s", gotPath, diff)
+	}
+
+	mustBuild(t, got)
+}
+
+func TestGenVarDict(t *testing.T) {
+	const introspection = `
+<node>
+  <interface name="org.test.VarDict">
+    <method name="Notify">
+      <arg name="summary" type="s" direction="in">
+        <annotation name="com.github.danderson.dbus.VarDictKey" value="ignored s"/>
+      </arg>
+      <arg name="hints" type="a{sv}" direction="in">
+        <annotation name="com.github.danderson.dbus.VarDictKey" value="urgency y"/>
+        <annotation name="com.github.danderson.dbus.VarDictKey" value="malformed"/>
+        <annotation name="com.github.danderson.dbus.VarDictKey" value="badtype (s"/>
+        <annotation name="com.github.danderson.dbus.VarDictKey" value="action-icons b"/>
+        <annotation name="com.github.danderson.dbus.VarDictKey" value="x-position i"/>
+        <annotation name="com.github.danderson.dbus.VarDictKey" value="unknown s"/>
+      </arg>
+      <arg name="id" type="u" direction="out"/>
+    </method>
+    <method name="Query">
+      <arg type="a{sv}" direction="in"/>
+      <arg name="results" type="a{sv}" direction="out"/>
+    </method>
+  </interface>
+</node>`
+	var desc dbus.ObjectDescription
+	if err := xml.Unmarshal([]byte(introspection), &desc); err != nil {
+		t.Fatalf("parsing introspection data: %v", err)
+	}
+	iface := desc.Interfaces["org.test.VarDict"]
+	if iface == nil {
+		t.Fatal("org.test.VarDict missing from parsed introspection data")
+	}
+	sig := func(s string) dbus.Signature {
+		t.Helper()
+		ret, err := dbus.ParseSignature(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret
+	}
+	wantKeys := []dbus.KeyDescription{
+		{Name: "urgency", Type: sig("y")},
+		{Name: "action-icons", Type: sig("b")},
+		{Name: "x-position", Type: sig("i")},
+		{Name: "unknown", Type: sig("s")},
+	}
+	gotKeys := iface.Methods[0].In[1].Keys
+	if diff := cmp.Diff(gotKeys, wantKeys, cmp.Comparer(dbus.Signature.Equal)); diff != "" {
+		t.Errorf("wrong documented keys for hints (-got+want):\n%s", diff)
+	}
+	if keys := iface.Methods[0].In[0].Keys; len(keys) != 0 {
+		t.Errorf("got documented keys %v for non-dict arg summary, want none", keys)
+	}
+
+	got, err := dbusgen.InterfaceWithOptions(iface, dbusgen.Options{VarDictStructs: true})
+	if err != nil {
+		t.Fatalf("generating interface: %v", err)
+	}
+	goldenPath := filepath.Join("testdata", iface.Name)
+	want, err := golden.ReadFile(goldenPath)
+	if err != nil {
+		t.Errorf("reading golden file %q: %v", goldenPath, err)
+	}
+	if diff := cmp.Diff(strings.Split(got, "\n"), strings.Split(string(want), "\n")); diff != "" {
+		gotPath := goldenPath + ".got"
+		os.WriteFile(gotPath, []byte(got), 0600)
+		t.Errorf("wrong dbusgen output (-got+want, got file written to %s):\n%s", gotPath, diff)
+	}
+
+	mustBuild(t, got)
+}
@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// NewStream returns a Transport that runs over conn, which must be
+// connected to a DBus server.
+//
+// Stream transports cannot send or receive file descriptors.
+func NewStream(ctx context.Context, conn net.Conn) (Transport, error) {
+	ret := &streamTransport{
+		conn: conn,
+		buf:  bufio.NewReader(conn),
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		ret.Close()
+		return nil, err
+	}
+	if err := auth(conn, ret.buf, false); err != nil {
+		ret.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		ret.Close()
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// streamTransport is a Transport that runs over an arbitrary
+// net.Conn, without support for file descriptor passing.
+type streamTransport struct {
+	conn net.Conn
+	buf  *bufio.Reader
+}
+
+func (s *streamTransport) Read(bs []byte) (int, error) {
+	n, err := s.buf.Read(bs)
+	if errors.Is(err, io.ErrClosedPipe) {
+		// Report local closure of a net.Pipe the same way as for
+		// other net.Conns.
+		err = net.ErrClosed
+	}
+	return n, err
+}
+
+func (s *streamTransport) Write(bs []byte) (int, error) {
+	return s.conn.Write(bs)
+}
+
+func (s *streamTransport) Close() error {
+	return s.conn.Close()
+}
+
+func (s *streamTransport) WriteWithFiles(bs []byte, fs []*os.File) (int, error) {
+	if len(fs) != 0 {
+		return 0, errors.New("transport does not support sending files")
+	}
+	return s.Write(bs)
+}
+
+func (s *streamTransport) PeerCredentials() (*Credentials, error) {
+	return nil, fmt.Errorf("stream transport cannot get peer credentials: %w", errors.ErrUnsupported)
+}
+
+func (s *streamTransport) GetFiles(n int) ([]*os.File, error) {
+	if n != 0 {
+		return nil, errors.New("transport does not support receiving files")
+	}
+	return nil, nil
+}
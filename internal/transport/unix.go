@@ -11,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/creachadair/mds/queue"
@@ -27,6 +28,23 @@ type Transport interface {
 	// WriteWithFiles is like Transport.Write, but additionally sends
 	// the given files as ancillary data.
 	WriteWithFiles(bs []byte, fds []*os.File) (int, error)
+	// PeerCredentials returns the credentials of the process at the
+	// other end of the transport, as reported by the operating
+	// system. It returns an error wrapping errors.ErrUnsupported if
+	// the transport cannot determine the peer's credentials.
+	PeerCredentials() (*Credentials, error)
+}
+
+// Credentials are the credentials of a transport's peer process, as
+// recorded by the operating system when the connection was
+// established.
+type Credentials struct {
+	PID uint32
+	UID uint32
+	GID uint32
+	// SecurityLabel is the peer's LSM security label, or nil if the
+	// system has no LSM that provides labels.
+	SecurityLabel []byte
 }
 
 // DialUnix connects to the bus at the given path.
@@ -40,7 +58,29 @@ func DialUnix(ctx context.Context, path string) (Transport, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewUnix(ctx, conn)
+}
+
+// NewUnix returns a Transport that runs over conn, which must be
+// connected to a DBus server.
+func NewUnix(ctx context.Context, conn *net.UnixConn) (Transport, error) {
+	return newUnix(ctx, conn, (*unixTransport).auth)
+}
+
+// AcceptUnix returns a Transport that runs over conn, which must be
+// connected to a DBus client. AcceptUnix performs the server side of
+// the authentication handshake, identifying itself to the client
+// with guid.
+//
+// AcceptUnix only accepts clients that authenticate with the
+// EXTERNAL mechanism as the same user as the current process.
+func AcceptUnix(ctx context.Context, conn *net.UnixConn, guid string) (Transport, error) {
+	return newUnix(ctx, conn, func(u *unixTransport) error {
+		return u.serverAuth(guid)
+	})
+}
 
+func newUnix(ctx context.Context, conn *net.UnixConn, auth func(*unixTransport) error) (Transport, error) {
 	ret := &unixTransport{
 		conn: conn,
 		fds:  queue.New[*os.File](),
@@ -56,7 +96,7 @@ func DialUnix(ctx context.Context, path string) (Transport, error) {
 		ret.Close()
 		return nil, err
 	}
-	if err := ret.auth(); err != nil {
+	if err := auth(ret); err != nil {
 		ret.Close()
 		return nil, err
 	}
@@ -73,7 +113,13 @@ type unixTransport struct {
 	conn *net.UnixConn
 	oob  [512]byte
 	buf  *bufio.Reader
-	fds  *queue.Queue[*os.File]
+
+	// Close can run concurrently with reads, so received files are
+	// guarded by a mutex. Everything else is only touched by the
+	// reader, or is safe for concurrent use.
+	fdsMu  sync.Mutex
+	closed bool // received files are closed immediately
+	fds    *queue.Queue[*os.File]
 }
 
 func (u *unixTransport) Read(bs []byte) (int, error) {
@@ -85,21 +131,40 @@ func (u *unixTransport) Write(bs []byte) (int, error) {
 }
 
 func (u *unixTransport) Close() error {
+	u.fdsMu.Lock()
+	defer u.fdsMu.Unlock()
+	u.closed = true
 	u.fds.Each(func(f *os.File) bool {
 		f.Close()
 		return true
 	})
 	u.fds.Clear()
-	u.buf.Discard(u.buf.Buffered())
 	return u.conn.Close()
 }
 
+func (u *unixTransport) PeerCredentials() (*Credentials, error) {
+	raw, err := u.conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		ret  *Credentials
+		cErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		ret, cErr = peerCredentials(int(fd))
+	}); err != nil {
+		return nil, err
+	}
+	return ret, cErr
+}
+
 func (u *unixTransport) WriteWithFiles(bs []byte, fs []*os.File) (int, error) {
 	if len(fs) == 0 {
 		return u.Write(bs)
 	}
 
-	fds := make([]int, len(fs))
+	fds := make([]int, 0, len(fs))
 	for _, f := range fs {
 		fds = append(fds, int(f.Fd()))
 	}
@@ -117,6 +182,8 @@ func (u *unixTransport) WriteWithFiles(bs []byte, fs []*os.File) (int, error) {
 }
 
 func (u *unixTransport) GetFiles(n int) ([]*os.File, error) {
+	u.fdsMu.Lock()
+	defer u.fdsMu.Unlock()
 	ret := make([]*os.File, 0, n)
 	for range n {
 		f, ok := u.fds.Pop()
@@ -132,6 +199,13 @@ func (u *unixTransport) GetFiles(n int) ([]*os.File, error) {
 }
 
 func (u *unixTransport) auth() error {
+	return auth(u.conn, u.buf, true)
+}
+
+// auth performs the client side of the DBus authentication handshake
+// over w and r. If negotiateFDs is true, auth also requests the
+// ability to pass file descriptors.
+func auth(w io.Writer, r *bufio.Reader, negotiateFDs bool) error {
 	// In theory, we're supposed to speak SASL now and carefully
 	// negotiate an authentication with the bus. However, in practice,
 	// when you talk to busses over a unix socket, the bus
@@ -144,17 +218,22 @@ func (u *unixTransport) auth() error {
 	// hang up anyway so no point in sequencing the messages cleanly.
 	uid := os.Getuid()
 	uidBs := hex.EncodeToString([]byte(strconv.Itoa(uid)))
-	if _, err := u.conn.Write([]byte("\x00AUTH EXTERNAL ")); err != nil {
+	if _, err := w.Write([]byte("\x00AUTH EXTERNAL ")); err != nil {
 		return err
 	}
-	if _, err := io.WriteString(u.conn, uidBs); err != nil {
+	if _, err := io.WriteString(w, uidBs); err != nil {
 		return err
 	}
-	if _, err := u.conn.Write([]byte("\r\nNEGOTIATE_UNIX_FD\r\nBEGIN\r\n")); err != nil {
+	if negotiateFDs {
+		if _, err := w.Write([]byte("\r\nNEGOTIATE_UNIX_FD")); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write([]byte("\r\nBEGIN\r\n")); err != nil {
 		return err
 	}
 
-	resp, err := u.buf.ReadString('\n')
+	resp, err := r.ReadString('\n')
 	if err != nil {
 		return err
 	}
@@ -162,7 +241,10 @@ func (u *unixTransport) auth() error {
 		return fmt.Errorf("AUTH EXTERNAL failed, server said %q", strings.TrimSpace(resp))
 	}
 
-	resp, err = u.buf.ReadString('\n')
+	if !negotiateFDs {
+		return nil
+	}
+	resp, err = r.ReadString('\n')
 	if err != nil {
 		return err
 	}
@@ -173,6 +255,119 @@ func (u *unixTransport) auth() error {
 	return nil
 }
 
+func (u *unixTransport) serverAuth(guid string) error {
+	creds, err := u.PeerCredentials()
+	if err != nil {
+		return err
+	}
+	return serverAuth(u.conn, u.buf, guid, creds.UID)
+}
+
+// maxAuthCommands is the maximum number of commands a client may
+// send during the authentication handshake.
+const maxAuthCommands = 16
+
+// maxAuthLine is the maximum length of a line that a client may send
+// during the authentication handshake.
+const maxAuthLine = 1024
+
+// serverAuth performs the server side of the DBus authentication
+// handshake over w and r, with a client whose operating system
+// credentials say it is running as peerUID.
+//
+// Like the client side, this implements just enough SASL to
+// authenticate local clients with the EXTERNAL mechanism, and
+// rejects all other mechanisms.
+func serverAuth(w io.Writer, r *bufio.Reader, guid string, peerUID uint32) error {
+	nul, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if nul != 0 {
+		return errors.New("client did not send leading NUL byte")
+	}
+
+	// allowed reports whether the client may authenticate as the
+	// hex-encoded uid it claimed, if any.
+	allowed := func(claimed string) bool {
+		if peerUID != uint32(os.Getuid()) {
+			return false
+		}
+		if claimed == "" {
+			return true
+		}
+		bs, err := hex.DecodeString(claimed)
+		if err != nil {
+			return false
+		}
+		return string(bs) == strconv.FormatUint(uint64(peerUID), 10)
+	}
+
+	var authed, waitingData bool
+	for range maxAuthCommands {
+		line, err := readAuthLine(r)
+		if err != nil {
+			return err
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSuffix(line, "\r\n"), " ")
+
+		var resp string
+		switch {
+		case cmd == "BEGIN" && authed:
+			return nil
+		case cmd == "NEGOTIATE_UNIX_FD" && authed:
+			resp = "AGREE_UNIX_FD"
+		case cmd == "AUTH" && !authed:
+			mech, initial, hasInitial := strings.Cut(arg, " ")
+			switch {
+			case mech != "EXTERNAL":
+				resp = "REJECTED EXTERNAL"
+			case !hasInitial:
+				waitingData = true
+				resp = "DATA"
+			case allowed(initial):
+				authed = true
+				resp = "OK " + guid
+			default:
+				resp = "REJECTED EXTERNAL"
+			}
+		case cmd == "DATA" && waitingData:
+			waitingData = false
+			if allowed(arg) {
+				authed = true
+				resp = "OK " + guid
+			} else {
+				resp = "REJECTED EXTERNAL"
+			}
+		case (cmd == "CANCEL" || cmd == "ERROR") && !authed:
+			waitingData = false
+			resp = "REJECTED EXTERNAL"
+		default:
+			resp = "ERROR"
+		}
+		if _, err := io.WriteString(w, resp+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return errors.New("too many commands in authentication handshake")
+}
+
+// readAuthLine reads one line of the authentication handshake from
+// r, and returns an error if the line is longer than maxAuthLine.
+func readAuthLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxAuthLine {
+			return "", errors.New("authentication command too long")
+		}
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
+
 func (u *unixTransport) readToBuf(bs []byte) (int, error) {
 	n, oobn, flags, _, err := u.conn.ReadMsgUnix(bs, u.oob[:])
 	if flags&unix.MSG_CTRUNC != 0 {
@@ -180,7 +375,7 @@ func (u *unixTransport) readToBuf(bs []byte) (int, error) {
 		return 0, errors.New("control message truncated")
 	}
 	if oobn > 0 {
-		if oobErr := u.parseFDs(u.oob[:oobn]); err != nil {
+		if oobErr := u.parseFDs(u.oob[:oobn]); oobErr != nil {
 			u.Close()
 			return 0, oobErr
 		}
@@ -219,7 +414,7 @@ func (u *unixTransport) parseFDs(oob []byte) error {
 			if f == nil {
 				errs = append(errs, fmt.Errorf("invalid file descriptor %d received on dbus socket", fd))
 			} else {
-				u.fds.Add(f)
+				u.addFile(f)
 			}
 		}
 	}
@@ -230,6 +425,18 @@ func (u *unixTransport) parseFDs(oob []byte) error {
 	return nil
 }
 
+// addFile queues f for a future GetFiles call, or closes it if the
+// transport is closed.
+func (u *unixTransport) addFile(f *os.File) {
+	u.fdsMu.Lock()
+	defer u.fdsMu.Unlock()
+	if u.closed {
+		f.Close()
+		return
+	}
+	u.fds.Add(f)
+}
+
 type funcReader func([]byte) (int, error)
 
 func (f funcReader) Read(bs []byte) (int, error) {
@@ -0,0 +1,29 @@
+package transport
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentials returns the credentials of the peer of the Unix
+// socket fd.
+func peerCredentials(fd int) (*Credentials, error) {
+	cred, err := unix.GetsockoptUcred(fd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Credentials{
+		PID: uint32(cred.Pid),
+		UID: cred.Uid,
+		GID: cred.Gid,
+	}
+	// SO_PEERSEC fails with ENOPROTOOPT if no LSM provides labels.
+	label, err := unix.GetsockoptString(fd, unix.SOL_SOCKET, unix.SO_PEERSEC)
+	if err == nil && label != "" {
+		ret.SecurityLabel = []byte(label)
+	} else if err != nil && !errors.Is(err, unix.ENOPROTOOPT) {
+		return nil, err
+	}
+	return ret, nil
+}
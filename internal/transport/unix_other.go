@@ -0,0 +1,15 @@
+//go:build !linux
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// peerCredentials returns the credentials of the peer of the Unix
+// socket fd.
+func peerCredentials(fd int) (*Credentials, error) {
+	return nil, fmt.Errorf("peer credentials on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
@@ -3,6 +3,7 @@ package dbus
 import (
 	"cmp"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
@@ -53,6 +54,63 @@ type InterfaceDescription struct {
 	Methods    []*MethodDescription   `xml:"method"`
 	Signals    []*SignalDescription   `xml:"signal"`
 	Properties []*PropertyDescription `xml:"property"`
+	// Doc is the interface's human-readable documentation, if the
+	// introspection data provides any.
+	Doc string `xml:"-"`
+}
+
+func (d *InterfaceDescription) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type plain InterfaceDescription
+	var raw struct {
+		plain
+		Meta   []xmlAnnotation `xml:"annotation"`
+		DocXML xmlDoc          `xml:"doc"`
+	}
+	if err := dec.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	*d = InterfaceDescription(raw.plain)
+	d.Doc = docString(raw.Meta, raw.DocXML)
+	return nil
+}
+
+// xmlAnnotation is an annotation element in introspection data.
+type xmlAnnotation struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// xmlDoc is a doc:doc documentation element in introspection data,
+// as used by many freedesktop.org interface definitions.
+type xmlDoc struct {
+	Summary string   `xml:"summary"`
+	Paras   []string `xml:"description>para"`
+}
+
+// docString returns the documentation text provided by either a
+// GDBus DocString annotation, or a doc:doc element. Paragraphs are
+// separated by blank lines, and whitespace within paragraphs is
+// normalized to single spaces.
+func docString(meta []xmlAnnotation, doc xmlDoc) string {
+	var paras []string
+	for _, attr := range meta {
+		if attr.Name == "org.gtk.GDBus.DocString" {
+			paras = append(paras, attr.Value)
+		}
+	}
+	if len(paras) == 0 {
+		paras = append(paras, doc.Summary)
+		paras = append(paras, doc.Paras...)
+	}
+	var ret []string
+	for _, para := range paras {
+		for _, p := range strings.Split(strings.ReplaceAll(para, "\r\n", "\n"), "\n\n") {
+			if p := strings.Join(strings.Fields(p), " "); p != "" {
+				ret = append(ret, p)
+			}
+		}
+	}
+	return strings.Join(ret, "\n\n")
 }
 
 func (d InterfaceDescription) String() string {
@@ -83,6 +141,42 @@ func (d InterfaceDescription) String() string {
 	return ret.String()
 }
 
+// Method returns the description of the named method, if the
+// interface has one.
+func (d *InterfaceDescription) Method(name string) (*MethodDescription, bool) {
+	idx := slices.IndexFunc(d.Methods, func(m *MethodDescription) bool {
+		return m.Name == name
+	})
+	if idx < 0 {
+		return nil, false
+	}
+	return d.Methods[idx], true
+}
+
+// Signal returns the description of the named signal, if the
+// interface has one.
+func (d *InterfaceDescription) Signal(name string) (*SignalDescription, bool) {
+	idx := slices.IndexFunc(d.Signals, func(s *SignalDescription) bool {
+		return s.Name == name
+	})
+	if idx < 0 {
+		return nil, false
+	}
+	return d.Signals[idx], true
+}
+
+// Property returns the description of the named property, if the
+// interface has one.
+func (d *InterfaceDescription) Property(name string) (*PropertyDescription, bool) {
+	idx := slices.IndexFunc(d.Properties, func(p *PropertyDescription) bool {
+		return p.Name == name
+	})
+	if idx < 0 {
+		return nil, false
+	}
+	return d.Properties[idx], true
+}
+
 // MethodDescription describes a DBus method.
 //
 // Method descriptions are provided by the DBus peer offering the
@@ -97,6 +191,9 @@ type MethodDescription struct {
 	// If true, NoReply indicates that the caller is expected to use
 	// Interface.OneWay to invoke this method, not Interface.Call.
 	NoReply bool
+	// Doc is the method's human-readable documentation, if the
+	// introspection data provides any.
+	Doc string
 }
 
 func (m MethodDescription) String() string {
@@ -137,23 +234,23 @@ func (m *MethodDescription) UnmarshalXML(d *xml.Decoder, start xml.StartElement)
 	var raw struct {
 		Name string `xml:"name,attr"`
 		Args []struct {
-			Name      string `xml:"name,attr"`
-			Type      string `xml:"type,attr"`
-			Direction string `xml:"direction,attr"`
+			Name      string          `xml:"name,attr"`
+			Type      string          `xml:"type,attr"`
+			Direction string          `xml:"direction,attr"`
+			Meta      []xmlAnnotation `xml:"annotation"`
 		} `xml:"arg"`
-		Meta []struct {
-			Name  string `xml:"name,attr"`
-			Value string `xml:"value,attr"`
-		} `xml:"annotation"`
+		Meta []xmlAnnotation `xml:"annotation"`
+		Doc  xmlDoc          `xml:"doc"`
 	}
 	if err := d.DecodeElement(&raw, &start); err != nil {
 		return err
 	}
 	m.Name = raw.Name
+	m.Doc = docString(raw.Meta, raw.Doc)
 	m.In, m.Out = nil, nil
 	m.Deprecated, m.NoReply = false, false
 	for _, arg := range raw.Args {
-		sig, err := ParseSignature(arg.Type)
+		sig, err := parseWireSignature(arg.Type)
 		if err != nil {
 			return fmt.Errorf("invalid signature %q for arg %s: %w", arg.Type, arg.Name, err)
 		}
@@ -161,6 +258,16 @@ func (m *MethodDescription) UnmarshalXML(d *xml.Decoder, start xml.StartElement)
 			Name: arg.Name,
 			Type: sig,
 		}
+		for _, attr := range arg.Meta {
+			if attr.Name != varDictKeyAnnotation || sig.String() != "a{sv}" {
+				continue
+			}
+			// Key annotations are only documentation, so a malformed
+			// one shouldn't make the entire description unusable.
+			if key, err := parseKeyDescription(attr.Value); err == nil {
+				ad.Keys = append(ad.Keys, key)
+			}
+		}
 		if arg.Direction == "in" {
 			m.In = append(m.In, ad)
 		} else {
@@ -189,6 +296,9 @@ type SignalDescription struct {
 	// Deprecated, if true, indicates that the signal should be
 	// avoided in new code.
 	Deprecated bool
+	// Doc is the signal's human-readable documentation, if the
+	// introspection data provides any.
+	Doc string
 }
 
 func (s SignalDescription) String() string {
@@ -216,19 +326,18 @@ func (s *SignalDescription) UnmarshalXML(d *xml.Decoder, start xml.StartElement)
 			Name string `xml:"name,attr"`
 			Type string `xml:"type,attr"`
 		} `xml:"arg"`
-		Meta []struct {
-			Name  string `xml:"name,attr"`
-			Value string `xml:"value,attr"`
-		} `xml:"annotation"`
+		Meta []xmlAnnotation `xml:"annotation"`
+		Doc  xmlDoc          `xml:"doc"`
 	}
 	if err := d.DecodeElement(&raw, &start); err != nil {
 		return err
 	}
 	s.Name = raw.Name
+	s.Doc = docString(raw.Meta, raw.Doc)
 	s.Args = nil
 	s.Deprecated = false
 	for _, attr := range raw.Attributes {
-		sig, err := ParseSignature(attr.Type)
+		sig, err := parseWireSignature(attr.Type)
 		if err != nil {
 			return fmt.Errorf("invalid signature %q for signal arg %s: %w", attr.Type, attr.Name, err)
 		}
@@ -276,6 +385,9 @@ type PropertyDescription struct {
 	// Deprecated, if true, indicates that the property should be
 	// avoided in new code.
 	Deprecated bool
+	// Doc is the property's human-readable documentation, if the
+	// introspection data provides any.
+	Doc string
 }
 
 func (p PropertyDescription) String() string {
@@ -307,19 +419,18 @@ func (p PropertyDescription) String() string {
 
 func (p *PropertyDescription) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	var raw struct {
-		Name   string `xml:"name,attr"`
-		Type   string `xml:"type,attr"`
-		Access string `xml:"access,attr"`
-		Meta   []struct {
-			Name  string `xml:"name,attr"`
-			Value string `xml:"value,attr"`
-		} `xml:"annotation"`
+		Name   string          `xml:"name,attr"`
+		Type   string          `xml:"type,attr"`
+		Access string          `xml:"access,attr"`
+		Meta   []xmlAnnotation `xml:"annotation"`
+		Doc    xmlDoc          `xml:"doc"`
 	}
 	if err := d.DecodeElement(&raw, &start); err != nil {
 		return err
 	}
 	p.Name = raw.Name
-	sig, err := ParseSignature(raw.Type)
+	p.Doc = docString(raw.Meta, raw.Doc)
+	sig, err := parseWireSignature(raw.Type)
 	if err != nil {
 		return fmt.Errorf("invalid signature %q for property %s: %w", raw.Type, raw.Name, err)
 	}
@@ -365,6 +476,47 @@ func (p *PropertyDescription) UnmarshalXML(d *xml.Decoder, start xml.StartElemen
 type ArgumentDescription struct {
 	Name string // optional
 	Type Signature
+	// Keys are the documented keys of an a{sv} method argument, in
+	// the order that the introspection data lists them.
+	//
+	// DBus has no standard way to describe dictionary keys. This
+	// package defines its own convention: keys are read from
+	// annotations on the argument named
+	// "com.github.danderson.dbus.VarDictKey", whose value is the key
+	// name and the signature of its value, separated by a space.
+	// Annotations with malformed values, or on arguments of other
+	// types, are ignored. For example:
+	//
+	//	<arg name="hints" type="a{sv}" direction="in">
+	//	  <annotation name="com.github.danderson.dbus.VarDictKey" value="urgency y"/>
+	//	</arg>
+	Keys []KeyDescription
+}
+
+// varDictKeyAnnotation is the name of the argument annotation that
+// documents a key of an a{sv} argument.
+const varDictKeyAnnotation = "com.github.danderson.dbus.VarDictKey"
+
+// KeyDescription describes a documented key of an a{sv} argument.
+type KeyDescription struct {
+	Name string
+	Type Signature
+}
+
+// parseKeyDescription parses the value of a varDictKeyAnnotation.
+func parseKeyDescription(s string) (KeyDescription, error) {
+	name, typ, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok || name == "" {
+		return KeyDescription{}, errors.New("want key name and signature separated by a space")
+	}
+	sig, err := parseWireSignature(strings.TrimSpace(typ))
+	if err != nil {
+		return KeyDescription{}, err
+	}
+	if !sig.isSingleType() {
+		return KeyDescription{}, fmt.Errorf("key type %q is not a single complete type", sig)
+	}
+	return KeyDescription{name, sig}, nil
 }
 
 func (a ArgumentDescription) String() string {
@@ -0,0 +1,443 @@
+package dbus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+// MarshalJSON returns the JSON encoding of v, a value that can be
+// marshaled to DBus.
+//
+// The JSON encoding follows v's DBus representation rather than its
+// Go type, so that any two values with the same DBus wire encoding
+// produce the same JSON:
+//
+//   - Booleans and numbers are JSON booleans and numbers.
+//   - Strings, object paths and signatures are JSON strings.
+//   - Byte arrays are base64-encoded JSON strings.
+//   - Other arrays, and structs, are JSON arrays of their elements
+//     or fields.
+//   - Dicts are JSON objects. Non-string keys are formatted as
+//     strings.
+//   - Variants are JSON objects of the form {"sig": "...", "value": ...}
+//     where sig is the signature of the variant's inner value.
+//   - File descriptors are the file descriptor number.
+//
+// If v is a struct with [InlineLayout], or otherwise has a
+// multi-value signature, MarshalJSON returns a JSON array of the
+// values.
+func MarshalJSON(v any) ([]byte, error) {
+	if v == nil {
+		return nil, errors.New("cannot marshal nil value to JSON")
+	}
+	sig, err := SignatureOf(v)
+	if err != nil {
+		return nil, err
+	}
+	if sig.IsZero() {
+		return []byte("[]"), nil
+	}
+	str := sig.String()
+	if !sig.isSingleType() {
+		// A multi-value signature has the same wire layout as a
+		// struct of those values.
+		str = "(" + str + ")"
+	}
+	canonical, err := parseWireSignature(str)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-tripping through the wire format produces a value of a
+	// known shape that can be walked alongside its signature.
+	out := reflect.New(canonical.Type())
+	if err := roundTrip(v, out.Interface()); err != nil {
+		return nil, err
+	}
+
+	j, err := jsonValue(str, out.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// jsonValue converts v, a value of the canonical Go type for sig, into
+// a value that encoding/json marshals in the form described by
+// [MarshalJSON].
+func jsonValue(sig string, v reflect.Value) (any, error) {
+	for v.Kind() == reflect.Pointer && sig[0] != 'h' {
+		v = v.Elem()
+	}
+
+	switch sig[0] {
+	case 'v':
+		if v.IsNil() {
+			return nil, nil
+		}
+		inner := v.Elem()
+		isig, err := signatureFor(inner.Type(), nil)
+		if err != nil {
+			return nil, err
+		}
+		iv, err := jsonValue(isig.String(), inner)
+		if err != nil {
+			return nil, err
+		}
+		return jsonVariant{isig.String(), iv}, nil
+	case 'a':
+		if sig[1] == 'y' {
+			if v.Len() == 0 {
+				return "", nil
+			}
+			return v.Bytes(), nil
+		}
+		if sig[1] == '{' {
+			ks, vs := splitDictSig(sig)
+			ret := make(map[string]any, v.Len())
+			for iter := v.MapRange(); iter.Next(); {
+				k, err := jsonValue(ks, iter.Key())
+				if err != nil {
+					return nil, err
+				}
+				val, err := jsonValue(vs, iter.Value())
+				if err != nil {
+					return nil, err
+				}
+				ret[fmt.Sprint(k)] = val
+			}
+			return ret, nil
+		}
+		ret := make([]any, v.Len())
+		for i := range v.Len() {
+			elem, err := jsonValue(sig[1:], v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = elem
+		}
+		return ret, nil
+	case '(':
+		ret := make([]any, 0, v.NumField())
+		rest := sig[1 : len(sig)-1]
+		for i := range v.NumField() {
+			_, next, err := parseOne(rest, false)
+			if err != nil {
+				return nil, err
+			}
+			f, err := jsonValue(rest[:len(rest)-len(next)], v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, f)
+			rest = next
+		}
+		return ret, nil
+	case 'g':
+		return v.Interface().(Signature).String(), nil
+	case 'o':
+		return v.String(), nil
+	case 'h':
+		if v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface().(*os.File).Fd(), nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// jsonVariant is the JSON encoding of a DBus variant.
+type jsonVariant struct {
+	Sig   string `json:"sig"`
+	Value any    `json:"value"`
+}
+
+// splitDictSig returns the key and value signatures of the dict
+// signature sig.
+func splitDictSig(sig string) (key, val string) {
+	// Dict keys are always basic types, so the key is a single
+	// character.
+	return sig[2:3], sig[3 : len(sig)-1]
+}
+
+// UnmarshalJSON parses data, in the JSON encoding described by
+// [MarshalJSON], as a value of type sig. It returns a value that
+// marshals to DBus with that signature.
+//
+// JSON numbers are converted to the integer or floating point type
+// required by sig, and an error is returned if they do not fit. Byte
+// arrays may be given as either a base64-encoded string or an array
+// of numbers. Dict keys are parsed from their string form according
+// to the dict's key type.
+//
+// If sig is a multi-value signature, data must be a JSON array of
+// the values, and UnmarshalJSON returns a struct with [InlineLayout]
+// containing the values.
+func UnmarshalJSON(data []byte, sig Signature) (any, error) {
+	if sig.IsZero() {
+		return nil, errors.New("cannot unmarshal JSON into zero Signature")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var j any
+	if err := dec.Decode(&j); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	if sig.isSingleType() {
+		v, err := fromJSON(sig.String(), j)
+		if err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+
+	vals, ok := j.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot unmarshal JSON %T into multi-value signature %q, want array", j, sig)
+	}
+	fs := []reflect.StructField{
+		{
+			Name:    "_",
+			PkgPath: "github.com/danderson/dbus",
+			Type:    reflect.TypeFor[InlineLayout](),
+		},
+	}
+	var vs []reflect.Value
+	for rest := sig.String(); rest != ""; {
+		_, next, err := parseOne(rest, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(vs) == len(vals) {
+			return nil, fmt.Errorf("not enough values in JSON array for signature %q", sig)
+		}
+		v, err := fromJSON(rest[:len(rest)-len(next)], vals[len(vs)])
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", len(vs), err)
+		}
+		fs = append(fs, reflect.StructField{
+			// Not FieldN, to avoid reflect.StructOf returning the
+			// otherwise identical type made by ParseSignature,
+			// whose fields are unexported.
+			Name: fmt.Sprintf("Value%d", len(vs)),
+			Type: v.Type(),
+		})
+		vs = append(vs, v)
+		rest = next
+	}
+	if len(vs) != len(vals) {
+		return nil, fmt.Errorf("too many values in JSON array for signature %q", sig)
+	}
+	ret := reflect.New(reflect.StructOf(fs)).Elem()
+	for i, v := range vs {
+		ret.Field(i + 1).Set(v)
+	}
+	return ret.Interface(), nil
+}
+
+// fromJSON converts j, a value produced by encoding/json with
+// UseNumber, into a value of the canonical Go type for the single
+// complete type sig.
+func fromJSON(sig string, j any) (reflect.Value, error) {
+	s, err := parseWireSignature(sig)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	t := s.Type()
+	ret := reflect.New(t).Elem()
+
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot unmarshal JSON %T into DBus type %q", j, sig)
+	}
+
+	switch sig[0] {
+	case 'b':
+		b, ok := j.(bool)
+		if !ok {
+			return mismatch()
+		}
+		ret.SetBool(b)
+	case 'y', 'q', 'u', 't':
+		n, ok := j.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		u, err := strconv.ParseUint(n.String(), 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value for DBus type %q: %w", sig, err)
+		}
+		ret.SetUint(u)
+	case 'n', 'i', 'x':
+		n, ok := j.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		i, err := strconv.ParseInt(n.String(), 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value for DBus type %q: %w", sig, err)
+		}
+		ret.SetInt(i)
+	case 'd':
+		n, ok := j.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value for DBus type %q: %w", sig, err)
+		}
+		ret.SetFloat(f)
+	case 's':
+		str, ok := j.(string)
+		if !ok {
+			return mismatch()
+		}
+		ret.SetString(str)
+	case 'o':
+		str, ok := j.(string)
+		if !ok {
+			return mismatch()
+		}
+		if err := ObjectPath(str).Valid(); err != nil {
+			return reflect.Value{}, err
+		}
+		ret.SetString(str)
+	case 'g':
+		str, ok := j.(string)
+		if !ok {
+			return mismatch()
+		}
+		s, err := parseWireSignature(str)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ret.Set(reflect.ValueOf(s))
+	case 'h':
+		return reflect.Value{}, errors.New("cannot unmarshal file descriptors from JSON")
+	case 'v':
+		obj, ok := j.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		str, ok := obj["sig"].(string)
+		if !ok {
+			return reflect.Value{}, errors.New("variant is missing string field \"sig\"")
+		}
+		val, ok := obj["value"]
+		if !ok {
+			return reflect.Value{}, errors.New("variant is missing field \"value\"")
+		}
+		if len(obj) != 2 {
+			return reflect.Value{}, errors.New("variant has unexpected fields")
+		}
+		inner, err := parseWireSignature(str)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if !inner.isSingleType() {
+			return reflect.Value{}, fmt.Errorf("variant signature %q must be a single type", str)
+		}
+		v, err := fromJSON(str, val)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v.Kind() == reflect.Interface {
+			// Keep nested variants behind a pointer, as the
+			// decoder does. Storing the inner any directly in ret
+			// would flatten the two variants into one.
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		}
+		ret.Set(v)
+	case 'a':
+		if sig[1] == 'y' {
+			if str, ok := j.(string); ok {
+				bs, err := base64.StdEncoding.DecodeString(str)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				ret.SetBytes(bs)
+				return ret, nil
+			}
+		}
+		if sig[1] == '{' {
+			obj, ok := j.(map[string]any)
+			if !ok {
+				return mismatch()
+			}
+			ks, vs := splitDictSig(sig)
+			ret.Set(reflect.MakeMapWithSize(t, len(obj)))
+			for k, v := range obj {
+				var jk any = k
+				switch ks {
+				case "b":
+					b, err := strconv.ParseBool(k)
+					if err != nil {
+						return reflect.Value{}, fmt.Errorf("invalid dict key %q: %w", k, err)
+					}
+					jk = b
+				case "y", "n", "q", "i", "u", "x", "t", "d":
+					jk = json.Number(k)
+				}
+				kv, err := fromJSON(ks, jk)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("dict key %q: %w", k, err)
+				}
+				vv, err := fromJSON(vs, v)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("dict value for key %q: %w", k, err)
+				}
+				ret.SetMapIndex(kv, vv)
+			}
+			return ret, nil
+		}
+		arr, ok := j.([]any)
+		if !ok {
+			return mismatch()
+		}
+		ret.Set(reflect.MakeSlice(t, len(arr), len(arr)))
+		for i, elem := range arr {
+			v, err := fromJSON(sig[1:], elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("array element %d: %w", i, err)
+			}
+			ret.Index(i).Set(v)
+		}
+	case '(':
+		arr, ok := j.([]any)
+		if !ok {
+			return mismatch()
+		}
+		if len(arr) != t.NumField() {
+			return reflect.Value{}, fmt.Errorf("struct %q has %d fields, JSON array has %d elements", sig, t.NumField(), len(arr))
+		}
+		rest := sig[1 : len(sig)-1]
+		for i := range t.NumField() {
+			_, next, err := parseOne(rest, false)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v, err := fromJSON(rest[:len(rest)-len(next)], arr[i])
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("struct field %d: %w", i, err)
+			}
+			ret.Field(i).Set(v)
+			rest = next
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported DBus type %q", sig)
+	}
+	return ret, nil
+}
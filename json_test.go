@@ -0,0 +1,220 @@
+package dbus
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	type tuple struct {
+		A string
+		B uint32
+	}
+	type inline struct {
+		_ InlineLayout
+		A string
+		B bool
+	}
+	type vardict struct {
+		_     InlineLayout
+		Name  string         `dbus:"key=name"`
+		Other map[string]any `dbus:"vardict"`
+	}
+	var nested any = uint32(1)
+
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"bool", true, `true`},
+		{"byte", byte(42), `42`},
+		{"int16", int16(-2), `-2`},
+		{"uint64", uint64(1 << 60), `1152921504606846976`},
+		{"float", 1.5, `1.5`},
+		{"string", "foo", `"foo"`},
+		{"object path", ObjectPath("/foo/bar"), `"/foo/bar"`},
+		{"signature", mustParseSignature("a{sv}"), `"a{sv}"`},
+		{"bytes", []byte("foo"), `"Zm9v"`},
+		{"empty bytes", []byte{}, `""`},
+		{"string array", []string{"a", "b"}, `["a","b"]`},
+		{"empty array", []uint32(nil), `[]`},
+		{"struct", tuple{"a", 1}, `["a",1]`},
+		{"struct pointer", &tuple{"a", 1}, `["a",1]`},
+		{"struct array", []tuple{{"a", 1}, {"b", 2}}, `[["a",1],["b",2]]`},
+		{"inline struct", inline{A: "a", B: true}, `["a",true]`},
+		{"dict", map[string]uint16{"a": 1}, `{"a":1}`},
+		{"int dict", map[int32]string{-1: "a", 2: "b"}, `{"-1":"a","2":"b"}`},
+		{"variant", map[string]any{"a": "b"}, `{"a":{"sig":"s","value":"b"}}`},
+		{"variant struct", []any{tuple{"a", 1}}, `[{"sig":"(su)","value":["a",1]}]`},
+		{"nested variant", []any{any(uint32(1))}, `[{"sig":"u","value":1}]`},
+		{"variant in variant", []any{&nested}, `[{"sig":"v","value":{"sig":"u","value":1}}]`},
+		{"vardict", vardict{Name: "foo", Other: map[string]any{"x": int32(1)}}, `{"name":{"sig":"s","value":"foo"},"x":{"sig":"i","value":1}}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := MarshalJSON(tc.in)
+			if err != nil {
+				t.Fatalf("MarshalJSON(%#v) failed: %v", tc.in, err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("MarshalJSON(%#v) = %s, want %s", tc.in, got, tc.want)
+			}
+
+			sig, err := SignatureOf(tc.in)
+			if err != nil {
+				t.Fatalf("SignatureOf(%#v) failed: %v", tc.in, err)
+			}
+			back, err := UnmarshalJSON(got, sig)
+			if err != nil {
+				t.Fatalf("UnmarshalJSON(%s, %q) failed: %v", got, sig, err)
+			}
+			backSig, err := SignatureOf(back)
+			if err != nil {
+				t.Fatalf("SignatureOf(%#v) failed: %v", back, err)
+			}
+			if !backSig.Equal(sig) {
+				t.Errorf("UnmarshalJSON(%s, %q) returned value of type %q", got, sig, backSig)
+			}
+			again, err := MarshalJSON(back)
+			if err != nil {
+				t.Fatalf("MarshalJSON(%#v) failed: %v", back, err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("JSON round trip changed value\n got: %s\nwant: %s", again, got)
+			}
+		})
+	}
+}
+
+func TestMarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+	}{
+		{"nil", nil},
+		{"channel", make(chan int)},
+		{"nil variant", []any{nil}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := MarshalJSON(tc.in); err == nil {
+				t.Errorf("MarshalJSON(%#v) = %s, want error", tc.in, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		sig  string
+		in   string
+		want any
+	}{
+		{"y", `255`, uint8(255)},
+		{"n", `-5`, int16(-5)},
+		{"t", `18446744073709551615`, uint64(18446744073709551615)},
+		{"d", `2`, float64(2)},
+		{"o", `"/foo"`, ObjectPath("/foo")},
+		{"ay", `[1,2,3]`, []byte{1, 2, 3}},
+		{"ay", `"AQID"`, []byte{1, 2, 3}},
+		{"a{ub}", `{"1":true}`, map[uint32]bool{1: true}},
+		{"a{bs}", `{"false":"no"}`, map[bool]string{false: "no"}},
+		{"v", `{"sig":"q","value":7}`, uint16(7)},
+	}
+
+	for _, tc := range tests {
+		got, err := UnmarshalJSON([]byte(tc.in), mustParseSignature(tc.sig))
+		if err != nil {
+			t.Errorf("UnmarshalJSON(%s, %q) failed: %v", tc.in, tc.sig, err)
+			continue
+		}
+		if tc.sig == "v" {
+			// UnmarshalJSON returns the variant's inner value.
+			if got != tc.want {
+				t.Errorf("UnmarshalJSON(%s, %q) = %#v, want %#v", tc.in, tc.sig, got, tc.want)
+			}
+			continue
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("UnmarshalJSON(%s, %q) wrong result (-got+want):\n%s", tc.in, tc.sig, diff)
+		}
+	}
+}
+
+func TestUnmarshalJSONMulti(t *testing.T) {
+	got, err := UnmarshalJSON([]byte(`["foo",{"sig":"b","value":true}]`), mustParseSignature("sv"))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	sig, err := SignatureOf(got)
+	if err != nil {
+		t.Fatalf("SignatureOf(%#v) failed: %v", got, err)
+	}
+	if sig.String() != "sv" {
+		t.Errorf("UnmarshalJSON returned value with signature %q, want %q", sig, "sv")
+	}
+}
+
+func TestUnmarshalJSONNestedVariant(t *testing.T) {
+	const in = `[{"sig":"v","value":{"sig":"u","value":1}},{"sig":"s","value":"x"}]`
+	got, err := UnmarshalJSON([]byte(in), mustParseSignature("vv"))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	sig, err := SignatureOf(got)
+	if err != nil {
+		t.Fatalf("SignatureOf(%#v) failed: %v", got, err)
+	}
+	if sig.String() != "vv" {
+		t.Errorf("UnmarshalJSON returned value with signature %q, want %q", sig, "vv")
+	}
+	out, err := MarshalJSON(got)
+	if err != nil {
+		t.Fatalf("MarshalJSON(%#v) failed: %v", got, err)
+	}
+	if string(out) != in {
+		t.Errorf("JSON round trip changed value\n got: %s\nwant: %s", out, in)
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		sig string
+		in  string
+	}{
+		{"y", `256`},
+		{"u", `-1`},
+		{"i", `1.5`},
+		{"i", `"1"`},
+		{"s", `1`},
+		{"b", `"true"`},
+		{"o", `"foo"`},
+		{"g", `"!"`},
+		{"h", `0`},
+		{"ay", `"not base64!"`},
+		{"as", `{"a":1}`},
+		{"a{us}", `{"x":"a"}`},
+		{"(su)", `["a"]`},
+		{"(su)", `["a",1,2]`},
+		{"v", `"foo"`},
+		{"v", `{"value":1}`},
+		{"v", `{"sig":"su","value":["a",1]}`},
+		{"v", `{"sig":"u","value":1,"extra":2}`},
+		{"su", `"foo"`},
+		{"su", `["foo"]`},
+		{"su", `["foo",1,2]`},
+		{"s", `"a" "b"`},
+		{"s", `{`},
+	}
+	for _, tc := range tests {
+		if got, err := UnmarshalJSON([]byte(tc.in), mustParseSignature(tc.sig)); err == nil {
+			t.Errorf("UnmarshalJSON(%s, %q) = %#v, want error", tc.in, tc.sig, got)
+		}
+	}
+	if got, err := UnmarshalJSON([]byte(`1`), Signature{}); err == nil {
+		t.Errorf("UnmarshalJSON with zero signature = %#v, want error", got)
+	}
+}
@@ -0,0 +1,164 @@
+package dbus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/danderson/dbus/internal/transport"
+)
+
+// authTimeout is how long a Listener waits for a new client to
+// complete the authentication handshake.
+const authTimeout = 10 * time.Second
+
+// maxPendingAuths is the maximum number of clients that a Listener
+// is either authenticating, or holding until they are returned by
+// Accept.
+const maxPendingAuths = 64
+
+// A Listener accepts peer-to-peer DBus connections on a Unix domain
+// socket.
+//
+// Listeners are the server side of [DialOptions.PeerToPeer]
+// connections: clients connect with [DialWithOptions] or
+// [ConnectWithOptions], and each accepted client is a peer-to-peer
+// [Conn], on which the server can [Conn.Handle] method calls, make
+// calls of its own to Peer(""), and emit signals.
+type Listener struct {
+	ln   *net.UnixListener
+	guid string
+	opts DialOptions
+
+	conns chan *Conn    // authenticated clients, waiting for Accept
+	auths chan struct{} // semaphore bounding clients not yet accepted
+	done  chan struct{} // closed when acceptLoop exits
+	err   error         // why acceptLoop exited, set before done is closed
+}
+
+// Listen listens for peer-to-peer DBus connections on the Unix
+// domain socket at the given path.
+func Listen(ctx context.Context, path string) (*Listener, error) {
+	return ListenWithOptions(ctx, path, DialOptions{})
+}
+
+// ListenWithOptions is like [Listen], but configures accepted Conns
+// according to opts. opts.PeerToPeer is ignored, accepted Conns are
+// always peer-to-peer.
+func ListenWithOptions(ctx context.Context, path string, opts DialOptions) (*Listener, error) {
+	if err := opts.valid(); err != nil {
+		return nil, err
+	}
+	opts.PeerToPeer = true
+
+	var guid [16]byte
+	if _, err := rand.Read(guid[:]); err != nil {
+		return nil, err
+	}
+
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Listener{
+		ln:    ln.(*net.UnixListener),
+		guid:  hex.EncodeToString(guid[:]),
+		opts:  opts,
+		conns: make(chan *Conn),
+		auths: make(chan struct{}, maxPendingAuths),
+		done:  make(chan struct{}),
+	}
+	go ret.acceptLoop()
+	return ret, nil
+}
+
+// Accept waits for a client to connect and authenticate, and
+// returns a Conn to that client.
+//
+// Only clients running as the same user as the current process can
+// authenticate. Clients that fail to authenticate are disconnected
+// and logged, and Accept continues waiting for the next client.
+// Clients authenticate concurrently, so a slow client does not delay
+// the clients that connect after it. The Listener stops accepting
+// new clients while a fixed number of clients are authenticating or
+// waiting for Accept.
+func (l *Listener) Accept() (*Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// acceptLoop accepts incoming connections and authenticates each one
+// in its own goroutine, until the listener is closed.
+func (l *Listener) acceptLoop() {
+	defer close(l.done)
+	for {
+		l.auths <- struct{}{}
+		conn, err := l.ln.AcceptUnix()
+		if err != nil {
+			<-l.auths
+			l.err = err
+			return
+		}
+		go l.authenticate(conn)
+	}
+}
+
+// authenticate completes the handshake with conn, and hands the
+// resulting Conn to Accept.
+func (l *Listener) authenticate(conn *net.UnixConn) {
+	defer func() { <-l.auths }()
+	ret, err := l.serve(conn)
+	if err != nil {
+		l.log().Warn("DBus client failed to connect", "err", err)
+		return
+	}
+	select {
+	case l.conns <- ret:
+	case <-l.done:
+		ret.Close()
+	}
+}
+
+func (l *Listener) serve(conn *net.UnixConn) (*Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	defer cancel()
+	t, err := transport.AcceptUnix(ctx, conn, l.guid)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(ctx, t, l.opts)
+}
+
+// Addr returns the listener's network address.
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close stops listening and removes the listening socket. Conns
+// previously returned by Accept are not affected. Clients that
+// connected but were not yet returned by Accept are disconnected.
+//
+// Any blocked Accept call is unblocked and returns an error.
+func (l *Listener) Close() error {
+	err := l.ln.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (l *Listener) log() *slog.Logger {
+	if l.opts.Logger != nil {
+		return l.opts.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,192 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "sock")
+	var logs lockedBuffer
+	l, err := ListenWithOptions(ctx, path, DialOptions{
+		Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	if err != nil {
+		t.Fatalf("ListenWithOptions() failed: %v", err)
+	}
+	defer l.Close()
+
+	type accepted struct {
+		c   *Conn
+		err error
+	}
+	acceptc := make(chan accepted, 1)
+	go func() {
+		c, err := l.Accept()
+		acceptc <- accepted{c, err}
+	}()
+
+	client, err := DialWithOptions(ctx, path, DialOptions{PeerToPeer: true})
+	if err != nil {
+		t.Fatalf("DialWithOptions() failed: %v", err)
+	}
+	defer client.Close()
+
+	a := <-acceptc
+	if a.err != nil {
+		t.Fatalf("Accept() failed: %v", a.err)
+	}
+	server := a.c
+	defer server.Close()
+
+	server.Handle("org.test", "Echo", func(_ context.Context, _ ObjectPath, s string) (string, error) {
+		return s, nil
+	})
+	var got string
+	if err := client.Peer("").Object("/").Interface("org.test").Call(ctx, "Echo", "hello", &got); err != nil {
+		t.Fatalf("Call() to server failed: %v", err)
+	}
+	if got != "hello" {
+		t.Errorf("Call() to server got %q, want %q", got, "hello")
+	}
+	if err := server.Peer("").Ping(ctx); err != nil {
+		t.Errorf("Ping() of client failed: %v", err)
+	}
+
+	// A client that doesn't speak DBus is skipped over, and neither
+	// it nor a client that stalls during authentication prevents
+	// later clients from connecting.
+	stalled, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dialing listener: %v", err)
+	}
+	defer stalled.Close()
+	go func() {
+		c, err := l.Accept()
+		acceptc <- accepted{c, err}
+	}()
+	bad, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dialing listener: %v", err)
+	}
+	if _, err := bad.Write([]byte("\x00AUTH ANONYMOUS\r\nBEGIN\r\n")); err != nil {
+		t.Fatalf("writing bad auth: %v", err)
+	}
+	bad.Close()
+	client2, err := DialWithOptions(ctx, path, DialOptions{PeerToPeer: true})
+	if err != nil {
+		t.Fatalf("second DialWithOptions() failed: %v", err)
+	}
+	defer client2.Close()
+	a = <-acceptc
+	if a.err != nil {
+		t.Fatalf("second Accept() failed: %v", a.err)
+	}
+	a.c.Close()
+	for !strings.Contains(logs.String(), "DBus client failed to connect") {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("failed client was not logged, got logs:\n%s", logs.String())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept() after Close() got err %v, want net.ErrClosed", err)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(bs []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(bs)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestListenerLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "sock")
+	l, err := ListenWithOptions(ctx, path, DialOptions{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("ListenWithOptions() failed: %v", err)
+	}
+	defer l.Close()
+
+	// A client that sends an overly long auth command is
+	// disconnected, rather than buffered until it times out.
+	long, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dialing listener: %v", err)
+	}
+	defer long.Close()
+	if _, err := long.Write(append([]byte("\x00AUTH "), bytes.Repeat([]byte("A"), 4096)...)); err != nil {
+		t.Fatalf("writing long auth: %v", err)
+	}
+	long.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := long.Read(make([]byte, 1)); errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("client sending long auth command was not disconnected")
+	}
+
+	// Clients that authenticated but were not accepted count
+	// against the limit on pending clients.
+	for range maxPendingAuths {
+		c, err := DialWithOptions(ctx, path, DialOptions{PeerToPeer: true})
+		if err != nil {
+			t.Fatalf("DialWithOptions() failed: %v", err)
+		}
+		defer c.Close()
+	}
+	dialc := make(chan error, 1)
+	go func() {
+		c, err := DialWithOptions(ctx, path, DialOptions{PeerToPeer: true})
+		if err == nil {
+			defer c.Close()
+		}
+		dialc <- err
+	}()
+	select {
+	case err := <-dialc:
+		t.Fatalf("DialWithOptions() with full Listener returned early, err %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept() failed: %v", err)
+	}
+	defer c.Close()
+	if err := <-dialc; err != nil {
+		t.Fatalf("DialWithOptions() after Accept() failed: %v", err)
+	}
+}
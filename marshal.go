@@ -8,7 +8,9 @@ import (
 	"os"
 	"reflect"
 	"slices"
+	"unsafe"
 
+	lru "github.com/creachadair/mds/cache"
 	"github.com/danderson/dbus/fragments"
 )
 
@@ -30,13 +32,102 @@ var marshalerType = reflect.TypeFor[Marshaler]()
 
 var encoders cache[reflect.Type, fragments.EncoderFunc]
 
+// wireEncoders caches the encoders of wire types, which are kept out
+// of encoders. See wireTypes.
+var wireEncoders = lru.New(lru.LRU[reflect.Type, fragments.EncoderFunc](maxWireSignatures))
+
 func encoderFor(t reflect.Type) (ret fragments.EncoderFunc, err error) {
-	e := encoderGen{}
-	return e.get(t)
+	if ret, err := encoders.Get(t); !errors.Is(err, errNotFound) {
+		return ret, err
+	}
+	if ret, ok := wireEncoders.Get(t); ok {
+		return ret, nil
+	}
+	e := encoderGen{wire: isWireType(t)}
+	ret, err = e.get(t)
+	if e.wire && err == nil {
+		wireEncoders.Put(t, ret)
+	}
+	return ret, err
+}
+
+// A bodyEncoder encodes a message body of a particular type.
+type bodyEncoder struct {
+	enc fragments.EncoderFunc
+	// sig is the signature of the encoded body, in the form expected
+	// by the message header.
+	sig Signature
+}
+
+var bodyEncoders cache[reflect.Type, bodyEncoder]
+
+// bodyEncoderFor returns the bodyEncoder for the given type. It
+// combines the encoder and signature lookups needed to send a
+// message into a single cache lookup.
+func bodyEncoderFor(t reflect.Type) (ret bodyEncoder, err error) {
+	if ret, err := bodyEncoders.Get(t); err == nil {
+		return ret, nil
+	} else if !errors.Is(err, errNotFound) {
+		return bodyEncoder{}, err
+	}
+
+	defer func() {
+		if isWireType(t) {
+			// Wire types are only cached in bounded storage, by
+			// encoderFor and signatureFor.
+			return
+		}
+		if err != nil {
+			bodyEncoders.SetErr(t, err)
+		} else {
+			bodyEncoders.Set(t, ret)
+		}
+	}()
+
+	enc, err := encoderFor(t)
+	if err != nil {
+		return bodyEncoder{}, fmt.Errorf("getting encoder for %s: %w", t, err)
+	}
+	sig, err := bodySignatureFor(t)
+	if err != nil {
+		return bodyEncoder{}, err
+	}
+	return bodyEncoder{enc, sig}, nil
+}
+
+// bodySignatureFor returns the signature of a message body of type
+// t, in the form expected by the message header.
+//
+// DBus has no empty struct type, but an empty struct is a natural
+// way to write a message body with no values, so empty structs have
+// the zero Signature when used as message bodies.
+func bodySignatureFor(t reflect.Type) (Signature, error) {
+	if isEmptyStruct(t) {
+		return Signature{}, nil
+	}
+	sig, err := signatureFor(t, nil)
+	if err != nil {
+		return Signature{}, err
+	}
+	return sig.asMsgBody(), nil
+}
+
+// isEmptyStruct reports whether t, after dereferencing pointers, is
+// a struct with no DBus fields.
+func isEmptyStruct(t reflect.Type) bool {
+	t = derefType(t)
+	if t.Kind() != reflect.Struct || t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return false
+	}
+	fs, err := getStructInfo(t)
+	return err == nil && len(fs.StructFields) == 0
 }
 
 type encoderGen struct {
 	stack []reflect.Type
+	// wire is whether the encoder is for a wire type, in which case
+	// the generated encoders are not added to encoders.
+	wire bool
 }
 
 func (e *encoderGen) get(t reflect.Type) (ret fragments.EncoderFunc, err error) {
@@ -57,6 +148,9 @@ func (e *encoderGen) get(t reflect.Type) (ret fragments.EncoderFunc, err error)
 	// below.
 	defer func(t reflect.Type) {
 		e.stack = e.stack[:len(e.stack)-1]
+		if e.wire {
+			return
+		}
 		if err != nil {
 			encoders.SetErr(t, err)
 		} else {
@@ -158,8 +252,11 @@ func (e *encoderGen) newFileEncoder() fragments.EncoderFunc {
 
 func (e *encoderGen) newObjectPathEncoder() fragments.EncoderFunc {
 	return func(ctx context.Context, e *fragments.Encoder, v reflect.Value) error {
-		p := v.Interface().(ObjectPath)
-		e.String(string(p.Clean()))
+		p := v.Interface().(ObjectPath).Clean()
+		if err := p.Valid(); err != nil {
+			return err
+		}
+		e.String(string(p))
 		return nil
 	}
 }
@@ -292,6 +389,10 @@ func (e *encoderGen) newSliceEncoder(t reflect.Type) (fragments.EncoderFunc, err
 		}, nil
 	}
 
+	if t.Kind() == reflect.Slice && encodesAsFixedNumber(t.Elem()) {
+		return e.newFixedSliceEncoder(t), nil
+	}
+
 	elemEnc, err := e.get(t.Elem())
 	if err != nil {
 		return nil, err
@@ -311,6 +412,25 @@ func (e *encoderGen) newSliceEncoder(t reflect.Type) (fragments.EncoderFunc, err
 	return fn, nil
 }
 
+// encodesAsFixedNumber reports whether t can be encoded in bulk, as
+// part of a slice of fixed-width numbers.
+func encodesAsFixedNumber(t reflect.Type) bool {
+	return fixedNumberKinds.Has(t.Kind()) && !t.Implements(marshalerType) && !reflect.PointerTo(t).Implements(marshalerType)
+}
+
+// newFixedSliceEncoder returns an encoder for a slice of fixed-width
+// numbers, which writes the slice's memory in a single pass.
+func (e *encoderGen) newFixedSliceEncoder(t reflect.Type) fragments.EncoderFunc {
+	size := int(t.Elem().Size())
+	return func(ctx context.Context, e *fragments.Encoder, v reflect.Value) error {
+		var bs []byte
+		if n := v.Len(); n > 0 {
+			bs = unsafe.Slice((*byte)(v.UnsafePointer()), n*size)
+		}
+		return e.FixedArray(size, bs)
+	}
+}
+
 func (e *encoderGen) newStructEncoder(t reflect.Type) (fragments.EncoderFunc, error) {
 	fs, err := getStructInfo(t)
 	if err != nil {
@@ -416,7 +536,9 @@ func (e *encoderGen) newVarDictFieldEncoder(f *structField) (fragments.EncoderFu
 
 			other := f.GetWithZero(v)
 			ks := other.MapKeys()
-			slices.SortFunc(ks, kCmp)
+			if contextSortMaps(ctx) {
+				slices.SortFunc(ks, kCmp)
+			}
 			for _, mapKey := range ks {
 				mapVal := other.MapIndex(mapKey)
 				err := e.Struct(func() error {
@@ -457,7 +579,9 @@ func (e *encoderGen) newMapEncoder(t reflect.Type) (fragments.EncoderFunc, error
 
 	fn := func(ctx context.Context, e *fragments.Encoder, v reflect.Value) error {
 		ks := v.MapKeys()
-		slices.SortFunc(ks, kCmp)
+		if contextSortMaps(ctx) {
+			slices.SortFunc(ks, kCmp)
+		}
 		return e.Array(true, func() error {
 			for _, mk := range ks {
 				mv := v.MapIndex(mk)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/danderson/dbus/fragments"
@@ -107,6 +108,37 @@ func TestMarshalUnmarshal(t *testing.T) {
 			0, 1,
 			// second val
 			0, 2),
+		ok("[]uint64", "at",
+			[]uint64{1, 0x0102030405060708},
+			// array length, excluding padding
+			0, 0, 0, 16,
+			// pad to element
+			0, 0, 0, 0,
+			// first val
+			0, 0, 0, 0, 0, 0, 0, 1,
+			// second val
+			1, 2, 3, 4, 5, 6, 7, 8),
+		ok("empty []uint64", "(atq)",
+			struct {
+				A []uint64
+				B uint16
+			}{nil, 42},
+			// array length
+			0, 0, 0, 0,
+			// pad to element, even when empty
+			0, 0, 0, 0,
+			// .B
+			0, 42),
+		ok("[]int32", "ai",
+			[]int32{-1, 2},
+			0, 0, 0, 8,
+			0xff, 0xff, 0xff, 0xff,
+			0, 0, 0, 2),
+		ok("[]float64", "ad",
+			[]float64{3402823700},
+			0, 0, 0, 8,
+			0, 0, 0, 0,
+			0x41, 0xE9, 0x5A, 0x5F, 0x02, 0x80, 0x00, 0x00),
 		ok("[][]uint16", "aaq",
 			[][]uint16{{1}, {2, 3}},
 			// outer array length
@@ -254,8 +286,11 @@ func TestMarshalUnmarshal(t *testing.T) {
 		),
 
 		ok("object path", "o",
-			ObjectPath("foo"),
-			0, 0, 0, 3, 'f', 'o', 'o', 0),
+			ObjectPath("/foo"),
+			0, 0, 0, 4, '/', 'f', 'o', 'o', 0),
+		ok("object path root", "o",
+			ObjectPath("/"),
+			0, 0, 0, 1, '/', 0),
 
 		ok("map string", "a{qs}",
 			map[uint16]string{
@@ -467,6 +502,14 @@ func TestMarshalUnmarshal(t *testing.T) {
 			0,
 			// val
 			0, 42),
+		ok("nested any", "v",
+			ptr(any(ptr(any(uint16(42))))),
+			// signature (variant)
+			1, 'v', 0,
+			// signature (uint16)
+			1, 'q', 0,
+			// val
+			0, 42),
 		asymmetric("any of inlined one field struct", "v",
 			ptr(any(uint16(42))),
 			ptr(any(InlineSingle{A: 42})),
@@ -489,6 +532,12 @@ func TestMarshalUnmarshal(t *testing.T) {
 			5),
 		fail("any of excessively large struct",
 			ptr(any(Large{}))),
+		fail("relative object path",
+			ObjectPath("foo"),
+			0, 0, 0, 3, 'f', 'o', 'o', 0),
+		fail("object path with invalid chars",
+			ObjectPath("/foo-bar"),
+			0, 0, 0, 8, '/', 'f', 'o', 'o', '-', 'b', 'a', 'r', 0),
 	}
 
 	for _, tc := range tests {
@@ -617,3 +666,395 @@ func TestMarshalInvalid(t *testing.T) {
 		t.Fatal("SignatureFor[Large]() succeeded, want error")
 	}
 }
+
+func TestNestedVariants(t *testing.T) {
+	type vardict struct {
+		V     any            `dbus:"key=v"`
+		Other map[string]any `dbus:"vardict"`
+	}
+	// Variants decode structs as pointers to anonymous structs.
+	type anonSimple = struct {
+		Field0 int16
+		Field1 bool
+	}
+	tests := []struct {
+		name string
+		in   any
+		want any
+	}{
+		{"deep", ptr(any(ptr(any(ptr(any(ptr(any("foo")))))))), nil},
+		{"struct",
+			ptr(any(ptr(any(Simple{1, true})))),
+			ptr(any(ptr(any(&anonSimple{1, true})))),
+		},
+		{"vardict field", vardict{V: ptr(any(uint16(1)))}, nil},
+		{"vardict field struct",
+			vardict{V: ptr(any(Simple{1, true}))},
+			vardict{V: ptr(any(&anonSimple{1, true}))},
+		},
+		{"vardict unknown", vardict{
+			Other: map[string]any{
+				"x": ptr(any(ptr(any("bar")))),
+			},
+		}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := fragments.Encoder{
+				Order:  fragments.BigEndian,
+				Mapper: encoderFor,
+			}
+			if err := enc.Value(context.Background(), tc.in); err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			got := reflect.New(reflect.TypeOf(tc.in))
+			dec := fragments.Decoder{
+				Order:  fragments.BigEndian,
+				Mapper: decoderFor,
+				In:     bytes.NewReader(enc.Out),
+			}
+			if err := dec.Value(context.Background(), got.Interface()); err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			want := tc.want
+			if want == nil {
+				want = tc.in
+			}
+			if diff := cmp.Diff(got.Elem().Interface(), want); diff != "" {
+				t.Errorf("nested variants did not round-trip (-got+want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshalReusesStorage(t *testing.T) {
+	enc := fragments.Encoder{
+		Order:  fragments.BigEndian,
+		Mapper: encoderFor,
+	}
+	type both struct {
+		A []string
+		B []byte
+	}
+	in := both{
+		[]string{"foo", "bar"},
+		[]byte{1, 2, 3},
+	}
+	if err := enc.Value(context.Background(), in); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	decode := func(ctx context.Context) (out both, strs []string, bs []byte) {
+		strs = make([]string, 1, 8)
+		bs = []byte{42}
+		bs = slices.Grow(bs, 8)
+		out = both{strs, bs}
+		dec := fragments.Decoder{
+			Order:  fragments.BigEndian,
+			Mapper: decoderFor,
+			In:     bytes.NewReader(enc.Out),
+		}
+		if err := dec.Value(ctx, &out); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if diff := cmp.Diff(out, in); diff != "" {
+			t.Fatalf("decode wrong output (-got+want):\n%s", diff)
+		}
+		if &out.A[0] != &strs[0] {
+			t.Error("decoding []string did not reuse existing capacity")
+		}
+		return out, strs, bs
+	}
+
+	out, _, bs := decode(context.Background())
+	if &out.B[0] == &bs[0] {
+		t.Error("decoding []byte reused existing storage without opt-in")
+	}
+	if bs[0] != 42 {
+		t.Errorf("decoding []byte modified the previous backing array, got %d want 42", bs[0])
+	}
+
+	out, _, bs = decode(WithContextReuseBytes(context.Background(), true))
+	if &out.B[0] != &bs[0] {
+		t.Error("decoding []byte with WithContextReuseBytes did not reuse existing capacity")
+	}
+}
+
+func TestMarshalUnsortedMaps(t *testing.T) {
+	in := map[uint32]string{}
+	for i := range uint32(100) {
+		in[i] = "x"
+	}
+	encode := func(ctx context.Context) []byte {
+		t.Helper()
+		enc := fragments.Encoder{
+			Order:  fragments.BigEndian,
+			Mapper: encoderFor,
+		}
+		if err := enc.Value(ctx, in); err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		return enc.Out
+	}
+
+	sorted := encode(context.Background())
+	if got := encode(WithContextSortedMaps(context.Background(), true)); !bytes.Equal(got, sorted) {
+		t.Error("explicitly sorted encoding differs from default encoding")
+	}
+
+	unsortedCtx := WithContextSortedMaps(context.Background(), false)
+	sawUnsorted := false
+	for range 10 {
+		raw := encode(unsortedCtx)
+		if !bytes.Equal(raw, sorted) {
+			sawUnsorted = true
+		}
+		var out map[uint32]string
+		dec := fragments.Decoder{
+			Order:  fragments.BigEndian,
+			Mapper: decoderFor,
+			In:     bytes.NewReader(raw),
+		}
+		if err := dec.Value(context.Background(), &out); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if diff := cmp.Diff(out, in); diff != "" {
+			t.Fatalf("unsorted map roundtrip wrong output (-got+want):\n%s", diff)
+		}
+	}
+	if !sawUnsorted {
+		t.Error("unsorted map encoding always matched sorted encoding")
+	}
+}
+
+func TestUnmarshalStrictBooleans(t *testing.T) {
+	decode := func(ctx context.Context, raw []byte) (bool, error) {
+		var out bool
+		dec := fragments.Decoder{
+			Order:  fragments.BigEndian,
+			Mapper: decoderFor,
+			In:     bytes.NewReader(raw),
+		}
+		err := dec.Value(ctx, &out)
+		return out, err
+	}
+
+	strict := WithContextStrictBooleans(context.Background(), true)
+	for _, ctx := range []context.Context{context.Background(), strict} {
+		if got, err := decode(ctx, []byte{0, 0, 0, 1}); err != nil || !got {
+			t.Errorf("decoding 1 got (%v, %v), want (true, nil)", got, err)
+		}
+		if got, err := decode(ctx, []byte{0, 0, 0, 0}); err != nil || got {
+			t.Errorf("decoding 0 got (%v, %v), want (false, nil)", got, err)
+		}
+	}
+
+	if got, err := decode(context.Background(), []byte{0, 0, 0, 2}); err != nil || !got {
+		t.Errorf("lenient decoding of 2 got (%v, %v), want (true, nil)", got, err)
+	}
+	if _, err := decode(strict, []byte{0, 0, 0, 2}); err == nil {
+		t.Error("strict decoding of 2 succeeded, want error")
+	}
+}
+
+func TestUnmarshalInvalidStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"invalid UTF-8", []byte{0, 0, 0, 2, 0xc3, 0x28, 0}},
+		{"embedded NUL", []byte{0, 0, 0, 3, 'a', 0, 'b', 0}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			decode := func(ctx context.Context) (string, error) {
+				var out string
+				dec := fragments.Decoder{
+					Order:  fragments.BigEndian,
+					Mapper: decoderFor,
+					In:     bytes.NewReader(tc.raw),
+				}
+				err := dec.Value(ctx, &out)
+				return out, err
+			}
+
+			if got, err := decode(context.Background()); err == nil {
+				t.Errorf("decoding invalid string succeeded, got %q", got)
+			}
+			lenient := WithContextStrictStrings(context.Background(), false)
+			got, err := decode(lenient)
+			if err != nil {
+				t.Fatalf("lenient decoding failed: %v", err)
+			}
+			if want := string(tc.raw[4 : len(tc.raw)-1]); got != want {
+				t.Errorf("lenient decoding got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalFixedArrays(t *testing.T) {
+	type myUint32 uint32
+	in := struct {
+		A byte
+		B []int16
+		C []myUint32
+		D []uint64
+		E []float64
+	}{
+		A: 1,
+		B: []int16{-1, 2, -3},
+		C: []myUint32{4, 5},
+		D: []uint64{6, 1 << 60},
+		E: []float64{7.5, -8},
+	}
+	for _, order := range []fragments.ByteOrder{fragments.BigEndian, fragments.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			enc := fragments.Encoder{
+				Order:  order,
+				Mapper: encoderFor,
+			}
+			if err := enc.Value(context.Background(), in); err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			out := in
+			out.B = make([]int16, 0, 10)
+			out.C, out.D, out.E = nil, nil, nil
+			dec := fragments.Decoder{
+				Order:  order,
+				Mapper: decoderFor,
+				In:     bytes.NewReader(enc.Out),
+			}
+			if err := dec.Value(context.Background(), &out); err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if diff := cmp.Diff(out, in); diff != "" {
+				t.Errorf("decode wrong output (-got+want):\n%s", diff)
+			}
+			if cap(out.B) != 10 {
+				t.Error("decoding []int16 did not reuse existing capacity")
+			}
+		})
+	}
+
+	for _, bad := range [][]byte{
+		// Length not a multiple of element size
+		{0, 0, 0, 3, 0, 1, 2},
+		// Truncated
+		{0, 0, 0, 8, 0, 0, 0, 1},
+	} {
+		var got []uint32
+		dec := fragments.Decoder{
+			Order:  fragments.BigEndian,
+			Mapper: decoderFor,
+			In:     bytes.NewReader(bad),
+		}
+		if err := dec.Value(context.Background(), &got); err == nil {
+			t.Errorf("decoding % x succeeded, want error", bad)
+		}
+	}
+}
+
+func BenchmarkUnmarshalFixedArray(b *testing.B) {
+	in := make([]uint64, 1<<20)
+	for i := range in {
+		in[i] = uint64(i)
+	}
+	enc := fragments.Encoder{
+		Order:  fragments.BigEndian,
+		Mapper: encoderFor,
+	}
+	if err := enc.Value(context.Background(), in); err != nil {
+		b.Fatalf("encode failed: %v", err)
+	}
+	b.SetBytes(int64(len(enc.Out)))
+	b.ReportAllocs()
+	out := make([]uint64, 0, len(in))
+	r := bytes.NewReader(enc.Out)
+	for range b.N {
+		r.Reset(enc.Out)
+		dec := fragments.Decoder{
+			Order:  fragments.BigEndian,
+			Mapper: decoderFor,
+			In:     r,
+		}
+		if err := dec.Value(context.Background(), &out); err != nil {
+			b.Fatalf("decode failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkMarshalFixedArray(b *testing.B) {
+	in := make([]uint32, 1<<20)
+	for i := range in {
+		in[i] = uint32(i)
+	}
+	enc := fragments.Encoder{
+		Order:  fragments.BigEndian,
+		Mapper: encoderFor,
+	}
+	b.SetBytes(int64(4 * len(in)))
+	b.ReportAllocs()
+	for range b.N {
+		enc.Out = enc.Out[:0]
+		if err := enc.Value(context.Background(), in); err != nil {
+			b.Fatalf("encode failed: %v", err)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	type vardict struct {
+		_     InlineLayout
+		Name  string         `dbus:"key=name"`
+		Count uint32         `dbus:"key=count"`
+		Other map[string]any `dbus:"vardict"`
+	}
+	targets := []reflect.Type{
+		reflect.TypeFor[string](),
+		reflect.TypeFor[any](),
+		reflect.TypeFor[Nested](),
+		reflect.TypeFor[Arrays](),
+		reflect.TypeFor[vardict](),
+		reflect.TypeFor[map[string]any](),
+		reflect.TypeFor[header](),
+	}
+
+	f.Add([]byte{0, 0, 0, 3, 'f', 'o', 'o', 0}, true)
+	f.Add([]byte{1, 'u', 0, 0, 0, 0, 0, 42}, true)
+	f.Add([]byte{2, 'a', 'v', 0, 0, 0, 0, 8, 1, 'y', 0, 7, 1, 's', 0, 0}, false)
+	f.Add([]byte{
+		0, 0, 0, 0x10, 0, 0, 0, 0,
+		0, 0, 0, 4, 'n', 'a', 'm', 'e', 0,
+		1, 's', 0, 0, 0, 0, 1, 'x', 0,
+	}, true)
+	f.Add([]byte("l\x01\x00\x01\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00"), false)
+
+	f.Fuzz(func(t *testing.T, data []byte, bigEndian bool) {
+		var order fragments.ByteOrder = fragments.LittleEndian
+		if bigEndian {
+			order = fragments.BigEndian
+		}
+		for _, typ := range targets {
+			dec := fragments.Decoder{
+				Order:  order,
+				Mapper: decoderFor,
+				In:     bytes.NewReader(data),
+			}
+			v := reflect.New(typ)
+			if err := dec.Value(context.Background(), v.Interface()); err != nil {
+				continue
+			}
+
+			// Anything that decodes successfully must be
+			// representable, and so encodable.
+			enc := fragments.Encoder{
+				Order:  order,
+				Mapper: encoderFor,
+			}
+			if err := enc.Value(context.Background(), v.Interface()); err != nil {
+				t.Errorf("decoded %s value %#v, but cannot encode it: %v", typ, v.Elem().Interface(), err)
+			}
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"math"
 	"reflect"
 	"slices"
 	"strings"
@@ -14,19 +15,32 @@ import (
 // Match is a filter that matches DBus signals.
 type Match struct {
 	sender       value.Maybe[string]
+	destination  value.Maybe[string]
 	object       value.Maybe[ObjectPath]
 	objectPrefix value.Maybe[ObjectPath]
+	iface        value.Maybe[string]
+	member       value.Maybe[string]
 	signal       value.Maybe[signalMatch]
 	property     value.Maybe[interfaceMember]
 	argStr       map[int]string
 	argPath      map[int]ObjectPath
+	argPathNS    map[int]ObjectPath
 	arg0NS       value.Maybe[string]
+	argValue     map[int]reflect.Value
+	eavesdrop    bool
 }
 
 type signalMatch struct {
 	interfaceMember
 	stringFields map[int]func(reflect.Value) string
 	objectFields map[int]func(reflect.Value) string
+	valueFields  map[int]valueField
+}
+
+// valueField is a non-string basic typed field of a signal.
+type valueField struct {
+	typ reflect.Type
+	get func(reflect.Value) reflect.Value
 }
 
 // MatchNotification returns a match for the given notification.
@@ -54,6 +68,7 @@ func MatchNotification[NotificationT any]() *Match {
 		interfaceMember: sig,
 		stringFields:    map[int]func(reflect.Value) string{},
 		objectFields:    map[int]func(reflect.Value) string{},
+		valueFields:     map[int]valueField{},
 	}
 
 	inf, err := getStructInfo(bt)
@@ -66,6 +81,8 @@ func MatchNotification[NotificationT any]() *Match {
 			sm.objectFields[i] = field.StringGetter()
 		} else if fieldBottom.Kind() == reflect.String {
 			sm.stringFields[i] = field.StringGetter()
+		} else if _, ok := kindToStr[fieldBottom.Kind()]; ok {
+			sm.valueFields[i] = valueField{fieldBottom, field.ValueGetter()}
 		}
 	}
 
@@ -90,12 +107,21 @@ func (m *Match) filterString() string {
 	if s, ok := m.sender.GetOK(); ok {
 		kv("sender", s)
 	}
+	if d, ok := m.destination.GetOK(); ok {
+		kv("destination", d)
+	}
 	if o, ok := m.object.GetOK(); ok {
 		kv("path", o.String())
 	}
 	if p, ok := m.objectPrefix.GetOK(); ok {
 		kv("path_namespace", p.String())
 	}
+	if i, ok := m.iface.GetOK(); ok {
+		kv("interface", i)
+	}
+	if n, ok := m.member.GetOK(); ok {
+		kv("member", n)
+	}
 	if pm, ok := m.property.GetOK(); ok {
 		kv("interface", "org.freedesktop.DBus.Properties")
 		kv("member", "PropertiesChanged")
@@ -118,6 +144,9 @@ func (m *Match) filterString() string {
 			kv("arg0namespace", n)
 		}
 	}
+	if m.eavesdrop {
+		kv("eavesdrop", "true")
+	}
 
 	return strings.Join(ms, ",")
 }
@@ -138,12 +167,21 @@ func (m *Match) matchesSignal(hdr *header, body reflect.Value) bool {
 	if s, ok := m.sender.GetOK(); ok && hdr.Sender != s {
 		return false
 	}
+	if d, ok := m.destination.GetOK(); ok && hdr.Destination != d {
+		return false
+	}
 	if o, ok := m.object.GetOK(); ok && hdr.Path != o {
 		return false
 	}
 	if p, ok := m.objectPrefix.GetOK(); ok && hdr.Path != p && !hdr.Path.IsChildOf(p) {
 		return false
 	}
+	if i, ok := m.iface.GetOK(); ok && hdr.Interface != i {
+		return false
+	}
+	if n, ok := m.member.GetOK(); ok && hdr.Member != n {
+		return false
+	}
 
 	if sm, ok := m.signal.GetOK(); ok {
 		if hdr.Interface != sm.Interface || hdr.Member != sm.Member {
@@ -167,11 +205,25 @@ func (m *Match) matchesSignal(hdr *header, body reflect.Value) bool {
 				}
 			}
 		}
+		for i, want := range m.argPathNS {
+			get := sm.stringFields[i]
+			if get == nil {
+				get = sm.objectFields[i]
+			}
+			if got := ObjectPath(get(body.Elem())); got != want && !got.IsChildOf(want) {
+				return false
+			}
+		}
 		if n, ok := m.arg0NS.GetOK(); ok {
 			if got := sm.stringFields[0](body.Elem()); got != n && !strings.HasPrefix(got, n+".") {
 				return false
 			}
 		}
+		for i, want := range m.argValue {
+			if got := sm.valueFields[i].get(body.Elem()); !got.IsValid() || !got.Equal(want) {
+				return false
+			}
+		}
 	}
 
 	return true
@@ -188,6 +240,9 @@ func (m *Match) matchesProperty(hdr *header, prop interfaceMember, body reflect.
 	if s, ok := m.sender.GetOK(); ok && hdr.Sender != s {
 		return false
 	}
+	if d, ok := m.destination.GetOK(); ok && hdr.Destination != d {
+		return false
+	}
 	if o, ok := m.object.GetOK(); ok && hdr.Path != o {
 		return false
 	}
@@ -210,6 +265,17 @@ func (m *Match) Peer(p Peer) *Match {
 	return m
 }
 
+// Destination restricts the match to signals addressed to the given
+// Peer.
+//
+// Broadcast signals have no destination, and so never match. To
+// observe signals addressed to peers other than this connection, the
+// match must also use [Match.Eavesdrop].
+func (m *Match) Destination(p Peer) *Match {
+	m.destination = value.Just(p.Name())
+	return m
+}
+
 // Object restricts the match to a single source path.
 func (m *Match) Object(o ObjectPath) *Match {
 	m.objectPrefix = value.Absent[ObjectPath]()
@@ -235,15 +301,78 @@ func (m *Match) ObjectPrefix(o ObjectPath) *Match {
 	return m
 }
 
+// Eavesdrop makes the match also receive signals that are addressed
+// to other peers, rather than only broadcast signals and signals
+// addressed to this connection.
+//
+// Eavesdropping is a legacy mechanism that some older buses require
+// in order to observe unicast traffic. Modern buses may restrict or
+// reject eavesdropping match rules, and prefer that observers use
+// org.freedesktop.DBus.Monitoring.BecomeMonitor instead.
+func (m *Match) Eavesdrop() *Match {
+	m.eavesdrop = true
+	return m
+}
+
+// Interface restricts the match to signals of the given interface.
+//
+// Interface can only be used on matches created by
+// [MatchAllSignals]. Notification matches already match a single
+// interface.
+func (m *Match) Interface(name string) *Match {
+	m.mustBeUntyped("Interface")
+	m.iface = value.Just(name)
+	return m
+}
+
+// Member restricts the match to signals with the given member name.
+//
+// Member can only be used on matches created by
+// [MatchAllSignals]. Notification matches already match a single
+// member.
+func (m *Match) Member(name string) *Match {
+	m.mustBeUntyped("Member")
+	m.member = value.Just(name)
+	return m
+}
+
+// mustBeUntyped panics if m matches a specific notification type.
+func (m *Match) mustBeUntyped(method string) {
+	if p, ok := m.property.GetOK(); ok {
+		panic(fmt.Errorf("%s applied to property match %s, can only be applied to matches from MatchAllSignals", method, p))
+	}
+	if sm, ok := m.signal.GetOK(); ok {
+		panic(fmt.Errorf("%s applied to signal match %s, can only be applied to matches from MatchAllSignals", method, sm.interfaceMember))
+	}
+}
+
+// maxMatchArg is the highest argument index that the bus accepts in
+// argN and argNpath match rules.
+const maxMatchArg = 63
+
+// mustBeBusArg panics if i is not an argument index that the bus can
+// match on. The bus rejects the entire match rule if any argument
+// index is out of range, so catch the mistake early with a clearer
+// error.
+func mustBeBusArg(method string, i int) {
+	if i < 0 || i > maxMatchArg {
+		panic(fmt.Errorf("invalid %s match on arg %d, the bus only supports matching on args 0 to %d", method, i, maxMatchArg))
+	}
+}
+
 // ArgStr restricts the match to signals whose i-th body field is a
 // string equal to val.
 //
+// The bus can only match on the first 64 fields of a signal, so i
+// must be between 0 and 63.
+//
 // ArgStr can only be used on signal matches, not property matches.
 func (m *Match) ArgStr(i int, val string) *Match {
 	sm, ok := m.signal.GetOK()
 	if !ok {
 		panic(fmt.Errorf("ArgStr applied to property match %s, can only be applied to signal matches", m.property.Get()))
 	}
+	mustBeBusArg("ArgStr", i)
 	if sm.stringFields[i] == nil {
 		panic(fmt.Errorf("invalid ArgStr match on arg %d, argument is not a string", i))
 	}
@@ -257,6 +386,9 @@ func (m *Match) ArgStr(i int, val string) *Match {
 // ArgPathPrefix restricts the Match to signals whose i-th body field
 // is a string or ObjectPath with the given prefix.
 //
+// The bus can only match on the first 64 fields of a signal, so i
+// must be between 0 and 63.
+//
 // ArgPathPrefix can only be used on signal matches, not property
 // matches.
 func (m *Match) ArgPathPrefix(i int, val ObjectPath) *Match {
@@ -264,6 +396,7 @@ func (m *Match) ArgPathPrefix(i int, val ObjectPath) *Match {
 	if !ok {
 		panic(fmt.Errorf("ArgPathPrefix applied to property match %s, can only be applied to signal matches", m.property.Get()))
 	}
+	mustBeBusArg("ArgPathPrefix", i)
 	if sm.stringFields[i] == nil && sm.objectFields[i] == nil {
 		panic(fmt.Errorf("invalid ArgPathPrefix match on arg %d, argument is not a string or an ObjectPath", i))
 	}
@@ -274,6 +407,36 @@ func (m *Match) ArgPathPrefix(i int, val ObjectPath) *Match {
 	return m
 }
 
+// ArgPathNamespace restricts the Match to signals whose i-th body
+// field is a string or ObjectPath equal to val, or a descendant of
+// val.
+//
+// For example, ArgPathNamespace(1, "/mascots/gopher") matches
+// signals whose second field is /mascots/gopher or
+// /mascots/gopher/plushie, but not /mascots/glenda.
+//
+// The bus's match rules cannot express this filter, so like
+// [Match.ArgEquals], ArgPathNamespace does not narrow the set of
+// signals that the bus sends to the [Conn]. Signals are filtered
+// locally before delivery to the [Watcher].
+//
+// ArgPathNamespace can only be used on signal matches, not property
+// matches.
+func (m *Match) ArgPathNamespace(i int, val ObjectPath) *Match {
+	sm, ok := m.signal.GetOK()
+	if !ok {
+		panic(fmt.Errorf("ArgPathNamespace applied to property match %s, can only be applied to signal matches", m.property.Get()))
+	}
+	if sm.stringFields[i] == nil && sm.objectFields[i] == nil {
+		panic(fmt.Errorf("invalid ArgPathNamespace match on arg %d, argument is not a string or an ObjectPath", i))
+	}
+	if m.argPathNS == nil {
+		m.argPathNS = map[int]ObjectPath{}
+	}
+	m.argPathNS[i] = val.Clean()
+	return m
+}
+
 // Arg0Namespace restricts the Match to signals whose first body field
 // is a peer or interface name with the given dot-separated prefix.
 //
@@ -291,6 +454,103 @@ func (m *Match) Arg0Namespace(val string) *Match {
 	return m
 }
 
+// ArgEquals restricts the Match to signals whose i-th body field is
+// a boolean or number equal to val.
+//
+// Unlike the other Arg matchers, ArgEquals is not supported by the
+// bus, and so does not narrow the set of signals that the bus sends
+// to the [Conn]. Signals are filtered locally before delivery to the
+// [Watcher].
+//
+// val can be of any numeric type, as long as its value can be
+// represented by the field's type without loss. ArgEquals panics if
+// the i-th field is not a boolean or number, or if val cannot be
+// compared to it.
+//
+// ArgEquals can only be used on signal matches, not property
+// matches.
+func (m *Match) ArgEquals(i int, val any) *Match {
+	sm, ok := m.signal.GetOK()
+	if !ok {
+		panic(fmt.Errorf("ArgEquals applied to property match %s, can only be applied to signal matches", m.property.Get()))
+	}
+	f, ok := sm.valueFields[i]
+	if !ok {
+		panic(fmt.Errorf("invalid ArgEquals match on arg %d, argument is not a boolean or number", i))
+	}
+	want, ok := convertArgValue(f.typ, val)
+	if !ok {
+		panic(fmt.Errorf("invalid ArgEquals match on arg %d, cannot compare %T(%v) to %s", i, val, val, f.typ))
+	}
+	if m.argValue == nil {
+		m.argValue = map[int]reflect.Value{}
+	}
+	m.argValue[i] = want
+	return m
+}
+
+// maxExactFloatInt is the largest magnitude integer that float64 can
+// represent exactly, along with all smaller integers.
+const maxExactFloatInt = 1 << 53
+
+// convertArgValue converts v to type t, if v is a boolean or number
+// that can be represented exactly by t.
+func convertArgValue(t reflect.Type, v any) (reflect.Value, bool) {
+	rv := reflect.ValueOf(v)
+	z := reflect.Zero(t)
+	switch t.Kind() {
+	case reflect.Bool:
+		if rv.Kind() != reflect.Bool {
+			return reflect.Value{}, false
+		}
+	case reflect.Int16, reflect.Int32, reflect.Int64:
+		switch {
+		case rv.CanInt():
+			if z.OverflowInt(rv.Int()) {
+				return reflect.Value{}, false
+			}
+		case rv.CanUint():
+			if rv.Uint() > math.MaxInt64 || z.OverflowInt(int64(rv.Uint())) {
+				return reflect.Value{}, false
+			}
+		default:
+			return reflect.Value{}, false
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case rv.CanInt():
+			if rv.Int() < 0 || z.OverflowUint(uint64(rv.Int())) {
+				return reflect.Value{}, false
+			}
+		case rv.CanUint():
+			if z.OverflowUint(rv.Uint()) {
+				return reflect.Value{}, false
+			}
+		default:
+			return reflect.Value{}, false
+		}
+	case reflect.Float64:
+		// Integers beyond 2^53 lose precision as float64, and would
+		// then match signals carrying a different value.
+		switch {
+		case rv.CanInt():
+			if i := rv.Int(); i > maxExactFloatInt || i < -maxExactFloatInt {
+				return reflect.Value{}, false
+			}
+		case rv.CanUint():
+			if rv.Uint() > maxExactFloatInt {
+				return reflect.Value{}, false
+			}
+		case rv.CanFloat():
+		default:
+			return reflect.Value{}, false
+		}
+	default:
+		return reflect.Value{}, false
+	}
+	return rv.Convert(t), true
+}
+
 func escapeMatchArg(s string) string {
 	s = strings.ReplaceAll(s, "'", "'\\''")
 	return "'" + s + "'"
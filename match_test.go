@@ -1,7 +1,9 @@
 package dbus
 
 import (
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -72,6 +74,10 @@ func TestMatch(t *testing.T) {
 			want: want,
 		}
 	}
+	withDest := func(m sigMatch, dest string) sigMatch {
+		m.hdr.Destination = dest
+		return m
+	}
 	prop := func(want bool, sender, path, iface, name string, prop any) propMatch {
 		return propMatch{
 			hdr:  hdr(sender, path, "org.freedesktop.DBus.Properties", "PropertiesChanged"),
@@ -96,6 +102,56 @@ func TestMatch(t *testing.T) {
 			},
 		},
 
+		{
+			name:   "all signals interface",
+			m:      MatchAllSignals().Interface("org.test"),
+			filter: `type='signal',interface='org.test'`,
+			matchSignals: []sigMatch{
+				sig(true, "test", "/test", "org.test", "Signal", &TestSignal{}),
+				sig(true, "test", "/test", "org.test", "Signal2", &TestSignal2{}),
+				sig(false, "test2", "/test2", "org.test2", "Signal2", &TestSignal2{}),
+			},
+			matchProps: []propMatch{
+				prop(false, "test", "/test", "org.test", "Prop", &TestProp{}),
+			},
+		},
+
+		{
+			name:   "all signals interface member",
+			m:      MatchAllSignals().Object("/test").Interface("org.test").Member("Signal2"),
+			filter: `type='signal',path='/test',interface='org.test',member='Signal2'`,
+			matchSignals: []sigMatch{
+				sig(true, "test", "/test", "org.test", "Signal2", &TestSignal2{}),
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{}),
+				sig(false, "test", "/test", "org.test2", "Signal2", &TestSignal2{}),
+				sig(false, "test", "/test2", "org.test", "Signal2", &TestSignal2{}),
+			},
+		},
+
+		{
+			name:   "all signals eavesdrop",
+			m:      MatchAllSignals().Interface("org.test").Eavesdrop(),
+			filter: `type='signal',interface='org.test',eavesdrop='true'`,
+			matchSignals: []sigMatch{
+				sig(true, "test", "/test", "org.test", "Signal", &TestSignal{}),
+				sig(false, "test2", "/test2", "org.test2", "Signal2", &TestSignal2{}),
+			},
+		},
+
+		{
+			name:   "all signals destination",
+			m:      MatchAllSignals().Destination(conn.Peer(":1.42")).Eavesdrop(),
+			filter: `type='signal',destination=':1.42',eavesdrop='true'`,
+			matchSignals: []sigMatch{
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{}),
+				withDest(sig(true, "test", "/test", "org.test", "Signal", &TestSignal{}), ":1.42"),
+				withDest(sig(false, "test", "/test", "org.test", "Signal", &TestSignal{}), ":1.43"),
+			},
+			matchProps: []propMatch{
+				prop(false, "test", "/test", "org.test", "Prop", &TestProp{}),
+			},
+		},
+
 		{
 			name:   "signal",
 			m:      MatchNotification[TestSignal](),
@@ -274,6 +330,60 @@ func TestMatch(t *testing.T) {
 			},
 		},
 
+		{
+			name:   "signal arg path namespace",
+			m:      MatchNotification[TestSignal]().ArgPathNamespace(1, "/foo").ArgPathNamespace(2, "/bar"),
+			filter: `type='signal',interface='org.test',member='Signal'`,
+			matchSignals: []sigMatch{
+				sig(true, "test", "/test", "org.test", "Signal", &TestSignal{
+					B: "/foo",
+					C: "/bar",
+				}),
+				sig(true, "test", "/test", "org.test", "Signal", &TestSignal{
+					B: "/foo/qux",
+					C: "/bar/zot/qux",
+				}),
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{
+					B: "/foobar",
+					C: "/bar",
+				}),
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{
+					B: "/foo",
+					C: "/",
+				}),
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{}),
+			},
+			matchProps: []propMatch{
+				prop(false, "test", "/test", "org.test", "Prop", &TestProp{}),
+				prop(false, "test2", "/test2", "org.test2", "Prop2", TestProp2(0)),
+			},
+		},
+
+		{
+			name:   "signal arg equals",
+			m:      MatchNotification[TestSignal]().ArgStr(0, "foo").ArgEquals(3, 42),
+			filter: `type='signal',interface='org.test',member='Signal',arg0='foo'`,
+			matchSignals: []sigMatch{
+				sig(true, "test", "/test", "org.test", "Signal", &TestSignal{
+					A: "foo",
+					D: 42,
+				}),
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{
+					A: "foo",
+					D: 43,
+				}),
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{
+					A: "bar",
+					D: 42,
+				}),
+				sig(false, "test", "/test", "org.test", "Signal", &TestSignal{}),
+			},
+			matchProps: []propMatch{
+				prop(false, "test", "/test", "org.test", "Prop", &TestProp{}),
+				prop(false, "test2", "/test2", "org.test2", "Prop2", TestProp2(0)),
+			},
+		},
+
 		{
 			name:   "property",
 			m:      MatchNotification[TestProp](),
@@ -365,3 +475,113 @@ func TestMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchArgEqualsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  int
+		val  any
+	}{
+		{"string field", 0, 42},
+		{"object path field", 1, 42},
+		{"out of range field", 4, 42},
+		{"bool value", 3, true},
+		{"string value", 3, "42"},
+		{"float value", 3, 1.5},
+		{"overflow", 3, 70000},
+		{"nil value", 3, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ArgEquals(%d, %#v) did not panic", tc.arg, tc.val)
+				}
+			}()
+			MatchNotification[TestSignal]().ArgEquals(tc.arg, tc.val)
+		})
+	}
+}
+
+func TestConvertArgValue(t *testing.T) {
+	f64 := reflect.TypeFor[float64]()
+	tests := []struct {
+		val  any
+		want bool
+	}{
+		{1.5, true},
+		{42, true},
+		{int64(1 << 53), true},
+		{int64(-1 << 53), true},
+		{uint64(1 << 53), true},
+		{int64(1<<53 + 1), false},
+		{int64(-1<<53 - 1), false},
+		{uint64(1<<53 + 1), false},
+		{uint64(math.MaxUint64), false},
+		{int64(math.MinInt64), false},
+		{"42", false},
+		{true, false},
+	}
+
+	for _, tc := range tests {
+		got, ok := convertArgValue(f64, tc.val)
+		if ok != tc.want {
+			t.Errorf("convertArgValue(float64, %T(%v)) ok=%v, want %v", tc.val, tc.val, ok, tc.want)
+			continue
+		}
+		if ok && got.Float() != reflect.ValueOf(tc.val).Convert(f64).Float() {
+			t.Errorf("convertArgValue(float64, %T(%v)) = %v, want %v", tc.val, tc.val, got, tc.val)
+		}
+	}
+}
+
+func TestMatchArgIndexOutOfRange(t *testing.T) {
+	tests := []struct {
+		name string
+		m    func() *Match
+	}{
+		{"ArgStr high", func() *Match { return MatchNotification[TestSignal]().ArgStr(64, "foo") }},
+		{"ArgStr negative", func() *Match { return MatchNotification[TestSignal]().ArgStr(-1, "foo") }},
+		{"ArgPathPrefix high", func() *Match { return MatchNotification[TestSignal]().ArgPathPrefix(64, "/foo") }},
+		{"ArgPathPrefix negative", func() *Match { return MatchNotification[TestSignal]().ArgPathPrefix(-1, "/foo") }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil {
+					t.Fatalf("%s did not panic", tc.name)
+				}
+				if !strings.Contains(err.Error(), "only supports matching on args 0 to 63") {
+					t.Errorf("%s panicked with %q, want arg index range error", tc.name, err)
+				}
+			}()
+			tc.m()
+		})
+	}
+}
+
+func TestMatchInterfaceMemberInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		m    func() *Match
+	}{
+		{"signal interface", func() *Match { return MatchNotification[TestSignal]().Interface("org.test") }},
+		{"signal member", func() *Match { return MatchNotification[TestSignal]().Member("Signal") }},
+		{"property interface", func() *Match { return MatchNotification[TestProp]().Interface("org.test") }},
+		{"property member", func() *Match { return MatchNotification[TestProp]().Member("Prop") }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", tc.name)
+				}
+			}()
+			tc.m()
+		})
+	}
+}
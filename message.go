@@ -0,0 +1,334 @@
+package dbus
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/danderson/dbus/fragments"
+)
+
+// MessageType is the type of a DBus message.
+type MessageType byte
+
+const (
+	// MessageCall is a method call.
+	MessageCall MessageType = MessageType(msgTypeCall)
+	// MessageReturn is a successful method call response.
+	MessageReturn MessageType = MessageType(msgTypeReturn)
+	// MessageError is a failed method call response.
+	MessageError MessageType = MessageType(msgTypeError)
+	// MessageSignal is a signal.
+	MessageSignal MessageType = MessageType(msgTypeSignal)
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case MessageCall:
+		return "Call"
+	case MessageReturn:
+		return "Return"
+	case MessageError:
+		return "Error"
+	case MessageSignal:
+		return "Signal"
+	default:
+		return fmt.Sprintf("MessageType(%d)", byte(t))
+	}
+}
+
+// MessageTrace is a summary of a message sent or received by a
+// [Conn], for debugging. See [DialOptions.Trace].
+type MessageTrace struct {
+	// Sent is true if the Conn sent the message, and false if it
+	// received it.
+	Sent bool
+	// Type is the message's type.
+	Type MessageType
+	// Serial is the message's serial number.
+	Serial uint32
+	// ReplySerial is the serial of the message to which this message
+	// is replying, or zero if the message is not a reply.
+	ReplySerial uint32
+	// Sender is the unique bus name of the message's sender. It is
+	// empty for sent messages, since the bus fills it in.
+	Sender string
+	// Destination is the bus name of the message's recipient, or
+	// empty for broadcast signals.
+	Destination string
+	// Path is the target object of a call, or the source object of
+	// a signal.
+	Path ObjectPath
+	// Interface is the target interface of a call, or the source
+	// interface of a signal.
+	Interface string
+	// Member is the method name of a call, or the signal name of a
+	// signal.
+	Member string
+	// ErrName is the name of the error in an error message.
+	ErrName string
+	// Signature is the signature of the message's body.
+	Signature Signature
+	// Length is the length of the message's body, in bytes.
+	Length int
+}
+
+func newMessageTrace(hdr *header, sent bool) MessageTrace {
+	return MessageTrace{
+		Sent:        sent,
+		Type:        MessageType(hdr.Type),
+		Serial:      hdr.Serial,
+		ReplySerial: hdr.ReplySerial,
+		Sender:      hdr.Sender,
+		Destination: hdr.Destination,
+		Path:        hdr.Path,
+		Interface:   hdr.Interface,
+		Member:      hdr.Member,
+		ErrName:     hdr.ErrName,
+		Signature:   hdr.Signature,
+		Length:      int(hdr.Length),
+	}
+}
+
+// MessageFlags are flags that modify the handling of a DBus message.
+type MessageFlags byte
+
+const (
+	// FlagNoReplyExpected indicates that the sender of a method call
+	// does not want a response.
+	FlagNoReplyExpected MessageFlags = MessageFlags(flagNoReplyExpected)
+	// FlagNoAutoStart indicates that the bus should not start the
+	// message's destination if it is not running.
+	FlagNoAutoStart MessageFlags = MessageFlags(flagNoAutoStart)
+	// FlagAllowInteractiveAuth indicates that the sender is willing
+	// to wait for interactive authorization of a method call.
+	FlagAllowInteractiveAuth MessageFlags = MessageFlags(flagAllowInteractiveAuthz)
+)
+
+// MessageHeader is the header of a DBus message.
+type MessageHeader struct {
+	// Order is the byte order of the message's wire encoding.
+	Order fragments.ByteOrder
+	// Type is the message's type.
+	Type MessageType
+	// Flags are the message's flags.
+	Flags MessageFlags
+	// Version is the message's DBus protocol version.
+	Version uint8
+	// Serial is the message's serial number.
+	Serial uint32
+
+	// Path is the target object of a call, or the source object of
+	// a signal.
+	Path ObjectPath
+	// Interface is the target interface of a call, or the source
+	// interface of a signal.
+	Interface string
+	// Member is the method name of a call, or the signal name of a
+	// signal.
+	Member string
+	// ErrName is the name of the error in an error message.
+	ErrName string
+	// ReplySerial is the serial of the message to which this message
+	// is replying.
+	ReplySerial uint32
+	// Destination is the bus name of the message's recipient.
+	Destination string
+	// Sender is the unique bus name of the message's sender.
+	Sender string
+	// Signature is the signature of the message's body.
+	Signature Signature
+	// NumFDs is the number of file descriptors that accompanied the
+	// message. The file descriptors themselves are not part of a
+	// Message.
+	NumFDs uint32
+
+	// UnknownHeaderFields are header fields with field codes that
+	// this package does not know, keyed by field code. Such fields
+	// can be added by future versions of the DBus specification, and
+	// are preserved so that they can be inspected and re-encoded.
+	UnknownHeaderFields map[byte]any
+}
+
+// newMessageHeader returns a MessageHeader with the same content as
+// hdr. The returned value shares no memory with hdr.
+func newMessageHeader(hdr *header, order fragments.ByteOrder) *MessageHeader {
+	return &MessageHeader{
+		Order:       order,
+		Type:        MessageType(hdr.Type),
+		Flags:       MessageFlags(hdr.Flags),
+		Version:     hdr.Version,
+		Serial:      hdr.Serial,
+		Path:        hdr.Path,
+		Interface:   hdr.Interface,
+		Member:      hdr.Member,
+		ErrName:     hdr.ErrName,
+		ReplySerial: hdr.ReplySerial,
+		Destination: hdr.Destination,
+		Sender:      hdr.Sender,
+		Signature:   hdr.Signature,
+		NumFDs:      hdr.NumFDs,
+
+		UnknownHeaderFields: maps.Clone(hdr.Unknown),
+	}
+}
+
+// Message is a raw DBus message.
+//
+// Message is a low-level representation of DBus wire traffic, for
+// use in tools that process DBus messages outside of a [Conn], such
+// as analyzers of captured bus traffic. Most users should use the
+// higher level APIs of [Conn] instead.
+type Message struct {
+	MessageHeader
+
+	// Body is the message's encoded body.
+	Body []byte
+}
+
+// DecodeBody decodes the message body into v, which must be a
+// pointer to a value whose DBus signature matches the message's
+// Signature.
+//
+// Decoding options such as [WithContextStrictBooleans] are read from
+// ctx.
+func (m *Message) DecodeBody(ctx context.Context, v any) error {
+	dec := fragments.Decoder{
+		Order:  m.Order,
+		Mapper: decoderFor,
+		In:     bytes.NewReader(m.Body),
+	}
+	return dec.Value(ctx, v)
+}
+
+// DecodeMessage reads one DBus message from r.
+//
+// DecodeMessage checks that the message's header is valid, but does
+// not validate the body. Use [Message.DecodeBody] to decode it. Method
+// calls without a destination are accepted, since they are valid on
+// peer-to-peer connections.
+//
+// If r is at end of input, DecodeMessage returns [io.EOF]. If r ends
+// partway through a message, the returned error wraps
+// [io.ErrUnexpectedEOF].
+func DecodeMessage(r io.Reader) (*Message, error) {
+	// Check for a clean end of input separately, because the decoder
+	// can't tell it apart from a truncated message.
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return nil, err
+	}
+	msg, err := decodeMsg(io.MultiReader(bytes.NewReader(first[:]), r))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if err := msg.valid(false); err != nil {
+		return nil, fmt.Errorf("invalid message header: %w", err)
+	}
+	return &Message{
+		MessageHeader: *newMessageHeader(&msg.header, msg.order),
+		Body:          msg.body,
+	}, nil
+}
+
+// EncodeMessage returns the wire encoding of m, using the given byte
+// order.
+//
+// m.Body must already be encoded in the given byte order. If m.Order
+// is set and differs from order, EncodeMessage returns an error
+// unless the body is empty. If m.Version is zero, the message is
+// encoded with the current DBus protocol version, 1. Like
+// [DecodeMessage], EncodeMessage accepts method calls without a
+// destination.
+func EncodeMessage(m *Message, order fragments.ByteOrder) ([]byte, error) {
+	if m.Order != nil && !sameByteOrder(m.Order, order) && len(m.Body) > 0 {
+		return nil, fmt.Errorf("message body is encoded in %s byte order, cannot encode message in %s", m.Order, order)
+	}
+	if len(m.Body) > maxMessageSize {
+		return nil, fmt.Errorf("message body length %d exceeds maximum of %d bytes", len(m.Body), maxMessageSize)
+	}
+	if m.Signature.IsZero() != (len(m.Body) == 0) {
+		return nil, errors.New("message must have both a body and a body signature, or neither")
+	}
+	hdr := header{
+		Type:        msgType(m.Type),
+		Flags:       byte(m.Flags),
+		Version:     cmp.Or(m.Version, 1),
+		Length:      uint32(len(m.Body)),
+		Serial:      m.Serial,
+		Path:        m.Path,
+		Interface:   m.Interface,
+		Member:      m.Member,
+		ErrName:     m.ErrName,
+		ReplySerial: m.ReplySerial,
+		Destination: m.Destination,
+		Sender:      m.Sender,
+		Signature:   m.Signature,
+		NumFDs:      m.NumFDs,
+		Unknown:     m.UnknownHeaderFields,
+	}
+	for code := range m.UnknownHeaderFields {
+		if code == 0 {
+			return nil, errors.New("unknown header field has code 0, which is invalid")
+		}
+		if code >= 1 && code <= 9 {
+			return nil, fmt.Errorf("unknown header field has code %d, which is a known header field", code)
+		}
+	}
+	if err := hdr.valid(false); err != nil {
+		return nil, fmt.Errorf("invalid message header: %w", err)
+	}
+	enc := fragments.Encoder{
+		Order:  order,
+		Mapper: encoderFor,
+	}
+	if err := enc.Value(context.Background(), &hdr); err != nil {
+		return nil, err
+	}
+	if n := len(enc.Out) + len(m.Body); n > maxMessageSize {
+		return nil, fmt.Errorf("message length %d exceeds maximum of %d bytes", n, maxMessageSize)
+	}
+	return append(enc.Out, m.Body...), nil
+}
+
+// maxMessageSize is the maximum size of a DBus message, including its
+// header, as set by the DBus specification. Messages that declare a
+// larger size are rejected before any of the body is read.
+const maxMessageSize = 1 << 27
+
+// decodeMsg reads a message header and body from r.
+func decodeMsg(r io.Reader) (*msg, error) {
+	// The size limit covers the whole message, so the header is read
+	// from the same budget as the body.
+	lr := &io.LimitedReader{R: r, N: maxMessageSize}
+	dec := fragments.Decoder{
+		Order:  fragments.NativeEndian,
+		Mapper: decoderFor,
+		In:     lr,
+	}
+	var ret msg
+	err := dec.Value(context.Background(), &ret.header)
+	if err != nil {
+		return nil, err
+	}
+	if int64(ret.header.Length) > lr.N {
+		hdrLen := maxMessageSize - lr.N
+		return nil, fmt.Errorf("message length %d exceeds maximum of %d bytes", hdrLen+int64(ret.header.Length), maxMessageSize)
+	}
+	ret.body, err = io.ReadAll(io.LimitReader(lr, int64(ret.header.Length)))
+	if err != nil {
+		return nil, err
+	}
+	if len(ret.body) < int(ret.header.Length) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	ret.order = dec.Order
+	return &ret, nil
+}
@@ -0,0 +1,315 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danderson/dbus/fragments"
+	"github.com/danderson/dbus/internal/transport"
+	"github.com/google/go-cmp/cmp"
+)
+
+// bufferTransport is a transport.Transport that captures writes.
+type bufferTransport struct{ bytes.Buffer }
+
+func (*bufferTransport) Close() error                       { return nil }
+func (*bufferTransport) GetFiles(n int) ([]*os.File, error) { return nil, nil }
+func (b *bufferTransport) WriteWithFiles(bs []byte, fs []*os.File) (int, error) {
+	return b.Write(bs)
+}
+func (*bufferTransport) PeerCredentials() (*transport.Credentials, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// encodeTestMsg returns the wire encoding of a message with the given
+// header and body, as written by a Conn.
+func encodeTestMsg(t *testing.T, order fragments.ByteOrder, hdr header, body any) []byte {
+	t.Helper()
+	var buf bufferTransport
+	c := &Conn{
+		t: &buf,
+		enc: fragments.Encoder{
+			Order:  order,
+			Mapper: encoderFor,
+		},
+	}
+	if err := c.writeMsg(context.Background(), &hdr, body); err != nil {
+		t.Fatalf("writeMsg failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeMessage(t *testing.T) {
+	hdr := header{
+		Type:        msgTypeCall,
+		Flags:       flagNoAutoStart,
+		Version:     1,
+		Serial:      42,
+		Path:        "/org/test/Object",
+		Interface:   "org.test.Interface",
+		Member:      "Method",
+		Destination: "org.test.Peer",
+		Sender:      ":1.2",
+		Unknown: map[uint8]any{
+			42:  "future",
+			200: uint32(7),
+		},
+	}
+	body := Simple{A: 42, B: true}
+
+	for _, order := range []fragments.ByteOrder{fragments.BigEndian, fragments.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			raw := encodeTestMsg(t, order, hdr, body)
+			// Two messages back to back, to check that DecodeMessage
+			// consumes exactly one message.
+			r := bytes.NewReader(append(raw, raw...))
+			for range 2 {
+				got, err := DecodeMessage(r)
+				if err != nil {
+					t.Fatalf("DecodeMessage failed: %v", err)
+				}
+				want := &Message{
+					MessageHeader: MessageHeader{
+						Order:       order,
+						Type:        MessageCall,
+						Flags:       FlagNoAutoStart,
+						Version:     1,
+						Serial:      42,
+						Path:        "/org/test/Object",
+						Interface:   "org.test.Interface",
+						Member:      "Method",
+						Destination: "org.test.Peer",
+						Sender:      ":1.2",
+						Signature:   mustParseSignature("nb"),
+						UnknownHeaderFields: map[byte]any{
+							42:  "future",
+							200: uint32(7),
+						},
+					},
+				}
+				gotBody := got.Body
+				got.Body = nil
+				if diff := cmp.Diff(got, want, cmp.Comparer(func(a, b fragments.ByteOrder) bool { return a == b })); diff != "" {
+					t.Errorf("DecodeMessage wrong result (-got+want):\n%s", diff)
+				}
+				got.Body = gotBody
+
+				var gotSimple Simple
+				if err := got.DecodeBody(context.Background(), &gotSimple); err != nil {
+					t.Fatalf("DecodeBody failed: %v", err)
+				}
+				if gotSimple != body {
+					t.Errorf("DecodeBody got %v, want %v", gotSimple, body)
+				}
+			}
+			if _, err := DecodeMessage(r); err != io.EOF {
+				t.Errorf("DecodeMessage at end of input got err %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestDecodeMessageErrors(t *testing.T) {
+	valid := encodeTestMsg(t, fragments.LittleEndian, header{
+		Type:        msgTypeReturn,
+		Version:     1,
+		Serial:      1,
+		ReplySerial: 1,
+	}, "foo")
+	noReplySerial := encodeTestMsg(t, fragments.LittleEndian, header{
+		Type:    msgTypeReturn,
+		Version: 1,
+		Serial:  1,
+	}, nil)
+
+	tooLarge := bytes.Clone(valid)
+	binary.LittleEndian.PutUint32(tooLarge[4:], maxMessageSize+1)
+	// The body alone fits, but not with the header in front of it.
+	tooLargeWithHeader := bytes.Clone(valid)
+	binary.LittleEndian.PutUint32(tooLargeWithHeader[4:], maxMessageSize)
+
+	tests := []struct {
+		name    string
+		in      []byte
+		wantErr string
+	}{
+		{"empty", nil, ""},
+		{"bad byte order", append([]byte{'x'}, valid[1:]...), ""},
+		{"truncated header", valid[:10], ""},
+		{"truncated body", valid[:len(valid)-1], ""},
+		{"invalid header", noReplySerial, ""},
+		{"body too large", tooLarge, "exceeds maximum"},
+		{"message too large", tooLargeWithHeader, "exceeds maximum"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeMessage(bytes.NewReader(tc.in))
+			if err == nil {
+				t.Fatalf("DecodeMessage succeeded, want error. Got: %+v", got)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("DecodeMessage got err %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageDecodeBodyContext(t *testing.T) {
+	m := &Message{
+		MessageHeader: MessageHeader{
+			Order:     fragments.LittleEndian,
+			Signature: mustParseSignature("b"),
+		},
+		Body: []byte{2, 0, 0, 0},
+	}
+
+	var got bool
+	if err := m.DecodeBody(context.Background(), &got); err != nil {
+		t.Fatalf("DecodeBody failed: %v", err)
+	}
+	if !got {
+		t.Error("DecodeBody decoded boolean 2 as false, want true")
+	}
+	strict := WithContextStrictBooleans(context.Background(), true)
+	if err := m.DecodeBody(strict, &got); err == nil {
+		t.Error("DecodeBody with WithContextStrictBooleans accepted boolean 2, want error")
+	}
+
+	m.Signature = mustParseSignature("s")
+	m.Body = []byte{1, 0, 0, 0, 0xff, 0}
+	var gotStr string
+	if err := m.DecodeBody(context.Background(), &gotStr); err == nil {
+		t.Errorf("DecodeBody accepted invalid UTF-8 %q, want error", gotStr)
+	}
+	lenient := WithContextStrictStrings(context.Background(), false)
+	if err := m.DecodeBody(lenient, &gotStr); err != nil {
+		t.Fatalf("DecodeBody with lenient strings failed: %v", err)
+	}
+	if want := "\xff"; gotStr != want {
+		t.Errorf("DecodeBody with lenient strings got %q, want %q", gotStr, want)
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	for _, order := range []fragments.ByteOrder{fragments.BigEndian, fragments.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			hdrs := []header{
+				{
+					Type:        msgTypeCall,
+					Flags:       flagNoReplyExpected,
+					Version:     1,
+					Serial:      42,
+					Path:        "/org/test/Object",
+					Interface:   "org.test.Interface",
+					Member:      "Method",
+					Destination: "org.test.Peer",
+				},
+				{
+					Type:        msgTypeError,
+					Version:     1,
+					Serial:      3,
+					ReplySerial: 2,
+					ErrName:     "org.test.Error",
+					Sender:      ":1.42",
+				},
+				{
+					Type:      msgTypeSignal,
+					Version:   1,
+					Serial:    7,
+					Path:      "/",
+					Interface: "org.test.Interface",
+					Member:    "Signal",
+					Unknown:   map[uint8]any{100: []string{"a", "b"}},
+				},
+				{
+					// Calls on peer-to-peer connections have no
+					// destination.
+					Type:      msgTypeCall,
+					Version:   1,
+					Serial:    8,
+					Path:      "/org/test/Object",
+					Interface: "org.test.Interface",
+					Member:    "Method",
+				},
+			}
+			bodies := []any{Simple{A: 1, B: true}, "oops", nil, uint32(42)}
+			for i, hdr := range hdrs {
+				// The Conn's encoding is the reference, EncodeMessage
+				// must produce identical bytes.
+				want := encodeTestMsg(t, order, hdr, bodies[i])
+				m, err := DecodeMessage(bytes.NewReader(want))
+				if err != nil {
+					t.Fatalf("DecodeMessage failed: %v", err)
+				}
+				got, err := EncodeMessage(m, order)
+				if err != nil {
+					t.Fatalf("EncodeMessage(%+v) failed: %v", m, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("EncodeMessage(%+v) wrong encoding:\n got: % x\nwant: % x", m, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeMessageErrors(t *testing.T) {
+	valid := Message{
+		MessageHeader: MessageHeader{
+			Type:        MessageReturn,
+			Serial:      2,
+			ReplySerial: 1,
+			Signature:   mustParseSignature("s"),
+			Order:       fragments.LittleEndian,
+		},
+		Body: []byte{1, 0, 0, 0, 'a', 0},
+	}
+	if _, err := EncodeMessage(&valid, fragments.LittleEndian); err != nil {
+		t.Fatalf("EncodeMessage(%+v) failed: %v", valid, err)
+	}
+	// Byte orders are compared by their wire encoding, so the native
+	// order matches whichever fixed order the machine uses.
+	native := fragments.LittleEndian
+	if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+		native = fragments.BigEndian
+	}
+	nativeMsg := valid
+	nativeMsg.Order = native
+	if native == fragments.BigEndian {
+		nativeMsg.Body = []byte{0, 0, 0, 1, 'a', 0}
+	}
+	if _, err := EncodeMessage(&nativeMsg, fragments.NativeEndian); err != nil {
+		t.Fatalf("EncodeMessage(%+v, NativeEndian) failed: %v", nativeMsg, err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Message)
+	}{
+		{"wrong byte order", func(m *Message) { m.Order = fragments.BigEndian }},
+		{"no signature", func(m *Message) { m.Signature = Signature{} }},
+		{"no body", func(m *Message) { m.Body = nil }},
+		{"no serial", func(m *Message) { m.Serial = 0 }},
+		{"no reply serial", func(m *Message) { m.ReplySerial = 0 }},
+		{"call without path", func(m *Message) { m.Type = MessageCall }},
+		{"unknown field with known code", func(m *Message) { m.UnknownHeaderFields = map[byte]any{6: "org.test.Dest"} }},
+		{"unknown field with invalid code", func(m *Message) { m.UnknownHeaderFields = map[byte]any{0: "invalid"} }},
+		{"unencodable unknown field", func(m *Message) { m.UnknownHeaderFields = map[byte]any{100: 42} }},
+		{"message too large", func(m *Message) { m.Body = make([]byte, maxMessageSize-8) }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := valid
+			tc.modify(&m)
+			if got, err := EncodeMessage(&m, fragments.LittleEndian); err == nil {
+				t.Errorf("EncodeMessage(%+v) = % x, want error", m, got)
+			}
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"iter"
 	"maps"
+	"slices"
+	"time"
 )
 
 // Object is an object exposed by a [Peer].
@@ -67,10 +70,13 @@ func (o Object) Child(path string) Object {
 // Introspect returns a [CallError] if the queried object does not
 // implement the [org.freedesktop.DBus.Introspectable] interface.
 //
+// If the Conn was created with [DialOptions.IntrospectionCacheTTL],
+// Introspect may return a cached description. See
+// [Object.InvalidateIntrospection].
+//
 // [org.freedesktop.DBus.Introspectable]: https://dbus.freedesktop.org/doc/dbus-specification.html#standard-interfaces-introspectable
 func (o Object) Introspect(ctx context.Context) (*ObjectDescription, error) {
-	var resp string
-	err := o.Interface(ifaceIntrospect).Call(ctx, "Introspect", nil, &resp)
+	resp, err := o.introspectXML(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +87,81 @@ func (o Object) Introspect(ctx context.Context) (*ObjectDescription, error) {
 	return &ret, nil
 }
 
+// introspectKey identifies an object in a Conn's introspection
+// cache.
+type introspectKey struct {
+	peer string
+	path ObjectPath
+}
+
+// cachedIntrospection is an entry in a Conn's introspection cache.
+type cachedIntrospection struct {
+	xml     string
+	expires time.Time
+}
+
+// introspectXML returns the object's raw introspection XML, from the
+// Conn's introspection cache if possible.
+//
+// The cache holds XML rather than parsed ObjectDescriptions, so that
+// callers of Introspect can't corrupt the cache by modifying the
+// description they get.
+func (o Object) introspectXML(ctx context.Context) (string, error) {
+	c := o.Conn()
+	key := introspectKey{o.p.Name(), o.path}
+	if c.introspections != nil {
+		if e, ok := c.introspections.Get(key); ok && time.Now().Before(e.expires) {
+			return e.xml, nil
+		}
+	}
+
+	var resp string
+	if err := o.Interface(ifaceIntrospect).Call(ctx, "Introspect", nil, &resp); err != nil {
+		return "", err
+	}
+
+	if c.introspections != nil {
+		c.introspections.Put(key, cachedIntrospection{
+			xml:     resp,
+			expires: time.Now().Add(c.introspectTTL),
+		})
+	}
+	return resp, nil
+}
+
+// InvalidateIntrospection discards the object's cached description,
+// if any, so that the next call to [Object.Introspect] queries the
+// object. It has no effect if the Conn doesn't cache introspection
+// results, see [DialOptions.IntrospectionCacheTTL].
+//
+// Only the object's own description is discarded. Cached
+// descriptions of its children are unaffected.
+func (o Object) InvalidateIntrospection() {
+	if c := o.Conn(); c.introspections != nil {
+		c.introspections.Remove(introspectKey{o.p.Name(), o.path})
+	}
+}
+
+// Interfaces returns an iterator over the descriptions of the
+// interfaces that the object implements, in name order.
+//
+// Interfaces introspects the object once, when iteration begins. If
+// introspection fails, the iterator yields only the error.
+func (o Object) Interfaces(ctx context.Context) iter.Seq2[*InterfaceDescription, error] {
+	return func(yield func(*InterfaceDescription, error) bool) {
+		desc, err := o.Introspect(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, name := range slices.Sorted(maps.Keys(desc.Interfaces)) {
+			if !yield(desc.Interfaces[name], nil) {
+				return
+			}
+		}
+	}
+}
+
 // ManagedObjects returns the children of the current Object, and the
 // interfaces they implement.
 //
@@ -1,6 +1,8 @@
 package dbus
 
 import (
+	"errors"
+	"fmt"
 	"path"
 	"strings"
 )
@@ -19,9 +21,43 @@ func (p ObjectPath) String() string {
 	return string(p.Clean())
 }
 
-// Valid reports whether p is a valid object path.
-func (p ObjectPath) Valid() bool {
-	return path.IsAbs(string(p.Clean()))
+// Valid reports whether p is a valid object path, as defined by the
+// DBus specification.
+//
+// A valid object path begins with a slash, and consists of zero or
+// more slash-separated elements. Each element must be non-empty, and
+// contain only the ASCII characters [A-Za-z0-9_]. Only the root path
+// "/" may end with a slash.
+//
+// Valid does not clean p prior to validation. Paths such as
+// "/foo//bar/" are invalid, even though [ObjectPath.Clean] would
+// convert them to a valid path.
+func (p ObjectPath) Valid() error {
+	s := string(p)
+	if s == "" {
+		return errors.New("invalid empty object path")
+	}
+	if s[0] != '/' {
+		return fmt.Errorf("invalid object path %q: must begin with /", s)
+	}
+	if s == "/" {
+		return nil
+	}
+	for _, elem := range strings.Split(s[1:], "/") {
+		if elem == "" {
+			return fmt.Errorf("invalid object path %q: empty path element", s)
+		}
+		for i := 0; i < len(elem); i++ {
+			if !isObjectPathChar(elem[i]) {
+				return fmt.Errorf("invalid object path %q: invalid character %q", s, elem[i])
+			}
+		}
+	}
+	return nil
+}
+
+func isObjectPathChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
 }
 
 // Child returns the object path at the given relative path from the
@@ -37,5 +73,5 @@ func (p ObjectPath) IsChildOf(parent ObjectPath) bool {
 	if len(sp) <= len(sparent) {
 		return false
 	}
-	return strings.HasPrefix(sp, sparent+"/")
+	return strings.HasPrefix(sp, strings.TrimSuffix(sparent, "/")+"/")
 }
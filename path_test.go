@@ -0,0 +1,71 @@
+package dbus
+
+import "testing"
+
+func TestObjectPathValid(t *testing.T) {
+	tests := []struct {
+		in    ObjectPath
+		valid bool
+	}{
+		{"/", true},
+		{"/foo", true},
+		{"/foo/bar", true},
+		{"/org/freedesktop/DBus", true},
+		{"/a_b/C9", true},
+
+		{"", false},
+		{"foo", false},
+		{"foo/bar", false},
+		{"//", false},
+		{"/foo/", false},
+		{"/foo//bar", false},
+		{"/foo-bar", false},
+		{"/foo.bar", false},
+		{"/foo/./bar", false},
+		{"/föö", false},
+	}
+
+	for _, tc := range tests {
+		err := tc.in.Valid()
+		if got := err == nil; got != tc.valid {
+			t.Errorf("ObjectPath(%q).Valid() = %v, want valid=%v", tc.in, err, tc.valid)
+		}
+	}
+}
+
+func FuzzObjectPathValid(f *testing.F) {
+	f.Add("/")
+	f.Add("/foo/bar")
+	f.Add("/foo//bar/")
+	f.Fuzz(func(t *testing.T, s string) {
+		p := ObjectPath(s)
+		if p.Valid() != nil {
+			return
+		}
+		// Valid paths must be unaffected by cleaning.
+		if got := p.Clean(); got != p {
+			t.Fatalf("valid path %q changed by Clean to %q", p, got)
+		}
+	})
+}
+
+func TestObjectPathIsChildOf(t *testing.T) {
+	tests := []struct {
+		p, parent ObjectPath
+		want      bool
+	}{
+		{"/foo", "/", true},
+		{"/foo/bar", "/", true},
+		{"/", "/", false},
+		{"/foo/bar", "/foo", true},
+		{"/foo", "/foo", false},
+		{"/foobar", "/foo", false},
+		{"/foo", "/foo/bar", false},
+	}
+
+	for _, tc := range tests {
+		if got := tc.p.IsChildOf(tc.parent); got != tc.want {
+			t.Errorf("ObjectPath(%q).IsChildOf(%q) = %v, want %v", tc.p, tc.parent, got, tc.want)
+		}
+	}
+}
@@ -4,7 +4,15 @@ import (
 	"bytes"
 	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net"
 	"os"
+	"strings"
+
+	"github.com/creachadair/mds/heapq"
+	"github.com/creachadair/mds/mapset"
 )
 
 // Peer is a named bus endpoint.
@@ -35,7 +43,53 @@ func (p Peer) String() string {
 //
 // [unique bus name]: https://dbus.freedesktop.org/doc/dbus-specification.html#message-bus-names
 func (p Peer) IsUniqueName() bool {
-	return p.name[0] == ':'
+	return strings.HasPrefix(p.name, ":")
+}
+
+// Valid reports whether the peer's name is a valid [bus name], as
+// defined by the DBus specification.
+//
+// A valid bus name is at most 255 bytes long, and consists of two or
+// more dot-separated elements. Each element must be non-empty, and
+// contain only the ASCII characters [A-Za-z0-9_-]. Unique names begin
+// with a colon, and their elements may begin with a digit. Well-known
+// names must not begin with a digit in any element.
+//
+// [bus name]: https://dbus.freedesktop.org/doc/dbus-specification.html#message-protocol-names-bus
+func (p Peer) Valid() error {
+	s := p.name
+	if s == "" {
+		return errors.New("invalid empty bus name")
+	}
+	if len(s) > 255 {
+		return fmt.Errorf("invalid bus name %q: longer than 255 bytes", s)
+	}
+	unique := p.IsUniqueName()
+	if unique {
+		s = s[1:]
+	}
+	elems := strings.Split(s, ".")
+	if len(elems) < 2 {
+		return fmt.Errorf("invalid bus name %q: must have at least two elements", p.name)
+	}
+	for _, elem := range elems {
+		if elem == "" {
+			return fmt.Errorf("invalid bus name %q: empty element", p.name)
+		}
+		if !unique && elem[0] >= '0' && elem[0] <= '9' {
+			return fmt.Errorf("invalid bus name %q: element %q begins with a digit", p.name, elem)
+		}
+		for i := 0; i < len(elem); i++ {
+			if !isBusNameChar(elem[i]) {
+				return fmt.Errorf("invalid bus name %q: invalid character %q", p.name, elem[i])
+			}
+		}
+	}
+	return nil
+}
+
+func isBusNameChar(c byte) bool {
+	return isObjectPathChar(c) || c == '-'
 }
 
 func (p Peer) Compare(other Peer) int {
@@ -56,6 +110,46 @@ func (p Peer) Object(path ObjectPath) Object {
 	}
 }
 
+// WalkObjects returns an iterator over the objects that the peer
+// exposes, found by recursively introspecting the peer's object tree
+// starting at the root object. Objects are visited in path order.
+//
+// If introspecting an object fails, the iterator yields the object
+// with the error, and skips the object's children. Otherwise, it
+// yields the object with a nil error. Use [Object.Introspect] or
+// [Object.Interfaces] to examine the yielded objects.
+//
+// Like [Object.Introspect], the walk relies on the object tree that
+// the peer describes, which may not accurately reflect the objects
+// that the peer actually implements.
+func (p Peer) WalkObjects(ctx context.Context) iter.Seq2[Object, error] {
+	return func(yield func(Object, error) bool) {
+		seen := mapset.New[ObjectPath]()
+		objs := heapq.New(Object.Compare)
+		objs.Add(p.Object("/"))
+		for !objs.IsEmpty() {
+			obj, _ := objs.Pop()
+			if seen.Has(obj.Path()) {
+				continue
+			}
+			seen.Add(obj.Path())
+			desc, err := obj.Introspect(ctx)
+			if err != nil {
+				if !yield(obj, fmt.Errorf("introspecting %s: %w", obj, err)) {
+					return
+				}
+				continue
+			}
+			for _, child := range desc.Children {
+				objs.Add(obj.Child(child))
+			}
+			if !yield(obj, nil) {
+				return
+			}
+		}
+	}
+}
+
 // Ping checks that the peer is reachable.
 //
 // Ping returns a [CallError] if the queried peer does not implement
@@ -68,6 +162,33 @@ func (p Peer) Ping(ctx context.Context) error {
 	return p.Object("/").Interface(ifacePeer).Call(ctx, "Ping", nil, nil)
 }
 
+// MachineID returns the ID of the machine on which the peer is
+// running.
+//
+// Peers on the same machine report the same machine ID, so comparing
+// machine IDs is a way to detect peers that share a machine. The
+// local machine's ID is conventionally stored in /etc/machine-id.
+//
+// The machine ID of a peer with a unique name never changes, so the
+// result is cached by the Conn. Well-known names can move between
+// peers on different machines, and are queried on every call.
+func (p Peer) MachineID(ctx context.Context) (string, error) {
+	cacheable := p.IsUniqueName()
+	if cacheable {
+		if id, ok := p.Conn().machineIDs.Get(p.name); ok {
+			return id, nil
+		}
+	}
+	var id string
+	if err := p.Object("/").Interface(ifacePeer).Call(ctx, "GetMachineId", nil, &id); err != nil {
+		return "", err
+	}
+	if cacheable {
+		p.Conn().machineIDs.Put(p.name, id)
+	}
+	return id, nil
+}
+
 // PeerIdentity describes the identity of a Peer.
 type PeerIdentity struct {
 	// UID is the Unix user ID of the peer, or nil if uid information is
@@ -78,6 +199,10 @@ type PeerIdentity struct {
 	// PIDFD is a file handle that represents the Peer's
 	// process. PIDFD should be preferred over PID, as it is not
 	// vulnerable to time-of-check/time-of-use vulnerabilities.
+	//
+	// PIDFD is owned by the caller of [Peer.Identity], which must
+	// close it when it is no longer needed, for example with
+	// [PeerIdentity.Close].
 	PIDFD *os.File `dbus:"key=ProcessFD"`
 	// PID is the Unix process ID of the peer, or nil if pid
 	// information is not available. Note that PIDs are not unique
@@ -98,6 +223,11 @@ type PeerIdentity struct {
 	//
 	// On AppArmor systems, SecurityLabel is the AppArmor profile name
 	// and enforcement mode.
+	//
+	// The bus reports the label with a trailing NUL byte, which
+	// [Peer.Identity] removes. Use [PeerIdentity.SecurityModule],
+	// [PeerIdentity.SELinuxContext] and
+	// [PeerIdentity.AppArmorProfile] to interpret the label.
 	SecurityLabel []byte `dbus:"key=LinuxSecurityLabel"`
 
 	// Unknown collects identity values provided by the bus that are
@@ -109,9 +239,17 @@ type PeerIdentity struct {
 //
 // The returned identity is provided by the bus itself, and guaranteed
 // to be accurate (bugs in the bus implementation notwithstanding).
+//
+// Identity uses the bus's GetConnectionCredentials method. On older
+// buses that do not implement it, Identity falls back to querying
+// the peer's UID and PID individually, and returns an identity with
+// only those fields that the bus was able to provide.
 func (p Peer) Identity(ctx context.Context) (PeerIdentity, error) {
 	var resp PeerIdentity
-	if err := p.Conn().bus.Interface(ifaceBus).Call(ctx, "GetConnectionCredentials", p.name, &resp); err != nil {
+	err := p.Conn().bus.Interface(ifaceBus).Call(ctx, "GetConnectionCredentials", p.name, &resp)
+	if errors.Is(err, ErrUnknownMethod) {
+		return p.legacyIdentity(ctx)
+	} else if err != nil {
 		return PeerIdentity{}, err
 	}
 	// The SELinux security context is reported with a trailing null
@@ -120,6 +258,176 @@ func (p Peer) Identity(ctx context.Context) (PeerIdentity, error) {
 	return resp, nil
 }
 
+// RemoteIdentity returns the identity of the process at the other end
+// of the Conn's socket, as recorded by the operating system when the
+// connection was established.
+//
+// For connections to a message bus, the remote process is the bus
+// itself, not any particular peer; use [Peer.Identity] to get the
+// identity of bus peers. For peer-to-peer connections, the remote
+// process is the peer.
+//
+// Only the UID, PID, primary group ID and security label are
+// available. RemoteIdentity returns an error wrapping
+// [errors.ErrUnsupported] if the Conn's transport cannot report
+// credentials, such as for Conns created with [Connect] over a
+// connection that is not a *net.UnixConn.
+func (c *Conn) RemoteIdentity() (PeerIdentity, error) {
+	creds, err := c.t.PeerCredentials()
+	if err != nil {
+		return PeerIdentity{}, err
+	}
+	return PeerIdentity{
+		UID:           &creds.UID,
+		GIDs:          []uint32{creds.GID},
+		PID:           &creds.PID,
+		SecurityLabel: creds.SecurityLabel,
+	}, nil
+}
+
+// legacyIdentity constructs a PeerIdentity using the individual
+// credential methods that predate GetConnectionCredentials.
+//
+// The bus reports an error for credentials that it cannot determine,
+// so a failed query only leaves its field unset. legacyIdentity
+// returns an error only if the peer doesn't exist, or if no
+// credentials at all are available.
+func (p Peer) legacyIdentity(ctx context.Context) (PeerIdentity, error) {
+	bus := p.Conn().bus.Interface(ifaceBus)
+	var (
+		ret      PeerIdentity
+		firstErr error
+	)
+	for _, q := range []struct {
+		method string
+		field  **uint32
+	}{
+		{"GetConnectionUnixUser", &ret.UID},
+		{"GetConnectionUnixProcessID", &ret.PID},
+	} {
+		var v uint32
+		err := bus.Call(ctx, q.method, p.name, &v)
+		if err == nil {
+			*q.field = &v
+			continue
+		}
+		if errors.Is(err, ErrNameHasNoOwner) || ctx.Err() != nil {
+			return PeerIdentity{}, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if ret.UID == nil && ret.PID == nil {
+		return PeerIdentity{}, firstErr
+	}
+	return ret, nil
+}
+
+// Close closes the identity's PIDFD, if any.
+func (id *PeerIdentity) Close() error {
+	if id.PIDFD == nil {
+		return nil
+	}
+	err := id.PIDFD.Close()
+	id.PIDFD = nil
+	return err
+}
+
+// SecurityModule is a Linux Security Module that can provide a peer's
+// security label.
+type SecurityModule uint8
+
+const (
+	// SecurityModuleUnknown indicates that the security label's
+	// format was not recognized, or that there is no security label.
+	SecurityModuleUnknown SecurityModule = iota
+	// SecurityModuleSELinux is the SELinux security module.
+	SecurityModuleSELinux
+	// SecurityModuleAppArmor is the AppArmor security module.
+	SecurityModuleAppArmor
+)
+
+func (m SecurityModule) String() string {
+	switch m {
+	case SecurityModuleUnknown:
+		return "Unknown"
+	case SecurityModuleSELinux:
+		return "SELinux"
+	case SecurityModuleAppArmor:
+		return "AppArmor"
+	default:
+		return fmt.Sprintf("SecurityModule(%d)", uint8(m))
+	}
+}
+
+// SecurityModule returns the security module that most likely
+// produced the identity's SecurityLabel.
+//
+// DBus does not say which security module provided the label, so
+// SecurityModule guesses based on the label's format. AppArmor labels
+// are "unconfined", or a profile name followed by a parenthesized
+// mode, such as "/usr/bin/foo (enforce)". SELinux labels are contexts
+// of at least three colon-separated fields, such as
+// "system_u:system_r:init_t:s0".
+func (id PeerIdentity) SecurityModule() SecurityModule {
+	if _, _, ok := parseAppArmorLabel(id.SecurityLabel); ok {
+		return SecurityModuleAppArmor
+	}
+	if _, ok := parseSELinuxLabel(id.SecurityLabel); ok {
+		return SecurityModuleSELinux
+	}
+	return SecurityModuleUnknown
+}
+
+// SELinuxContext returns the peer's SELinux security context, and
+// reports whether the identity's SecurityLabel is an SELinux context.
+func (id PeerIdentity) SELinuxContext() (string, bool) {
+	if id.SecurityModule() != SecurityModuleSELinux {
+		return "", false
+	}
+	return parseSELinuxLabel(id.SecurityLabel)
+}
+
+// AppArmorProfile returns the peer's AppArmor profile name and
+// enforcement mode, and reports whether the identity's SecurityLabel
+// is an AppArmor label.
+//
+// Unconfined peers have the profile "unconfined" and an empty mode.
+func (id PeerIdentity) AppArmorProfile() (profile, mode string, ok bool) {
+	return parseAppArmorLabel(id.SecurityLabel)
+}
+
+func parseSELinuxLabel(label []byte) (string, bool) {
+	s := string(label)
+	fields := strings.SplitN(s, ":", 4)
+	if len(fields) < 3 {
+		return "", false
+	}
+	for _, f := range fields[:3] {
+		if f == "" || strings.ContainsAny(f, " \t") {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+func parseAppArmorLabel(label []byte) (profile, mode string, ok bool) {
+	s := string(label)
+	if s == "unconfined" {
+		return s, "", true
+	}
+	profile, mode, ok = strings.Cut(s, " (")
+	if !ok || profile == "" || !strings.HasSuffix(mode, ")") {
+		return "", "", false
+	}
+	mode = strings.TrimSuffix(mode, ")")
+	if mode == "" || strings.ContainsAny(mode, " ()") {
+		return "", "", false
+	}
+	return profile, mode, true
+}
+
 // UID returns the Unix user ID for the peer, if available.
 //
 // Deprecated: use [Peer.Identity] instead, which returns more
@@ -170,6 +478,37 @@ func (p Peer) Exists(ctx context.Context) (bool, error) {
 	return exists, nil
 }
 
+// StartService starts the peer's activatable service, and reports
+// whether the service was newly started.
+//
+// If the service already owns the peer's name, StartService returns
+// false. Otherwise, the bus starts the service, and StartService
+// returns true once the service has claimed the peer's name.
+// StartService returns an error if the peer is not activatable, or
+// if the service fails to start.
+//
+// StartService is rarely needed, since sending a request to an
+// activatable peer starts it automatically.
+func (p Peer) StartService(ctx context.Context) (started bool, err error) {
+	var req struct {
+		Name  string
+		Flags uint32 // unused, must be zero
+	}
+	req.Name = p.name
+	var resp uint32
+	if err := p.Conn().bus.Interface(ifaceBus).Call(ctx, "StartServiceByName", req, &resp); err != nil {
+		return false, err
+	}
+	switch resp {
+	case 1: // DBUS_START_REPLY_SUCCESS
+		return true, nil
+	case 2: // DBUS_START_REPLY_ALREADY_RUNNING
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown StartServiceByName response %d", resp)
+	}
+}
+
 // Owner returns the current owner of this peer's name.
 //
 // If the Peer is a handle to a peer's unique connection name (like
@@ -184,6 +523,47 @@ func (p Peer) Owner(ctx context.Context) (Peer, error) {
 	return p.Conn().Peer(name), nil
 }
 
+// WaitForOwner waits until the peer's name has an owner, and returns
+// the owner's unique connection name as a Peer.
+//
+// If the name already has an owner, WaitForOwner returns
+// immediately. Otherwise, it waits for an owner to appear, or for ctx
+// to be canceled. Errors other than the name having no owner, such
+// as the bus rejecting the name as invalid, are returned
+// immediately.
+func (p Peer) WaitForOwner(ctx context.Context) (Peer, error) {
+	if owner, err := p.Owner(ctx); err == nil {
+		return owner, nil
+	} else if !errors.Is(err, ErrNameHasNoOwner) {
+		return Peer{}, err
+	}
+
+	// WatchOwner reports the current owner after subscribing to
+	// ownership changes, which takes care of the name acquiring an
+	// owner between the call above and now.
+	owners, cancel, err := p.Conn().WatchOwner(ctx, p.name)
+	if err != nil {
+		return Peer{}, err
+	}
+	defer cancel()
+	for {
+		select {
+		case owner, ok := <-owners:
+			if !ok {
+				if err := ctx.Err(); err != nil {
+					return Peer{}, err
+				}
+				return Peer{}, net.ErrClosed
+			}
+			if owner != nil {
+				return *owner, nil
+			}
+		case <-ctx.Done():
+			return Peer{}, ctx.Err()
+		}
+	}
+}
+
 // QueuedOwners returns the list of peers that have requested
 // ownership of this peer's name.
 //
@@ -0,0 +1,181 @@
+package dbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPeerValid(t *testing.T) {
+	var conn *Conn
+
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"org.freedesktop.DBus", true},
+		{"com.example.foo-bar", true},
+		{"com.example._private", true},
+		{"a.b", true},
+		{":1.42", true},
+		{":1.2.3", true},
+		{":abc.def", true},
+
+		{"", false},
+		{"org", false},
+		{":1", false},
+		{".org.example", false},
+		{"org.example.", false},
+		{"org..example", false},
+		{"org.1example", false},
+		{"org.example/foo", false},
+		{"org.exämple", false},
+		{"org.example" + strings.Repeat(".a", 123), false},
+	}
+
+	for _, tc := range tests {
+		err := conn.Peer(tc.name).Valid()
+		if tc.valid && err != nil {
+			t.Errorf("Peer(%q).Valid() = %v, want nil", tc.name, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("Peer(%q).Valid() = nil, want error", tc.name)
+		}
+	}
+}
+
+func TestPeerIdentitySecurityLabel(t *testing.T) {
+	tests := []struct {
+		label       string
+		want        SecurityModule
+		wantContext string
+		wantProfile string
+		wantMode    string
+	}{
+		{"", SecurityModuleUnknown, "", "", ""},
+		{"_", SecurityModuleUnknown, "", "", ""},
+		{"system_u:system_r:init_t:s0", SecurityModuleSELinux, "system_u:system_r:init_t:s0", "", ""},
+		{"unconfined_u:unconfined_r:unconfined_t:s0-s0:c0.c1023", SecurityModuleSELinux, "unconfined_u:unconfined_r:unconfined_t:s0-s0:c0.c1023", "", ""},
+		{"user_u:user_r:user_t", SecurityModuleSELinux, "user_u:user_r:user_t", "", ""},
+		{"unconfined", SecurityModuleAppArmor, "", "unconfined", ""},
+		{"/usr/sbin/cupsd (enforce)", SecurityModuleAppArmor, "", "/usr/sbin/cupsd", "enforce"},
+		{"snap.foo.bar (complain)", SecurityModuleAppArmor, "", "snap.foo.bar", "complain"},
+		{"a:b", SecurityModuleUnknown, "", "", ""},
+		{"foo (bar", SecurityModuleUnknown, "", "", ""},
+		{" (enforce)", SecurityModuleUnknown, "", "", ""},
+	}
+
+	for _, tc := range tests {
+		id := PeerIdentity{SecurityLabel: []byte(tc.label)}
+		if got := id.SecurityModule(); got != tc.want {
+			t.Errorf("SecurityModule() of %q = %s, want %s", tc.label, got, tc.want)
+		}
+		ctx, ok := id.SELinuxContext()
+		if ok != (tc.want == SecurityModuleSELinux) || ctx != tc.wantContext {
+			t.Errorf("SELinuxContext() of %q = %q, %v, want %q", tc.label, ctx, ok, tc.wantContext)
+		}
+		profile, mode, ok := id.AppArmorProfile()
+		if ok != (tc.want == SecurityModuleAppArmor) || profile != tc.wantProfile || mode != tc.wantMode {
+			t.Errorf("AppArmorProfile() of %q = %q, %q, %v, want %q, %q", tc.label, profile, mode, ok, tc.wantProfile, tc.wantMode)
+		}
+	}
+}
+
+func TestPeerIdentityFallback(t *testing.T) {
+	client, bus := newPipeConns(t)
+
+	// The bus doesn't implement GetConnectionCredentials, so Identity
+	// has to fall back to the older per-credential methods.
+	bus.Handle(ifaceBus, "GetConnectionUnixUser", func(_ context.Context, _ ObjectPath, name string) (uint32, error) {
+		switch name {
+		case ":1.1", ":1.2":
+			return 1000, nil
+		case ":1.3":
+			return 0, CallError{Name: ErrFailed.Name, Detail: "uid unknown"}
+		default:
+			return 0, CallError{Name: ErrNameHasNoOwner.Name, Detail: "no such name"}
+		}
+	})
+	bus.Handle(ifaceBus, "GetConnectionUnixProcessID", func(_ context.Context, _ ObjectPath, name string) (uint32, error) {
+		switch name {
+		case ":1.1", ":1.3":
+			return 42, nil
+		default:
+			return 0, CallError{Name: ErrFailed.Name, Detail: "pid unknown"}
+		}
+	})
+
+	ptr := func(v uint32) *uint32 { return &v }
+	tests := []struct {
+		name    string
+		wantUID *uint32
+		wantPID *uint32
+		wantErr error
+	}{
+		{":1.1", ptr(1000), ptr(42), nil},
+		{":1.2", ptr(1000), nil, nil},
+		{":1.3", nil, ptr(42), nil},
+		{":1.4", nil, nil, ErrNameHasNoOwner},
+	}
+	ctx := context.Background()
+	for _, tc := range tests {
+		got, err := client.Peer(tc.name).Identity(ctx)
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Identity(%s) got err %v, want %v", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Identity(%s) failed: %v", tc.name, err)
+			continue
+		}
+		eq := func(a, b *uint32) bool {
+			return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+		}
+		if !eq(got.UID, tc.wantUID) || !eq(got.PID, tc.wantPID) {
+			t.Errorf("Identity(%s) = %+v, want UID %v PID %v", tc.name, got, tc.wantUID, tc.wantPID)
+		}
+		if got.GIDs != nil || got.PIDFD != nil || got.SecurityLabel != nil || got.Unknown != nil {
+			t.Errorf("Identity(%s) has unexpected fields set: %+v", tc.name, got)
+		}
+	}
+}
+
+func TestWaitForOwnerError(t *testing.T) {
+	client, bus := newPipeConns(t)
+
+	bus.Handle(ifaceBus, "GetNameOwner", func(_ context.Context, _ ObjectPath, name string) (string, error) {
+		if name == "org.test.Denied" {
+			return "", CallError{Name: ErrAccessDenied.Name, Detail: "not allowed"}
+		}
+		return "", CallError{Name: ErrNameHasNoOwner.Name, Detail: "no owner"}
+	})
+	var matches atomic.Int32
+	bus.Handle(ifaceBus, "AddMatch", func(context.Context, ObjectPath, string) error {
+		matches.Add(1)
+		return nil
+	})
+	bus.Handle(ifaceBus, "RemoveMatch", func(context.Context, ObjectPath, string) error { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if owner, err := client.Peer("org.test.Denied").WaitForOwner(ctx); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("WaitForOwner() = %s, %v, want ErrAccessDenied", owner, err)
+	}
+	if n := matches.Load(); n != 0 {
+		t.Errorf("WaitForOwner() added %d matches after a bus error, want none", n)
+	}
+
+	// Names without an owner keep waiting.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if owner, err := client.Peer("org.test.Unowned").WaitForOwner(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForOwner() = %s, %v, want context.DeadlineExceeded", owner, err)
+	}
+	if n := matches.Load(); n != 1 {
+		t.Errorf("WaitForOwner() added %d matches while waiting, want 1", n)
+	}
+}
@@ -6,17 +6,17 @@ import (
 )
 
 // alignAsStruct reports whether t aligns like a DBus struct, i.e. to
-// 8 byte boundaries.
+// 8 byte boundaries. This is true of structs, but also of 64-bit
+// numbers, and of inlined structs whose first field is either of
+// those.
+//
+// Arrays of such types have padding between the array length and the
+// first element, which is not counted in the array length.
 func alignAsStruct(t reflect.Type) bool {
-	t = derefType(t)
-	if t.Kind() != reflect.Struct {
-		return false
-	}
-	fs, err := getStructInfo(t)
-	if err != nil {
-		panic(err)
-	}
-	return !fs.NoPad
+	// Types without a valid signature fail elsewhere, with a more
+	// useful error.
+	sig, err := signatureFor(t, nil)
+	return err == nil && sig.Alignment() == 8
 }
 
 func derefType(t reflect.Type) reflect.Type {
@@ -19,50 +19,128 @@ var (
 // RegisterPropertyChangeType registers T as the type to use when
 // reporting a property change for the given property.
 //
-// Panics if the property already has a registered type.
+// RegisterPropertyChangeType panics if T is not a valid DBus type,
+// if the property already has a registered type, or if T is already
+// registered for another property. Use [TryRegisterPropertyChangeType]
+// to get an error instead, and [UnregisterPropertyChangeType] to
+// remove an existing registration.
 func RegisterPropertyChangeType[T any](interfaceName, propertyName string) {
+	if err := TryRegisterPropertyChangeType[T](interfaceName, propertyName); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegisterPropertyChangeType is like [RegisterPropertyChangeType],
+// but returns an error instead of panicking.
+func TryRegisterPropertyChangeType[T any](interfaceName, propertyName string) error {
 	k := interfaceMember{interfaceName, propertyName}
 	t := reflect.TypeFor[T]()
 	if _, err := SignatureFor[T](); err != nil {
-		panic(fmt.Errorf("cannot use %s as dbus type for property change %s: %w", t, k, err))
+		return fmt.Errorf("cannot use %s as dbus type for property change %s: %w", t, k, err)
 	}
 
 	signalsMu.Lock()
 	defer signalsMu.Unlock()
 	if prev := propNameToType[k]; prev != nil {
-		panic(fmt.Errorf("duplicate property change type registration for %s, existing registration %s", k, prev))
+		return fmt.Errorf("duplicate property change type registration for %s, existing registration %s", k, prev)
 	}
 	if prev, ok := propTypeToName[t]; ok {
-		panic(fmt.Errorf("duplicate property change type registration for %s, already in use by %s", t, prev))
+		return fmt.Errorf("duplicate property change type registration for %s, already in use by %s", t, prev)
 	}
 	propNameToType[k] = t
 	propTypeToName[t] = k
+	return nil
 }
 
 // RegisterSignalType registers T as the struct type to use when
 // decoding the body of the given signal name.
 //
-// Panics if the signal already has a registered type.
+// RegisterSignalType panics if T is not a struct, if T is not a
+// valid DBus type, if the signal already has a registered type, or if
+// T is already registered for another signal. Use
+// [TryRegisterSignalType] to get an error instead, and
+// [UnregisterSignalType] to remove an existing registration.
 func RegisterSignalType[T any](interfaceName, signalName string) {
+	if err := TryRegisterSignalType[T](interfaceName, signalName); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegisterSignalType is like [RegisterSignalType], but returns an
+// error instead of panicking.
+func TryRegisterSignalType[T any](interfaceName, signalName string) error {
 	k := interfaceMember{interfaceName, signalName}
 	t := reflect.TypeFor[T]()
 	if t.Kind() != reflect.Struct {
-		panic(fmt.Errorf("cannot use type %s (%s) as the payload type for signal %s.%s, signal payloads must be structs", t, t.Kind(), k.Interface, k.Member))
+		return fmt.Errorf("cannot use type %s (%s) as the payload type for signal %s.%s, signal payloads must be structs", t, t.Kind(), k.Interface, k.Member)
 	}
-	if _, err := SignatureFor[T](); err != nil {
-		panic(fmt.Errorf("cannot use %s as dbus type for signal %s.%s: %w", t, k.Interface, k.Member, err))
+	if _, err := bodySignatureFor(t); err != nil {
+		return fmt.Errorf("cannot use %s as dbus type for signal %s.%s: %w", t, k.Interface, k.Member, err)
 	}
 
 	signalsMu.Lock()
 	defer signalsMu.Unlock()
 	if prev := signalNameToType[k]; prev != nil {
-		panic(fmt.Errorf("duplicate signal type registration for %s, existing registration %s", k, prev))
+		return fmt.Errorf("duplicate signal type registration for %s, existing registration %s", k, prev)
 	}
 	if prev, ok := signalTypeToName[t]; ok {
-		panic(fmt.Errorf("duplicate signal type registration for %s, already in use by %s", t, prev))
+		return fmt.Errorf("duplicate signal type registration for %s, already in use by %s", t, prev)
 	}
 	signalNameToType[k] = t
 	signalTypeToName[t] = k
+	return nil
+}
+
+// UnregisterSignalType removes the type registration for the given
+// signal, if any, and reports whether a registration was removed.
+//
+// Registrations are not replaced implicitly: registering a second
+// type for the same signal is an error. To override a registration,
+// unregister the existing type first.
+func UnregisterSignalType(interfaceName, signalName string) bool {
+	k := interfaceMember{interfaceName, signalName}
+	signalsMu.Lock()
+	defer signalsMu.Unlock()
+	t := signalNameToType[k]
+	if t == nil {
+		return false
+	}
+	delete(signalNameToType, k)
+	delete(signalTypeToName, t)
+	return true
+}
+
+// UnregisterPropertyChangeType removes the type registration for the
+// given property, if any, and reports whether a registration was
+// removed.
+//
+// As with [UnregisterSignalType], overriding a registration requires
+// unregistering the existing type first.
+func UnregisterPropertyChangeType(interfaceName, propertyName string) bool {
+	k := interfaceMember{interfaceName, propertyName}
+	signalsMu.Lock()
+	defer signalsMu.Unlock()
+	t := propNameToType[k]
+	if t == nil {
+		return false
+	}
+	delete(propNameToType, k)
+	delete(propTypeToName, t)
+	return true
+}
+
+// SignalTypeFor returns the type registered with
+// [RegisterSignalType] for the given signal, if any.
+func SignalTypeFor(interfaceName, signalName string) (reflect.Type, bool) {
+	t := signalTypeFor(interfaceName, signalName)
+	return t, t != nil
+}
+
+// PropertyChangeTypeFor returns the type registered with
+// [RegisterPropertyChangeType] for the given property, if any.
+func PropertyChangeTypeFor(interfaceName, propertyName string) (reflect.Type, bool) {
+	t := propTypeFor(interfaceName, propertyName)
+	return t, t != nil
 }
 
 func signalNameFor(t reflect.Type) (interfaceMember, bool) {
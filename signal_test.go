@@ -0,0 +1,102 @@
+package dbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+type registrySignal struct{ A string }
+type registryProp string
+
+func TestTryRegister(t *testing.T) {
+	// Registrations are global, clean up all of them so that the
+	// test can run repeatedly. Unregistering names that failed to
+	// register is a no-op.
+	t.Cleanup(func() {
+		for _, name := range []string{"Signal", "Other", "NotStruct", "BadType"} {
+			UnregisterSignalType("org.test.Registry", name)
+		}
+		for _, name := range []string{"Prop", "BadProp"} {
+			UnregisterPropertyChangeType("org.test.Registry", name)
+		}
+	})
+
+	if err := TryRegisterSignalType[registrySignal]("org.test.Registry", "Signal"); err != nil {
+		t.Fatalf("TryRegisterSignalType failed: %v", err)
+	}
+	if err := TryRegisterSignalType[registrySignal]("org.test.Registry", "Signal"); err == nil {
+		t.Error("duplicate TryRegisterSignalType succeeded")
+	}
+	if err := TryRegisterSignalType[registrySignal]("org.test.Registry", "Other"); err == nil {
+		t.Error("TryRegisterSignalType reusing a registered type succeeded")
+	}
+	if err := TryRegisterSignalType[string]("org.test.Registry", "NotStruct"); err == nil {
+		t.Error("TryRegisterSignalType with non-struct type succeeded")
+	}
+	if err := TryRegisterSignalType[struct{ A int }]("org.test.Registry", "BadType"); err == nil {
+		t.Error("TryRegisterSignalType with invalid DBus type succeeded")
+	}
+
+	if got, ok := SignalTypeFor("org.test.Registry", "Signal"); !ok || got != reflect.TypeFor[registrySignal]() {
+		t.Errorf("SignalTypeFor(Signal) = %v, %v, want %v, true", got, ok, reflect.TypeFor[registrySignal]())
+	}
+	if got, ok := SignalTypeFor("org.test.Registry", "NotStruct"); ok {
+		t.Errorf("SignalTypeFor(NotStruct) = %v, true, want nil, false", got)
+	}
+
+	if err := TryRegisterPropertyChangeType[registryProp]("org.test.Registry", "Prop"); err != nil {
+		t.Fatalf("TryRegisterPropertyChangeType failed: %v", err)
+	}
+	if err := TryRegisterPropertyChangeType[registryProp]("org.test.Registry", "Prop"); err == nil {
+		t.Error("duplicate TryRegisterPropertyChangeType succeeded")
+	}
+	if err := TryRegisterPropertyChangeType[int]("org.test.Registry", "BadProp"); err == nil {
+		t.Error("TryRegisterPropertyChangeType with invalid DBus type succeeded")
+	}
+	if got, ok := PropertyChangeTypeFor("org.test.Registry", "Prop"); !ok || got != reflect.TypeFor[registryProp]() {
+		t.Errorf("PropertyChangeTypeFor(Prop) = %v, %v, want %v, true", got, ok, reflect.TypeFor[registryProp]())
+	}
+}
+
+type overrideSignal struct{ A string }
+type overrideSignal2 struct{ A, B string }
+type overrideProp string
+
+func TestUnregister(t *testing.T) {
+	t.Cleanup(func() {
+		UnregisterSignalType("org.test.Unregister", "Signal")
+		UnregisterPropertyChangeType("org.test.Unregister", "Prop")
+		UnregisterPropertyChangeType("org.test.Unregister", "Prop2")
+	})
+
+	RegisterSignalType[overrideSignal]("org.test.Unregister", "Signal")
+	if err := TryRegisterSignalType[overrideSignal2]("org.test.Unregister", "Signal"); err == nil {
+		t.Fatal("registering a second type for a signal succeeded")
+	}
+	if !UnregisterSignalType("org.test.Unregister", "Signal") {
+		t.Fatal("UnregisterSignalType of registered signal returned false")
+	}
+	if UnregisterSignalType("org.test.Unregister", "Signal") {
+		t.Fatal("UnregisterSignalType of unregistered signal returned true")
+	}
+	if _, ok := signalNameFor(reflect.TypeFor[overrideSignal]()); ok {
+		t.Fatal("UnregisterSignalType did not remove type mapping")
+	}
+	if err := TryRegisterSignalType[overrideSignal2]("org.test.Unregister", "Signal"); err != nil {
+		t.Fatalf("registering override after unregister failed: %v", err)
+	}
+	if got, _ := SignalTypeFor("org.test.Unregister", "Signal"); got != reflect.TypeFor[overrideSignal2]() {
+		t.Fatalf("SignalTypeFor after override = %v, want %v", got, reflect.TypeFor[overrideSignal2]())
+	}
+
+	RegisterPropertyChangeType[overrideProp]("org.test.Unregister", "Prop")
+	if !UnregisterPropertyChangeType("org.test.Unregister", "Prop") {
+		t.Fatal("UnregisterPropertyChangeType of registered property returned false")
+	}
+	if _, ok := PropertyChangeTypeFor("org.test.Unregister", "Prop"); ok {
+		t.Fatal("PropertyChangeTypeFor found unregistered property")
+	}
+	if err := TryRegisterPropertyChangeType[overrideProp]("org.test.Unregister", "Prop2"); err != nil {
+		t.Fatalf("reusing type after unregister failed: %v", err)
+	}
+}
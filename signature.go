@@ -1,12 +1,16 @@
 package dbus
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"os"
 	"reflect"
 	"slices"
 	"strings"
+
+	lru "github.com/creachadair/mds/cache"
+	"github.com/danderson/dbus/fragments"
 )
 
 // A Signature describes the type of a DBus value.
@@ -35,7 +39,11 @@ func (s Signature) asStruct() Signature {
 			Type: s.typ,
 		},
 	})
-	return Signature{ret, "(" + s.str + ")"}
+	sig := Signature{ret, "(" + s.str + ")"}
+	if isWireType(s.typ) {
+		wireTypes.Put(ret, sig)
+	}
+	return sig
 }
 
 // String returns the string encoding of the Signature, as described
@@ -44,6 +52,39 @@ func (s Signature) String() string {
 	return s.str
 }
 
+// Equal reports whether s and other describe the same DBus type.
+//
+// Equal compares the canonical string encoding of the signatures,
+// and is the correct way to compare Signatures. Comparing Signatures
+// with == is unreliable, because the same DBus type can be
+// associated with several different Go types.
+func (s Signature) Equal(other Signature) bool {
+	return s.str == other.str
+}
+
+// Compare compares the string encodings of s and other, with the same
+// convention as [cmp.Compare].
+func (s Signature) Compare(other Signature) int {
+	return cmp.Compare(s.str, other.str)
+}
+
+// Alignment returns the DBus wire alignment of the first type
+// described by the signature, in bytes. It is always one of 1, 2, 4
+// or 8.
+//
+// Custom [Marshaler] and [Unmarshaler] implementations can use
+// Alignment with [fragments.Encoder.Pad] and
+// [fragments.Decoder.Pad] to correctly align a value of a known
+// signature.
+//
+// The zero Signature has an alignment of 1.
+func (s Signature) Alignment() int {
+	if s.str == "" {
+		return 1
+	}
+	return fragments.TypeAlignment(s.str[0])
+}
+
 // IsZero reports whether the signature is the zero value. A zero
 // Signature describes a void value.
 func (s Signature) IsZero() bool {
@@ -78,6 +119,11 @@ func mkSignature(typ reflect.Type, str string) Signature {
 }
 
 // ParseSignature parses a DBus type signature string.
+//
+// Parsed signatures are cached for the lifetime of the program, so
+// that repeated parsing of the same signature is cheap. ParseSignature
+// is intended for signatures that are known in advance, such as those
+// of the methods and signals that a program uses.
 func ParseSignature(sig string) (Signature, error) {
 	if ret, err := strToSignature.Get(sig); err == nil {
 		return ret, nil
@@ -85,12 +131,86 @@ func ParseSignature(sig string) (Signature, error) {
 		return Signature{}, err
 	}
 
-	if len(sig) > 255 {
-		err := fmt.Errorf("type signature is too long")
+	ret, err := parseSignature(sig)
+	if err != nil {
 		strToSignature.SetErr(sig, err)
 		return Signature{}, err
 	}
 
+	typeToSignature.Set(ret.typ, ret)
+	strToSignature.Set(sig, ret)
+
+	return ret, nil
+}
+
+// maxWireSignatures is the number of signatures received from peers
+// that are cached by parseWireSignature.
+const maxWireSignatures = 1024
+
+// wireSignatures caches signatures parsed by parseWireSignature.
+var wireSignatures = lru.New(lru.LRU[string, Signature](maxWireSignatures))
+
+// wireTypes maps the types of recently parsed wire signatures back to
+// their signature.
+//
+// The caches keyed by reflect.Type consult wireTypes to keep these
+// types out of their permanent storage, and use bounded caches for
+// them instead.
+var wireTypes = lru.New(lru.LRU[reflect.Type, Signature](maxWireSignatures))
+
+// isWireType reports whether t, or the type it points to, was
+// recently constructed from a signature received from a peer.
+func isWireType(t reflect.Type) bool {
+	return t != nil && wireTypes.Has(derefType(t))
+}
+
+// markWireType adds the type of sig to wireTypes.
+func markWireType(sig Signature) {
+	if sig.typ != nil && !wireTypes.Has(sig.typ) {
+		wireTypes.Put(sig.typ, sig)
+	}
+}
+
+// parseWireSignature is like [ParseSignature], for signatures that
+// were received from a peer.
+//
+// Peers can send arbitrarily many distinct signatures, so unlike
+// ParseSignature, parseWireSignature does not add new signatures or
+// their types to any permanent cache. Instead, it keeps a bounded
+// cache of recently used signatures, and marks their types in
+// wireTypes so that the encoder, decoder and type signature caches
+// also keep them in bounded storage.
+//
+// Note that the reflect package retains the types it constructs for
+// the lifetime of the program, so this only bounds the memory
+// retained by this package's caches.
+func parseWireSignature(sig string) (Signature, error) {
+	if ret, err := strToSignature.Get(sig); err == nil {
+		return ret, nil
+	}
+	if ret, ok := wireSignatures.Get(sig); ok {
+		markWireType(ret)
+		return ret, nil
+	}
+	if err := ValidateSignature(sig); err != nil {
+		return Signature{}, err
+	}
+	ret, err := parseSignature(sig)
+	if err != nil {
+		return Signature{}, err
+	}
+	wireSignatures.Put(sig, ret)
+	markWireType(ret)
+	return ret, nil
+}
+
+// parseSignature parses sig without consulting or updating any
+// caches.
+func parseSignature(sig string) (Signature, error) {
+	if len(sig) > 255 {
+		return Signature{}, fmt.Errorf("type signature is too long")
+	}
+
 	var (
 		rest  = sig
 		parts []reflect.Type
@@ -100,19 +220,16 @@ func ParseSignature(sig string) (Signature, error) {
 	for rest != "" {
 		part, rest, err = parseOne(rest, false)
 		if err != nil {
-			err := fmt.Errorf("invalid type signature %q: %w", sig, err)
-			strToSignature.SetErr(sig, err)
-			return Signature{}, err
+			return Signature{}, fmt.Errorf("invalid type signature %q: %w", sig, err)
 		}
 		parts = append(parts, part)
 	}
 
-	var ret Signature
 	switch len(parts) {
 	case 0:
-		ret = mkSignature(nil, "")
+		return mkSignature(nil, ""), nil
 	case 1:
-		ret = mkSignature(parts[0], sig)
+		return mkSignature(parts[0], sig), nil
 	default:
 		fs := make([]reflect.StructField, len(parts)+1)
 		fs[0] = reflect.StructField{
@@ -128,15 +245,8 @@ func ParseSignature(sig string) (Signature, error) {
 			}
 		}
 		st := reflect.StructOf(fs)
-		ret = mkSignature(st, sig)
-		// Also add the adjusted struct signature to cache.
-		strToSignature.Set(ret.str, ret)
+		return mkSignature(st, sig), nil
 	}
-
-	typeToSignature.Set(ret.typ, ret)
-	strToSignature.Set(sig, ret)
-
-	return ret, nil
 }
 
 func mustParseSignature(sig string) Signature {
@@ -151,14 +261,62 @@ func mustParseSignature(sig string) Signature {
 // and returns the corresponding reflect.Type as well as the remainder
 // of the type string.
 func parseOne(sig string, inArray bool) (t reflect.Type, rest string, err error) {
+	return parseNested(sig, inArray, 0, 0)
+}
+
+// checkNesting returns an error if the type signature sig exceeds the
+// container nesting limits of the DBus specification.
+func checkNesting(sig string) error {
+	for rest := sig; rest != ""; {
+		n, err := fragments.NextType(rest)
+		if err != nil {
+			return err
+		}
+		rest = rest[n:]
+	}
+	return nil
+}
+
+// ValidateSignature returns an error if sig is not a valid DBus type
+// signature string.
+//
+// ValidateSignature accepts exactly the signatures that
+// [ParseSignature] accepts, but only checks the signature's grammar
+// and limits, without constructing the corresponding Go types. It is
+// cheaper than ParseSignature when only validity matters, and does
+// not cache anything about sig.
+func ValidateSignature(sig string) error {
+	if len(sig) > 255 {
+		return errors.New("type signature is too long")
+	}
+	for rest := sig; rest != ""; {
+		n, err := fragments.NextType(rest)
+		if err != nil {
+			return fmt.Errorf("invalid type signature %q: %w", sig, err)
+		}
+		rest = rest[n:]
+	}
+	return nil
+}
+
+// parseNested is parseOne for a type nested within the given number
+// of arrays and structs. It returns an error if the type's containers
+// would exceed the nesting limits of the DBus specification.
+func parseNested(sig string, inArray bool, arrays, structs int) (t reflect.Type, rest string, err error) {
+	if sig == "" {
+		return nil, "", errors.New("unexpected end of signature")
+	}
 	if ret, ok := strToType[sig[0]]; ok {
 		return ret, sig[1:], nil
 	}
 
 	switch sig[0] {
 	case 'a':
+		if arrays >= fragments.MaxArrayDepth {
+			return nil, "", fmt.Errorf("arrays nested more than %d deep", fragments.MaxArrayDepth)
+		}
 		isDict := len(sig) > 1 && sig[1] == '{'
-		elem, rest, err := parseOne(sig[1:], true)
+		elem, rest, err := parseNested(sig[1:], true, arrays+1, structs)
 		if err != nil {
 			return nil, "", err
 		}
@@ -167,6 +325,9 @@ func parseOne(sig string, inArray bool) (t reflect.Type, rest string, err error)
 		}
 		return reflect.SliceOf(elem), rest, nil
 	case '(':
+		if structs >= fragments.MaxStructDepth {
+			return nil, "", fmt.Errorf("structs nested more than %d deep", fragments.MaxStructDepth)
+		}
 		var (
 			fields []reflect.Type
 			field  reflect.Type
@@ -174,7 +335,7 @@ func parseOne(sig string, inArray bool) (t reflect.Type, rest string, err error)
 			err    error
 		)
 		for rest != "" && rest[0] != ')' {
-			field, rest, err = parseOne(rest, false)
+			field, rest, err = parseNested(rest, false, arrays, structs+1)
 			if err != nil {
 				return nil, "", err
 			}
@@ -183,6 +344,9 @@ func parseOne(sig string, inArray bool) (t reflect.Type, rest string, err error)
 		if rest == "" {
 			return nil, "", fmt.Errorf("missing closing ) in struct definition")
 		}
+		if len(fields) == 0 {
+			return nil, "", errors.New("empty struct definition")
+		}
 		fs := make([]reflect.StructField, len(fields))
 		for i, f := range fields {
 			fs[i] = reflect.StructField{
@@ -195,14 +359,17 @@ func parseOne(sig string, inArray bool) (t reflect.Type, rest string, err error)
 		if !inArray {
 			return nil, "", errors.New("dict entry type found outside array")
 		}
-		key, rest, err := parseOne(sig[1:], false)
+		if structs >= fragments.MaxStructDepth {
+			return nil, "", fmt.Errorf("structs nested more than %d deep", fragments.MaxStructDepth)
+		}
+		key, rest, err := parseNested(sig[1:], false, arrays, structs+1)
 		if err != nil {
 			return nil, "", err
 		}
 		if !mapKeyKinds.Has(key.Kind()) {
 			return nil, "", fmt.Errorf("invalid dict entry key type %s, must be a dbus basic type", key)
 		}
-		val, rest, err := parseOne(rest, false)
+		val, rest, err := parseNested(rest, false, arrays, structs+1)
 		if err != nil {
 			return nil, "", err
 		}
@@ -215,6 +382,66 @@ func parseOne(sig string, inArray bool) (t reflect.Type, rest string, err error)
 	}
 }
 
+// checkMapKeyKind returns an error if the map type t has a key type
+// that obviously cannot be a DBus dict key.
+func checkMapKeyKind(t reflect.Type) error {
+	k := t.Key()
+	if k == reflect.TypeFor[any]() {
+		return typeErr(t, "map keys cannot be any")
+	}
+	switch k.Kind() {
+	case reflect.Slice:
+		return typeErr(t, "map keys cannot be slices")
+	case reflect.Array:
+		return typeErr(t, "map keys cannot be arrays")
+	case reflect.Struct:
+		return typeErr(t, "map keys cannot be structs")
+	}
+	if !mapKeyKinds.Has(k.Kind()) {
+		return typeErr(t, "map key type %s is not a DBus basic type", k)
+	}
+	return nil
+}
+
+// checkMapKeySignature returns an error if the map type t has a key
+// with signature ks that is not a DBus basic type.
+func checkMapKeySignature(t reflect.Type, ks Signature) error {
+	if len(ks.str) != 1 || !strings.Contains("ybnqiuxtdsogh", ks.str) {
+		return typeErr(t, "map key type %s has signature %q, must be a DBus basic type", t.Key(), ks.str)
+	}
+	return nil
+}
+
+// IsValidDBusType returns nil if values of type t can be represented
+// in the DBus wire format, or an error explaining why not.
+//
+// IsValidDBusType accepts exactly the types that [SignatureFor]
+// accepts, and is useful to validate types that are constructed
+// dynamically. Its errors describe the path from t to the offending
+// type, such as a struct field with an unsupported integer type or a
+// map with an invalid key.
+//
+// Unlike SignatureFor, IsValidDBusType does not cache its result, so
+// checking many distinct types, such as types built with
+// [reflect.StructOf], does not consume memory for the lifetime of the
+// program.
+func IsValidDBusType(t reflect.Type) error {
+	_, err := signatureGen{check: true}.get(t, nil)
+	return err
+}
+
+// checkSingleType returns an error if sig, the signature of container
+// type t's element or value, is not a single complete type. DBus
+// requires array elements and dict entry values to be single complete
+// types, which an inlined struct with more or less than one field is
+// not.
+func checkSingleType(t reflect.Type, what string, sig Signature) error {
+	if sig.str == "" || !sig.isSingleType() {
+		return typeErr(t, "%s %s has signature %q, which is not a single complete type", what, t.Elem(), sig.str)
+	}
+	return nil
+}
+
 // A signer provides its own DBus signature.
 type signer interface {
 	SignatureDBus() Signature
@@ -228,16 +455,41 @@ func SignatureFor[T any]() (Signature, error) {
 }
 
 // SignatureOf returns the Signature of the given value.
+//
+// If v is nil, SignatureOf returns the zero Signature, which
+// describes a void value such as the empty body of a message with no
+// arguments.
 func SignatureOf(v any) (Signature, error) {
+	if v == nil {
+		return Signature{}, nil
+	}
 	return signatureFor(reflect.TypeOf(v), nil)
 }
 
-func signatureFor(t reflect.Type, stack []reflect.Type) (sig Signature, err error) {
+func signatureFor(t reflect.Type, stack []reflect.Type) (Signature, error) {
+	return signatureGen{}.get(t, stack)
+}
+
+// signatureGen derives the signatures of Go types.
+type signatureGen struct {
+	// check, if set, makes the generator only check that types have
+	// a signature. Results are not added to the permanent signature
+	// cache, so that checking types constructed at runtime does not
+	// grow the cache without bound.
+	check bool
+}
+
+func (g signatureGen) get(t reflect.Type, stack []reflect.Type) (sig Signature, err error) {
 	if ret, err := typeToSignature.Get(t); err == nil {
 		return ret, nil
 	} else if !errors.Is(err, errNotFound) {
 		return Signature{}, err
 	}
+	if t != nil {
+		if ret, ok := wireTypes.Get(derefType(t)); ok {
+			return ret, nil
+		}
+	}
 
 	if slices.Contains(stack, t) {
 		return Signature{}, errors.New("recursive type signature")
@@ -247,12 +499,20 @@ func signatureFor(t reflect.Type, stack []reflect.Type) (sig Signature, err erro
 	// Note, defer captures the type value before we mess with it
 	// below.
 	defer func(t reflect.Type) {
+		if err == nil {
+			if len(sig.str) > 255 {
+				sig = Signature{}
+				err = typeErr(t, "type signature is too long")
+			} else if nestErr := checkNesting(sig.str); nestErr != nil {
+				sig = Signature{}
+				err = typeErr(t, "%w", nestErr)
+			}
+		}
+		if g.check {
+			return
+		}
 		if err != nil {
 			typeToSignature.SetErr(t, err)
-		} else if len(sig.str) > 255 {
-			sig = Signature{}
-			err = typeErr(t, "type signature is too long")
-			typeToSignature.SetErr(t, err)
 		} else {
 			typeToSignature.Set(t, sig)
 		}
@@ -289,30 +549,31 @@ func signatureFor(t reflect.Type, stack []reflect.Type) (sig Signature, err erro
 
 	switch t.Kind() {
 	case reflect.Slice, reflect.Array:
-		es, err := signatureFor(t.Elem(), stack)
+		es, err := g.get(t.Elem(), stack)
 		if err != nil {
+			return Signature{}, fmt.Errorf("%s element: %w", t, err)
+		}
+		if err := checkSingleType(t, "element", es); err != nil {
 			return Signature{}, err
 		}
 		return mkSignature(reflect.SliceOf(es.typ), "a"+es.str), nil
 	case reflect.Map:
 		k := t.Key()
-		if k == reflect.TypeFor[any]() {
-			return Signature{}, typeErr(t, "map keys cannot be any")
-		}
-		switch k.Kind() {
-		case reflect.Slice:
-			return Signature{}, typeErr(t, "map keys cannot be slices")
-		case reflect.Array:
-			return Signature{}, typeErr(t, "map keys cannot be arrays")
-		case reflect.Struct:
-			return Signature{}, typeErr(t, "map keys cannot be structs")
-		}
-		ks, err := signatureFor(k, stack)
+		if err := checkMapKeyKind(t); err != nil {
+			return Signature{}, err
+		}
+		ks, err := g.get(k, stack)
 		if err != nil {
+			return Signature{}, fmt.Errorf("%s key: %w", t, err)
+		}
+		if err := checkMapKeySignature(t, ks); err != nil {
 			return Signature{}, err
 		}
-		vs, err := signatureFor(t.Elem(), stack)
+		vs, err := g.get(t.Elem(), stack)
 		if err != nil {
+			return Signature{}, fmt.Errorf("%s value: %w", t, err)
+		}
+		if err := checkSingleType(t, "value", vs); err != nil {
 			return Signature{}, err
 		}
 
@@ -326,18 +587,22 @@ func signatureFor(t reflect.Type, stack []reflect.Type) (sig Signature, err erro
 		for _, f := range fs.StructFields {
 			// Descend through all fields, to look for cyclic
 			// references.
-			fieldSig, err := signatureFor(f.Type, stack)
+			fieldSig, err := g.get(f.Type, stack)
 			if err != nil {
-				return Signature{}, err
+				return Signature{}, fmt.Errorf("%s field %s: %w", t, f.Name, err)
 			}
 			s = append(s, fieldSig.str)
 		}
 		if fs.NoPad {
 			return mkSignature(t, strings.Join(s, "")), nil
+		} else if len(s) == 0 {
+			// DBus has no empty struct type. As a message body,
+			// an empty struct is void, see bodySignatureFor.
+			return Signature{}, typeErr(t, "empty struct has no DBus equivalent, use nil for a message with no values")
 		} else {
 			return mkSignature(t, "("+strings.Join(s, "")+")"), nil
 		}
 	}
 
-	return Signature{}, typeErr(t, "no mapping available")
+	return Signature{}, typeErr(t, "%s values have no DBus equivalent", t.Kind())
 }
@@ -1,9 +1,16 @@
 package dbus
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/danderson/dbus/fragments"
 )
 
 func TestSignatureOf(t *testing.T) {
@@ -39,13 +46,20 @@ func TestSignatureOf(t *testing.T) {
 		{struct{ A any }{int16(0)}, "(v)"},
 		{VarDict{}, "(a{sv})"},
 		{VarDictByte{}, "(a{yv})"},
-		{struct{}{}, "()"},
+		{Inline{}, "qy"},
+		{NestedInline{}, "(yqy)"},
+		{[]InlineSingle{}, "aq"},
+		{map[string]InlineSingle{}, "a{sq}"},
 
-		{},
+		{struct{}{}, ""},
+		{[]struct{}{}, ""},
 		{Tree{}, ""},
 		{map[Simple]bool{}, ""},
 		{map[[2]int64]bool{}, ""},
 		{map[any]bool{}, ""},
+		{map[*Simple]bool{}, ""},
+		{[]Inline{}, ""},
+		{map[string]Inline{}, ""},
 		{func() int { return 2 }, ""},
 	}
 
@@ -66,6 +80,105 @@ func TestSignatureOf(t *testing.T) {
 			t.Logf("SignatureOf(%T).String() = %q, err=%v", tc.in, got, err)
 		}
 	}
+
+	// nil is the void value, with the zero signature.
+	if sig, err := SignatureOf(nil); err != nil {
+		t.Errorf("SignatureOf(nil) got err %v, want nil", err)
+	} else if !sig.IsZero() {
+		t.Errorf("SignatureOf(nil) = %q, want zero Signature", sig)
+	}
+}
+
+func TestIsValidDBusType(t *testing.T) {
+	type badField struct {
+		A string
+		B []int8
+	}
+	type badNested struct {
+		X map[string]badField
+	}
+	tests := []struct {
+		in      reflect.Type
+		wantErr string
+	}{
+		{reflect.TypeFor[uint32](), ""},
+		{reflect.TypeFor[*os.File](), ""},
+		{reflect.TypeFor[Arrays](), ""},
+		{reflect.TypeFor[VarDict](), ""},
+		{reflect.TypeFor[map[ObjectPath]map[string]any](), ""},
+		{reflect.TypeFor[[]*SelfMarshalerPtr](), ""},
+
+		{nil, "nil interface"},
+		{reflect.TypeFor[int8](), "int8 values have no DBus equivalent"},
+		{reflect.TypeFor[int](), "int values have no DBus equivalent"},
+		{reflect.TypeFor[chan int](), "chan values have no DBus equivalent"},
+		{reflect.TypeFor[Tree](), "recursive type"},
+		{reflect.TypeFor[map[Simple]bool](), "map keys cannot be structs"},
+		{reflect.TypeFor[map[*Simple]bool](), "is not a DBus basic type"},
+		{reflect.TypeFor[badNested](), "field X: map[string]dbus.badField value: dbus.badField field B: []int8 element"},
+		{reflect.TypeFor[[]Inline](), "not a single complete type"},
+		{reflect.TypeFor[map[string]Inline](), "not a single complete type"},
+		{reflect.TypeFor[map[Signature]string](), "map keys cannot be structs"},
+		{reflect.TypeFor[map[*os.File]string](), "is not a DBus basic type"},
+		{reflect.TypeFor[struct{}](), "empty struct has no DBus equivalent"},
+		{reflect.TypeFor[[]struct{}](), "empty struct has no DBus equivalent"},
+		{deepSlice(33), "nested more than 32 deep"},
+		{reflect.StructOf(longFields(256)), "type signature is too long"},
+	}
+
+	for _, tc := range tests {
+		err := IsValidDBusType(tc.in)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("IsValidDBusType(%s) = %v, want nil", tc.in, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("IsValidDBusType(%s) = %v, want error containing %q", tc.in, err, tc.wantErr)
+		} else if testing.Verbose() {
+			t.Logf("IsValidDBusType(%s) = %v", tc.in, err)
+		}
+
+		// IsValidDBusType must agree with the full signature
+		// derivation.
+		_, sigErr := signatureFor(tc.in, nil)
+		if (err == nil) != (sigErr == nil) {
+			t.Errorf("IsValidDBusType(%s) = %v, but SignatureFor error is %v", tc.in, err, sigErr)
+		}
+	}
+
+	// Checking types does not add them to the signature cache.
+	for _, typ := range []reflect.Type{
+		reflect.StructOf([]reflect.StructField{{Name: "Good", Type: reflect.TypeFor[[3]uint16]()}}),
+		reflect.StructOf([]reflect.StructField{{Name: "Bad", Type: reflect.TypeFor[[3]int8]()}}),
+	} {
+		IsValidDBusType(typ)
+		for _, tt := range []reflect.Type{typ, typ.Field(0).Type} {
+			if _, err := typeToSignature.Get(tt); !errors.Is(err, errNotFound) {
+				t.Errorf("IsValidDBusType(%s) cached %s, err %v", typ, tt, err)
+			}
+		}
+	}
+}
+
+// deepSlice returns the type of a slice of uint32 nested depth times.
+func deepSlice(depth int) reflect.Type {
+	ret := reflect.TypeFor[uint32]()
+	for range depth {
+		ret = reflect.SliceOf(ret)
+	}
+	return ret
+}
+
+// longFields returns n uint32 struct fields.
+func longFields(n int) []reflect.StructField {
+	var ret []reflect.StructField
+	for i := range n {
+		ret = append(ret, reflect.StructField{
+			Name: fmt.Sprintf("F%d", i),
+			Type: reflect.TypeFor[uint32](),
+		})
+	}
+	return ret
 }
 
 func TestParseSignature(t *testing.T) {
@@ -145,6 +258,8 @@ func TestParseSignature(t *testing.T) {
 			}
 		}](), false},
 		{"v", reflect.TypeFor[any](), false},
+		{"()", nil, true},
+		{"a()", nil, true},
 	}
 
 	for _, tc := range tests {
@@ -162,6 +277,254 @@ func TestParseSignature(t *testing.T) {
 			if gotStr := got.String(); gotStr != tc.in {
 				t.Errorf("ParseSignature(%q).String() = %q, want %q", tc.in, gotStr, tc.in)
 			}
+
+			if err := ValidateSignature(tc.in); (err != nil) != (gotErr != nil) {
+				t.Errorf("ValidateSignature(%q) got err %v, but ParseSignature got err %v", tc.in, err, gotErr)
+			}
 		})
 	}
 }
+
+func TestParseSignatureLimits(t *testing.T) {
+	nest := func(open, inner, close string, n int) string {
+		return strings.Repeat(open, n) + inner + strings.Repeat(close, n)
+	}
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"max arrays", nest("a", "y", "", 32), false},
+		{"too many arrays", nest("a", "y", "", 33), true},
+		{"max structs", nest("(", "y", ")", 32), false},
+		{"too many structs", nest("(", "y", ")", 33), true},
+		{"max dicts", nest("a{s", "y", "}", 32), false},
+		{"too many dicts", nest("a{s", "y", "}", 33), true},
+		{"dict in max structs", nest("(", "a{sy}", ")", 31), false},
+		{"dict in too many structs", nest("(", "a{sy}", ")", 32), true},
+		{"max arrays and structs", nest("a(", "y", ")", 32), false},
+		{"max length", strings.Repeat("y", 255), false},
+		{"too long", strings.Repeat("y", 256), true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseSignature(tc.in)
+			if tc.wantErr && err == nil {
+				t.Fatalf("ParseSignature(%q) succeeded, want error", tc.in)
+			} else if !tc.wantErr && err != nil {
+				t.Fatalf("ParseSignature(%q) failed: %v", tc.in, err)
+			}
+			if err := ValidateSignature(tc.in); (err != nil) != tc.wantErr {
+				t.Fatalf("ValidateSignature(%q) got err %v, want error %v", tc.in, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzParseSignature(f *testing.F) {
+	f.Add("(nb)")
+	f.Add("a{sv}")
+	f.Add("aa(sa{oas})")
+	f.Add("sv")
+	f.Add("a{")
+	f.Fuzz(func(t *testing.T, s string) {
+		sig, err := ParseSignature(s)
+		if verr := ValidateSignature(s); (verr != nil) != (err != nil) {
+			t.Fatalf("ValidateSignature(%q) got err %v, but ParseSignature got err %v", s, verr, err)
+		}
+		if err != nil {
+			return
+		}
+		// Valid signatures must survive a round trip through their
+		// string form.
+		if got := sig.String(); got != s {
+			t.Fatalf("ParseSignature(%q).String() = %q", s, got)
+		}
+		again, err := ParseSignature(sig.String())
+		if err != nil {
+			t.Fatalf("ParseSignature(%q) failed on reparse: %v", sig, err)
+		}
+		if !again.Equal(sig) {
+			t.Fatalf("ParseSignature(%q) is not equal to itself after reparse", s)
+		}
+	})
+}
+
+func TestParseWireSignature(t *testing.T) {
+	wireSignatures.Clear()
+	defer wireSignatures.Clear()
+
+	// A signature that nothing else in the tests uses.
+	sig := "a(" + strings.Repeat("q", 200) + ")"
+	got, err := parseWireSignature(sig)
+	if err != nil {
+		t.Fatalf("parseWireSignature(%q) failed: %v", sig, err)
+	}
+	if got.String() != sig {
+		t.Errorf("parseWireSignature(%q) = %q", sig, got)
+	}
+	if _, err := strToSignature.Get(sig); !errors.Is(err, errNotFound) {
+		t.Errorf("parseWireSignature(%q) added signature to permanent cache", sig)
+	}
+
+	if _, err := parseWireSignature("a{"); err == nil {
+		t.Error("parseWireSignature of invalid signature succeeded")
+	}
+	if _, err := strToSignature.Get("a{"); !errors.Is(err, errNotFound) {
+		t.Error("parseWireSignature of invalid signature added error to permanent cache")
+	}
+
+	for i := range maxWireSignatures * 2 {
+		sig := strings.Repeat("y", i%200+1) + strings.Repeat("q", i/200+1)
+		if _, err := parseWireSignature(sig); err != nil {
+			t.Fatalf("parseWireSignature(%q) failed: %v", sig, err)
+		}
+	}
+	if got := wireSignatures.Len(); got > maxWireSignatures {
+		t.Errorf("wire signature cache has %d entries, want at most %d", got, maxWireSignatures)
+	}
+	if got := wireTypes.Len(); got > maxWireSignatures {
+		t.Errorf("wire type cache has %d entries, want at most %d", got, maxWireSignatures)
+	}
+}
+
+func TestWireTypeCaches(t *testing.T) {
+	ctx := context.Background()
+
+	// Signatures that nothing else in the tests uses.
+	var (
+		single = "(" + strings.Repeat("n", 30) + ")"
+		multi  = strings.Repeat("n", 31)
+		array  = "a(" + strings.Repeat("q", 30) + ")"
+	)
+	parse := func(s string) reflect.Type {
+		t.Helper()
+		sig, err := parseWireSignature(s)
+		if err != nil {
+			t.Fatalf("parseWireSignature(%q) failed: %v", s, err)
+		}
+		return sig.Type()
+	}
+
+	// Received variants hold structs behind a pointer, and can be
+	// forwarded to other peers.
+	singleType := parse(single)
+	var v any = reflect.New(singleType).Interface()
+	enc := fragments.Encoder{Order: fragments.NativeEndian, Mapper: encoderFor}
+	if err := enc.Value(ctx, &v); err != nil {
+		t.Fatalf("encoding variant failed: %v", err)
+	}
+	dec := fragments.Decoder{Order: fragments.NativeEndian, Mapper: decoderFor, In: bytes.NewReader(enc.Out)}
+	var got any
+	if err := dec.Value(ctx, &got); err != nil {
+		t.Fatalf("decoding variant failed: %v", err)
+	}
+	if sig, err := SignatureOf(got); err != nil || sig.String() != single {
+		t.Errorf("SignatureOf(received variant value) = %q, %v, want %q", sig, err, single)
+	}
+
+	// Message bodies have multi-value signatures, and the bodies of
+	// unregistered signals are decoded into a struct wrapping the
+	// message's signature.
+	multiType := parse(multi)
+	if sig, err := SignatureOf(reflect.New(multiType).Interface()); err != nil || sig.String() != multi {
+		t.Errorf("SignatureOf(received body) = %q, %v, want %q", sig, err, multi)
+	}
+	arraySig, err := parseWireSignature(array)
+	if err != nil {
+		t.Fatalf("parseWireSignature(%q) failed: %v", array, err)
+	}
+	arrayType := arraySig.asStruct().Type()
+	for _, typ := range []reflect.Type{multiType, arrayType} {
+		if _, err := decoderFor(typ); err != nil {
+			t.Fatalf("decoderFor(%s) failed: %v", typ, err)
+		}
+		if _, err := bodyEncoderFor(typ); err != nil {
+			t.Fatalf("bodyEncoderFor(%s) failed: %v", typ, err)
+		}
+	}
+
+	for _, typ := range []reflect.Type{singleType, multiType, arrayType} {
+		for _, typ := range []reflect.Type{typ, reflect.PointerTo(typ)} {
+			if _, err := decoders.Get(typ); !errors.Is(err, errNotFound) {
+				t.Errorf("decoder for wire type %s added to permanent cache", typ)
+			}
+			if _, err := encoders.Get(typ); !errors.Is(err, errNotFound) {
+				t.Errorf("encoder for wire type %s added to permanent cache", typ)
+			}
+			if _, err := bodyEncoders.Get(typ); !errors.Is(err, errNotFound) {
+				t.Errorf("body encoder for wire type %s added to permanent cache", typ)
+			}
+			if _, err := typeToSignature.Get(typ); !errors.Is(err, errNotFound) {
+				t.Errorf("signature of wire type %s added to permanent cache", typ)
+			}
+		}
+	}
+}
+
+func TestSignatureEqual(t *testing.T) {
+	tests := []struct {
+		a, b Signature
+		want bool
+	}{
+		{Signature{}, Signature{}, true},
+		{mustSignatureFor[uint16](), mustParseSignature("q"), true},
+		{mustSignatureFor[Simple](), mustParseSignature("(nb)"), true},
+		{mustSignatureFor[[]Simple](), mustParseSignature("a(nb)"), true},
+		{mustSignatureFor[map[string]any](), mustParseSignature("a{sv}"), true},
+		{mustSignatureFor[Inline](), mustParseSignature("qy"), true},
+
+		{Signature{}, mustParseSignature("q"), false},
+		{mustSignatureFor[uint16](), mustParseSignature("n"), false},
+		{mustSignatureFor[Simple](), mustParseSignature("nb"), false},
+		{mustSignatureFor[[]Simple](), mustParseSignature("(nb)"), false},
+	}
+
+	for _, tc := range tests {
+		if got := tc.a.Equal(tc.b); got != tc.want {
+			t.Errorf("Signature(%q).Equal(%q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+		if got := tc.b.Equal(tc.a); got != tc.want {
+			t.Errorf("Signature(%q).Equal(%q) = %v, want %v", tc.b, tc.a, got, tc.want)
+		}
+		if got := tc.a.Compare(tc.b) == 0; got != tc.want {
+			t.Errorf("Signature(%q).Compare(%q) = %d, want equal=%v", tc.a, tc.b, tc.a.Compare(tc.b), tc.want)
+		}
+	}
+}
+
+func TestSignatureAlignment(t *testing.T) {
+	tests := []struct {
+		in   Signature
+		want int
+	}{
+		{Signature{}, 1},
+		{mustParseSignature("y"), 1},
+		{mustParseSignature("g"), 1},
+		{mustParseSignature("v"), 1},
+		{mustParseSignature("n"), 2},
+		{mustParseSignature("q"), 2},
+		{mustParseSignature("b"), 4},
+		{mustParseSignature("i"), 4},
+		{mustParseSignature("u"), 4},
+		{mustParseSignature("h"), 4},
+		{mustParseSignature("s"), 4},
+		{mustParseSignature("o"), 4},
+		{mustParseSignature("ay"), 4},
+		{mustParseSignature("a(yy)"), 4},
+		{mustParseSignature("a{sv}"), 4},
+		{mustParseSignature("x"), 8},
+		{mustParseSignature("t"), 8},
+		{mustParseSignature("d"), 8},
+		{mustParseSignature("(y)"), 8},
+		{mustSignatureFor[Simple](), 8},
+		{mustSignatureFor[Inline](), 2},
+		{mustSignatureFor[SelfMarshalerPtr](), 2},
+	}
+
+	for _, tc := range tests {
+		if got := tc.in.Alignment(); got != tc.want {
+			t.Errorf("Signature(%q).Alignment() = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package dbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/danderson/dbus/fragments"
+)
+
+// ArrayFunc is an [Unmarshaler] that consumes a DBus array one
+// element at a time, rather than buffering the entire array in
+// memory.
+//
+// When a DBus array is unmarshaled into an ArrayFunc, the function is
+// called once for each element of the array, in order. If the
+// function returns an error, unmarshaling stops and returns that
+// error.
+//
+// ArrayFunc has the same [Signature] as a []T. To use it, set a
+// function value before unmarshaling, for example in the response
+// struct of a method call:
+//
+//	var resp struct {
+//		Items dbus.ArrayFunc[Item]
+//	}
+//	resp.Items = func(idx int, item Item) error {
+//		process(item)
+//		return nil
+//	}
+//	err := iface.Call(ctx, "ListItems", nil, &resp)
+//
+// ArrayFunc can only be unmarshaled, it cannot be marshaled.
+type ArrayFunc[T any] func(idx int, elem T) error
+
+// SignatureDBus implements [Unmarshaler].
+func (f ArrayFunc[T]) SignatureDBus() Signature {
+	sig, err := SignatureFor[[]T]()
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+// UnmarshalDBus implements [Unmarshaler].
+func (f *ArrayFunc[T]) UnmarshalDBus(ctx context.Context, d *fragments.Decoder) error {
+	if *f == nil {
+		return errors.New("cannot unmarshal into nil ArrayFunc")
+	}
+	isStruct := alignAsStruct(reflect.TypeFor[T]())
+	_, err := d.ArrayStream(isStruct, func(idx int, d *fragments.Decoder) error {
+		var elem T
+		if err := d.Value(ctx, &elem); err != nil {
+			return err
+		}
+		return (*f)(idx, elem)
+	})
+	return err
+}
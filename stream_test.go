@@ -0,0 +1,85 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danderson/dbus/fragments"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestArrayFunc(t *testing.T) {
+	want := []Simple{{1, true}, {2, false}, {3, true}}
+	in := struct {
+		A byte
+		B []Simple
+		C string
+	}{42, want, "foo"}
+
+	enc := fragments.Encoder{
+		Order:  fragments.BigEndian,
+		Mapper: encoderFor,
+	}
+	if err := enc.Value(context.Background(), in); err != nil {
+		t.Fatalf("encoding input: %v", err)
+	}
+	decode := func(v any) error {
+		dec := fragments.Decoder{
+			Order:  fragments.BigEndian,
+			Mapper: decoderFor,
+			In:     bytes.NewBuffer(enc.Out),
+		}
+		return dec.Value(context.Background(), v)
+	}
+
+	var got []Simple
+	var out struct {
+		A byte
+		B ArrayFunc[Simple]
+		C string
+	}
+	out.B = func(idx int, elem Simple) error {
+		if idx != len(got) {
+			t.Errorf("ArrayFunc called with index %d, want %d", idx, len(got))
+		}
+		got = append(got, elem)
+		return nil
+	}
+	if err := decode(&out); err != nil {
+		t.Fatalf("decoding into ArrayFunc: %v", err)
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("wrong ArrayFunc elements (-got+want):\n%s", diff)
+	}
+	if out.A != 42 || out.C != "foo" {
+		t.Errorf("wrong fields around ArrayFunc, got A=%d C=%q, want A=42 C=%q", out.A, out.C, "foo")
+	}
+
+	wantErr := errors.New("stop")
+	calls := 0
+	out.B = func(int, Simple) error {
+		calls++
+		return wantErr
+	}
+	if err := decode(&out); !errors.Is(err, wantErr) {
+		t.Errorf("decoding with failing ArrayFunc got err %v, want %v", err, wantErr)
+	}
+	if calls != 1 {
+		t.Errorf("failing ArrayFunc called %d times, want 1", calls)
+	}
+
+	out.B = nil
+	if err := decode(&out); err == nil {
+		t.Error("decoding into nil ArrayFunc succeeded, want error")
+	}
+
+	sig, err := SignatureOf(out)
+	if err != nil {
+		t.Fatalf("SignatureOf(ArrayFunc struct) got err: %v", err)
+	}
+	if got, want := sig.String(), "(ya(nb)s)"; got != want {
+		t.Errorf("SignatureOf(ArrayFunc struct) = %q, want %q", got, want)
+	}
+}
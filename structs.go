@@ -13,6 +13,33 @@ import (
 // of type InlineLayout will be laid out in DBus messages without the
 // initial 8-byte alignment that DBus structs normally enforce.
 //
+// An inlined struct is not a DBus struct: its signature is the
+// concatenation of its fields' signatures, without the surrounding
+// parentheses, and each field is aligned only as its own type
+// requires. When an inlined struct is a field of another struct, its
+// fields are encoded as if they were fields of the outer struct. For
+// example, the following Outer struct has signature (yyst), not
+// (y(ys)t):
+//
+//	type Tuple struct {
+//		_ dbus.InlineLayout
+//		A uint8
+//		B string
+//	}
+//
+//	type Outer struct {
+//		X uint8
+//		T Tuple
+//		Y uint64
+//	}
+//
+// This is useful to describe a group of fields that some protocol
+// uses as consecutive values, such as several top-level arguments of
+// a method call, without nesting them in a DBus struct. Unless it has
+// exactly one field, an inlined struct's signature is not a single
+// complete type, and so the struct cannot be used as an array
+// element, map value or variant value.
+//
 // By convention, InlineLayout should be used as the type of a field
 // named "_", placed at the beginning of the struct type definition.
 type InlineLayout struct{}
@@ -108,6 +135,15 @@ func (f *structField) StringGetter() func(reflect.Value) string {
 	}
 }
 
+// ValueGetter returns a function that loads the field from a struct
+// value, following any pointers. The returned function returns an
+// invalid reflect.Value if it encounters a nil pointer.
+func (f *structField) ValueGetter() func(reflect.Value) reflect.Value {
+	return func(structVal reflect.Value) reflect.Value {
+		return derefZero(f.GetWithZero(structVal))
+	}
+}
+
 func (f *structField) String() string {
 	var ret strings.Builder
 	kindStr := ""
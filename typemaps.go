@@ -80,4 +80,18 @@ var (
 		reflect.Float64,
 		reflect.String,
 	)
+
+	// fixedNumberKinds is the set of reflect.Kinds whose in-memory
+	// representation is the same as their DBus wire encoding, up to
+	// byte order. Slices of these kinds are encoded and decoded in
+	// bulk.
+	fixedNumberKinds = mapset.New(
+		reflect.Int16,
+		reflect.Uint16,
+		reflect.Int32,
+		reflect.Uint32,
+		reflect.Int64,
+		reflect.Uint64,
+		reflect.Float64,
+	)
 )
@@ -8,7 +8,11 @@ import (
 	"os"
 	"reflect"
 	"slices"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
 
+	lru "github.com/creachadair/mds/cache"
 	"github.com/danderson/dbus/fragments"
 )
 
@@ -45,15 +49,32 @@ var unmarshalerOnlyType = reflect.TypeFor[unmarshalerOnly]()
 
 var decoders cache[reflect.Type, fragments.DecoderFunc]
 
+// wireDecoders caches the decoders of wire types, which are kept out
+// of decoders. See wireTypes.
+var wireDecoders = lru.New(lru.LRU[reflect.Type, fragments.DecoderFunc](maxWireSignatures))
+
 // decoderFor returns the decoder func for the given type, if the type
 // is representable in the DBus wire format.
 func decoderFor(t reflect.Type) (ret fragments.DecoderFunc, err error) {
-	d := decoderGen{}
-	return d.get(t)
+	if ret, err := decoders.Get(t); !errors.Is(err, errNotFound) {
+		return ret, err
+	}
+	if ret, ok := wireDecoders.Get(t); ok {
+		return ret, nil
+	}
+	d := decoderGen{wire: isWireType(t)}
+	ret, err = d.get(t)
+	if d.wire && err == nil {
+		wireDecoders.Put(t, ret)
+	}
+	return ret, err
 }
 
 type decoderGen struct {
 	stack []reflect.Type
+	// wire is whether the decoder is for a wire type, in which case
+	// the generated decoders are not added to decoders.
+	wire bool
 }
 
 func (d *decoderGen) get(t reflect.Type) (ret fragments.DecoderFunc, err error) {
@@ -72,6 +93,9 @@ func (d *decoderGen) get(t reflect.Type) (ret fragments.DecoderFunc, err error)
 	// below.
 	defer func(t reflect.Type) {
 		d.stack = d.stack[:len(d.stack)-1]
+		if d.wire {
+			return
+		}
 		if err != nil {
 			decoders.SetErr(t, err)
 		} else {
@@ -129,9 +153,9 @@ func (d *decoderGen) get(t reflect.Type) (ret fragments.DecoderFunc, err error)
 	case reflect.Int8:
 		return nil, typeErr(t, "int8 has no corresponding DBus type, use uint8 instead")
 	case reflect.Int16, reflect.Int32, reflect.Int64:
-		return d.newIntDecoder(t), nil
+		return withEnumCheck(t, d.newIntDecoder(t)), nil
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return d.newUintDecoder(t), nil
+		return withEnumCheck(t, d.newUintDecoder(t)), nil
 	case reflect.Float32, reflect.Float64:
 		return d.newFloatDecoder(), nil
 	case reflect.String:
@@ -186,7 +210,9 @@ func (d *decoderGen) newObjectPathDecoder() fragments.DecoderFunc {
 		if err != nil {
 			return err
 		}
-		s = string(ObjectPath(s).Clean())
+		if err := ObjectPath(s).Valid(); err != nil {
+			return err
+		}
 		v.SetString(s)
 		return nil
 	}
@@ -198,7 +224,7 @@ func (d *decoderGen) newSignatureDecoder() fragments.DecoderFunc {
 		if err != nil {
 			return err
 		}
-		sig, err := ParseSignature(s)
+		sig, err := parseWireSignature(s)
 		if err != nil {
 			return err
 		}
@@ -221,12 +247,20 @@ func (d *decoderGen) newAnyDecoder() fragments.DecoderFunc {
 			return fmt.Errorf("unsupported variant type signature %q", sig)
 		}
 		inner := reflect.New(innerType)
-		if err := d.Value(ctx, inner.Interface()); err != nil {
+		err := d.Variant(func() error {
+			return d.Value(ctx, inner.Interface())
+		})
+		if err != nil {
 			return fmt.Errorf("reading variant value (signature %q): %w", sig, err)
 		}
-		if innerType.Kind() == reflect.Struct {
+		switch innerType.Kind() {
+		case reflect.Struct, reflect.Interface:
+			// Structs are kept behind a pointer. So are nested
+			// variants: storing the inner any directly in v would
+			// flatten the two variants into one, whereas a *any
+			// is a variant on the encoding side too.
 			v.Set(inner)
-		} else {
+		default:
 			v.Set(inner.Elem())
 		}
 		return nil
@@ -263,6 +297,9 @@ func (d *decoderGen) newBoolDecoder() fragments.DecoderFunc {
 		if err != nil {
 			return err
 		}
+		if u > 1 && contextStrictBooleans(ctx) {
+			return fmt.Errorf("invalid boolean value %d", u)
+		}
 		v.SetBool(u != 0)
 		return nil
 	}
@@ -371,15 +408,47 @@ func (d *decoderGen) newStringDecoder() fragments.DecoderFunc {
 		if err != nil {
 			return err
 		}
+		if contextStrictStrings(ctx) {
+			if err := validString(s); err != nil {
+				return err
+			}
+		}
 		v.SetString(s)
 		return nil
 	}
 }
 
+// validString returns an error if s is not a valid DBus string.
+func validString(s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("invalid string %q: not valid UTF-8", s)
+	}
+	if strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("invalid string %q: contains NUL byte", s)
+	}
+	return nil
+}
+
 func (d *decoderGen) newSliceDecoder(t reflect.Type) (fragments.DecoderFunc, error) {
-	if t.Elem().Kind() == reflect.Uint8 {
+	// Get the element decoder even if a bulk decoding path below
+	// doesn't need it, so that TryRegisterEnum knows the element
+	// type is in use.
+	elemDec, err := d.get(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Elem().Kind() == reflect.Uint8 && !isEnum(t.Elem()) {
 		fn := func(ctx context.Context, d *fragments.Decoder, v reflect.Value) error {
-			bs, err := d.Bytes()
+			var (
+				bs  []byte
+				err error
+			)
+			if contextReuseBytes(ctx) {
+				bs, err = d.AppendBytes(v.Bytes()[:0])
+			} else {
+				bs, err = d.Bytes()
+			}
 			if err != nil {
 				return err
 			}
@@ -389,10 +458,10 @@ func (d *decoderGen) newSliceDecoder(t reflect.Type) (fragments.DecoderFunc, err
 		return fn, nil
 	}
 
-	elemDec, err := d.get(t.Elem())
-	if err != nil {
-		return nil, err
+	if t.Kind() == reflect.Slice && decodesAsFixedNumber(t.Elem()) {
+		return d.newFixedSliceDecoder(t), nil
 	}
+
 	isStruct := alignAsStruct(t.Elem())
 
 	fn := func(ctx context.Context, d *fragments.Decoder, v reflect.Value) error {
@@ -415,6 +484,34 @@ func (d *decoderGen) newSliceDecoder(t reflect.Type) (fragments.DecoderFunc, err
 	return fn, nil
 }
 
+// decodesAsFixedNumber reports whether t can be decoded in bulk, as
+// part of a slice of fixed-width numbers.
+func decodesAsFixedNumber(t reflect.Type) bool {
+	// Types with custom decoding or validation need the per-element
+	// path.
+	return fixedNumberKinds.Has(t.Kind()) && !reflect.PointerTo(t).Implements(unmarshalerType) && !isEnum(t)
+}
+
+// newFixedSliceDecoder returns a decoder for a slice of fixed-width
+// numbers, which reads the entire array directly into the slice's
+// memory.
+func (d *decoderGen) newFixedSliceDecoder(t reflect.Type) fragments.DecoderFunc {
+	size := int(t.Elem().Size())
+	return func(ctx context.Context, d *fragments.Decoder, v reflect.Value) error {
+		return d.FixedArray(size, func(n int) []byte {
+			if v.Cap() < n {
+				v.Set(reflect.MakeSlice(t, n, n))
+			} else {
+				v.SetLen(n)
+			}
+			if n == 0 {
+				return nil
+			}
+			return unsafe.Slice((*byte)(v.UnsafePointer()), n*size)
+		})
+	}
+}
+
 func (d *decoderGen) newStructDecoder(t reflect.Type) (fragments.DecoderFunc, error) {
 	fs, err := getStructInfo(t)
 	if err != nil {
@@ -525,11 +622,14 @@ func (d *decoderGen) newVarDictFieldDecoder(f *structField) (fragments.DecoderFu
 				// *any(underlying) -> underlying
 				inner := val.Elem().Elem()
 				// the any decoder unmarshals structs as pointers, so
-				// need one more indirection.
-				if inner.Type().Kind() == reflect.Pointer {
+				// need one more indirection. Nested variants are
+				// also pointers, to *any, but those must stay
+				// wrapped so that the field receives the inner
+				// variant rather than its contents.
+				if inner.Type().Kind() == reflect.Pointer && inner.Type().Elem().Kind() == reflect.Struct {
 					inner = inner.Elem()
 				}
-				if fv.Type() != inner.Type() {
+				if !inner.Type().AssignableTo(fv.Type()) {
 					return fmt.Errorf("invalid type %s received for vardict field %s (%s)", inner.Type(), field.Name, fv.Type())
 				}
 				fv.Set(inner)
@@ -0,0 +1,76 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/danderson/dbus/fragments"
+)
+
+// DecodeVariant converts v, a value produced by unmarshaling a DBus
+// variant into an any, into a T.
+//
+// When decoding into an any, DBus structs are unmarshaled into
+// anonymous Go structs with fields named Field0, Field1 and so
+// on. DecodeVariant copies such values into T by field order,
+// including any structs nested in arrays and maps. The signature of
+// v must match the signature of T.
+//
+// If v is already a T or a *T, DecodeVariant returns it as-is.
+func DecodeVariant[T any](v any) (T, error) {
+	var ret T
+	switch v := v.(type) {
+	case nil:
+		return ret, fmt.Errorf("cannot decode nil variant value into %s", reflect.TypeFor[T]())
+	case T:
+		return v, nil
+	case *T:
+		if v != nil {
+			return *v, nil
+		}
+	}
+
+	vsig, err := SignatureOf(v)
+	if err != nil {
+		return ret, err
+	}
+	tsig, err := SignatureFor[T]()
+	if err != nil {
+		return ret, err
+	}
+	if !vsig.Equal(tsig) {
+		return ret, fmt.Errorf("cannot decode variant value of type %q into %s (type %q)", vsig, reflect.TypeFor[T](), tsig)
+	}
+
+	if err := roundTrip(v, &ret); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+// roundTrip encodes v to the wire format, and decodes the result into
+// out, which must be a pointer.
+//
+// Round-tripping through the wire format reuses all the existing
+// mapping rules, so that both v and out can use struct tags,
+// Marshalers, Unmarshalers and so on.
+func roundTrip(v, out any) error {
+	var files []*os.File
+	ctx := withContextFiles(context.Background(), &files)
+	enc := fragments.Encoder{
+		Order:  fragments.NativeEndian,
+		Mapper: encoderFor,
+	}
+	if err := enc.Value(ctx, v); err != nil {
+		return err
+	}
+	dec := fragments.Decoder{
+		Order:  fragments.NativeEndian,
+		Mapper: decoderFor,
+		In:     bytes.NewBuffer(enc.Out),
+	}
+	return dec.Value(ctx, out)
+}
@@ -0,0 +1,104 @@
+package dbus
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/danderson/dbus/fragments"
+	"github.com/google/go-cmp/cmp"
+)
+
+// variantRoundTrip returns v as the any decoder would produce it
+// when reading v from a variant.
+func variantRoundTrip(t *testing.T, v any) any {
+	t.Helper()
+	enc := fragments.Encoder{
+		Order:  fragments.BigEndian,
+		Mapper: encoderFor,
+	}
+	if err := enc.Value(context.Background(), &v); err != nil {
+		t.Fatalf("encoding %T: %v", v, err)
+	}
+	dec := fragments.Decoder{
+		Order:  fragments.BigEndian,
+		Mapper: decoderFor,
+		In:     bytes.NewBuffer(enc.Out),
+	}
+	var ret any
+	if err := dec.Value(context.Background(), &ret); err != nil {
+		t.Fatalf("decoding %T: %v", v, err)
+	}
+	return ret
+}
+
+func TestDecodeVariant(t *testing.T) {
+	t.Run("simple", func(t *testing.T) {
+		want := Simple{A: 42, B: true}
+		v := variantRoundTrip(t, want)
+		got, err := DecodeVariant[Simple](v)
+		if err != nil {
+			t.Fatalf("DecodeVariant(%#v) got err: %v", v, err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Fatalf("DecodeVariant(%#v) wrong result (-got+want):\n%s", v, diff)
+		}
+	})
+
+	t.Run("nested arrays", func(t *testing.T) {
+		want := Arrays{
+			A: []string{"foo", "bar"},
+			B: []Simple{{1, true}, {2, false}},
+			C: [][]Nested{{{A: 1, B: Simple{3, true}}}},
+		}
+		v := variantRoundTrip(t, want)
+		got, err := DecodeVariant[Arrays](v)
+		if err != nil {
+			t.Fatalf("DecodeVariant(%#v) got err: %v", v, err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Fatalf("DecodeVariant(%#v) wrong result (-got+want):\n%s", v, diff)
+		}
+	})
+
+	t.Run("map of structs", func(t *testing.T) {
+		want := map[string]Simple{"a": {1, true}, "b": {2, false}}
+		v := variantRoundTrip(t, want)
+		got, err := DecodeVariant[map[string]Simple](v)
+		if err != nil {
+			t.Fatalf("DecodeVariant(%#v) got err: %v", v, err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Fatalf("DecodeVariant(%#v) wrong result (-got+want):\n%s", v, diff)
+		}
+	})
+
+	t.Run("same type", func(t *testing.T) {
+		got, err := DecodeVariant[uint32](uint32(42))
+		if err != nil {
+			t.Fatalf("DecodeVariant(42) got err: %v", err)
+		}
+		if got != 42 {
+			t.Fatalf("DecodeVariant(42) = %d, want 42", got)
+		}
+
+		want := Simple{A: 1}
+		got2, err := DecodeVariant[Simple](&want)
+		if err != nil {
+			t.Fatalf("DecodeVariant(&Simple) got err: %v", err)
+		}
+		if got2 != want {
+			t.Fatalf("DecodeVariant(&Simple) = %#v, want %#v", got2, want)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		v := variantRoundTrip(t, Simple{A: 42, B: true})
+		if got, err := DecodeVariant[Nested](v); err == nil {
+			t.Fatalf("DecodeVariant[Nested](%#v) = %#v, want error", v, got)
+		}
+		if got, err := DecodeVariant[Simple](nil); err == nil {
+			t.Fatalf("DecodeVariant[Simple](nil) = %#v, want error", got)
+		}
+	})
+}
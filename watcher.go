@@ -3,6 +3,7 @@ package dbus
 import (
 	"context"
 	"errors"
+	"fmt"
 	"maps"
 	"net"
 	"reflect"
@@ -12,7 +13,42 @@ import (
 	"github.com/creachadair/mds/queue"
 )
 
-const maxWatcherQueue = 20
+// defaultWatcherQueue is the number of notifications a Watcher
+// buffers, if WatchOptions.BufferSize is zero.
+const defaultWatcherQueue = 20
+
+// A WatchPolicy determines what a Watcher does when it receives a
+// notification and its buffer is full.
+type WatchPolicy int
+
+const (
+	// WatchDropNewest discards incoming notifications until the
+	// caller has made room in the buffer. This is the default
+	// policy.
+	WatchDropNewest WatchPolicy = iota
+	// WatchDropOldest discards the oldest buffered notification to
+	// make room for the incoming one.
+	WatchDropOldest
+	// WatchUnbounded never discards notifications, and ignores
+	// BufferSize. The Watcher queues every notification until the
+	// caller receives it, without blocking the Conn.
+	//
+	// The queue has no size limit: if the caller falls behind, for
+	// example during a flood of signals, the Watcher's memory use
+	// grows without bound. Only use WatchUnbounded when the caller
+	// is certain to keep up in the long run.
+	WatchUnbounded
+)
+
+// WatchOptions configures a Watcher.
+type WatchOptions struct {
+	// BufferSize is the maximum number of notifications that the
+	// Watcher queues for delivery, except with the [WatchUnbounded]
+	// policy. If zero, a small default buffer size is used.
+	BufferSize int
+	// Policy is the behavior of the Watcher when its buffer is full.
+	Policy WatchPolicy
+}
 
 // A Watcher delivers notifications received from the bus that match
 // its filters.
@@ -24,6 +60,9 @@ type Watcher struct {
 	notifications chan *Notification
 	pumpStopped   chan struct{}
 
+	bufSize int
+	policy  WatchPolicy
+
 	mu      sync.Mutex
 	closed  bool
 	queue   queue.Queue[*Notification]
@@ -44,14 +83,28 @@ type Notification struct {
 	// a pointer to an anonymous struct if no type was registered for
 	// the signal.
 	//
-	// For property changes, Body is a pointer to the struct type that
-	// was associated with the property using
-	// RegisterPropertyChangeType, or a pointer to an anonymous struct
-	// if no type was registered for the property.
+	// For property changes, Body is a pointer to the type that was
+	// associated with the property using RegisterPropertyChangeType.
+	// Changes to unregistered properties are only reported as part
+	// of the [PropertiesChanged] signal.
 	Body any
-	// Overflow reports that the watcher discarded some notifications
-	// that followed this one, due to the caller not processing
-	// delivered notifications fast enough.
+	// Signature is the DBus signature of the notification's payload,
+	// as it was received on the wire.
+	//
+	// For signals, Signature is the signal message's body signature,
+	// which describes the fields of Body. It lets generic listeners
+	// describe signals that have no registered type, whose Body
+	// struct has only placeholder field names. For property changes,
+	// Signature is the signature of the property's value.
+	Signature Signature
+	// Overflow reports that the watcher discarded some
+	// notifications, due to the caller not processing delivered
+	// notifications fast enough.
+	//
+	// With the [WatchDropNewest] policy, the discarded notifications
+	// immediately followed this one. With the [WatchDropOldest]
+	// policy, the discarded notifications immediately preceded this
+	// one.
 	Overflow bool
 }
 
@@ -61,15 +114,38 @@ type Notification struct {
 // A newly created Watcher delivers no notifications. The caller must
 // use [Watcher.Match] to specify which signals and property changes
 // the Watcher should provide.
+//
+// The returned Watcher buffers a small number of notifications, and
+// discards incoming notifications if the buffer is full. Use
+// [Conn.WatchWithOptions] to configure different behavior.
 func (c *Conn) Watch() (*Watcher, error) {
+	return c.WatchWithOptions(WatchOptions{})
+}
+
+// WatchWithOptions is like [Conn.Watch], but configures the
+// Watcher's buffering according to opts.
+func (c *Conn) WatchWithOptions(opts WatchOptions) (*Watcher, error) {
+	if opts.BufferSize < 0 {
+		return nil, fmt.Errorf("invalid watcher buffer size %d", opts.BufferSize)
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = defaultWatcherQueue
+	}
+	switch opts.Policy {
+	case WatchDropNewest, WatchDropOldest, WatchUnbounded:
+	default:
+		return nil, fmt.Errorf("unknown watch policy %d", opts.Policy)
+	}
+
 	w := &Watcher{
 		conn:          c,
 		notifications: make(chan *Notification),
 		wakePump:      make(chan struct{}, 1),
 		pumpStopped:   make(chan struct{}),
+		bufSize:       opts.BufferSize,
+		policy:        opts.Policy,
 		matches:       mapset.New[*Match](),
 	}
-
 	if err := c.addWatcher(w); err != nil {
 		return nil, err
 	}
@@ -77,6 +153,53 @@ func (c *Conn) Watch() (*Watcher, error) {
 	return w, nil
 }
 
+// Signals watches the bus for notifications of type T, and delivers
+// their decoded bodies on the returned channel.
+//
+// T must be registered with [RegisterSignalType] or
+// [RegisterPropertyChangeType] prior to calling Signals. Like
+// [MatchNotification], Signals panics if T is not registered.
+//
+// The returned cancel function stops the watch and closes the
+// channel. The caller must drain the channel promptly, notifications
+// are discarded if the caller falls behind. Use [Conn.Watch] directly
+// for finer control over delivery.
+func Signals[T any](c *Conn) (signals <-chan T, cancel func(), err error) {
+	m := MatchNotification[T]()
+
+	w, err := c.Watch()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := w.Match(m); err != nil {
+		w.Close()
+		return nil, nil, err
+	}
+
+	ret := make(chan T)
+	stop := make(chan struct{})
+	go func() {
+		defer close(ret)
+		for n := range w.Chan() {
+			v, ok := n.Body.(*T)
+			if !ok {
+				continue
+			}
+			select {
+			case ret <- *v:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	cancel = sync.OnceFunc(func() {
+		close(stop)
+		w.Close()
+	})
+	return ret, cancel, nil
+}
+
 func (c *Conn) addWatcher(w *Watcher) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -109,23 +232,28 @@ func (w *Watcher) Close() {
 	}
 }
 
-func (w *Watcher) addMatch(m *Match) error {
+// addMatch adds m to the Watcher's matches. It reports whether m was
+// newly added.
+func (w *Watcher) addMatch(m *Match) (bool, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if w.closed {
-		return net.ErrClosed
+		return false, net.ErrClosed
+	}
+	if w.matches.Has(m) {
+		return false, nil
 	}
 	w.matches.Add(m)
-	return nil
+	return true, nil
 }
 
 func (w *Watcher) removeMatch(m *Match) bool {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	if w.closed {
+	if w.closed || !w.matches.Has(m) {
 		return false
 	}
-	delete(w.matches, m)
+	w.matches.Remove(m)
 	return true
 }
 
@@ -146,10 +274,11 @@ func (w *Watcher) clearMatches() (mapset.Set[*Match], bool) {
 // Chan returns the channel on which notifications are delivered.
 //
 // The caller must drain this channel of new notifications promptly,
-// to avoid overflowing the Watcher's receive queue and losing
-// notifications. Missing notifications due to an overflow are
-// indicated by the Overflow field of the [Notification] that
-// immediately precedes the discarded signal(s).
+// to avoid overflowing the Watcher's receive queue. What happens on
+// overflow depends on the Watcher's [WatchPolicy]. Missing
+// notifications due to an overflow are indicated by the Overflow
+// field of a [Notification] adjacent to the discarded
+// notification(s).
 func (w *Watcher) Chan() <-chan *Notification {
 	return w.notifications
 }
@@ -158,35 +287,64 @@ func (w *Watcher) Chan() <-chan *Notification {
 // specification m.
 //
 // Matches are additive: a notification is delivered if it matches any
-// of the Watcher's match specifications.
+// of the Watcher's match specifications. Adding the same *Match to a
+// Watcher more than once has no effect.
 //
 // If the match is added successfully, the returned remove function
-// may be used to remove thee match without affecting other
-// matches. Use of remove is optional, and may be ignored if the set
+// may be used to remove the match without affecting other
+// matches. Calling remove is equivalent to calling [Watcher.Unmatch]
+// with m. Use of remove is optional, and may be ignored if the set
 // of matches doesn't need to change for the lifetime of the Watcher.
 func (w *Watcher) Match(m *Match) (remove func() error, err error) {
 	if err = w.conn.addMatch(context.Background(), m); err != nil {
 		return nil, err
 	}
 
-	if err = w.addMatch(m); err != nil {
+	added, err := w.addMatch(m)
+	if err != nil || !added {
 		rmErr := w.conn.removeMatch(context.Background(), m)
-		return nil, errors.Join(err, rmErr)
+		if err = errors.Join(err, rmErr); err != nil {
+			return nil, err
+		}
 	}
 
-	return func() error {
-		if !w.removeMatch(m) {
-			return nil
-		}
-		return w.conn.removeMatch(context.Background(), m)
-	}, nil
+	return func() error { return w.Unmatch(m) }, nil
+}
+
+// Unmatch stops delivery of notifications that match the
+// specification m, which must have been previously added with
+// [Watcher.Match]. Notifications that are already queued for delivery
+// are not affected.
+//
+// The match rule is removed from the bus once no Watcher on the
+// [Conn] is using it. Unmatch does nothing if m is not one of the
+// Watcher's matches.
+func (w *Watcher) Unmatch(m *Match) error {
+	if !w.removeMatch(m) {
+		return nil
+	}
+	return w.conn.removeMatch(context.Background(), m)
 }
 
 func (w *Watcher) enqueueLocked(n Notification) {
-	if w.queue.Len() >= maxWatcherQueue {
-		last, _ := w.queue.Peek(-1)
-		last.Overflow = true
-		return
+	if w.queue.Len() >= w.bufSize {
+		switch w.policy {
+		case WatchDropNewest:
+			last, _ := w.queue.Peek(-1)
+			last.Overflow = true
+			return
+		case WatchDropOldest:
+			w.queue.Pop()
+			if first, ok := w.queue.Peek(0); ok {
+				first.Overflow = true
+			} else {
+				n.Overflow = true
+			}
+		case WatchUnbounded:
+			// Queue beyond the buffer size. Only the pump waits
+			// for the caller to catch up, never the Conn's read
+			// loop.
+		}
 	}
 
 	w.queue.Add(&n)
@@ -218,13 +376,14 @@ func (w *Watcher) deliverSignal(sender Interface, hdr *header, body reflect.Valu
 	}
 
 	w.enqueueLocked(Notification{
-		Sender: sender,
-		Name:   hdr.Member,
-		Body:   body.Interface(),
+		Sender:    sender,
+		Name:      hdr.Member,
+		Body:      body.Interface(),
+		Signature: hdr.Signature,
 	})
 }
 
-func (w *Watcher) deliverProp(sender Interface, hdr *header, prop interfaceMember, value reflect.Value) {
+func (w *Watcher) deliverProp(sender Interface, hdr *header, prop interfaceMember, sig Signature, value reflect.Value) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if w.closed {
@@ -244,9 +403,10 @@ func (w *Watcher) deliverProp(sender Interface, hdr *header, prop interfaceMembe
 	}
 
 	w.enqueueLocked(Notification{
-		Sender: sender,
-		Name:   prop.Member,
-		Body:   value.Interface(),
+		Sender:    sender,
+		Name:      prop.Member,
+		Body:      value.Interface(),
+		Signature: sig,
 	})
 }
 
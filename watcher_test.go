@@ -0,0 +1,313 @@
+package dbus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danderson/dbus/fragments"
+	"github.com/danderson/dbus/internal/transport"
+)
+
+func newTestWatcher(policy WatchPolicy, bufSize int) *Watcher {
+	w := &Watcher{
+		wakePump: make(chan struct{}, 1),
+		bufSize:  bufSize,
+		policy:   policy,
+	}
+	return w
+}
+
+func (w *Watcher) enqueueTest(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.enqueueLocked(Notification{Name: name})
+}
+
+type queued struct {
+	Name     string
+	Overflow bool
+}
+
+func (w *Watcher) drainTest() []queued {
+	var ret []queued
+	for {
+		n := w.popNotification()
+		if n == nil {
+			return ret
+		}
+		ret = append(ret, queued{n.Name, n.Overflow})
+	}
+}
+
+func TestWatcherPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  WatchPolicy
+		bufSize int
+		want    []queued
+	}{
+		{
+			"drop newest",
+			WatchDropNewest,
+			3,
+			[]queued{{"a", false}, {"b", false}, {"c", true}},
+		},
+		{
+			"drop oldest",
+			WatchDropOldest,
+			3,
+			[]queued{{"c", true}, {"d", false}, {"e", false}},
+		},
+		{
+			"drop oldest single",
+			WatchDropOldest,
+			1,
+			[]queued{{"e", true}},
+		},
+		{
+			"large buffer",
+			WatchDropNewest,
+			10,
+			[]queued{{"a", false}, {"b", false}, {"c", false}, {"d", false}, {"e", false}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newTestWatcher(tc.policy, tc.bufSize)
+			for _, n := range []string{"a", "b", "c", "d", "e"} {
+				w.enqueueTest(n)
+			}
+			got := w.drainTest()
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("wrong queued notifications, got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWatcherPolicyUnbounded(t *testing.T) {
+	w := newTestWatcher(WatchUnbounded, 2)
+	for _, n := range []string{"a", "b", "c", "d"} {
+		w.enqueueTest(n)
+	}
+	got := w.drainTest()
+	want := []queued{{"a", false}, {"b", false}, {"c", false}, {"d", false}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("wrong queued notifications, got %v want %v", got, want)
+	}
+}
+
+// stalledTransport is a transport whose peer accepts every message
+// but never replies.
+type stalledTransport struct {
+	wrote     chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (t *stalledTransport) Read(bs []byte) (int, error) {
+	<-t.closed
+	return 0, net.ErrClosed
+}
+
+func (t *stalledTransport) Write(bs []byte) (int, error) {
+	select {
+	case t.wrote <- struct{}{}:
+	default:
+	}
+	return len(bs), nil
+}
+
+func (t *stalledTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return nil
+}
+
+func (t *stalledTransport) GetFiles(n int) ([]*os.File, error) { return nil, nil }
+
+func (t *stalledTransport) PeerCredentials() (*transport.Credentials, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (t *stalledTransport) WriteWithFiles(bs []byte, fs []*os.File) (int, error) {
+	return t.Write(bs)
+}
+
+func TestWatcherMatchDoesNotBlockOthers(t *testing.T) {
+	tr := &stalledTransport{
+		wrote:  make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	c := &Conn{
+		t: tr,
+		enc: fragments.Encoder{
+			Order:  fragments.NativeEndian,
+			Mapper: encoderFor,
+		},
+		calls:    map[uint32]*pendingCall{},
+		handlers: map[interfaceMember]handlerFunc{},
+	}
+	c.closeOnce = sync.OnceValue(c.close)
+	c.bus = c.Peer("org.freedesktop.DBus").Object("/org/freedesktop/DBus")
+	go c.readLoop()
+	defer func() {
+		// Nothing will ever answer the outstanding AddMatch, fail
+		// it so that Close doesn't wait on the bus.
+		c.mu.Lock()
+		c.closed = true
+		for _, call := range c.calls {
+			call.err = net.ErrClosed
+			close(call.notify)
+		}
+		c.calls = nil
+		c.mu.Unlock()
+		c.Close()
+	}()
+
+	match := func(m *Match) <-chan error {
+		ret := make(chan error, 1)
+		go func() {
+			w, err := c.Watch()
+			if err != nil {
+				ret <- err
+				return
+			}
+			_, err = w.Match(m)
+			ret <- err
+		}()
+		return ret
+	}
+	waitWrite := func() {
+		t.Helper()
+		select {
+		case <-tr.wrote:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for AddMatch to be sent")
+		}
+	}
+
+	rule := MatchNotification[NameOwnerChanged]()
+	first := match(rule)
+	waitWrite()
+
+	// A second user of the same rule waits for the bus to accept
+	// the rule, rather than assuming that it will.
+	second := match(MatchNotification[NameOwnerChanged]())
+	for {
+		c.matchMu.Lock()
+		refs := c.matchRefs[rule.filterString()].refs
+		c.matchMu.Unlock()
+		if refs == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// While the bus sits on the AddMatch, other rules can still be
+	// added.
+	other := match(MatchNotification[NameOwnerChanged]().ArgStr(0, "org.test.Other"))
+	waitWrite()
+	select {
+	case err := <-second:
+		t.Fatalf("Match() of rule with AddMatch in flight returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The bus rejects the rules, which fails every user.
+	errRejected := errors.New("rejected")
+	c.mu.Lock()
+	for serial, call := range c.calls {
+		call.err = errRejected
+		close(call.notify)
+		delete(c.calls, serial)
+	}
+	c.mu.Unlock()
+	for _, ch := range []<-chan error{first, second, other} {
+		select {
+		case err := <-ch:
+			if !errors.Is(err, errRejected) {
+				t.Errorf("Match() got err %v, want %v", err, errRejected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Match() did not return after AddMatch failed")
+		}
+	}
+	c.matchMu.Lock()
+	defer c.matchMu.Unlock()
+	if len(c.matchRefs) != 0 {
+		t.Errorf("failed match rules are still referenced: %v", c.matchRefs)
+	}
+}
+
+func TestWatcherCloseRemovesMatches(t *testing.T) {
+	client, bus := newPipeConns(t)
+
+	var (
+		mu      sync.Mutex
+		added   []string
+		removed []string
+	)
+	bus.Handle(ifaceBus, "AddMatch", func(_ context.Context, _ ObjectPath, rule string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		added = append(added, rule)
+		return nil
+	})
+	bus.Handle(ifaceBus, "RemoveMatch", func(_ context.Context, _ ObjectPath, rule string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, rule)
+		return nil
+	})
+	check := func(what string, wantAdded, wantRemoved []string) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		slices.Sort(added)
+		slices.Sort(removed)
+		if !slices.Equal(added, wantAdded) {
+			t.Errorf("%s: AddMatch rules = %q, want %q", what, added, wantAdded)
+		}
+		if !slices.Equal(removed, wantRemoved) {
+			t.Errorf("%s: RemoveMatch rules = %q, want %q", what, removed, wantRemoved)
+		}
+	}
+
+	const (
+		shared = "type='signal',interface='org.test.Shared'"
+		only1  = "type='signal',interface='org.test.One'"
+	)
+	w1, err := client.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	w2, err := client.Watch()
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	for _, m := range []struct {
+		w *Watcher
+		m *Match
+	}{
+		{w1, MatchAllSignals().Interface("org.test.Shared")},
+		{w1, MatchAllSignals().Interface("org.test.One")},
+		{w2, MatchAllSignals().Interface("org.test.Shared")},
+	} {
+		if _, err := m.w.Match(m.m); err != nil {
+			t.Fatalf("Match(%s) failed: %v", m.m.filterString(), err)
+		}
+	}
+	check("after Match", []string{only1, shared}, nil)
+
+	w1.Close()
+	check("after first Close", []string{only1, shared}, []string{only1})
+
+	w2.Close()
+	check("after second Close", []string{only1, shared}, []string{only1, shared})
+}